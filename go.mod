@@ -5,10 +5,13 @@ go 1.17
 require (
 	github.com/anchore/go-struct-converter v0.0.0-20221118182256-c68fdcfa2092 // indirect
 	github.com/google/licensecheck v0.3.1 // indirect
+	github.com/klauspost/compress v1.15.15 // indirect
 	github.com/panjf2000/ants v1.3.0 // indirect
+	github.com/spdx/gordf v0.0.0-20201111095634-7098f93598fb // indirect
 	github.com/spdx/tools-golang v0.5.5 // indirect
 	github.com/tjfoc/gmsm v1.4.1 // indirect
 	github.com/ulikunitz/xz v0.5.12 // indirect
 	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee // indirect
 	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
+	sigs.k8s.io/yaml v1.4.0 // indirect
 )
@@ -0,0 +1,360 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cyclonedx
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CycloneDX 1.5 规范
+const (
+	BOMFormat   = "CycloneDX"
+	SpecVersion = "1.5"
+	xmlnsPrefix = "http://cyclonedx.org/schema/bom/"
+	xmlns       = xmlnsPrefix + SpecVersion
+)
+
+// 组件类型
+const (
+	ComponentTypeApplication = "application"
+	ComponentTypeLibrary     = "library"
+	ComponentTypeFile        = "file"
+)
+
+// 组件范围
+const (
+	ScopeRequired = "required"
+	ScopeOptional = "optional"
+)
+
+// 外部引用类型
+const (
+	ExternalRefWebsite      = "website"
+	ExternalRefDistribution = "distribution"
+)
+
+// PropertyPrefix 本工具自定义属性名称的前缀, 如 deepin-sbom-tools:hash:SM3
+const PropertyPrefix = "deepin-sbom-tools:"
+
+// BOM CycloneDX 文档, 同一结构同时用于 JSON 与 XML 编解码
+type BOM struct {
+	XMLName      xml.Name     `json:"-" xml:"bom"`
+	XMLNS        string       `json:"-" xml:"xmlns,attr"`
+	BOMFormat    string       `json:"bomFormat" xml:"-"`
+	SpecVersion  string       `json:"specVersion" xml:"-"`
+	SerialNumber string       `json:"serialNumber,omitempty" xml:"serialNumber,attr,omitempty"`
+	Version      int          `json:"version" xml:"version,attr"`
+	Metadata     *Metadata    `json:"metadata,omitempty" xml:"metadata,omitempty"`
+	Components   Components   `json:"components,omitempty" xml:"components,omitempty"`
+	Dependencies Dependencies `json:"dependencies,omitempty" xml:"dependencies,omitempty"`
+}
+
+type Metadata struct {
+	Timestamp  string     `json:"timestamp,omitempty" xml:"timestamp,omitempty"`
+	Tools      *Tools     `json:"tools,omitempty" xml:"tools,omitempty"`
+	Component  *Component `json:"component,omitempty" xml:"component,omitempty"`
+	Properties Properties `json:"properties,omitempty" xml:"properties,omitempty"`
+}
+
+type Tools struct {
+	Components Components `json:"components,omitempty" xml:"components,omitempty"`
+}
+
+// Component 组件, XML 中子元素的顺序与 1.5 schema 一致
+type Component struct {
+	BOMRef             string                `json:"bom-ref,omitempty" xml:"bom-ref,attr,omitempty"`
+	Type               string                `json:"type" xml:"type,attr"`
+	Supplier           *OrganizationalEntity `json:"supplier,omitempty" xml:"supplier,omitempty"`
+	Name               string                `json:"name" xml:"name"`
+	Version            string                `json:"version,omitempty" xml:"version,omitempty"`
+	Description        string                `json:"description,omitempty" xml:"description,omitempty"`
+	Scope              string                `json:"scope,omitempty" xml:"scope,omitempty"`
+	Hashes             Hashes                `json:"hashes,omitempty" xml:"hashes,omitempty"`
+	Licenses           Licenses              `json:"licenses,omitempty" xml:"licenses,omitempty"`
+	Copyright          string                `json:"copyright,omitempty" xml:"copyright,omitempty"`
+	CPE                string                `json:"cpe,omitempty" xml:"cpe,omitempty"`
+	PackageURL         string                `json:"purl,omitempty" xml:"purl,omitempty"`
+	ExternalReferences ExternalReferences    `json:"externalReferences,omitempty" xml:"externalReferences,omitempty"`
+	Properties         Properties            `json:"properties,omitempty" xml:"properties,omitempty"`
+	Components         Components            `json:"components,omitempty" xml:"components,omitempty"` //内嵌的组件
+}
+
+type OrganizationalEntity struct {
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+}
+
+type Hash struct {
+	Alg     string `json:"alg" xml:"alg,attr"`
+	Content string `json:"content" xml:",chardata"`
+}
+
+type ExternalReference struct {
+	Type string `json:"type" xml:"type,attr"`
+	URL  string `json:"url" xml:"url"`
+}
+
+type Property struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Value string `json:"value" xml:",chardata"`
+}
+
+// XML 中的列表带有一层包装元素, 如 <hashes><hash/></hashes>。
+// 列表类型自行编解码包装元素, 空列表由 omitempty 整体省略
+type (
+	Components         []Component
+	Dependencies       []Dependency
+	Hashes             []Hash
+	ExternalReferences []ExternalReference
+	Properties         []Property
+)
+
+func marshalXMLList(e *xml.Encoder, start xml.StartElement, item string, items interface{}) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(items, xml.StartElement{Name: xml.Name{Local: item}}); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (l Components) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalXMLList(e, start, "component", []Component(l))
+}
+
+func (l *Components) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []Component `xml:"component"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	*l = append(*l, aux.Items...)
+	return err
+}
+
+func (l Dependencies) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalXMLList(e, start, "dependency", []Dependency(l))
+}
+
+func (l *Dependencies) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []Dependency `xml:"dependency"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	*l = append(*l, aux.Items...)
+	return err
+}
+
+func (l Hashes) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalXMLList(e, start, "hash", []Hash(l))
+}
+
+func (l *Hashes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []Hash `xml:"hash"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	*l = append(*l, aux.Items...)
+	return err
+}
+
+func (l ExternalReferences) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalXMLList(e, start, "reference", []ExternalReference(l))
+}
+
+func (l *ExternalReferences) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []ExternalReference `xml:"reference"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	*l = append(*l, aux.Items...)
+	return err
+}
+
+func (l Properties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalXMLList(e, start, "property", []Property(l))
+}
+
+func (l *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []Property `xml:"property"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	*l = append(*l, aux.Items...)
+	return err
+}
+
+type License struct {
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+}
+
+// LicenseChoice 单个许可证或许可证表达式, 两者只能有一个
+type LicenseChoice struct {
+	License    *License `json:"license,omitempty"`
+	Expression string   `json:"expression,omitempty"`
+}
+
+type Licenses []LicenseChoice
+
+// MarshalXML XML 中许可证与表达式直接作为 licenses 的子元素
+func (l Licenses) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, choice := range l {
+		var err error
+		if choice.License != nil {
+			err = e.EncodeElement(choice.License, xml.StartElement{Name: xml.Name{Local: "license"}})
+		} else {
+			err = e.EncodeElement(choice.Expression, xml.StartElement{Name: xml.Name{Local: "expression"}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (l *Licenses) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Items []struct {
+			XMLName xml.Name
+			License
+			Value string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	for _, item := range aux.Items {
+		switch item.XMLName.Local {
+		case "license":
+			license := item.License
+			*l = append(*l, LicenseChoice{License: &license})
+		case "expression":
+			*l = append(*l, LicenseChoice{Expression: item.Value})
+		}
+	}
+	return nil
+}
+
+// Dependency 组件依赖关系, XML 中 dependsOn 表示为嵌套的 dependency 元素
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+type dependencyRef struct {
+	Ref string `xml:"ref,attr"`
+}
+
+func (d Dependency) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	aux := struct {
+		Ref       string          `xml:"ref,attr"`
+		DependsOn []dependencyRef `xml:"dependency"`
+	}{Ref: d.Ref}
+	for _, ref := range d.DependsOn {
+		aux.DependsOn = append(aux.DependsOn, dependencyRef{Ref: ref})
+	}
+	return e.EncodeElement(aux, start)
+}
+
+func (d *Dependency) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Ref       string          `xml:"ref,attr"`
+		DependsOn []dependencyRef `xml:"dependency"`
+	}
+	if err := dec.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	d.Ref = aux.Ref
+	for _, ref := range aux.DependsOn {
+		d.DependsOn = append(d.DependsOn, ref.Ref)
+	}
+	return nil
+}
+
+// NewBOM 创建带随机序列号的空文档
+func NewBOM() *BOM {
+	return &BOM{
+		XMLNS:        xmlns,
+		BOMFormat:    BOMFormat,
+		SpecVersion:  SpecVersion,
+		SerialNumber: newSerialNumber(),
+		Version:      1,
+	}
+}
+
+// newSerialNumber 生成 urn:uuid 形式的随机 UUID(v4)
+func newSerialNumber() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return ""
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// WriteJSON 以 JSON 格式输出文档
+func WriteJSON(bom *BOM, w io.Writer) error {
+	out := *bom
+	out.BOMFormat = BOMFormat
+	out.SpecVersion = SpecVersion
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(&out)
+}
+
+// WriteXML 以 XML 格式输出文档
+func WriteXML(bom *BOM, w io.Writer) error {
+	// 读取的文档中 XMLName 带有命名空间, 清除后统一输出 1.5 命名空间
+	out := *bom
+	out.XMLName = xml.Name{}
+	out.XMLNS = xmlns
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(&out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadJSON 读取 JSON 格式文档
+func ReadJSON(r io.Reader) (*BOM, error) {
+	bom := &BOM{}
+	if err := json.NewDecoder(r).Decode(bom); err != nil {
+		return nil, err
+	}
+	if bom.BOMFormat != BOMFormat {
+		return nil, errors.New("not a CycloneDX document")
+	}
+	return bom, nil
+}
+
+// ReadXML 读取 XML 格式文档
+func ReadXML(r io.Reader) (*BOM, error) {
+	bom := &BOM{}
+	if err := xml.NewDecoder(r).Decode(bom); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(bom.XMLName.Space, xmlnsPrefix) {
+		return nil, errors.New("not a CycloneDX document")
+	}
+	bom.BOMFormat = BOMFormat
+	bom.SpecVersion = strings.TrimPrefix(bom.XMLName.Space, xmlnsPrefix)
+	return bom, nil
+}
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cyclonedx
+
+import (
+	"crypto/sha1"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"deepin-sbom-tools/pkg/version"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 自定义属性名称
+const (
+	PropertySection           = PropertyPrefix + "section"
+	PropertyInstalledSize     = PropertyPrefix + "installed-size"
+	PropertyVersionConstraint = PropertyPrefix + "version-constraint"
+	PropertyRelationship      = PropertyPrefix + "relationship"
+	PropertySourceInfo        = PropertyPrefix + "source-info"
+	PropertyFileName          = PropertyPrefix + "file-name"
+	PropertyCopyrightInFile   = PropertyPrefix + "copyright-in-file"
+)
+
+// 作为组件输出的软件包关系及其范围, Breaks、Conflicts、Provides 不是依赖, 不输出
+var relationScopes = map[string]string{
+	plugin.RelationPreDepends: ScopeRequired,
+	plugin.RelationDepends:    ScopeRequired,
+	plugin.RelationRecommends: ScopeOptional,
+	plugin.RelationSuggests:   ScopeOptional,
+	plugin.RelationBuiltUsing: ScopeRequired,
+
+	plugin.RelationBuildDepends:      ScopeRequired,
+	plugin.RelationBuildDependsArch:  ScopeRequired,
+	plugin.RelationBuildDependsIndep: ScopeRequired,
+
+	plugin.RelationBase:    ScopeRequired,
+	plugin.RelationContent: ScopeRequired,
+	plugin.RelationRuntime: ScopeRequired,
+}
+
+// genBOMRef 与 SPDX 文档中的元素 ID 使用相同规则, 便于两种格式互相对照
+func genBOMRef(prefix string, s string) string {
+	hSHA1 := sha1.New()
+	hSHA1.Write([]byte(s))
+	return fmt.Sprintf("%s-%x", prefix, hSHA1.Sum(nil))
+}
+
+// ToolComponent 本工具的组件描述
+func ToolComponent() Component {
+	return Component{
+		Type:    ComponentTypeApplication,
+		Name:    "deepin-sbom-tools",
+		Version: version.VERSION,
+	}
+}
+
+// propertiesOf 转换格式特有的属性, 名称加上本工具的前缀
+func propertiesOf(props []plugin.Property) Properties {
+	var res Properties
+	for _, p := range props {
+		res = append(res, Property{Name: PropertyPrefix + p.Name, Value: p.Value})
+	}
+	return res
+}
+
+// nestedComponent 生成内嵌软件包对应的组件, 与 SPDX 文档中的软件包 ID 使用相同规则
+func nestedComponent(p plugin.PkgInfo, purlNS string) Component {
+	c := Component{
+		BOMRef:      genBOMRef("PACKAGE", p.Type+" "+p.Name+" "+p.Version+" "+p.Architecture+" "+p.FileName),
+		Type:        ComponentTypeLibrary,
+		Name:        p.Name,
+		Version:     p.Version,
+		Description: p.Description,
+		Licenses:    LicensesFromExpression(p.LicenseDeclared),
+		Copyright:   p.Copyright,
+	}
+	if p.Maintainer != "" && p.Maintainer != "NOASSERTION" {
+		c.Supplier = &OrganizationalEntity{Name: p.Maintainer}
+	}
+	if p.Type != "" {
+		c.PackageURL = tool.PackageURL(p.Type, purlNS, p.Name, p.Version, p.Architecture)
+		c.CPE = tool.CPE(p.Name, p.Version)
+	}
+	if p.Homepage != "" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: ExternalRefWebsite, URL: p.Homepage})
+	}
+	if p.DownloadLocation != "" && p.DownloadLocation != "NOASSERTION" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: ExternalRefDistribution, URL: p.DownloadLocation})
+	}
+	c.Hashes, c.Properties = HashesFromChecksums(p.Hash)
+	if p.FileName != "" {
+		c.Properties = append(c.Properties, Property{Name: PropertyFileName, Value: p.FileName})
+	}
+	if info := p.SourceInfo(); info != "" {
+		c.Properties = append(c.Properties, Property{Name: PropertySourceInfo, Value: info})
+	}
+	c.Properties = append(c.Properties, propertiesOf(p.Properties)...)
+	return c
+}
+
+// CreateBOM 根据软件包信息生成 CycloneDX 文档:
+// 软件包本身为 metadata.component, 内嵌的软件包为其子组件, 依赖与包内文件为 components;
+// purlNS 为 purl 中的发行版命名空间
+func CreateBOM(pkg plugin.PkgInfo, purlNS string) *BOM {
+	bom := NewBOM()
+
+	top := Component{
+		BOMRef:      genBOMRef("PACKAGE", pkg.Name),
+		Type:        ComponentTypeApplication,
+		Name:        pkg.Name,
+		Version:     pkg.Version,
+		Description: pkg.Description,
+		Licenses:    LicensesFromExpression(pkg.LicenseDeclared),
+		Copyright:   pkg.Copyright,
+	}
+	if pkg.Maintainer != "" && pkg.Maintainer != "NOASSERTION" {
+		top.Supplier = &OrganizationalEntity{Name: pkg.Maintainer}
+	}
+	if pkg.Type != "" {
+		top.PackageURL = tool.PackageURL(pkg.Type, purlNS, pkg.Name, pkg.Version, pkg.Architecture)
+		top.CPE = tool.CPE(pkg.Name, pkg.Version)
+	}
+	if pkg.Homepage != "" {
+		top.ExternalReferences = append(top.ExternalReferences, ExternalReference{Type: ExternalRefWebsite, URL: pkg.Homepage})
+	}
+	if pkg.DownloadLocation != "" && pkg.DownloadLocation != "NOASSERTION" {
+		top.ExternalReferences = append(top.ExternalReferences, ExternalReference{Type: ExternalRefDistribution, URL: pkg.DownloadLocation})
+	}
+	if pkg.Section != "" {
+		top.Properties = append(top.Properties, Property{Name: PropertySection, Value: pkg.Section})
+	}
+	if pkg.InstalledSize > 0 {
+		top.Properties = append(top.Properties, Property{Name: PropertyInstalledSize, Value: strconv.Itoa(pkg.InstalledSize)})
+	}
+	if info := pkg.SourceInfo(); info != "" {
+		top.Properties = append(top.Properties, Property{Name: PropertySourceInfo, Value: info})
+	}
+	top.Properties = append(top.Properties, propertiesOf(pkg.Properties)...)
+	for _, p := range pkg.Packages {
+		top.Components = append(top.Components, nestedComponent(p, purlNS))
+	}
+
+	bom.Metadata = &Metadata{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Tools:     &Tools{Components: []Component{ToolComponent()}},
+		Component: &top,
+	}
+
+	topDep := Dependency{Ref: top.BOMRef}
+	var depends []Dependency
+	index := make(map[string]int)
+	for _, rel := range pkg.Relations {
+		scope, ok := relationScopes[rel.Type]
+		if !ok {
+			continue
+		}
+		for i, dep := range rel.Alternatives {
+			// a | b 中第一个之后的候选在满足第一个候选时不需要安装, 范围为 optional
+			scope := scope
+			if i > 0 {
+				scope = ScopeOptional
+			}
+			ref := genBOMRef("DEPEND", dep.String())
+			relProp := Property{Name: PropertyRelationship, Value: rel.Type + ": " + rel.String()}
+			if i, ok := index[ref]; ok {
+				bom.Components[i].Properties = append(bom.Components[i].Properties, relProp)
+				// 在其他关系中为必需依赖时保持 required
+				if scope == ScopeRequired {
+					bom.Components[i].Scope = scope
+				}
+				continue
+			}
+			index[ref] = len(bom.Components)
+
+			c := Component{
+				BOMRef: ref,
+				Type:   ComponentTypeLibrary,
+				Name:   dep.Name,
+				Scope:  scope,
+			}
+			// 只有精确版本约束才能确定依赖的版本
+			if dep.Operator == "=" {
+				c.Version = dep.Version
+			} else if dep.Operator != "" {
+				c.Properties = append(c.Properties, Property{Name: PropertyVersionConstraint, Value: dep.Operator + " " + dep.Version})
+			}
+			c.Properties = append(c.Properties, relProp)
+			// rpm 的文件依赖(/bin/sh)和能力依赖(libc.so.6(GLIBC_2.34))不是软件包, 不生成 purl
+			if pkg.Type != "" && !strings.ContainsAny(dep.Name, "/()") {
+				c.PackageURL = tool.PackageURL(pkg.Type, purlNS, dep.Name, c.Version, "")
+				c.CPE = tool.CPE(dep.Name, c.Version)
+			}
+			bom.Components = append(bom.Components, c)
+			topDep.DependsOn = append(topDep.DependsOn, ref)
+			depends = append(depends, Dependency{Ref: ref})
+		}
+	}
+
+	for _, f := range pkg.FileList {
+		c := Component{
+			BOMRef:    genBOMRef("FILE", f.FileName),
+			Type:      ComponentTypeFile,
+			Name:      f.FileName,
+			Licenses:  LicensesFromExpression(f.License),
+			Copyright: f.CopyrightText(),
+		}
+		// 没有 copyright 文件中的许可证时使用文件中扫描到的许可证
+		if len(c.Licenses) == 0 && len(f.LicenseInfoInFile) > 0 {
+			c.Licenses = LicensesFromExpression(strings.Join(f.LicenseInfoInFile, " AND "))
+		}
+		c.Hashes, c.Properties = HashesFromChecksums(f.Hash)
+		// 同时有 copyright 文件中的版权时, 扫描到的版权行记录在属性中
+		if f.Copyright != "" {
+			for _, line := range f.CopyrightInFile {
+				c.Properties = append(c.Properties, Property{Name: PropertyCopyrightInFile, Value: line})
+			}
+		}
+		bom.Components = append(bom.Components, c)
+	}
+
+	bom.Dependencies = append([]Dependency{topDep}, depends...)
+	return bom
+}
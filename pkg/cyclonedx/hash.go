@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cyclonedx
+
+import (
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// hashPropertyPrefix CycloneDX 不支持的摘要算法(如 SM3)以属性形式保存
+const hashPropertyPrefix = PropertyPrefix + "hash:"
+
+// SPDX 与 CycloneDX 摘要算法名称对应关系
+var spdxToCdxAlgos = map[common.ChecksumAlgorithm]string{
+	common.MD5:         "MD5",
+	common.SHA1:        "SHA-1",
+	common.SHA256:      "SHA-256",
+	common.SHA384:      "SHA-384",
+	common.SHA512:      "SHA-512",
+	common.SHA3_256:    "SHA3-256",
+	common.SHA3_384:    "SHA3-384",
+	common.SHA3_512:    "SHA3-512",
+	common.BLAKE2b_256: "BLAKE2b-256",
+	common.BLAKE2b_384: "BLAKE2b-384",
+	common.BLAKE2b_512: "BLAKE2b-512",
+	common.BLAKE3:      "BLAKE3",
+}
+
+// HashesFromChecksums 转换 SPDX 校验和, 无对应算法的保存为 deepin-sbom-tools:hash:<算法> 属性
+func HashesFromChecksums(checksums []common.Checksum) (Hashes, Properties) {
+	var hashes Hashes
+	var props Properties
+	for _, c := range checksums {
+		if alg, ok := spdxToCdxAlgos[c.Algorithm]; ok {
+			hashes = append(hashes, Hash{Alg: alg, Content: c.Value})
+		} else {
+			props = append(props, Property{Name: hashPropertyPrefix + string(c.Algorithm), Value: c.Value})
+		}
+	}
+	return hashes, props
+}
+
+// IsHashProperty 判断属性是否保存了 CycloneDX 不支持的摘要
+func IsHashProperty(name string) bool {
+	return strings.HasPrefix(name, hashPropertyPrefix)
+}
+
+// ChecksumsFromHashes 为 HashesFromChecksums 的逆过程
+func ChecksumsFromHashes(hashes Hashes, props Properties) []common.Checksum {
+	var checksums []common.Checksum
+	for _, h := range hashes {
+		for algo, alg := range spdxToCdxAlgos {
+			if alg == h.Alg {
+				checksums = append(checksums, common.Checksum{Algorithm: algo, Value: h.Content})
+				break
+			}
+		}
+	}
+	for _, p := range props {
+		if strings.HasPrefix(p.Name, hashPropertyPrefix) {
+			algo := common.ChecksumAlgorithm(strings.TrimPrefix(p.Name, hashPropertyPrefix))
+			checksums = append(checksums, common.Checksum{Algorithm: algo, Value: p.Value})
+		}
+	}
+	return checksums
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cyclonedx
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LicensesFromExpression 将 SPDX 许可证表达式转换为 CycloneDX 许可证,
+// 单个许可证 ID 使用 license.id, 复合表达式、LicenseRef 及带 + 的许可证使用 expression
+func LicensesFromExpression(expr string) Licenses {
+	expr = strings.TrimSpace(expr)
+	switch expr {
+	case "", "NOASSERTION", "NONE":
+		return nil
+	}
+	if strings.ContainsAny(expr, " ()") || strings.HasPrefix(expr, "LicenseRef-") || strings.Contains(expr, ":") ||
+		strings.HasSuffix(expr, "+") {
+		return Licenses{{Expression: expr}}
+	}
+	return Licenses{{License: &License{ID: expr}}}
+}
+
+var invalidLicenseRefChars = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// Expression 将 CycloneDX 许可证合并为 SPDX 许可证表达式, 多个许可证之间为 AND 关系
+func (l Licenses) Expression() string {
+	var parts []string
+	for _, choice := range l {
+		var part string
+		switch {
+		case choice.Expression != "":
+			part = choice.Expression
+		case choice.License != nil && choice.License.ID != "":
+			part = choice.License.ID
+		case choice.License != nil && choice.License.Name != "":
+			part = "LicenseRef-" + strings.Trim(invalidLicenseRefChars.ReplaceAllString(choice.License.Name, "-"), "-")
+		default:
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	for i, part := range parts {
+		if strings.Contains(part, " ") {
+			parts[i] = "(" + part + ")"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cyclonedx
+
+import (
+	"deepin-sbom-tools/pkg/plugin"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// isAssertion 判断 SPDX 字段是否有实际内容
+func isAssertion(s string) bool {
+	return s != "" && s != "NOASSERTION" && s != "NONE"
+}
+
+// FromSPDX 将 SPDX 2.3 文档转换为 CycloneDX 文档。
+// 文档 DESCRIBES 的第一个包作为 metadata.component, 该包 CONTAINS 的包作为其子组件, 其他包与文件作为 components,
+// DEPENDS_ON 及各类 *_DEPENDENCY_OF 关系转换为 dependencies
+func FromSPDX(doc *v2_3.Document) *BOM {
+	bom := NewBOM()
+	bom.Metadata = &Metadata{Tools: &Tools{}}
+
+	if doc.CreationInfo != nil {
+		bom.Metadata.Timestamp = doc.CreationInfo.Created
+		for _, c := range doc.CreationInfo.Creators {
+			if c.CreatorType == "Tool" {
+				bom.Metadata.Tools.Components = append(bom.Metadata.Tools.Components, Component{
+					Type: ComponentTypeApplication,
+					Name: c.Creator,
+				})
+			}
+		}
+	}
+	bom.Metadata.Tools.Components = append(bom.Metadata.Tools.Components, ToolComponent())
+
+	var topID common.ElementID
+	for _, rel := range doc.Relationships {
+		if rel.Relationship == common.TypeRelationshipDescribe && rel.RefA.ElementRefID == doc.SPDXIdentifier &&
+			rel.RefB.DocumentRefID == "" {
+			topID = rel.RefB.ElementRefID
+			break
+		}
+	}
+	if topID == "" && len(doc.Packages) > 0 {
+		topID = doc.Packages[0].PackageSPDXIdentifier
+	}
+
+	nested := make(map[common.ElementID]bool)
+	for _, rel := range doc.Relationships {
+		if rel.Relationship == common.TypeRelationshipContains && rel.RefA.ElementRefID == topID &&
+			rel.RefA.DocumentRefID == "" && rel.RefB.DocumentRefID == "" {
+			nested[rel.RefB.ElementRefID] = true
+		}
+	}
+	var children Components
+	for _, pkg := range doc.Packages {
+		c := componentFromPackage(pkg)
+		switch {
+		case pkg.PackageSPDXIdentifier == topID:
+			c.Type = ComponentTypeApplication
+			bom.Metadata.Component = &c
+		case nested[pkg.PackageSPDXIdentifier]:
+			children = append(children, c)
+		default:
+			bom.Components = append(bom.Components, c)
+		}
+	}
+	if bom.Metadata.Component != nil {
+		bom.Metadata.Component.Components = children
+	}
+	for _, file := range doc.Files {
+		bom.Components = append(bom.Components, componentFromFile(file))
+	}
+	for _, pkg := range doc.Packages {
+		for _, file := range pkg.Files {
+			bom.Components = append(bom.Components, componentFromFile(file))
+		}
+	}
+
+	// 每个包都列出依赖关系, 没有依赖的包 dependsOn 为空
+	deps := make(map[string][]string)
+	seen := make(map[string]bool)
+	optional := make(map[string]bool)
+	for _, pkg := range doc.Packages {
+		deps[string(pkg.PackageSPDXIdentifier)] = nil
+	}
+	addDep := func(from, to common.DocElementID) {
+		if from.DocumentRefID != "" || to.DocumentRefID != "" || from.SpecialID != "" || to.SpecialID != "" {
+			return
+		}
+		key := string(from.ElementRefID) + " " + string(to.ElementRefID)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		deps[string(from.ElementRefID)] = append(deps[string(from.ElementRefID)], string(to.ElementRefID))
+	}
+	for _, rel := range doc.Relationships {
+		switch {
+		case rel.Relationship == common.TypeRelationshipDependsOn:
+			addDep(rel.RefA, rel.RefB)
+		case strings.HasSuffix(rel.Relationship, "DEPENDENCY_OF"):
+			addDep(rel.RefB, rel.RefA)
+			if rel.Relationship == common.TypeRelationshipOptionalDependencyOf {
+				optional[string(rel.RefA.ElementRefID)] = true
+			}
+		}
+	}
+	for i := range bom.Components {
+		if optional[bom.Components[i].BOMRef] {
+			bom.Components[i].Scope = ScopeOptional
+		}
+	}
+	for _, pkg := range doc.Packages {
+		ref := string(pkg.PackageSPDXIdentifier)
+		bom.Dependencies = append(bom.Dependencies, Dependency{Ref: ref, DependsOn: deps[ref]})
+	}
+	return bom
+}
+
+func componentFromPackage(pkg *v2_3.Package) Component {
+	c := Component{
+		BOMRef:      string(pkg.PackageSPDXIdentifier),
+		Type:        ComponentTypeLibrary,
+		Name:        pkg.PackageName,
+		Version:     pkg.PackageVersion,
+		Description: pkg.PackageDescription,
+	}
+	if pkg.PackageSupplier != nil && isAssertion(pkg.PackageSupplier.Supplier) {
+		c.Supplier = &OrganizationalEntity{Name: pkg.PackageSupplier.Supplier}
+	}
+	c.Hashes, c.Properties = HashesFromChecksums(pkg.PackageChecksums)
+	if isAssertion(pkg.PackageLicenseDeclared) {
+		c.Licenses = LicensesFromExpression(pkg.PackageLicenseDeclared)
+	} else {
+		c.Licenses = LicensesFromExpression(pkg.PackageLicenseConcluded)
+	}
+	if isAssertion(pkg.PackageCopyrightText) {
+		c.Copyright = pkg.PackageCopyrightText
+	}
+	if pkg.PackageFileName != "" {
+		c.Properties = append(c.Properties, Property{Name: PropertyFileName, Value: pkg.PackageFileName})
+	}
+	if pkg.PackageSourceInfo != "" {
+		c.Properties = append(c.Properties, Property{Name: PropertySourceInfo, Value: pkg.PackageSourceInfo})
+	}
+	for _, ref := range pkg.PackageExternalReferences {
+		switch ref.RefType {
+		case common.TypePackageManagerPURL:
+			if c.PackageURL == "" {
+				c.PackageURL = ref.Locator
+			}
+		case common.TypeSecurityCPE23Type, common.TypeSecurityCPE22Type:
+			if c.CPE == "" {
+				c.CPE = ref.Locator
+			}
+		}
+	}
+	if isAssertion(pkg.PackageHomePage) {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: ExternalRefWebsite, URL: pkg.PackageHomePage})
+	}
+	if isAssertion(pkg.PackageDownloadLocation) {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: ExternalRefDistribution, URL: pkg.PackageDownloadLocation})
+	}
+	return c
+}
+
+func componentFromFile(file *v2_3.File) Component {
+	c := Component{
+		BOMRef: string(file.FileSPDXIdentifier),
+		Type:   ComponentTypeFile,
+		Name:   file.FileName,
+	}
+	c.Hashes, c.Properties = HashesFromChecksums(file.Checksums)
+	c.Licenses = LicensesFromExpression(file.LicenseConcluded)
+	if isAssertion(file.FileCopyrightText) {
+		c.Copyright = file.FileCopyrightText
+	}
+	if strings.HasPrefix(file.FileComment, plugin.CopyrightInFileComment) {
+		for _, line := range strings.Split(strings.TrimPrefix(file.FileComment, plugin.CopyrightInFileComment), "\n") {
+			c.Properties = append(c.Properties, Property{Name: PropertyCopyrightInFile, Value: line})
+		}
+	}
+	return c
+}
@@ -6,24 +6,300 @@ package doc
 
 import (
 	"crypto/sha1"
+	"deepin-sbom-tools/pkg/log"
 	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
 	"deepin-sbom-tools/pkg/version"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/spdx/tools-golang/spdx/v2/common"
 	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+	"github.com/spdx/tools-golang/utils"
 )
 
+// versionConstraintComment 依赖包注释中版本约束的前缀
+const versionConstraintComment = "version constraint: "
+
+// noVerificationCodeComment 文件缺少 SHA1 而无法计算包校验码时, 包注释中记录原因的前缀
+const noVerificationCodeComment = "package verification code not computed: "
+
 func genSPDXIdentifier(prefix string, s string) common.ElementID {
 	hSHA1 := sha1.New()
 	hSHA1.Write([]byte(s))
 	return common.ElementID(fmt.Sprintf("%s-%x", prefix, hSHA1.Sum(nil)))
 }
 
-func CreateDocument(topLevelPkg plugin.PkgInfo, namespaceBase string) (*v2_3.Document, error) {
+// setVerificationCode 根据文件 SHA1 计算包校验码, 所有文件都有 SHA1 时才标记为已分析文件,
+// 否则在包注释中记录未计算的原因
+func setVerificationCode(pkg *v2_3.Package, files []*v2_3.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+	for _, f := range files {
+		hasSHA1 := false
+		for _, c := range f.Checksums {
+			if c.Algorithm == common.SHA1 {
+				hasSHA1 = true
+				break
+			}
+		}
+		if !hasSHA1 {
+			log.Warning(f.FileName, "has no SHA1 checksum, skip package verification code")
+			reason := noVerificationCodeComment + f.FileName + " has no SHA1 checksum"
+			if pkg.PackageComment != "" {
+				reason = pkg.PackageComment + "\n" + reason
+			}
+			pkg.PackageComment = reason
+			return nil
+		}
+	}
+	code, err := utils.GetVerificationCode(files, "")
+	if err != nil {
+		return err
+	}
+	pkg.FilesAnalyzed = true
+	pkg.IsFilesAnalyzedTagPresent = true
+	pkg.PackageVerificationCode = &code
+	return nil
+}
+
+// licenseInfoFromFiles 汇总各文件中出现的许可证
+func licenseInfoFromFiles(files []*v2_3.File) []string {
+	var licenses []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		for _, l := range f.LicenseInfoInFiles {
+			if !seen[l] {
+				seen[l] = true
+				licenses = append(licenses, l)
+			}
+		}
+	}
+	sort.Strings(licenses)
+	return licenses
+}
+
+// packageExternalRefs 生成软件包的 purl 与 CPE 外部引用,
+// rpm 的文件依赖(/bin/sh)和能力依赖(libc.so.6(GLIBC_2.34))不是软件包, 不生成
+func packageExternalRefs(pkgType, purlNS, name, version, arch string) []*v2_3.PackageExternalReference {
+	if pkgType == "" || strings.ContainsAny(name, "/()") {
+		return nil
+	}
+	return []*v2_3.PackageExternalReference{
+		{
+			Category: common.CategoryPackageManager,
+			RefType:  common.TypePackageManagerPURL,
+			Locator:  tool.PackageURL(pkgType, purlNS, name, version, arch),
+		},
+		{
+			Category: common.CategorySecurity,
+			RefType:  common.TypeSecurityCPE23Type,
+			Locator:  tool.CPE(name, version),
+		},
+	}
+}
+
+// 软件包关系对应的 SPDX 关系类型, reverse 为 true 时关系由依赖指向软件包
+var relationshipTypes = map[string]struct {
+	typ     string
+	reverse bool
+}{
+	plugin.RelationPreDepends: {common.TypeRelationshipDependsOn, false},
+	plugin.RelationDepends:    {common.TypeRelationshipDependsOn, false},
+	plugin.RelationRecommends: {common.TypeRelationshipOptionalDependencyOf, true},
+	plugin.RelationSuggests:   {common.TypeRelationshipOptionalDependencyOf, true},
+	plugin.RelationBuiltUsing: {common.TypeRelationshipStaticLink, false},
+
+	plugin.RelationBuildDepends:      {common.TypeRelationshipBuildDependencyOf, true},
+	plugin.RelationBuildDependsArch:  {common.TypeRelationshipBuildDependencyOf, true},
+	plugin.RelationBuildDependsIndep: {common.TypeRelationshipBuildDependencyOf, true},
+
+	plugin.RelationBase:    {common.TypeRelationshipDependsOn, false},
+	plugin.RelationContent: {common.TypeRelationshipDependsOn, false},
+	plugin.RelationRuntime: {common.TypeRelationshipDependsOn, false},
+}
+
+// relationshipOf 生成软件包与依赖之间的关系, 注释中保留原始关系及候选项;
+// Breaks、Conflicts、Provides、Build-Conflicts 没有对应的类型, 使用 OTHER。
+// alternative 表示 a | b 中第一个之后的候选, 满足第一个候选时不需要安装, 依赖关系记为 OPTIONAL_DEPENDENCY_OF
+func relationshipOf(rel plugin.Relation, pkgID, depID common.ElementID, alternative bool) *v2_3.Relationship {
+	r := &v2_3.Relationship{
+		RefA:                common.DocElementID{ElementRefID: pkgID},
+		RefB:                common.DocElementID{ElementRefID: depID},
+		Relationship:        common.TypeRelationshipOther,
+		RelationshipComment: rel.Type + ": " + rel.String(),
+	}
+	if t, ok := relationshipTypes[rel.Type]; ok {
+		r.Relationship = t.typ
+		if alternative {
+			r.Relationship, t.reverse = common.TypeRelationshipOptionalDependencyOf, true
+		}
+		if t.reverse {
+			r.RefA, r.RefB = r.RefB, r.RefA
+		}
+	}
+	return r
+}
+
+// dependPackage 生成依赖对应的软件包, 只有精确版本约束才能确定依赖的版本, 其他约束记录在注释中
+func dependPackage(pkgType, purlNS string, dep plugin.Dependency, id common.ElementID) *v2_3.Package {
+	pkg := &v2_3.Package{
+		PackageName:             dep.Name,
+		PackageSPDXIdentifier:   id,
+		PackageDownloadLocation: "NOASSERTION",
+	}
+	if dep.Operator == "=" {
+		pkg.PackageVersion = dep.Version
+	} else if dep.Operator != "" {
+		pkg.PackageComment = versionConstraintComment + dep.Operator + " " + dep.Version
+	}
+	pkg.PackageExternalReferences = packageExternalRefs(pkgType, purlNS, dep.Name, pkg.PackageVersion, "")
+	return pkg
+}
+
+// otherLicenses 生成 LicenseRef- 对应的许可证信息, 软件包中没有许可证全文时记为 NOASSERTION
+func otherLicenses(licenses []plugin.OtherLicense) []*v2_3.OtherLicense {
+	var res []*v2_3.OtherLicense
+	for _, l := range licenses {
+		other := &v2_3.OtherLicense{
+			LicenseIdentifier: l.ID,
+			ExtractedText:     l.Text,
+			LicenseName:       l.Name,
+		}
+		if other.ExtractedText == "" {
+			other.ExtractedText = "NOASSERTION"
+			other.LicenseComment = "license text not found in the package"
+		}
+		res = append(res, other)
+	}
+	return res
+}
+
+var invalidLicenseRefChars = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+var licenseRefPattern = regexp.MustCompile(`(?:DocumentRef-[A-Za-z0-9.\-]+:)?LicenseRef-[A-Za-z0-9.\-]+`)
+
+// licenseSet 文档中各软件包的 LicenseRef-, 不同软件包使用同一 ID 时, 全文相同的合并为一项,
+// 全文不同的改名为 LicenseRef-<软件包名>-<原名称>, 各自保留自己的全文
+type licenseSet struct {
+	texts    map[string]string
+	licenses []*v2_3.OtherLicense
+}
+
+func newLicenseSet() *licenseSet {
+	return &licenseSet{texts: make(map[string]string)}
+}
+
+// add 加入软件包的 LicenseRef-, 有改名时返回许可证表达式已替换为新 ID 的软件包副本, 不修改原软件包
+func (s *licenseSet) add(p plugin.PkgInfo) plugin.PkgInfo {
+	renames := make(map[string]string)
+	var licenses []plugin.OtherLicense
+	for _, l := range p.OtherLicenses {
+		if text, ok := s.texts[l.ID]; ok && text != l.Text {
+			name := strings.Trim(invalidLicenseRefChars.ReplaceAllString(p.Name, "-"), "-")
+			base := "LicenseRef-" + name + "-" + strings.TrimPrefix(l.ID, "LicenseRef-")
+			id := base
+			for i := 2; ; i++ {
+				if text, ok := s.texts[id]; !ok || text == l.Text {
+					break
+				}
+				id = fmt.Sprintf("%s-%d", base, i)
+			}
+			renames[l.ID] = id
+			l.ID = id
+		}
+		licenses = append(licenses, l)
+		if _, ok := s.texts[l.ID]; !ok {
+			s.texts[l.ID] = l.Text
+			s.licenses = append(s.licenses, otherLicenses([]plugin.OtherLicense{l})...)
+		}
+	}
+	if len(renames) == 0 {
+		return p
+	}
+
+	rename := func(expr string) string {
+		return licenseRefPattern.ReplaceAllStringFunc(expr, func(id string) string {
+			if renamed, ok := renames[id]; ok {
+				return renamed
+			}
+			return id
+		})
+	}
+	p.OtherLicenses = licenses
+	p.LicenseDeclared = rename(p.LicenseDeclared)
+	files := make([]*plugin.FileInfo, 0, len(p.FileList))
+	for _, f := range p.FileList {
+		file := *f
+		file.License = rename(f.License)
+		file.LicenseInfoInFile = nil
+		for _, l := range f.LicenseInfoInFile {
+			file.LicenseInfoInFile = append(file.LicenseInfoInFile, rename(l))
+		}
+		files = append(files, &file)
+	}
+	p.FileList = files
+	return p
+}
+
+// packageOf 生成软件包信息, 不包括文件
+func packageOf(p plugin.PkgInfo, id common.ElementID, purlNS string) *v2_3.Package {
+	pkg := &v2_3.Package{
+		PackageName:             p.Name,
+		PackageSPDXIdentifier:   id,
+		PackageDownloadLocation: "NOASSERTION",
+		PackageVersion:          p.Version,
+		PackageFileName:         p.FileName,
+		PackageChecksums:        p.Hash,
+		PackageSupplier: &common.Supplier{
+			Supplier:     strings.Replace(strings.Replace(p.Maintainer, "<", "(", -1), ">", ")", -1),
+			SupplierType: "Organization",
+		},
+		PackageLicenseDeclared:    p.LicenseDeclared,
+		PackageCopyrightText:      p.Copyright,
+		FilesAnalyzed:             false,
+		PackageDescription:        p.Description,
+		PackageHomePage:           p.Homepage,
+		PackageExternalReferences: packageExternalRefs(p.Type, purlNS, p.Name, p.Version, p.Architecture),
+		PackageSourceInfo:         p.SourceInfo(),
+		PackageComment:            p.PropertiesComment(),
+	}
+	if p.Maintainer == "" || p.Maintainer == "NOASSERTION" {
+		pkg.PackageSupplier = &common.Supplier{Supplier: "NOASSERTION"}
+	}
+	if p.DownloadLocation != "" {
+		pkg.PackageDownloadLocation = p.DownloadLocation
+	}
+	if p.Architecture == plugin.ArchSource {
+		pkg.PrimaryPackagePurpose = "SOURCE"
+	}
+	return pkg
+}
+
+// fileOf 生成文件信息
+func fileOf(v *plugin.FileInfo) *v2_3.File {
+	file := &v2_3.File{
+		FileName:           v.FileName,
+		FileSPDXIdentifier: genSPDXIdentifier("FILE", v.FileName),
+		Checksums:          v.Hash,
+		LicenseConcluded:   v.License,
+		LicenseInfoInFiles: v.LicenseInfoInFile,
+		FileCopyrightText:  v.CopyrightText(),
+		FileComment:        v.CopyrightComment(),
+	}
+	if file.FileCopyrightText == "" {
+		file.FileCopyrightText = "NOASSERTION"
+	}
+	return file
+}
+
+// CreateDocument 根据软件包信息生成 SPDX 文档, purlNS 为 purl 中的发行版命名空间
+func CreateDocument(topLevelPkg plugin.PkgInfo, namespaceBase, purlNS string) (*v2_3.Document, error) {
 	//todo 空参数检查
 	if topLevelPkg.Maintainer == "" {
 		return nil, errors.New("not enough parameters")
@@ -43,61 +319,64 @@ func CreateDocument(topLevelPkg plugin.PkgInfo, namespaceBase string) (*v2_3.Doc
 			Created: time.Now().UTC().Format(time.RFC3339),
 		},
 	}
+	licenses := newLicenseSet()
+	topLevelPkg = licenses.add(topLevelPkg)
 	{
-		doc.Packages = append(doc.Packages, &v2_3.Package{
-			PackageName:             topLevelPkg.Name,
-			PackageSPDXIdentifier:   genSPDXIdentifier("PACKAGE", topLevelPkg.Name),
-			PackageDownloadLocation: "NOASSERTION",
-			PackageVersion:          topLevelPkg.Version,
-			PackageSupplier: &common.Supplier{
-				Supplier:     strings.Replace(strings.Replace(topLevelPkg.Maintainer, "<", "(", -1), ">", ")", -1),
-				SupplierType: "Organization",
-			},
-			PackageLicenseDeclared: topLevelPkg.LicenseDeclared,
-			PackageCopyrightText:   topLevelPkg.Copyright,
-			FilesAnalyzed:          false,
-			PackageDescription:     topLevelPkg.Description,
-			PackageHomePage:        topLevelPkg.Homepage,
-		})
+		doc.Packages = append(doc.Packages, packageOf(topLevelPkg, genSPDXIdentifier("PACKAGE", topLevelPkg.Name), purlNS))
 		doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
 			RefA:         common.DocElementID{ElementRefID: doc.SPDXIdentifier},
 			RefB:         common.DocElementID{ElementRefID: doc.Packages[0].PackageSPDXIdentifier},
 			Relationship: "DESCRIBES",
 		})
-		for cnt, pkg := range topLevelPkg.Depends {
-			var name string
-			var ver string
-			idx := strings.Split(pkg, " ")
-			if len(idx) == 3 {
-				name = idx[0]
-				ver = strings.TrimRight(idx[2], ")")
-			} else {
-				name = pkg
+		topPkg := doc.Packages[0]
+		seen := make(map[common.ElementID]bool)
+		for _, rel := range topLevelPkg.Relations {
+			for i, dep := range rel.Alternatives {
+				id := genSPDXIdentifier("DEPEND", dep.String())
+				if !seen[id] {
+					seen[id] = true
+					doc.Packages = append(doc.Packages, dependPackage(topLevelPkg.Type, purlNS, dep, id))
+				}
+				doc.Relationships = append(doc.Relationships, relationshipOf(rel, topPkg.PackageSPDXIdentifier, id, i > 0))
 			}
-			name = strings.Split(name, ":")[0]
-			doc.Packages = append(doc.Packages, &v2_3.Package{
-				PackageName:             name,
-				PackageVersion:          ver,
-				PackageSPDXIdentifier:   genSPDXIdentifier("DEPEND", pkg),
-				PackageDownloadLocation: "NOASSERTION",
-			})
-			doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
-				RefA:         common.DocElementID{ElementRefID: doc.Packages[0].PackageSPDXIdentifier},
-				RefB:         common.DocElementID{ElementRefID: doc.Packages[cnt+1].PackageSPDXIdentifier},
-				Relationship: "DEPENDS_ON",
-			})
 		}
 	}
 	{
 		// fmt.Println(topLevelPkg.FileList)
+		topPkg := doc.Packages[0]
 		for _, v := range topLevelPkg.FileList {
-			doc.Files = append(doc.Files, &v2_3.File{
-				FileName:           v.FileName,
-				FileSPDXIdentifier: genSPDXIdentifier("FILE", v.FileName),
-				Checksums:          v.Hash,
-				FileCopyrightText:  "NOASSERTION",
+			file := fileOf(v)
+			doc.Files = append(doc.Files, file)
+			doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+				RefA:         common.DocElementID{ElementRefID: topPkg.PackageSPDXIdentifier},
+				RefB:         common.DocElementID{ElementRefID: file.FileSPDXIdentifier},
+				Relationship: "CONTAINS",
 			})
 		}
+		if err := setVerificationCode(topPkg, doc.Files); err != nil {
+			return nil, err
+		}
+		if topPkg.FilesAnalyzed {
+			topPkg.PackageLicenseInfoFromFiles = licenseInfoFromFiles(doc.Files)
+		}
+	}
+
+	// 内嵌的软件包由顶层软件包 CONTAINS
+	for _, p := range topLevelPkg.Packages {
+		p = licenses.add(p)
+		pkg := packageOf(p, nestedPackageID(p), purlNS)
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+			RefA:         common.DocElementID{ElementRefID: doc.Packages[0].PackageSPDXIdentifier},
+			RefB:         common.DocElementID{ElementRefID: pkg.PackageSPDXIdentifier},
+			Relationship: "CONTAINS",
+		})
 	}
+	doc.OtherLicenses = licenses.licenses
 	return doc, nil
 }
+
+// nestedPackageID 内嵌软件包的 ID, 同一软件包可能以不同路径出现多次
+func nestedPackageID(p plugin.PkgInfo) common.ElementID {
+	return genSPDXIdentifier("PACKAGE", p.Type+" "+p.Name+" "+p.Version+" "+p.Architecture+" "+p.FileName)
+}
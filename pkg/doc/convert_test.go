@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"deepin-sbom-tools/pkg/plugin"
+	"reflect"
+	"testing"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+func TestCreateDocumentRelationships(t *testing.T) {
+	tests := []struct {
+		field string
+		deps  []plugin.Dependency
+		want  []string //关系两端为软件包名称, 候选依次对应
+	}{
+		{plugin.RelationPreDepends, []plugin.Dependency{{Name: "dpkg"}}, []string{"hello DEPENDS_ON dpkg"}},
+		{plugin.RelationDepends, []plugin.Dependency{{Name: "libc6", Operator: ">=", Version: "2.34"}}, []string{"hello DEPENDS_ON libc6"}},
+		{plugin.RelationRecommends, []plugin.Dependency{{Name: "hello-doc"}}, []string{"hello-doc OPTIONAL_DEPENDENCY_OF hello"}},
+		{plugin.RelationSuggests, []plugin.Dependency{{Name: "hello-extra"}}, []string{"hello-extra OPTIONAL_DEPENDENCY_OF hello"}},
+		{plugin.RelationBreaks, []plugin.Dependency{{Name: "hello-old", Operator: "<<", Version: "2.0"}}, []string{"hello OTHER hello-old"}},
+		{plugin.RelationConflicts, []plugin.Dependency{{Name: "hello-traditional"}}, []string{"hello OTHER hello-traditional"}},
+		{plugin.RelationProvides, []plugin.Dependency{{Name: "hello-virtual"}}, []string{"hello OTHER hello-virtual"}},
+		{plugin.RelationBuiltUsing, []plugin.Dependency{{Name: "gcc-13", Operator: "=", Version: "13.2.0-7"}}, []string{"hello STATIC_LINK gcc-13"}},
+		{plugin.RelationBuildDepends, []plugin.Dependency{{Name: "debhelper-compat", Operator: "=", Version: "13"}}, []string{"debhelper-compat BUILD_DEPENDENCY_OF hello"}},
+		{plugin.RelationBuildDependsArch, []plugin.Dependency{{Name: "libfoo-dev"}}, []string{"libfoo-dev BUILD_DEPENDENCY_OF hello"}},
+		{plugin.RelationBuildDependsIndep, []plugin.Dependency{{Name: "texinfo"}}, []string{"texinfo BUILD_DEPENDENCY_OF hello"}},
+		{plugin.RelationBuildConflicts, []plugin.Dependency{{Name: "autoconf2.13"}}, []string{"hello OTHER autoconf2.13"}},
+		{plugin.RelationBuildConflictsArch, []plugin.Dependency{{Name: "libbar-dev"}}, []string{"hello OTHER libbar-dev"}},
+		{plugin.RelationBuildConflictsIndep, []plugin.Dependency{{Name: "sphinx"}}, []string{"hello OTHER sphinx"}},
+		{plugin.RelationBase, []plugin.Dependency{{Name: "core22"}}, []string{"hello DEPENDS_ON core22"}},
+		{plugin.RelationContent, []plugin.Dependency{{Name: "gtk-common-themes"}}, []string{"hello DEPENDS_ON gtk-common-themes"}},
+		{plugin.RelationRuntime, []plugin.Dependency{{Name: "org.gnome.Platform"}}, []string{"hello DEPENDS_ON org.gnome.Platform"}},
+		// 第一个之后的候选为可选依赖, 冲突类的关系中候选之间没有先后, 仍为 OTHER
+		{
+			plugin.RelationDepends,
+			[]plugin.Dependency{{Name: "default-mta"}, {Name: "mail-transport-agent"}, {Name: "postfix"}},
+			[]string{"hello DEPENDS_ON default-mta", "mail-transport-agent OPTIONAL_DEPENDENCY_OF hello", "postfix OPTIONAL_DEPENDENCY_OF hello"},
+		},
+		{
+			plugin.RelationBuildDepends,
+			[]plugin.Dependency{{Name: "libgtk-4-dev"}, {Name: "libgtk-3-dev"}},
+			[]string{"libgtk-4-dev BUILD_DEPENDENCY_OF hello", "libgtk-3-dev OPTIONAL_DEPENDENCY_OF hello"},
+		},
+		{
+			plugin.RelationConflicts,
+			[]plugin.Dependency{{Name: "hello-a"}, {Name: "hello-b"}},
+			[]string{"hello OTHER hello-a", "hello OTHER hello-b"},
+		},
+	}
+
+	pkg := plugin.PkgInfo{Type: "deb", Name: "hello", Version: "2.10-3", Architecture: "amd64", Maintainer: "Debian"}
+	for _, tt := range tests {
+		pkg.Relations = append(pkg.Relations, plugin.Relation{Type: tt.field, Alternatives: tt.deps})
+	}
+	doc, err := CreateDocument(pkg, "https://example.org/spdx/", "deepin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[common.ElementID]string)
+	for _, p := range doc.Packages {
+		names[p.PackageSPDXIdentifier] = p.PackageName
+	}
+	// 每个候选生成一条关系, 注释中保留原始字段
+	var got []string
+	comments := make(map[string]string)
+	for _, rel := range doc.Relationships {
+		if rel.Relationship == common.TypeRelationshipDescribe {
+			continue
+		}
+		s := names[rel.RefA.ElementRefID] + " " + rel.Relationship + " " + names[rel.RefB.ElementRefID]
+		got = append(got, s)
+		comments[s] = rel.RelationshipComment
+	}
+	i := 0
+	for _, tt := range tests {
+		rel := plugin.Relation{Type: tt.field, Alternatives: tt.deps}
+		for _, want := range tt.want {
+			if i >= len(got) {
+				t.Fatalf("%s: missing %q", tt.field, want)
+			}
+			if got[i] != want {
+				t.Errorf("%s: got %q, want %q", tt.field, got[i], want)
+			} else if c := tt.field + ": " + rel.String(); comments[want] != c {
+				t.Errorf("%s: comment = %q, want %q", want, comments[want], c)
+			}
+			i++
+		}
+	}
+	if i != len(got) {
+		t.Errorf("unexpected relationships %q", got[i:])
+	}
+
+	// 带精确版本约束的依赖才有版本, 其他约束记录在注释中
+	for _, p := range doc.Packages {
+		switch p.PackageName {
+		case "gcc-13":
+			if p.PackageVersion != "13.2.0-7" || p.PackageComment != "" {
+				t.Errorf("gcc-13: version %q, comment %q", p.PackageVersion, p.PackageComment)
+			}
+		case "libc6":
+			if p.PackageVersion != "" || p.PackageComment != versionConstraintComment+">= 2.34" {
+				t.Errorf("libc6: version %q, comment %q", p.PackageVersion, p.PackageComment)
+			}
+		}
+	}
+}
+
+// TestCreateDocumentLicenseRefs 内嵌软件包中与上级或其他内嵌软件包同名的 LicenseRef- 全文不同时各自保留
+func TestCreateDocumentLicenseRefs(t *testing.T) {
+	top := testLicensePackage("hello", "")
+	top.Type = "snap"
+	top.Packages = []plugin.PkgInfo{
+		testLicensePackage("libsqlite3-0", "The author disclaims copyright to this source code."),
+		testLicensePackage("tzdata", "This file is in the public domain."),
+		testLicensePackage("sqlite3", "The author disclaims copyright to this source code."),
+		testLicensePackage("libfoo", ""),
+	}
+	doc, err := CreateDocument(top, "https://example.org/spdx/", "deepin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range doc.OtherLicenses {
+		got = append(got, l.LicenseIdentifier)
+	}
+	wantIDs := []string{
+		"LicenseRef-public-domain",
+		"LicenseRef-libsqlite3-0-public-domain",
+		"LicenseRef-tzdata-public-domain",
+		"LicenseRef-sqlite3-public-domain",
+	}
+	if !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("other licenses = %q, want %q", got, wantIDs)
+	}
+	want := map[common.ElementID]string{genSPDXIdentifier("PACKAGE", top.Name): ""}
+	for _, p := range top.Packages {
+		want[nestedPackageID(p)] = p.OtherLicenses[0].Text
+	}
+	checkLicenseRefs(t, doc, want)
+}
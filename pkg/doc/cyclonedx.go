@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"deepin-sbom-tools/pkg/cyclonedx"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/version"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+var validElementID = regexp.MustCompile(`^[A-Za-z0-9.\-]+$`)
+
+// elementIDFromComponent bom-ref 符合 SPDX ID 规则时直接使用, 否则按 prefix 重新生成
+func elementIDFromComponent(prefix string, c *cyclonedx.Component) common.ElementID {
+	if validElementID.MatchString(c.BOMRef) {
+		return common.ElementID(c.BOMRef)
+	}
+	if c.BOMRef != "" {
+		return genSPDXIdentifier(prefix, c.BOMRef)
+	}
+	return genSPDXIdentifier(prefix, c.Name+"@"+c.Version)
+}
+
+// purlArch 返回 purl 中的 arch 限定符
+func purlArch(purl string) string {
+	idx := strings.Index(purl, "?")
+	if idx < 0 {
+		return ""
+	}
+	query := purl[idx+1:]
+	if i := strings.Index(query, "#"); i >= 0 {
+		query = query[:i]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	return values.Get("arch")
+}
+
+func packageFromComponent(c *cyclonedx.Component) *v2_3.Package {
+	pkg := &v2_3.Package{
+		PackageName:             c.Name,
+		PackageSPDXIdentifier:   elementIDFromComponent("PACKAGE", c),
+		PackageVersion:          c.Version,
+		PackageDownloadLocation: "NOASSERTION",
+		PackageDescription:      c.Description,
+		PackageLicenseDeclared:  c.Licenses.Expression(),
+		PackageCopyrightText:    c.Copyright,
+		PackageChecksums:        cyclonedx.ChecksumsFromHashes(c.Hashes, c.Properties),
+	}
+	if c.Supplier != nil && c.Supplier.Name != "" {
+		pkg.PackageSupplier = &common.Supplier{
+			Supplier:     strings.Replace(strings.Replace(c.Supplier.Name, "<", "(", -1), ">", ")", -1),
+			SupplierType: "Organization",
+		}
+	}
+	// 依赖的版本约束与格式特有的属性, 与 CreateDocument 一样记录在注释中
+	var comments []string
+	for _, p := range c.Properties {
+		switch p.Name {
+		case cyclonedx.PropertyVersionConstraint:
+			comments = append(comments, versionConstraintComment+p.Value)
+		case cyclonedx.PropertySourceInfo:
+			pkg.PackageSourceInfo = p.Value
+		case cyclonedx.PropertyFileName:
+			pkg.PackageFileName = p.Value
+		case cyclonedx.PropertySection, cyclonedx.PropertyInstalledSize, cyclonedx.PropertyRelationship:
+		default:
+			if strings.HasPrefix(p.Name, cyclonedx.PropertyPrefix) && !cyclonedx.IsHashProperty(p.Name) {
+				comments = append(comments, strings.TrimPrefix(p.Name, cyclonedx.PropertyPrefix)+": "+p.Value)
+			}
+		}
+	}
+	pkg.PackageComment = strings.Join(comments, "\n")
+	if purlArch(c.PackageURL) == plugin.ArchSource {
+		pkg.PrimaryPackagePurpose = "SOURCE"
+	}
+	for _, ref := range c.ExternalReferences {
+		switch ref.Type {
+		case cyclonedx.ExternalRefWebsite:
+			pkg.PackageHomePage = ref.URL
+		case cyclonedx.ExternalRefDistribution:
+			pkg.PackageDownloadLocation = ref.URL
+		}
+	}
+	if c.PackageURL != "" {
+		pkg.PackageExternalReferences = append(pkg.PackageExternalReferences, &v2_3.PackageExternalReference{
+			Category: common.CategoryPackageManager,
+			RefType:  common.TypePackageManagerPURL,
+			Locator:  c.PackageURL,
+		})
+	}
+	if c.CPE != "" {
+		pkg.PackageExternalReferences = append(pkg.PackageExternalReferences, &v2_3.PackageExternalReference{
+			Category: common.CategorySecurity,
+			RefType:  common.TypeSecurityCPE23Type,
+			Locator:  c.CPE,
+		})
+	}
+	return pkg
+}
+
+// FromCycloneDX 将 CycloneDX 文档转换为 SPDX 2.3 文档, metadata.component 作为文档描述的软件包,
+// 其子组件与文件组件由该包 CONTAINS, dependencies 转换为 DEPENDS_ON
+func FromCycloneDX(bom *cyclonedx.BOM, namespaceBase string) (*v2_3.Document, error) {
+	if bom.Metadata == nil || bom.Metadata.Component == nil {
+		return nil, errors.New("the CycloneDX document has no metadata component")
+	}
+	top := bom.Metadata.Component
+	docName := top.Name + "_" + top.Version
+	if arch := purlArch(top.PackageURL); arch != "" {
+		docName += "_" + arch
+	}
+	created := bom.Metadata.Timestamp
+	if created == "" {
+		created = time.Now().UTC().Format(time.RFC3339)
+	}
+	doc := &v2_3.Document{
+		SPDXVersion:       v2_3.Version,
+		DataLicense:       v2_3.DataLicense,
+		SPDXIdentifier:    "DOCUMENT",
+		DocumentName:      docName,
+		DocumentNamespace: namespaceBase + docName,
+		CreationInfo: &v2_3.CreationInfo{
+			Created: created,
+		},
+	}
+	creators := map[string]bool{"deepin-sbom-tools_" + version.VERSION: true}
+	doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, common.Creator{
+		Creator:     "deepin-sbom-tools_" + version.VERSION,
+		CreatorType: "Tool",
+	})
+	if bom.Metadata.Tools != nil {
+		for _, t := range bom.Metadata.Tools.Components {
+			name := t.Name
+			if t.Version != "" {
+				name += "_" + t.Version
+			}
+			if name != "" && !creators[name] {
+				creators[name] = true
+				doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, common.Creator{Creator: name, CreatorType: "Tool"})
+			}
+		}
+	}
+
+	topPkg := packageFromComponent(top)
+	doc.Packages = append(doc.Packages, topPkg)
+	doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+		RefA:         common.DocElementID{ElementRefID: doc.SPDXIdentifier},
+		RefB:         common.DocElementID{ElementRefID: topPkg.PackageSPDXIdentifier},
+		Relationship: "DESCRIBES",
+	})
+
+	ids := map[string]common.ElementID{top.BOMRef: topPkg.PackageSPDXIdentifier}
+	// 内嵌的子组件由顶层软件包 CONTAINS
+	for i := range top.Components {
+		pkg := packageFromComponent(&top.Components[i])
+		ids[top.Components[i].BOMRef] = pkg.PackageSPDXIdentifier
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+			RefA:         common.DocElementID{ElementRefID: topPkg.PackageSPDXIdentifier},
+			RefB:         common.DocElementID{ElementRefID: pkg.PackageSPDXIdentifier},
+			Relationship: "CONTAINS",
+		})
+	}
+	scopes := make(map[string]string)
+	for i := range bom.Components {
+		c := &bom.Components[i]
+		if c.Type == cyclonedx.ComponentTypeFile {
+			file := &v2_3.File{
+				FileName:           c.Name,
+				FileSPDXIdentifier: elementIDFromComponent("FILE", c),
+				Checksums:          cyclonedx.ChecksumsFromHashes(c.Hashes, c.Properties),
+				LicenseConcluded:   c.Licenses.Expression(),
+				FileCopyrightText:  c.Copyright,
+			}
+			if file.FileCopyrightText == "" {
+				file.FileCopyrightText = "NOASSERTION"
+			}
+			var scanned []string
+			for _, p := range c.Properties {
+				if p.Name == cyclonedx.PropertyCopyrightInFile {
+					scanned = append(scanned, p.Value)
+				}
+			}
+			if len(scanned) > 0 {
+				file.FileComment = plugin.CopyrightInFileComment + strings.Join(scanned, "\n")
+			}
+			doc.Files = append(doc.Files, file)
+			doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+				RefA:         common.DocElementID{ElementRefID: topPkg.PackageSPDXIdentifier},
+				RefB:         common.DocElementID{ElementRefID: file.FileSPDXIdentifier},
+				Relationship: "CONTAINS",
+			})
+			continue
+		}
+		pkg := packageFromComponent(c)
+		ids[c.BOMRef] = pkg.PackageSPDXIdentifier
+		scopes[c.BOMRef] = c.Scope
+		doc.Packages = append(doc.Packages, pkg)
+	}
+
+	for _, dep := range bom.Dependencies {
+		from, ok := ids[dep.Ref]
+		if !ok {
+			continue
+		}
+		for _, ref := range dep.DependsOn {
+			to, ok := ids[ref]
+			if !ok {
+				continue
+			}
+			rel := &v2_3.Relationship{
+				RefA:         common.DocElementID{ElementRefID: from},
+				RefB:         common.DocElementID{ElementRefID: to},
+				Relationship: common.TypeRelationshipDependsOn,
+			}
+			// 可选依赖对应 OPTIONAL_DEPENDENCY_OF, 关系方向相反
+			if scopes[ref] == cyclonedx.ScopeOptional {
+				rel.RefA, rel.RefB = rel.RefB, rel.RefA
+				rel.Relationship = common.TypeRelationshipOptionalDependencyOf
+			}
+			doc.Relationships = append(doc.Relationships, rel)
+		}
+	}
+
+	if err := setVerificationCode(topPkg, doc.Files); err != nil {
+		return nil, err
+	}
+	doc.OtherLicenses = otherLicenses(licenseRefs(doc))
+	return doc, nil
+}
+
+// licenseRefs 收集文档中引用的 LicenseRef-, CycloneDX 中没有许可证全文, 名称使用 LicenseRef- 后的部分
+func licenseRefs(doc *v2_3.Document) []plugin.OtherLicense {
+	var exprs []string
+	for _, pkg := range doc.Packages {
+		exprs = append(exprs, pkg.PackageLicenseDeclared, pkg.PackageLicenseConcluded)
+	}
+	for _, file := range doc.Files {
+		exprs = append(exprs, file.LicenseConcluded)
+	}
+	var refs []plugin.OtherLicense
+	seen := make(map[string]bool)
+	for _, expr := range exprs {
+		for _, tok := range tokenizeLicenseExpr(expr) {
+			if strings.HasPrefix(tok, "LicenseRef-") && !seen[tok] {
+				seen[tok] = true
+				refs = append(refs, plugin.OtherLicense{ID: tok, Name: strings.TrimPrefix(tok, "LicenseRef-")})
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"bytes"
+	"deepin-sbom-tools/pkg/cyclonedx"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+func testChecksums(t *testing.T, content string) []common.Checksum {
+	t.Helper()
+	checksums, err := tool.GetChecksumsForReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checksums
+}
+
+// roundTripRelationships 列出转换前后都应保留的关系, 其他关系类型在 CycloneDX 中没有对应
+func roundTripRelationships(doc *v2_3.Document) []string {
+	var res []string
+	for _, rel := range doc.Relationships {
+		switch rel.Relationship {
+		case common.TypeRelationshipDescribe, common.TypeRelationshipContains,
+			common.TypeRelationshipDependsOn, common.TypeRelationshipOptionalDependencyOf:
+			res = append(res, string(rel.RefA.ElementRefID)+" "+rel.Relationship+" "+string(rel.RefB.ElementRefID))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func packagePURL(pkg *v2_3.Package) string {
+	for _, ref := range pkg.PackageExternalReferences {
+		if ref.RefType == common.TypePackageManagerPURL {
+			return ref.Locator
+		}
+	}
+	return ""
+}
+
+// TestCycloneDXRoundTrip SPDX 转换为 CycloneDX 并读回后, purl、摘要(包括保存为属性的 SM3)及依赖关系不变
+func TestCycloneDXRoundTrip(t *testing.T) {
+	pkg := plugin.PkgInfo{
+		Type:            "deb",
+		Name:            "hello",
+		Version:         "2.10-3",
+		Architecture:    "amd64",
+		Maintainer:      "Debian <hello@example.org>",
+		LicenseDeclared: "GPL-3.0-or-later",
+		Hash:            testChecksums(t, "hello.deb"),
+		Relations: []plugin.Relation{
+			{Type: plugin.RelationDepends, Alternatives: []plugin.Dependency{{Name: "libc6", Operator: ">=", Version: "2.34"}}},
+			{Type: plugin.RelationDepends, Alternatives: []plugin.Dependency{{Name: "default-mta"}, {Name: "mail-transport-agent"}}},
+			{Type: plugin.RelationRecommends, Alternatives: []plugin.Dependency{{Name: "hello-doc", Operator: "=", Version: "2.10-3"}}},
+		},
+		FileList: []*plugin.FileInfo{
+			{FileName: "/usr/bin/hello", Hash: testChecksums(t, "binary"), License: "GPL-3.0-or-later"},
+			{FileName: "/usr/share/doc/hello/copyright", Hash: testChecksums(t, "copyright"), License: "GPL-3.0-or-later"},
+		},
+		Packages: []plugin.PkgInfo{
+			{Type: "deb", Name: "libfoo1", Version: "1.0", Architecture: "amd64", Maintainer: "Foo", FileName: "debs/libfoo1.deb", Hash: testChecksums(t, "libfoo1.deb")},
+		},
+	}
+	want, err := CreateDocument(pkg, "https://example.org/spdx/", "deepin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bom := cyclonedx.FromSPDX(want)
+	if bom.Metadata.Component == nil || bom.Metadata.Component.PackageURL != packagePURL(want.Packages[0]) {
+		t.Fatalf("metadata component = %+v", bom.Metadata.Component)
+	}
+	// SM3 不是 CycloneDX 的摘要算法, 以属性保存
+	hasSM3 := false
+	for _, p := range bom.Metadata.Component.Properties {
+		if cyclonedx.IsHashProperty(p.Name) && strings.HasSuffix(p.Name, "SM3") {
+			hasSM3 = true
+		}
+	}
+	if !hasSM3 {
+		t.Errorf("no SM3 property in %+v", bom.Metadata.Component.Properties)
+	}
+
+	for _, format := range []string{FormatCycloneDXJSON, FormatCycloneDXXML} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteBOM(bom, &buf, format); err != nil {
+				t.Fatal(err)
+			}
+			read, err := ReadBOM(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := FromCycloneDX(read, "https://example.org/spdx/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantPkgs := make(map[common.ElementID]*v2_3.Package)
+			for _, p := range want.Packages {
+				wantPkgs[p.PackageSPDXIdentifier] = p
+			}
+			if len(got.Packages) != len(want.Packages) {
+				t.Errorf("got %d packages, want %d", len(got.Packages), len(want.Packages))
+			}
+			for _, p := range got.Packages {
+				w, ok := wantPkgs[p.PackageSPDXIdentifier]
+				if !ok {
+					t.Errorf("unexpected package %s %s", p.PackageSPDXIdentifier, p.PackageName)
+					continue
+				}
+				if packagePURL(p) != packagePURL(w) {
+					t.Errorf("%s: purl = %q, want %q", p.PackageName, packagePURL(p), packagePURL(w))
+				}
+				if !reflect.DeepEqual(p.PackageChecksums, w.PackageChecksums) {
+					t.Errorf("%s: checksums = %v, want %v", p.PackageName, p.PackageChecksums, w.PackageChecksums)
+				}
+			}
+
+			wantFiles := make(map[string][]common.Checksum)
+			for _, f := range want.Files {
+				wantFiles[f.FileName] = f.Checksums
+			}
+			if len(got.Files) != len(want.Files) {
+				t.Errorf("got %d files, want %d", len(got.Files), len(want.Files))
+			}
+			for _, f := range got.Files {
+				if !reflect.DeepEqual(f.Checksums, wantFiles[f.FileName]) {
+					t.Errorf("%s: checksums = %v, want %v", f.FileName, f.Checksums, wantFiles[f.FileName])
+				}
+			}
+			if got.Packages[0].PackageVerificationCode == nil ||
+				got.Packages[0].PackageVerificationCode.Value != want.Packages[0].PackageVerificationCode.Value {
+				t.Errorf("verification code = %v, want %v", got.Packages[0].PackageVerificationCode, want.Packages[0].PackageVerificationCode)
+			}
+
+			if g, w := roundTripRelationships(got), roundTripRelationships(want); !reflect.DeepEqual(g, w) {
+				t.Errorf("relationships = %q\nwant %q", g, w)
+			}
+		})
+	}
+}
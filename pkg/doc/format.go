@@ -0,0 +1,291 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"bytes"
+	"deepin-sbom-tools/pkg/cyclonedx"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/spdx"
+	"deepin-sbom-tools/pkg/tool"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/spdx/tools-golang/json"
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+	"github.com/spdx/tools-golang/tagvalue"
+	"github.com/spdx/tools-golang/yaml"
+)
+
+// sbom 文件格式
+const (
+	FormatSPDXJSON = "spdx-json"
+	FormatSPDXTV   = "spdx-tv"
+	FormatSPDXYAML = "spdx-yaml"
+	FormatSPDXRDF  = "spdx-rdf"
+
+	FormatSPDX3JSONLD = "spdx3-jsonld"
+
+	FormatCycloneDXJSON = "cyclonedx-json"
+	FormatCycloneDXXML  = "cyclonedx-xml"
+)
+
+// 各格式对应的文件扩展名
+var formatExts = map[string]string{
+	FormatSPDXJSON: ".spdx.json",
+	FormatSPDXTV:   ".spdx",
+	FormatSPDXYAML: ".spdx.yaml",
+	FormatSPDXRDF:  ".spdx.rdf.xml",
+
+	FormatSPDX3JSONLD: ".spdx3.json",
+
+	FormatCycloneDXJSON: ".cdx.json",
+	FormatCycloneDXXML:  ".cdx.xml",
+}
+
+// SPDX 2.3 规范中定义的摘要算法, tag-value 读取器会拒绝 SM3 等其他算法
+var spdxChecksumAlgos = map[common.ChecksumAlgorithm]bool{
+	common.SHA1: true, common.SHA224: true, common.SHA256: true, common.SHA384: true, common.SHA512: true,
+	common.MD2: true, common.MD4: true, common.MD5: true, common.MD6: true,
+	common.SHA3_256: true, common.SHA3_384: true, common.SHA3_512: true,
+	common.BLAKE2b_256: true, common.BLAKE2b_384: true, common.BLAKE2b_512: true, common.BLAKE3: true,
+	common.ADLER32: true,
+}
+
+// Formats 返回支持的格式名称列表
+func Formats() []string {
+	var formats []string
+	for k := range formatExts {
+		formats = append(formats, k)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// FormatExt 返回格式对应的文件扩展名
+func FormatExt(format string) (string, error) {
+	ext, ok := formatExts[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported format %s, available: %s", format, strings.Join(Formats(), ", "))
+	}
+	return ext, nil
+}
+
+// FormatFromName 根据文件扩展名推断格式, 多个扩展名匹配时取最长的一个
+func FormatFromName(name string) (string, bool) {
+	var format, matched string
+	for f, ext := range formatExts {
+		if strings.HasSuffix(name, ext) && len(ext) > len(matched) {
+			format, matched = f, ext
+		}
+	}
+	return format, format != ""
+}
+
+// FileName 生成 <name>_<version>_<arch><ext> 形式的文件名, 版本中的 epoch 与 dpkg 文件名一样省略
+func FileName(pkg plugin.PkgInfo, format string) (string, error) {
+	ext, err := FormatExt(format)
+	if err != nil {
+		return "", err
+	}
+	version := pkg.Version
+	if idx := strings.Index(version, ":"); idx >= 0 {
+		version = version[idx+1:]
+	}
+	name := pkg.Name
+	if version != "" {
+		name += "_" + version
+	}
+	if pkg.Architecture != "" {
+		name += "_" + pkg.Architecture
+	}
+	return strings.Replace(name, "/", "_", -1) + ext, nil
+}
+
+// WriteDocument 按指定格式输出 SPDX 文档
+func WriteDocument(doc *v2_3.Document, w io.Writer, format string) error {
+	switch format {
+	case FormatSPDXJSON:
+		return json.Write(withDocumentRefPrefix(doc), w, json.EscapeHTML(false), json.Indent("\t"))
+	case FormatSPDXTV:
+		return tagvalue.Write(withSpecChecksums(doc), w)
+	case FormatSPDXYAML:
+		return yaml.Write(withDocumentRefPrefix(doc), w)
+	case FormatSPDXRDF:
+		return writeRDF(doc, w)
+	}
+	return notSPDXFormat(format)
+}
+
+// ReadDocument 按指定格式读取 SPDX 文档
+func ReadDocument(r io.Reader, format string) (*v2_3.Document, error) {
+	switch format {
+	case FormatSPDXJSON:
+		return trimDocumentRefPrefix(json.Read(r))
+	case FormatSPDXTV:
+		return tagvalue.Read(r)
+	case FormatSPDXYAML:
+		return trimDocumentRefPrefix(yaml.Read(r))
+	case FormatSPDXRDF:
+		return readRDF(r)
+	}
+	return nil, notSPDXFormat(format)
+}
+
+// IsSBOMFile 根据扩展名判断是否为 ReadSBOMFile 能读取的文件
+func IsSBOMFile(name string) bool {
+	format, ok := FormatFromName(name)
+	return strings.HasSuffix(name, ".deb") || (ok && !IsSPDX3(format))
+}
+
+// ReadSBOMFile 按扩展名读取 sbom 文件, CycloneDX 格式转换为 SPDX 文档, deb 包读取其中嵌入的 sbom;
+// 同时返回文件原始内容, 用于计算文档摘要
+func ReadSBOMFile(name string, namespaceBase string) (*v2_3.Document, []byte, error) {
+	var data []byte
+	var err error
+	format, _ := FormatFromName(name)
+	if !IsSBOMFile(name) {
+		return nil, nil, fmt.Errorf("%s is not a supported sbom file", name)
+	} else if strings.HasSuffix(name, ".deb") {
+		format = FormatSPDXJSON
+		data, err = tool.ExtractDebSBOM(name)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsCycloneDX(format) {
+		document, err := ReadDocument(bytes.NewReader(data), format)
+		return document, data, err
+	}
+	bom, err := ReadBOM(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := FromCycloneDX(bom, namespaceBase)
+	return document, data, err
+}
+
+func notSPDXFormat(format string) error {
+	if _, err := FormatExt(format); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s is not a SPDX 2.3 format", format)
+}
+
+// IsSPDX3 判断是否为 SPDX 3.0 格式
+func IsSPDX3(format string) bool {
+	return format == FormatSPDX3JSONLD
+}
+
+// WriteSPDX3 按指定格式输出 SPDX 3.0 文档
+func WriteSPDX3(document *spdx.Document, w io.Writer, format string) error {
+	if format == FormatSPDX3JSONLD {
+		return spdx.WriteJSONLD(document, w)
+	}
+	if _, err := FormatExt(format); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s is not a SPDX 3.0 format", format)
+}
+
+// IsCycloneDX 判断是否为 CycloneDX 格式
+func IsCycloneDX(format string) bool {
+	return format == FormatCycloneDXJSON || format == FormatCycloneDXXML
+}
+
+// WriteBOM 按指定格式输出 CycloneDX 文档
+func WriteBOM(bom *cyclonedx.BOM, w io.Writer, format string) error {
+	switch format {
+	case FormatCycloneDXJSON:
+		return cyclonedx.WriteJSON(bom, w)
+	case FormatCycloneDXXML:
+		return cyclonedx.WriteXML(bom, w)
+	}
+	return notCycloneDXFormat(format)
+}
+
+// ReadBOM 按指定格式读取 CycloneDX 文档
+func ReadBOM(r io.Reader, format string) (*cyclonedx.BOM, error) {
+	switch format {
+	case FormatCycloneDXJSON:
+		return cyclonedx.ReadJSON(r)
+	case FormatCycloneDXXML:
+		return cyclonedx.ReadXML(r)
+	}
+	return nil, notCycloneDXFormat(format)
+}
+
+func notCycloneDXFormat(format string) error {
+	if _, err := FormatExt(format); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s is not a CycloneDX format", format)
+}
+
+// withDocumentRefPrefix 返回 externalDocumentId 带有 DocumentRef- 前缀的文档副本, 不修改原文档;
+// DocumentRefID 不含前缀, tag-value 输出时会加上, 而 JSON 与 YAML 原样输出
+func withDocumentRefPrefix(doc *v2_3.Document) *v2_3.Document {
+	if len(doc.ExternalDocumentReferences) == 0 {
+		return doc
+	}
+	res := *doc
+	res.ExternalDocumentReferences = nil
+	for _, ref := range doc.ExternalDocumentReferences {
+		ref.DocumentRefID = documentRefPrefix + strings.TrimPrefix(ref.DocumentRefID, documentRefPrefix)
+		res.ExternalDocumentReferences = append(res.ExternalDocumentReferences, ref)
+	}
+	return &res
+}
+
+// trimDocumentRefPrefix 去掉读取的 externalDocumentId 中的 DocumentRef- 前缀, 与关系中引用的外部文档 ID 一致
+func trimDocumentRefPrefix(doc *v2_3.Document, err error) (*v2_3.Document, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range doc.ExternalDocumentReferences {
+		ref := &doc.ExternalDocumentReferences[i]
+		ref.DocumentRefID = strings.TrimPrefix(ref.DocumentRefID, documentRefPrefix)
+	}
+	return doc, nil
+}
+
+// withSpecChecksums 返回只包含规范内摘要算法的文档副本, 不修改原文档
+func withSpecChecksums(doc *v2_3.Document) *v2_3.Document {
+	filter := func(checksums []common.Checksum) []common.Checksum {
+		var res []common.Checksum
+		for _, c := range checksums {
+			if spdxChecksumAlgos[c.Algorithm] {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+	filterFiles := func(files []*v2_3.File) []*v2_3.File {
+		var res []*v2_3.File
+		for _, f := range files {
+			file := *f
+			file.Checksums = filter(f.Checksums)
+			res = append(res, &file)
+		}
+		return res
+	}
+
+	res := *doc
+	res.Files = filterFiles(doc.Files)
+	res.Packages = nil
+	for _, p := range doc.Packages {
+		pkg := *p
+		pkg.PackageChecksums = filter(p.PackageChecksums)
+		pkg.Files = filterFiles(p.Files)
+		res.Packages = append(res.Packages, &pkg)
+	}
+	return &res
+}
@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"crypto/sha1"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"deepin-sbom-tools/pkg/version"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// IndexInput 参与生成索引的 sbom 文档及其原始内容
+type IndexInput struct {
+	Document *v2_3.Document
+	Data     []byte
+}
+
+// IndexStats 索引中依赖的解析结果
+type IndexStats struct {
+	Resolved   int //解析到具体文档的依赖
+	Unresolved int //仓库中没有满足条件的软件包
+	Sources    int //关联到源码包的二进制包
+}
+
+// indexTarget 可以满足依赖的软件包, 即某个文档描述的软件包或其 Provides 的虚包
+type indexTarget struct {
+	element common.DocElementID
+	version string //虚包没有版本时为空, 只能满足没有版本约束的依赖
+}
+
+// documentRefPrefix 外部文档 ID 的前缀, DocumentRefID 中不含该前缀
+const documentRefPrefix = "DocumentRef-"
+
+var documentRefInvalidChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// documentRefID 由文档名生成 DocumentRef- 之后的部分, 重名时加上序号
+func documentRefID(name string, used map[string]bool) string {
+	id := strings.Trim(documentRefInvalidChars.ReplaceAllString(name, "-"), "-")
+	if id == "" {
+		id = "document"
+	}
+	ref := id
+	for i := 2; used[ref]; i++ {
+		ref = fmt.Sprintf("%s-%d", id, i)
+	}
+	used[ref] = true
+	return ref
+}
+
+// describedPackages 返回文档描述的软件包
+func describedPackages(doc *v2_3.Document) []*v2_3.Package {
+	var pkgs []*v2_3.Package
+	for _, rel := range doc.Relationships {
+		if rel.Relationship != common.TypeRelationshipDescribe || rel.RefB.DocumentRefID != "" {
+			continue
+		}
+		for _, pkg := range doc.Packages {
+			if pkg.PackageSPDXIdentifier == rel.RefB.ElementRefID {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	return pkgs
+}
+
+// dependencyConstraint 返回依赖节点上的版本约束, 精确版本记录在版本中, 其他约束记录在注释中
+func dependencyConstraint(pkg *v2_3.Package) (string, string) {
+	if pkg.PackageVersion != "" {
+		return "=", pkg.PackageVersion
+	}
+	if strings.HasPrefix(pkg.PackageComment, versionConstraintComment) {
+		fields := strings.Fields(strings.TrimPrefix(pkg.PackageComment, versionConstraintComment))
+		if len(fields) == 2 {
+			return fields[0], fields[1]
+		}
+	}
+	return "", ""
+}
+
+// isSourcePackage 判断是否为源码包, 源码包不能满足依赖
+func isSourcePackage(pkg *v2_3.Package) bool {
+	return pkg.PrimaryPackagePurpose == "SOURCE"
+}
+
+// sourceOf 返回二进制包来源说明中的源码包名与版本, 见 plugin.PkgInfo.SourceInfo
+func sourceOf(pkg *v2_3.Package) (string, string, bool) {
+	if !strings.HasPrefix(pkg.PackageSourceInfo, plugin.SourceInfoPrefix) {
+		return "", "", false
+	}
+	fields := strings.Fields(strings.TrimPrefix(pkg.PackageSourceInfo, plugin.SourceInfoPrefix))
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// isProvides 判断是否为 Provides 关系, 见 relationshipOf
+func isProvides(rel *v2_3.Relationship) bool {
+	return rel.Relationship == common.TypeRelationshipOther &&
+		strings.HasPrefix(rel.RelationshipComment, plugin.RelationProvides+": ")
+}
+
+// CreateIndexDocument 生成仓库级的索引文档, 通过 ExternalDocumentRefs 引用各软件包的 sbom,
+// 并将各文档中的依赖节点解析为仓库中满足版本约束的具体软件包, 多个软件包满足时取版本最高的;
+// 版本按 deb 的规则比较。二进制包的来源与仓库中的源码包名称、版本一致时, 生成二进制包 GENERATED_FROM 源码包的关系
+func CreateIndexDocument(name string, inputs []IndexInput, namespaceBase string) (*v2_3.Document, IndexStats, error) {
+	var stats IndexStats
+	doc := &v2_3.Document{
+		SPDXVersion:       v2_3.Version,
+		DataLicense:       v2_3.DataLicense,
+		SPDXIdentifier:    "DOCUMENT",
+		DocumentName:      name,
+		DocumentNamespace: namespaceBase + name,
+		CreationInfo: &v2_3.CreationInfo{
+			Creators: []common.Creator{{
+				Creator:     fmt.Sprintf("deepin-sbom-tools_" + version.VERSION),
+				CreatorType: "Tool",
+			}},
+			Created: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	refs := make([]string, len(inputs))
+	used := make(map[string]bool)
+	targets := make(map[string][]indexTarget)
+	sources := make(map[string]common.DocElementID)
+	for i, input := range inputs {
+		d := input.Document
+		if d.DocumentNamespace == "" {
+			return nil, stats, fmt.Errorf("document %s has no namespace", d.DocumentName)
+		}
+		refs[i] = documentRefID(d.DocumentName, used)
+		doc.ExternalDocumentReferences = append(doc.ExternalDocumentReferences, v2_3.ExternalDocumentRef{
+			DocumentRefID: refs[i],
+			URI:           d.DocumentNamespace,
+			Checksum:      common.Checksum{Algorithm: common.SHA1, Value: fmt.Sprintf("%x", sha1.Sum(input.Data))},
+		})
+
+		described := make(map[common.ElementID]bool)
+		for _, pkg := range describedPackages(d) {
+			described[pkg.PackageSPDXIdentifier] = true
+			element := common.MakeDocElementID(refs[i], string(pkg.PackageSPDXIdentifier))
+			doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+				RefA:         common.MakeDocElementID("", string(doc.SPDXIdentifier)),
+				RefB:         element,
+				Relationship: common.TypeRelationshipDescribe,
+			})
+			if isSourcePackage(pkg) {
+				sources[pkg.PackageName+" "+pkg.PackageVersion] = element
+				continue
+			}
+			targets[pkg.PackageName] = append(targets[pkg.PackageName], indexTarget{element: element, version: pkg.PackageVersion})
+		}
+		// 虚包由提供它的软件包满足, 带版本的 Provides 记录在依赖节点的版本中
+		for _, rel := range d.Relationships {
+			if !isProvides(rel) || !described[rel.RefA.ElementRefID] {
+				continue
+			}
+			for _, pkg := range d.Packages {
+				if pkg.PackageSPDXIdentifier == rel.RefB.ElementRefID {
+					element := common.MakeDocElementID(refs[i], string(rel.RefA.ElementRefID))
+					targets[pkg.PackageName] = append(targets[pkg.PackageName], indexTarget{element: element, version: pkg.PackageVersion})
+				}
+			}
+		}
+	}
+
+	for i, input := range inputs {
+		d := input.Document
+		described := make(map[common.ElementID]bool)
+		for _, pkg := range describedPackages(d) {
+			described[pkg.PackageSPDXIdentifier] = true
+			name, version, ok := sourceOf(pkg)
+			if !ok {
+				continue
+			}
+			if src, ok := sources[name+" "+version]; ok {
+				stats.Sources++
+				doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+					RefA:         common.MakeDocElementID(refs[i], string(pkg.PackageSPDXIdentifier)),
+					RefB:         src,
+					Relationship: common.TypeRelationshipGeneratedFrom,
+				})
+			}
+		}
+		// 依赖节点解析到的软件包, 未能解析的为空
+		resolved := make(map[common.ElementID]*common.DocElementID)
+		for _, pkg := range d.Packages {
+			if described[pkg.PackageSPDXIdentifier] {
+				continue
+			}
+			op, ver := dependencyConstraint(pkg)
+			var best *indexTarget
+			for j, t := range targets[pkg.PackageName] {
+				if op != "" && (t.version == "" || !tool.DebVersionSatisfies(t.version, op, ver)) {
+					continue
+				}
+				if best == nil || (t.version != "" && tool.CompareDebVersions(t.version, best.version) > 0) {
+					best = &targets[pkg.PackageName][j]
+				}
+			}
+			if best == nil {
+				resolved[pkg.PackageSPDXIdentifier] = nil
+				continue
+			}
+			resolved[pkg.PackageSPDXIdentifier] = &best.element
+		}
+
+		// 将依赖关系中的依赖节点替换为解析到的软件包; Provides、Breaks 等 OTHER 关系不是依赖, 不处理
+		local := func(id common.DocElementID) common.DocElementID {
+			return common.MakeDocElementID(refs[i], string(id.ElementRefID))
+		}
+		for _, rel := range d.Relationships {
+			if rel.Relationship == common.TypeRelationshipOther || rel.Relationship == common.TypeRelationshipDescribe ||
+				rel.Relationship == common.TypeRelationshipContains {
+				continue
+			}
+			a, aDep := resolved[rel.RefA.ElementRefID]
+			b, bDep := resolved[rel.RefB.ElementRefID]
+			if aDep == bDep {
+				continue
+			}
+			if (aDep && a == nil) || (bDep && b == nil) {
+				stats.Unresolved++
+				continue
+			}
+			r := &v2_3.Relationship{
+				RefA:                local(rel.RefA),
+				RefB:                local(rel.RefB),
+				Relationship:        rel.Relationship,
+				RelationshipComment: rel.RelationshipComment,
+			}
+			if aDep {
+				r.RefA = *a
+			} else {
+				r.RefB = *b
+			}
+			stats.Resolved++
+			doc.Relationships = append(doc.Relationships, r)
+		}
+	}
+	return doc, stats, nil
+}
@@ -0,0 +1,633 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"bufio"
+	"bytes"
+	"deepin-sbom-tools/pkg/log"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/spdx/tools-golang/rdf"
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+const (
+	nsSPDX     = "http://spdx.org/rdf/terms#"
+	nsLicenses = "http://spdx.org/licenses/"
+)
+
+// rdf 本体中定义的摘要算法, SM3 等其他算法无法表示
+var rdfChecksumAlgos = map[common.ChecksumAlgorithm]string{
+	common.SHA1:   "sha1",
+	common.SHA224: "sha224",
+	common.SHA256: "sha256",
+	common.SHA384: "sha384",
+	common.SHA512: "sha512",
+	common.MD2:    "md2",
+	common.MD4:    "md4",
+	common.MD5:    "md5",
+	common.MD6:    "md6",
+}
+
+// rdfElementURI RDF/XML 中引用的元素 URI: 命名空间#SPDXRef-ID
+var rdfElementURI = regexp.MustCompile(`rdf:(?:about|resource)="([^"#]*)#SPDXRef-([^"]*)"`)
+
+// readRDF 读取 RDF/XML 格式的文档; tools-golang 只取元素 URI 中 # 之后的部分,
+// 外部文档中的元素因此被当作本文档的元素, 这里按 URI 的命名空间找回对应的外部文档
+func readRDF(r io.Reader) (*v2_3.Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := trimDocumentRefPrefix(rdf.Read(bytes.NewReader(data)))
+	if err != nil || len(doc.ExternalDocumentReferences) == 0 {
+		return doc, err
+	}
+	refs := make(map[string]string)
+	for _, ref := range doc.ExternalDocumentReferences {
+		refs[ref.URI] = ref.DocumentRefID
+	}
+	externals := make(map[common.ElementID]string)
+	for _, m := range rdfElementURI.FindAllSubmatch(data, -1) {
+		if ref, ok := refs[html.UnescapeString(string(m[1]))]; ok {
+			externals[common.ElementID(html.UnescapeString(string(m[2])))] = ref
+		}
+	}
+	locals := make(map[common.ElementID]bool)
+	for _, pkg := range doc.Packages {
+		locals[pkg.PackageSPDXIdentifier] = true
+	}
+	for _, file := range doc.Files {
+		locals[file.FileSPDXIdentifier] = true
+	}
+	resolve := func(id *common.DocElementID) {
+		if ref, ok := externals[id.ElementRefID]; ok && id.DocumentRefID == "" && id.SpecialID == "" && !locals[id.ElementRefID] {
+			id.DocumentRefID = ref
+		}
+	}
+	for _, rel := range doc.Relationships {
+		resolve(&rel.RefA)
+		resolve(&rel.RefB)
+	}
+	return doc, nil
+}
+
+// rdfWriter 以 RDF/XML 形式输出 SPDX 2.3 文档。
+// gordf 不会把 rdf:resource 引用解析到 rdf:about 定义的节点, 因此包和文件在第一次被引用处
+// 内嵌定义, 之后的引用使用 rdf:resource, 与 tools-golang 的 rdf 读取器兼容
+type rdfWriter struct {
+	w        *bufio.Writer
+	ns       string
+	doc      *v2_3.Document
+	indent   int
+	packages map[common.ElementID]*v2_3.Package
+	files    map[common.ElementID]*v2_3.File
+	written  map[common.ElementID]bool
+}
+
+func writeRDF(doc *v2_3.Document, w io.Writer) error {
+	rw := &rdfWriter{
+		w:        bufio.NewWriter(w),
+		ns:       doc.DocumentNamespace,
+		doc:      doc,
+		packages: map[common.ElementID]*v2_3.Package{},
+		files:    map[common.ElementID]*v2_3.File{},
+		written:  map[common.ElementID]bool{},
+	}
+	for _, pkg := range doc.Packages {
+		rw.packages[pkg.PackageSPDXIdentifier] = pkg
+		for _, file := range pkg.Files {
+			rw.files[file.FileSPDXIdentifier] = file
+		}
+	}
+	for _, file := range doc.Files {
+		rw.files[file.FileSPDXIdentifier] = file
+	}
+	rw.writeDocument()
+	return rw.w.Flush()
+}
+
+func (rw *rdfWriter) line(format string, args ...interface{}) {
+	rw.w.WriteString(strings.Repeat("  ", rw.indent))
+	fmt.Fprintf(rw.w, format, args...)
+	rw.w.WriteByte('\n')
+}
+
+func (rw *rdfWriter) open(tag string, attrs string) {
+	if attrs != "" {
+		rw.line("<%s %s>", tag, attrs)
+	} else {
+		rw.line("<%s>", tag)
+	}
+	rw.indent++
+}
+
+func (rw *rdfWriter) close(tag string) {
+	rw.indent--
+	rw.line("</%s>", tag)
+}
+
+func (rw *rdfWriter) literal(tag, value string) {
+	if value == "" {
+		return
+	}
+	rw.line("<%s>%s</%s>", tag, xmlText(value), tag)
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlText 转义文本内容, 与 xml.EscapeText 不同, 保留换行等空白字符
+func xmlText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func (rw *rdfWriter) resource(tag, uri string) {
+	rw.line(`<%s rdf:resource="%s"/>`, tag, escapeXML(uri))
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// elementURI 将元素 ID 转换为 URI, 外部文档中的元素使用外部文档的命名空间
+func (rw *rdfWriter) elementURI(id common.DocElementID) string {
+	if id.SpecialID != "" {
+		return nsSPDX + strings.ToLower(id.SpecialID)
+	}
+	switch id.ElementRefID {
+	case "NOASSERTION", "NONE":
+		return nsSPDX + strings.ToLower(string(id.ElementRefID))
+	}
+	ns := rw.ns
+	if id.DocumentRefID != "" {
+		for _, ref := range rw.doc.ExternalDocumentReferences {
+			if ref.DocumentRefID == id.DocumentRefID {
+				ns = ref.URI
+				break
+			}
+		}
+	}
+	return ns + "#SPDXRef-" + string(id.ElementRefID)
+}
+
+func (rw *rdfWriter) idURI(id common.ElementID) string {
+	return rw.ns + "#SPDXRef-" + string(id)
+}
+
+func (rw *rdfWriter) writeDocument() {
+	doc := rw.doc
+	rw.w.WriteString(xml.Header)
+	rw.open("rdf:RDF", `xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" `+
+		`xmlns:rdfs="http://www.w3.org/2000/01/rdf-schema#" `+
+		`xmlns:doap="http://usefulinc.com/ns/doap#" `+
+		`xmlns:spdx="http://spdx.org/rdf/terms#"`)
+
+	rw.open("spdx:SpdxDocument", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.idURI(doc.SPDXIdentifier))))
+	rw.literal("spdx:specVersion", doc.SPDXVersion)
+	rw.resource("spdx:dataLicense", nsLicenses+doc.DataLicense)
+	rw.literal("spdx:name", doc.DocumentName)
+	for _, ref := range doc.ExternalDocumentReferences {
+		rw.open("spdx:externalDocumentRef", "")
+		rw.open("spdx:ExternalDocumentRef", "")
+		rw.literal("spdx:externalDocumentId", documentRefPrefix+ref.DocumentRefID)
+		rw.resource("spdx:spdxDocument", ref.URI)
+		rw.writeChecksum(ref.Checksum)
+		rw.close("spdx:ExternalDocumentRef")
+		rw.close("spdx:externalDocumentRef")
+	}
+	if doc.CreationInfo != nil {
+		rw.open("spdx:creationInfo", "")
+		rw.open("spdx:CreationInfo", "")
+		rw.literal("spdx:licenseListVersion", doc.CreationInfo.LicenseListVersion)
+		for _, c := range doc.CreationInfo.Creators {
+			rw.literal("spdx:creator", c.CreatorType+": "+c.Creator)
+		}
+		rw.literal("spdx:created", doc.CreationInfo.Created)
+		rw.literal("rdfs:comment", doc.CreationInfo.CreatorComment)
+		rw.close("spdx:CreationInfo")
+		rw.close("spdx:creationInfo")
+	}
+	rw.literal("rdfs:comment", doc.DocumentComment)
+	for _, l := range doc.OtherLicenses {
+		rw.open("spdx:hasExtractedLicensingInfo", "")
+		rw.open("spdx:ExtractedLicensingInfo", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.ns+"#"+l.LicenseIdentifier)))
+		rw.literal("spdx:licenseId", l.LicenseIdentifier)
+		rw.literal("spdx:extractedText", l.ExtractedText)
+		rw.literal("spdx:name", l.LicenseName)
+		for _, s := range l.LicenseCrossReferences {
+			rw.literal("rdfs:seeAlso", s)
+		}
+		rw.literal("rdfs:comment", l.LicenseComment)
+		rw.close("spdx:ExtractedLicensingInfo")
+		rw.close("spdx:hasExtractedLicensingInfo")
+	}
+	rw.writeRelationships(doc.SPDXIdentifier)
+	rw.close("spdx:SpdxDocument")
+
+	// 没有被引用的元素定义在顶层
+	for _, pkg := range doc.Packages {
+		if !rw.written[pkg.PackageSPDXIdentifier] {
+			rw.writePackage(pkg)
+		}
+	}
+	for _, file := range doc.Files {
+		if !rw.written[file.FileSPDXIdentifier] {
+			rw.writeFile(file)
+		}
+	}
+	rw.close("rdf:RDF")
+}
+
+// writeRelationships 输出以 id 为主体的全部关系
+func (rw *rdfWriter) writeRelationships(id common.ElementID) {
+	for _, rel := range rw.doc.Relationships {
+		if rel.RefA.DocumentRefID != "" || rel.RefA.ElementRefID != id {
+			continue
+		}
+		rw.open("spdx:relationship", "")
+		rw.open("spdx:Relationship", "")
+		rw.resource("spdx:relationshipType", nsSPDX+"relationshipType_"+relationshipTypeName(rel.Relationship))
+		rw.writeElementRef("spdx:relatedSpdxElement", rel.RefB)
+		rw.literal("rdfs:comment", rel.RelationshipComment)
+		rw.close("spdx:Relationship")
+		rw.close("spdx:relationship")
+	}
+}
+
+// writeElementRef 引用一个元素, 本文档中尚未输出的包和文件在此处内嵌定义
+func (rw *rdfWriter) writeElementRef(tag string, id common.DocElementID) {
+	if id.DocumentRefID == "" && id.SpecialID == "" && !rw.written[id.ElementRefID] {
+		if pkg, ok := rw.packages[id.ElementRefID]; ok {
+			rw.open(tag, "")
+			rw.writePackage(pkg)
+			rw.close(tag)
+			return
+		}
+		if file, ok := rw.files[id.ElementRefID]; ok {
+			rw.open(tag, "")
+			rw.writeFile(file)
+			rw.close(tag)
+			return
+		}
+	}
+	rw.resource(tag, rw.elementURI(id))
+}
+
+// relationshipTypeName 将 DEPENDS_ON 形式转换为本体中的 dependsOn
+func relationshipTypeName(t string) string {
+	parts := strings.Split(strings.ToLower(t), "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func (rw *rdfWriter) writeChecksum(c common.Checksum) {
+	algo, ok := rdfChecksumAlgos[c.Algorithm]
+	if !ok {
+		log.Debug("checksum algorithm", c.Algorithm, "can not be expressed in rdf, skip")
+		return
+	}
+	rw.open("spdx:checksum", "")
+	rw.open("spdx:Checksum", "")
+	rw.resource("spdx:algorithm", nsSPDX+"checksumAlgorithm_"+algo)
+	rw.literal("spdx:checksumValue", c.Value)
+	rw.close("spdx:Checksum")
+	rw.close("spdx:checksum")
+}
+
+// writeText 输出 NONE/NOASSERTION 或文本
+func (rw *rdfWriter) writeText(tag, value string) {
+	switch value {
+	case "":
+		return
+	case "NONE", "NOASSERTION":
+		rw.resource(tag, nsSPDX+strings.ToLower(value))
+	default:
+		rw.literal(tag, value)
+	}
+}
+
+func (rw *rdfWriter) writePackage(pkg *v2_3.Package) {
+	rw.written[pkg.PackageSPDXIdentifier] = true
+	rw.open("spdx:Package", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.idURI(pkg.PackageSPDXIdentifier))))
+	rw.literal("spdx:name", pkg.PackageName)
+	rw.literal("spdx:versionInfo", pkg.PackageVersion)
+	rw.literal("spdx:packageFileName", pkg.PackageFileName)
+	if pkg.PackageSupplier != nil {
+		if pkg.PackageSupplier.Supplier == "NOASSERTION" {
+			rw.literal("spdx:supplier", "NOASSERTION")
+		} else {
+			rw.literal("spdx:supplier", pkg.PackageSupplier.SupplierType+": "+pkg.PackageSupplier.Supplier)
+		}
+	}
+	if pkg.PackageOriginator != nil {
+		if pkg.PackageOriginator.Originator == "NOASSERTION" {
+			rw.literal("spdx:originator", "NOASSERTION")
+		} else {
+			rw.literal("spdx:originator", pkg.PackageOriginator.OriginatorType+": "+pkg.PackageOriginator.Originator)
+		}
+	}
+	rw.writeText("spdx:downloadLocation", pkg.PackageDownloadLocation)
+	rw.literal("spdx:filesAnalyzed", fmt.Sprint(pkg.FilesAnalyzed))
+	if pkg.PackageVerificationCode != nil {
+		rw.open("spdx:packageVerificationCode", "")
+		rw.open("spdx:PackageVerificationCode", "")
+		rw.literal("spdx:packageVerificationCodeValue", pkg.PackageVerificationCode.Value)
+		for _, f := range pkg.PackageVerificationCode.ExcludedFiles {
+			rw.literal("spdx:packageVerificationCodeExcludedFile", f)
+		}
+		rw.close("spdx:PackageVerificationCode")
+		rw.close("spdx:packageVerificationCode")
+	}
+	for _, c := range pkg.PackageChecksums {
+		rw.writeChecksum(c)
+	}
+	if pkg.PackageHomePage != "" && pkg.PackageHomePage != "NONE" && pkg.PackageHomePage != "NOASSERTION" {
+		rw.resource("doap:homepage", pkg.PackageHomePage)
+	}
+	rw.literal("spdx:sourceInfo", pkg.PackageSourceInfo)
+	rw.writeLicense("spdx:licenseConcluded", pkg.PackageLicenseConcluded)
+	for _, l := range pkg.PackageLicenseInfoFromFiles {
+		rw.writeLicense("spdx:licenseInfoFromFiles", l)
+	}
+	rw.writeLicense("spdx:licenseDeclared", pkg.PackageLicenseDeclared)
+	rw.literal("spdx:licenseComments", pkg.PackageLicenseComments)
+	rw.writeText("spdx:copyrightText", pkg.PackageCopyrightText)
+	rw.literal("spdx:summary", pkg.PackageSummary)
+	rw.literal("spdx:description", pkg.PackageDescription)
+	rw.literal("rdfs:comment", pkg.PackageComment)
+	for _, ref := range pkg.PackageExternalReferences {
+		rw.open("spdx:externalRef", "")
+		rw.open("spdx:ExternalRef", "")
+		category := strings.Replace(strings.ToLower(ref.Category), "-", "", -1)
+		switch category {
+		case "packagemanager":
+			category = "packageManager"
+		case "persistentid":
+			category = "persistentId"
+		}
+		rw.resource("spdx:referenceCategory", nsSPDX+"referenceCategory_"+category)
+		rw.resource("spdx:referenceType", "http://spdx.org/rdf/references/"+ref.RefType)
+		rw.literal("spdx:referenceLocator", ref.Locator)
+		rw.literal("rdfs:comment", ref.ExternalRefComment)
+		rw.close("spdx:ExternalRef")
+		rw.close("spdx:externalRef")
+	}
+	for _, file := range pkg.Files {
+		rw.writeElementRef("spdx:hasFile", common.MakeDocElementID("", string(file.FileSPDXIdentifier)))
+	}
+	if pkg.PrimaryPackagePurpose != "" {
+		rw.resource("spdx:primaryPackagePurpose", nsSPDX+"purpose_"+strings.ToLower(strings.Replace(pkg.PrimaryPackagePurpose, "-", "_", -1)))
+	}
+	rw.literal("spdx:releaseDate", pkg.ReleaseDate)
+	rw.literal("spdx:builtDate", pkg.BuiltDate)
+	rw.literal("spdx:validUntilDate", pkg.ValidUntilDate)
+	rw.writeRelationships(pkg.PackageSPDXIdentifier)
+	rw.close("spdx:Package")
+}
+
+func (rw *rdfWriter) writeFile(file *v2_3.File) {
+	rw.written[file.FileSPDXIdentifier] = true
+	rw.open("spdx:File", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.idURI(file.FileSPDXIdentifier))))
+	rw.literal("spdx:fileName", file.FileName)
+	for _, t := range file.FileTypes {
+		rw.resource("spdx:fileType", nsSPDX+"fileType_"+strings.ToLower(t))
+	}
+	for _, c := range file.Checksums {
+		rw.writeChecksum(c)
+	}
+	rw.writeLicense("spdx:licenseConcluded", file.LicenseConcluded)
+	for _, l := range file.LicenseInfoInFiles {
+		rw.writeLicense("spdx:licenseInfoInFile", l)
+	}
+	rw.literal("spdx:licenseComments", file.LicenseComments)
+	rw.writeText("spdx:copyrightText", file.FileCopyrightText)
+	rw.literal("rdfs:comment", file.FileComment)
+	rw.literal("spdx:noticeText", file.FileNotice)
+	for _, c := range file.FileContributors {
+		rw.literal("spdx:fileContributor", c)
+	}
+	rw.writeRelationships(file.FileSPDXIdentifier)
+	rw.close("spdx:File")
+}
+
+// writeLicense 将许可证表达式转换为 rdf 许可证节点, 无法解析时记为 NOASSERTION
+func (rw *rdfWriter) writeLicense(tag, expr string) {
+	if expr == "" {
+		return
+	}
+	node, err := parseLicenseExpr(expr)
+	if err != nil {
+		log.Warning("invalid license expression", expr, err)
+		node = &licenseNode{id: "NOASSERTION"}
+	}
+	if node.op == "" {
+		rw.writeLicenseID(tag, node.id)
+		return
+	}
+	rw.open(tag, "")
+	rw.writeLicenseNode(node)
+	rw.close(tag)
+}
+
+// writeLicenseID 输出单个许可证, 以 + 结尾的许可证使用 OrLaterOperator
+func (rw *rdfWriter) writeLicenseID(tag, id string) {
+	if !strings.HasSuffix(id, "+") {
+		rw.resource(tag, rw.licenseURI(id))
+		return
+	}
+	id = strings.TrimSuffix(id, "+")
+	rw.open(tag, "")
+	rw.open("spdx:OrLaterOperator", "")
+	rw.open("spdx:member", "")
+	rw.open("spdx:License", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.licenseURI(id))))
+	rw.literal("spdx:licenseId", id)
+	rw.close("spdx:License")
+	rw.close("spdx:member")
+	rw.close("spdx:OrLaterOperator")
+	rw.close(tag)
+}
+
+func (rw *rdfWriter) licenseURI(id string) string {
+	switch id {
+	case "NONE", "NOASSERTION":
+		return nsSPDX + strings.ToLower(id)
+	}
+	if strings.HasPrefix(id, "LicenseRef-") {
+		return rw.ns + "#" + id
+	}
+	return nsLicenses + id
+}
+
+func (rw *rdfWriter) writeLicenseNode(node *licenseNode) {
+	switch node.op {
+	case "":
+		rw.writeLicenseID("spdx:member", node.id)
+	case "WITH":
+		rw.open("spdx:WithExceptionOperator", "")
+		rw.open("spdx:member", "")
+		rw.open("spdx:License", fmt.Sprintf(`rdf:about="%s"`, escapeXML(rw.licenseURI(node.args[0].id))))
+		rw.literal("spdx:licenseId", node.args[0].id)
+		rw.close("spdx:License")
+		rw.close("spdx:member")
+		rw.open("spdx:licenseException", "")
+		rw.open("spdx:LicenseException", "")
+		rw.literal("spdx:licenseExceptionId", node.args[1].id)
+		rw.close("spdx:LicenseException")
+		rw.close("spdx:licenseException")
+		rw.close("spdx:WithExceptionOperator")
+	default:
+		tag := "spdx:ConjunctiveLicenseSet"
+		if node.op == "OR" {
+			tag = "spdx:DisjunctiveLicenseSet"
+		}
+		rw.open(tag, "")
+		for _, arg := range node.args {
+			if arg.op == "" {
+				rw.writeLicenseID("spdx:member", arg.id)
+				continue
+			}
+			rw.open("spdx:member", "")
+			rw.writeLicenseNode(arg)
+			rw.close("spdx:member")
+		}
+		rw.close(tag)
+	}
+}
+
+// licenseNode 许可证表达式语法树, op 为空时表示单个许可证
+type licenseNode struct {
+	op   string
+	id   string
+	args []*licenseNode
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeLicenseExpr(expr string) []string {
+	expr = strings.Replace(expr, "(", " ( ", -1)
+	expr = strings.Replace(expr, ")", " ) ", -1)
+	return strings.Fields(expr)
+}
+
+// parseLicenseExpr 解析 SPDX 许可证表达式, 优先级 WITH > AND > OR
+func parseLicenseExpr(expr string) (*licenseNode, error) {
+	p := &licenseParser{tokens: tokenizeLicenseExpr(expr)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseParser) parseOr() (*licenseNode, error) {
+	return p.parseBinary("OR", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() (*licenseNode, error) {
+	return p.parseBinary("AND", p.parseWith)
+}
+
+func (p *licenseParser) parseBinary(op string, next func() (*licenseNode, error)) (*licenseNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	node := left
+	for p.peek() == op {
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if node.op != op {
+			node = &licenseNode{op: op, args: []*licenseNode{node}}
+		}
+		node.args = append(node.args, right)
+	}
+	return node, nil
+}
+
+func (p *licenseParser) parseWith() (*licenseNode, error) {
+	left, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "WITH" {
+		return left, nil
+	}
+	p.pos++
+	exception := p.peek()
+	if left.op != "" || !isLicenseID(exception) {
+		return nil, errors.New("invalid WITH expression")
+	}
+	p.pos++
+	return &licenseNode{op: "WITH", args: []*licenseNode{left, {id: exception}}}, nil
+}
+
+func (p *licenseParser) parseAtom() (*licenseNode, error) {
+	tok := p.peek()
+	switch {
+	case tok == "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return node, nil
+	case isLicenseID(tok):
+		p.pos++
+		return &licenseNode{id: tok}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %q", tok)
+}
+
+// isLicenseID 许可证 ID 只能包含字母、数字、'-'、'.'、':'(DocumentRef) 及结尾的 '+'
+func isLicenseID(tok string) bool {
+	switch tok {
+	case "", "AND", "OR", "WITH", "(", ")":
+		return false
+	}
+	for i, c := range tok {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.', c == ':':
+		case c == '+' && i == len(tok)-1:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/version"
+	"fmt"
+	"time"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// 系统文档中记录的软件包关系, Breaks、Conflicts 在已安装的系统中不成立, Built-Using 指向源码包
+var installedRelationTypes = map[string]bool{
+	plugin.RelationPreDepends: true,
+	plugin.RelationDepends:    true,
+	plugin.RelationRecommends: true,
+	plugin.RelationSuggests:   true,
+}
+
+// installedPackages 按名称查找已安装的软件包, 虚包由 Provides 提供
+type installedPackages struct {
+	ids   map[string][]common.ElementID //软件包名或虚包名对应的软件包
+	archs map[common.ElementID]string
+}
+
+func newInstalledPackages(pkgs []plugin.PkgInfo) *installedPackages {
+	installed := &installedPackages{
+		ids:   make(map[string][]common.ElementID),
+		archs: make(map[common.ElementID]string),
+	}
+	for _, p := range pkgs {
+		id := installedPackageID(p)
+		installed.archs[id] = p.Architecture
+		installed.ids[p.Name] = append(installed.ids[p.Name], id)
+		for _, rel := range p.Relations {
+			if rel.Type != plugin.RelationProvides {
+				continue
+			}
+			for _, dep := range rel.Alternatives {
+				installed.ids[dep.Name] = append(installed.ids[dep.Name], id)
+			}
+		}
+	}
+	return installed
+}
+
+// resolve 返回满足依赖的已安装软件包, 同名软件包有多个架构时优先选择与 arch 相同或 all 的
+func (s *installedPackages) resolve(dep plugin.Dependency, arch string) (common.ElementID, bool) {
+	ids := s.ids[dep.Name]
+	if len(ids) == 0 {
+		return "", false
+	}
+	for _, id := range ids {
+		if a := s.archs[id]; a == arch || a == "all" {
+			return id, true
+		}
+	}
+	return ids[0], true
+}
+
+// installedPackageID 已安装软件包的标识, 同一软件包可能安装多个架构
+func installedPackageID(p plugin.PkgInfo) common.ElementID {
+	return genSPDXIdentifier("PACKAGE", p.Name+":"+p.Architecture)
+}
+
+// CreateSystemDocument 生成整个系统的 SPDX 文档, system 描述操作系统本身,
+// 包含所有已安装的软件包及其文件; 依赖关系只记录到已安装的软件包, purlNS 为 purl 中的发行版命名空间
+func CreateSystemDocument(system plugin.PkgInfo, pkgs []plugin.PkgInfo, namespaceBase, purlNS string) (*v2_3.Document, error) {
+	docName := system.Name + "_" + system.Version
+	doc := &v2_3.Document{
+		SPDXVersion:       v2_3.Version,
+		DataLicense:       v2_3.DataLicense,
+		SPDXIdentifier:    "DOCUMENT",
+		DocumentName:      docName,
+		DocumentNamespace: namespaceBase + docName,
+		CreationInfo: &v2_3.CreationInfo{
+			Creators: []common.Creator{{
+				Creator:     fmt.Sprintf("deepin-sbom-tools_" + version.VERSION),
+				CreatorType: "Tool",
+			}},
+			Created: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	osPkg := packageOf(system, genSPDXIdentifier("OS", system.Name), purlNS)
+	osPkg.PackageSupplier = &common.Supplier{Supplier: "NOASSERTION"}
+	osPkg.PrimaryPackagePurpose = "OPERATING-SYSTEM"
+	doc.Packages = append(doc.Packages, osPkg)
+	doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+		RefA:         common.DocElementID{ElementRefID: doc.SPDXIdentifier},
+		RefB:         common.DocElementID{ElementRefID: osPkg.PackageSPDXIdentifier},
+		Relationship: "DESCRIBES",
+	})
+
+	installed := newInstalledPackages(pkgs)
+	files := make(map[common.ElementID]*v2_3.File)
+	licenses := newLicenseSet()
+	for _, p := range pkgs {
+		p = licenses.add(p)
+		pkg := packageOf(p, installedPackageID(p), purlNS)
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+			RefA:         common.DocElementID{ElementRefID: osPkg.PackageSPDXIdentifier},
+			RefB:         common.DocElementID{ElementRefID: pkg.PackageSPDXIdentifier},
+			Relationship: "CONTAINS",
+		})
+
+		// 同一个依赖可能出现在多个关系中, 每对软件包之间每种关系只记录一次
+		seen := make(map[string]bool)
+		for _, rel := range p.Relations {
+			if !installedRelationTypes[rel.Type] {
+				continue
+			}
+			// 已安装的第一个候选为依赖, 其余已安装的候选为可选依赖
+			alternative := false
+			for _, dep := range rel.Alternatives {
+				id, ok := installed.resolve(dep, p.Architecture)
+				if !ok || id == pkg.PackageSPDXIdentifier {
+					continue
+				}
+				if !seen[rel.Type+string(id)] {
+					seen[rel.Type+string(id)] = true
+					doc.Relationships = append(doc.Relationships, relationshipOf(rel, pkg.PackageSPDXIdentifier, id, alternative))
+				}
+				alternative = true
+			}
+		}
+
+		// Multi-Arch: same 的软件包之间共享文件, 文件只记录一次, 分别由各软件包包含
+		var pkgFiles []*v2_3.File
+		for _, v := range p.FileList {
+			file := fileOf(v)
+			if f, ok := files[file.FileSPDXIdentifier]; ok {
+				file = f
+			} else {
+				files[file.FileSPDXIdentifier] = file
+				doc.Files = append(doc.Files, file)
+			}
+			pkgFiles = append(pkgFiles, file)
+			doc.Relationships = append(doc.Relationships, &v2_3.Relationship{
+				RefA:         common.DocElementID{ElementRefID: pkg.PackageSPDXIdentifier},
+				RefB:         common.DocElementID{ElementRefID: file.FileSPDXIdentifier},
+				Relationship: "CONTAINS",
+			})
+		}
+		if err := setVerificationCode(pkg, pkgFiles); err != nil {
+			return nil, err
+		}
+		if pkg.FilesAnalyzed {
+			pkg.PackageLicenseInfoFromFiles = licenseInfoFromFiles(pkgFiles)
+		}
+	}
+	doc.OtherLicenses = licenses.licenses
+	return doc, nil
+}
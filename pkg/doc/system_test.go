@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"deepin-sbom-tools/pkg/plugin"
+	"reflect"
+	"testing"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// testLicensePackage 使用 LicenseRef-public-domain 的软件包, text 为空表示包中没有许可证全文
+func testLicensePackage(name, text string) plugin.PkgInfo {
+	const id = "LicenseRef-public-domain"
+	return plugin.PkgInfo{
+		Type: "deb", Name: name, Version: "1.0", Architecture: "amd64", Maintainer: "Debian",
+		LicenseDeclared: id + " AND MIT",
+		FileList: []*plugin.FileInfo{{
+			FileName:          "/usr/share/doc/" + name + "/copyright",
+			Hash:              []common.Checksum{{Algorithm: common.SHA1, Value: "da39a3ee5e6b4b0d3255bfef95601890afd80709"}},
+			License:           id,
+			LicenseInfoInFile: []string{id},
+		}},
+		OtherLicenses: []plugin.OtherLicense{{ID: id, Name: "public-domain", Text: text}},
+	}
+}
+
+// checkLicenseRefs 检查各软件包及其文件引用的 LicenseRef- 对应的全文, want 为软件包 ID 对应的全文
+func checkLicenseRefs(t *testing.T, doc *v2_3.Document, want map[common.ElementID]string) {
+	t.Helper()
+	texts := make(map[string]string)
+	for _, l := range doc.OtherLicenses {
+		if _, ok := texts[l.LicenseIdentifier]; ok {
+			t.Errorf("duplicate license %s", l.LicenseIdentifier)
+		}
+		texts[l.LicenseIdentifier] = l.ExtractedText
+	}
+	files := make(map[common.ElementID]*v2_3.File)
+	for _, f := range doc.Files {
+		files[f.FileSPDXIdentifier] = f
+	}
+	pkgs := make(map[common.ElementID]*v2_3.Package)
+	for _, p := range doc.Packages {
+		pkgs[p.PackageSPDXIdentifier] = p
+	}
+	refs := make(map[common.ElementID]string)
+	for _, p := range doc.Packages {
+		text, ok := want[p.PackageSPDXIdentifier]
+		if !ok {
+			continue
+		}
+		delete(want, p.PackageSPDXIdentifier)
+		id := tokenizeLicenseExpr(p.PackageLicenseDeclared)[0]
+		refs[p.PackageSPDXIdentifier] = id
+		if text == "" {
+			text = "NOASSERTION"
+		}
+		if texts[id] != text {
+			t.Errorf("%s: %s has text %q, want %q", p.PackageName, id, texts[id], text)
+		}
+		if p.PackageLicenseDeclared != id+" AND MIT" {
+			t.Errorf("%s: licenseDeclared = %q", p.PackageName, p.PackageLicenseDeclared)
+		}
+		if len(p.PackageLicenseInfoFromFiles) > 0 && !reflect.DeepEqual(p.PackageLicenseInfoFromFiles, []string{id}) {
+			t.Errorf("%s: licenseInfoFromFiles = %q, want [%s]", p.PackageName, p.PackageLicenseInfoFromFiles, id)
+		}
+	}
+	for _, rel := range doc.Relationships {
+		p, f := pkgs[rel.RefA.ElementRefID], files[rel.RefB.ElementRefID]
+		if rel.Relationship != common.TypeRelationshipContains || p == nil || f == nil {
+			continue
+		}
+		id, ok := refs[p.PackageSPDXIdentifier]
+		if !ok {
+			continue
+		}
+		if f.LicenseConcluded != id || !reflect.DeepEqual(f.LicenseInfoInFiles, []string{id}) {
+			t.Errorf("%s: license = %q, in file %q, want %s", f.FileName, f.LicenseConcluded, f.LicenseInfoInFiles, id)
+		}
+	}
+	for id := range want {
+		t.Errorf("package %s not found", id)
+	}
+}
+
+// TestCreateSystemDocumentLicenseRefs 不同软件包中同名的 LicenseRef- 全文不同时各自保留, 相同时合并
+func TestCreateSystemDocumentLicenseRefs(t *testing.T) {
+	pkgs := []plugin.PkgInfo{
+		testLicensePackage("base-passwd", ""),
+		testLicensePackage("libsqlite3-0", "The author disclaims copyright to this source code."),
+		testLicensePackage("tzdata", "This file is in the public domain."),
+		testLicensePackage("libc++1", "Placed in the public domain."),
+		testLicensePackage("libfoo", ""),
+		testLicensePackage("sqlite3", "The author disclaims copyright to this source code."),
+	}
+	// 改名后的 ID 已被其他软件包使用时再加序号
+	legacy := testLicensePackage("tzdata", "Public domain.")
+	legacy.Architecture = "all"
+	legacy.FileList[0].FileName = "/usr/share/doc/tzdata-legacy/copyright"
+	pkgs = append(pkgs, legacy)
+
+	system := plugin.PkgInfo{Name: "deepin", Version: "23"}
+	doc, err := CreateSystemDocument(system, pkgs, "https://example.org/spdx/", "deepin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range doc.OtherLicenses {
+		got = append(got, l.LicenseIdentifier)
+	}
+	wantIDs := []string{
+		"LicenseRef-public-domain",
+		"LicenseRef-libsqlite3-0-public-domain",
+		"LicenseRef-tzdata-public-domain",
+		"LicenseRef-libc-1-public-domain",
+		"LicenseRef-sqlite3-public-domain",
+		"LicenseRef-tzdata-public-domain-2",
+	}
+	if !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("other licenses = %q, want %q", got, wantIDs)
+	}
+	want := make(map[common.ElementID]string)
+	for _, p := range pkgs {
+		want[installedPackageID(p)] = p.OtherLicenses[0].Text
+	}
+	checkLicenseRefs(t, doc, want)
+
+	// 不修改传入的软件包
+	if pkgs[1].LicenseDeclared != "LicenseRef-public-domain AND MIT" || pkgs[1].FileList[0].License != "LicenseRef-public-domain" {
+		t.Errorf("input package modified: %+v", pkgs[1])
+	}
+}
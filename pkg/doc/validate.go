@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doc
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// documentRefPattern 许可证表达式中引用的外部文档, 如 DocumentRef-xxx:LicenseRef-yyy
+var documentRefPattern = regexp.MustCompile(documentRefPrefix + `([A-Za-z0-9.\-]+):`)
+
+// ValidateDocument 检查关系中引用的元素是否存在, 与 spdxlib.ValidateDocument 相同,
+// 但允许引用 ExternalDocumentRefs 中声明的外部文档的元素(DocumentRef-xxx:SPDXRef-yyy);
+// 关系及许可证中引用的外部文档都必须已声明
+func ValidateDocument(doc *v2_3.Document) error {
+	elements := make(map[common.ElementID]bool)
+	for _, pkg := range doc.Packages {
+		elements[pkg.PackageSPDXIdentifier] = true
+	}
+	for _, file := range doc.Files {
+		elements[file.FileSPDXIdentifier] = true
+	}
+	// 与 spdxlib 一样直接使用 DOCUMENT, rdf 读取的文档中没有设置 SPDXIdentifier
+	elements["DOCUMENT"] = true
+	externals := make(map[string]bool)
+	for _, ref := range doc.ExternalDocumentReferences {
+		externals[ref.DocumentRefID] = true
+	}
+
+	check := func(id common.DocElementID) error {
+		if id.SpecialID != "" {
+			return nil
+		}
+		if id.DocumentRefID != "" {
+			if !externals[id.DocumentRefID] {
+				return fmt.Errorf("DocumentRef-%s used in relationship but no such external document exists", id.DocumentRefID)
+			}
+			return nil
+		}
+		if !elements[id.ElementRefID] {
+			return fmt.Errorf("%s used in relationship but no such package exists", id.ElementRefID)
+		}
+		return nil
+	}
+	for _, rel := range doc.Relationships {
+		if err := check(rel.RefA); err != nil {
+			return err
+		}
+		if err := check(rel.RefB); err != nil {
+			return err
+		}
+	}
+
+	checkLicenses := func(id common.ElementID, licenses ...string) error {
+		for _, l := range licenses {
+			for _, m := range documentRefPattern.FindAllStringSubmatch(l, -1) {
+				if !externals[m[1]] {
+					return fmt.Errorf("%s%s used in license of %s but no such external document exists", documentRefPrefix, m[1], id)
+				}
+			}
+		}
+		return nil
+	}
+	for _, pkg := range doc.Packages {
+		licenses := append([]string{pkg.PackageLicenseConcluded, pkg.PackageLicenseDeclared}, pkg.PackageLicenseInfoFromFiles...)
+		if err := checkLicenses(pkg.PackageSPDXIdentifier, licenses...); err != nil {
+			return err
+		}
+	}
+	for _, file := range doc.Files {
+		licenses := append([]string{file.LicenseConcluded}, file.LicenseInfoInFiles...)
+		if err := checkLicenses(file.FileSPDXIdentifier, licenses...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package appimage
+
+import (
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 随应用打包的共享库所在的目录
+var libraryDirs = []string{"/usr/lib/", "/usr/lib64/", "/usr/lib32/", "/lib/", "/lib64/"}
+
+var errNoDesktop = errors.New("no .desktop file with a Name found in the AppImage root")
+
+// AppImage type 1(ISO 9660)及 type 2(squashfs)的 AppImage, 使用纯 Go 解析, 无需挂载或运行
+type AppImage struct {
+	appImageInfo plugin.PkgInfo
+	hashOpts     tool.HashOptions
+}
+
+// appImageEntry squashfs 与 ISO 9660 中条目的统一表示
+type appImageEntry struct {
+	path string
+	mode os.FileMode
+	size int64
+	link string
+	open func() io.Reader
+}
+
+// appImageFS AppImage 中内嵌的文件系统
+type appImageFS interface {
+	walk(fn func(e appImageEntry) error) error
+	readFile(name string) ([]byte, error)
+}
+
+type squashFS struct {
+	fs *tool.SquashFS
+}
+
+func (s *squashFS) walk(fn func(e appImageEntry) error) error {
+	return s.fs.Walk(func(e *tool.SquashFSEntry) error {
+		return fn(appImageEntry{path: e.Path, mode: e.Mode, size: e.Size, link: e.Link, open: e.Open})
+	})
+}
+
+func (s *squashFS) readFile(name string) ([]byte, error) {
+	return s.fs.ReadFile(name)
+}
+
+type isoFS struct {
+	iso *tool.ISO9660
+}
+
+func (i *isoFS) walk(fn func(e appImageEntry) error) error {
+	return i.iso.Walk(func(e *tool.ISO9660Entry) error {
+		return fn(appImageEntry{path: e.Path, mode: e.Mode, size: e.Size, link: e.Link, open: e.Open})
+	})
+}
+
+func (i *isoFS) readFile(name string) ([]byte, error) {
+	return i.iso.ReadFile(name)
+}
+
+func (a *AppImage) GetPMVersion() (string, error) {
+	output, err := exec.Command("appimagetool", "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (a *AppImage) GetPlugInfo() plugin.PlugInfo {
+	return plugin.PlugInfo{
+		PlugName: "APPIMAGE",
+		PlugVer:  "0.0.1",
+	}
+}
+
+func (a *AppImage) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "appimage", Extensions: []string{".AppImage"}, Description: "AppImage type 1 and type 2"}}
+}
+
+// Detect ELF 头中带有 AppImage 魔数
+func (a *AppImage) Detect(pkgPath string) int {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	defer f.Close()
+	if tool.AppImageType(f) == 0 {
+		return plugin.ConfidenceNone
+	}
+	return plugin.ConfidenceMagic
+}
+
+func (a *AppImage) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	info, err := tool.ReadAppImage(f, st.Size())
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+
+	var fs appImageFS
+	if info.Type == tool.AppImageType1 {
+		iso, err := tool.OpenISO9660(f)
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		fs = &isoFS{iso: iso}
+	} else {
+		if !tool.IsSquashFS(f, info.PayloadOffset) {
+			return plugin.PkgInfo{}, fmt.Errorf("no squashfs found at offset %d of %s", info.PayloadOffset, pkgPath)
+		}
+		sqfs, err := tool.OpenSquashFS(f, info.PayloadOffset)
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		defer sqfs.Close()
+		fs = &squashFS{fs: sqfs}
+	}
+
+	// 包文件hash, 同时记录 AppDir 根目录的 .desktop 文件及 metainfo 目录中的 AppStream 文件
+	stage, err := tool.NewHashStage(a.hashOpts)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer stage.Release()
+	var names []string
+	var desktop appImageEntry
+	var metainfo []string
+	err = fs.walk(func(e appImageEntry) error {
+		if strings.HasSuffix(e.path, ".desktop") && path.Dir(e.path) == "/" && desktop.path == "" {
+			desktop = e
+		}
+		if !e.mode.IsRegular() {
+			return nil
+		}
+		if dir := path.Dir(e.path); dir == "/usr/share/metainfo" || dir == "/usr/share/appdata" {
+			metainfo = append(metainfo, e.path)
+		}
+		if _, err := stage.Add(e.open(), e.size); err != nil {
+			return err
+		}
+		names = append(names, e.path)
+		return nil
+	})
+	checksums, waitErr := stage.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	if desktop.path == "" {
+		return plugin.PkgInfo{}, errNoDesktop
+	}
+
+	entry, err := readDesktopEntry(fs, desktop)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	id := strings.TrimSuffix(path.Base(desktop.path), ".desktop")
+	a.appImageInfo = pkgInfoFromDesktop(entry, readAppStream(fs, id, metainfo), info, filepath.Base(pkgPath))
+	res := a.appImageInfo
+
+	scans := stage.LicenseScans()
+	for i, name := range names {
+		file := &plugin.FileInfo{FileName: name, Hash: checksums[i]}
+		if scans[i] != nil {
+			file.SetLicenseScan(scans[i].Licenses, scans[i].Copyrights)
+		}
+		res.FileList = append(res.FileList, file)
+
+		// 共享库作为内嵌的组件, 符号链接不重复记录
+		if lib, version, ok := sharedLibrary(name); ok {
+			res.Packages = append(res.Packages, plugin.PkgInfo{
+				Name:             lib,
+				Version:          version,
+				Maintainer:       "NOASSERTION",
+				LicenseDeclared:  "NOASSERTION",
+				DownloadLocation: "NOASSERTION",
+				FileName:         name,
+				Hash:             checksums[i],
+			})
+		}
+	}
+
+	// AppStream 中的 project_license 为 SPDX 表达式
+	res.NormalizeLicenses(nil)
+	return res, nil
+}
+
+// sharedLibrary 判断文件是否为共享库目录中的共享库
+func sharedLibrary(name string) (lib, version string, ok bool) {
+	for _, dir := range libraryDirs {
+		if strings.HasPrefix(name, dir) {
+			return tool.ParseSharedLibraryName(path.Base(name))
+		}
+	}
+	return "", "", false
+}
+
+// readDesktopEntry 读取 .desktop 文件的 [Desktop Entry] 组, 根目录的 .desktop 常为指向 usr/share/applications 的符号链接
+func readDesktopEntry(fs appImageFS, desktop appImageEntry) (*tool.KeyFileGroup, error) {
+	name := desktop.path
+	if desktop.mode&os.ModeSymlink != 0 {
+		name = path.Join("/", path.Dir(name), desktop.link)
+	}
+	data, err := fs.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	g := tool.KeyFileGroupByName(tool.ParseKeyFile(data), "Desktop Entry")
+	if g == nil || g.Get("Name") == "" {
+		return nil, errNoDesktop
+	}
+	return g, nil
+}
+
+// readAppStream 优先读取与 .desktop 文件同名的 AppStream 文件, 否则依次尝试 metainfo 目录中的其他文件
+func readAppStream(fs appImageFS, id string, metainfo []string) *tool.AppStream {
+	candidates := []string{
+		"/usr/share/metainfo/" + id + ".appdata.xml",
+		"/usr/share/metainfo/" + id + ".metainfo.xml",
+		"/usr/share/appdata/" + id + ".appdata.xml",
+	}
+	for _, name := range append(candidates, metainfo...) {
+		data, err := fs.readFile(name)
+		if err != nil {
+			continue
+		}
+		as, err := tool.ParseAppStream(data, id)
+		if err != nil {
+			log.Warning("parse", name, "failed:", err)
+			continue
+		}
+		return as
+	}
+	return nil
+}
+
+// pkgInfoFromDesktop 名称取自 .desktop 文件, 与 appimagetool 生成的文件名一样将空格替换为下划线; 版本依次取 X-AppImage-Version、AppStream 及文件名中的版本
+func pkgInfoFromDesktop(entry *tool.KeyFileGroup, as *tool.AppStream, info *tool.AppImageInfo, fileName string) plugin.PkgInfo {
+	res := plugin.PkgInfo{
+		Type:             "appimage",
+		Name:             strings.Replace(entry.Get("Name"), " ", "_", -1),
+		Version:          entry.Get("X-AppImage-Version"),
+		Architecture:     entry.Get("X-AppImage-Arch"),
+		Maintainer:       "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		DownloadLocation: "NOASSERTION",
+		Description:      entry.Get("Comment"),
+	}
+	if res.Architecture == "" {
+		res.Architecture = info.Arch
+	}
+	if as != nil {
+		if res.Version == "" {
+			res.Version = as.Version
+		}
+		if as.Developer != "" {
+			res.Maintainer = as.Developer
+		}
+		if as.ProjectLicense != "" {
+			res.LicenseDeclared = as.ProjectLicense
+		}
+		res.Homepage = as.Homepage
+		if as.Summary != "" || as.Description != "" {
+			res.Description = strings.TrimSpace(as.Summary + "\n" + as.Description)
+		}
+	}
+	if res.Version == "" {
+		res.Version = fileNameVersion(fileName, res.Name, res.Architecture)
+	}
+
+	prop := func(name, value string) {
+		if value != "" {
+			res.Properties = append(res.Properties, plugin.Property{Name: "appimage:" + name, Value: value})
+		}
+	}
+	prop("type", strconv.Itoa(info.Type))
+	prop("exec", entry.Get("Exec"))
+	prop("categories", strings.TrimSuffix(entry.Get("Categories"), ";"))
+	prop("update-information", info.UpdateInfo)
+	if len(info.Signature) > 0 {
+		issuer, err := tool.PGPSignatureIssuer(info.Signature)
+		if err != nil {
+			log.Warning("parse AppImage signature failed:", err)
+			issuer = "unknown issuer"
+		}
+		prop("signature", issuer)
+	}
+	if len(info.SignatureKey) > 0 {
+		fingerprint, err := tool.PGPKeyFingerprint(info.SignatureKey)
+		if err != nil {
+			log.Warning("parse AppImage signing key failed:", err)
+			fingerprint = "unknown key"
+		}
+		prop("signing-key", fingerprint)
+	}
+	return res
+}
+
+// fileNameVersion 从 appimagetool 默认的 Name-version-arch.AppImage 文件名中取出版本
+func fileNameVersion(fileName, name, arch string) string {
+	base := strings.TrimSuffix(strings.TrimSuffix(fileName, ".AppImage"), ".appimage")
+	prefix := name + "-"
+	suffix := "-" + arch
+	if !strings.HasPrefix(base, prefix) || !strings.HasSuffix(base, suffix) || len(base) <= len(prefix)+len(suffix) {
+		return ""
+	}
+	return base[len(prefix) : len(base)-len(suffix)]
+}
+
+func New(opts tool.HashOptions) *AppImage {
+	appImage := &AppImage{hashOpts: opts}
+	return appImage
+}
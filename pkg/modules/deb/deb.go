@@ -5,19 +5,18 @@
 package deb
 
 import (
+	"archive/tar"
+	"bytes"
 	"deepin-sbom-tools/pkg/plugin"
 	"deepin-sbom-tools/pkg/tool"
-	"io/ioutil"
-	"os"
+	"io"
 	"os/exec"
-	"path"
-
-	"github.com/spdx/tools-golang/spdx/v2/common"
-	"github.com/spdx/tools-golang/utils"
+	"strings"
 )
 
 type Deb struct {
-	debInfo plugin.PkgInfo
+	debInfo  plugin.PkgInfo
+	hashOpts tool.HashOptions
 }
 
 func (d *Deb) GetPMVersion() (string, error) {
@@ -35,16 +34,19 @@ func (d *Deb) GetPlugInfo() plugin.PlugInfo {
 	}
 }
 
-func (d *Deb) IsValid(path string) bool {
-	_, err := d.GetPMVersion()
-	if err != nil {
-		return false
+func (d *Deb) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "deb", Extensions: []string{".deb", ".udeb", ".ddeb"}, Description: "Debian binary package"}}
+}
+
+// Detect 第一个成员为 debian-binary 的 ar 归档为 deb 包, 其他 ar 归档(如静态库)需要带有 deb 的扩展名
+func (d *Deb) Detect(path string) int {
+	if ok, err := tool.HasDebianBinary(path); err == nil && ok {
+		return plugin.ConfidenceContent
 	}
-	ok, err := tool.IsDebFile(path)
-	if err != nil {
-		return false
+	if ok, err := tool.IsDebFile(path); err == nil && ok && plugin.MatchExtension(d.FileTypes(), path) {
+		return plugin.ConfidenceMagic
 	}
-	return ok
+	return plugin.ConfidenceNone
 }
 
 func (d *Deb) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
@@ -52,79 +54,203 @@ func (d *Deb) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
 	if err != nil {
 		return plugin.PkgInfo{}, err
 	}
-	d.debInfo.Architecture = debCon.Architecture
-	d.debInfo.Name = debCon.Name
-	d.debInfo.Version = debCon.Version
-	d.debInfo.Section = debCon.Section
-	d.debInfo.Homepage = debCon.Homepage
-	d.debInfo.Maintainer = debCon.Maintainer
-	d.debInfo.Description = debCon.Description
-	d.debInfo.Depends = debCon.Depends
-	d.debInfo.InstalledSize = debCon.InstalledSize
+	d.debInfo = pkgInfoFromControl(debCon)
 
 	res := d.debInfo
 
-	// 包文件hash
-	tmpDir, err := ioutil.TempDir("/tmp", "*")
+	// 包文件hash, 流式读取 data.tar, 不解压到磁盘
+	copyright, err := d.hashByStream(pkgPath, &res)
 	if err != nil {
 		return res, err
 	}
-	defer func() {
-		os.RemoveAll(tmpDir)
-	}()
+	applyCopyright(copyright, &res)
+	return res, nil
+}
 
-	if err := exec.Command("dpkg-deb", "-R", pkgPath, tmpDir).Run(); err != nil {
-		return res, err
+// pkgInfoFromControl 由 control 信息生成软件包信息, 不包括文件及许可证
+func pkgInfoFromControl(debCon tool.DebControl) plugin.PkgInfo {
+	info := plugin.PkgInfo{
+		Type:          "deb",
+		Architecture:  debCon.Architecture,
+		Name:          debCon.Name,
+		Version:       debCon.Version,
+		Section:       debCon.Section,
+		Homepage:      debCon.Homepage,
+		Maintainer:    debCon.Maintainer,
+		Description:   debCon.Description,
+		InstalledSize: debCon.InstalledSize,
 	}
-	allFilePaths, err := utils.GetAllFilePaths(tmpDir, []string{"DEBIAN"})
-	if err != nil {
-		return res, err
+	// 没有 Source 字段时源码包与二进制包同名, Source 中没有版本时版本相同
+	info.Source, info.SourceVersion = tool.SplitDebSource(debCon.Source)
+	if info.Source == "" {
+		info.Source = debCon.Name
 	}
-	for _, f := range allFilePaths {
-		if sha1, sha256, md5, sm3, err := tool.GetHashesForFilePath(path.Join(tmpDir, f)); err != nil {
-			return res, err
-		} else {
-			res.FileList = append(res.FileList, &plugin.FileInfo{
-				FileName: f,
-				Hash: []common.Checksum{
-					{Algorithm: common.SHA1, Value: sha1},
-					{Algorithm: common.SHA256, Value: sha256},
-					{Algorithm: common.MD5, Value: md5},
-					{Algorithm: "SM3", Value: sm3},
-				},
-			})
+	if info.SourceVersion == "" {
+		info.SourceVersion = debCon.Version
+	}
+	info.Relations = relationsOf(debCon.Relations, tool.DebRelationFields)
+	return info
+}
+
+// relationsOf 按 fields 的顺序转换软件包关系
+func relationsOf(relations map[string][]tool.DebRelationGroup, fields []string) []plugin.Relation {
+	var res []plugin.Relation
+	for _, field := range fields {
+		for _, group := range relations[field] {
+			rel := plugin.Relation{Type: field}
+			for _, r := range group {
+				rel.Alternatives = append(rel.Alternatives, plugin.Dependency{
+					Name:     r.Name,
+					Arch:     r.ArchQualifier,
+					Operator: r.Operator,
+					Version:  r.Version,
+				})
+			}
+			res = append(res, rel)
 		}
 	}
-	//解压读取文件信息，解析文件license,读取copyright
-	//licensecheck:3.0.31-3
-	copyrightName := tmpDir + "/usr/share/doc/" + res.Name + "/copyright"
-	// out, err := exec.Command("licensecheck", "--copyright", "-m", "--deb-fmt", copyrightName).Output()
-	// if err != nil {
-	// 	return res, err
-	// }
-
-	// parts := strings.Split(strings.TrimRight(string(out), "\n"), "\t")
-	// for i, part := range parts {
-	// 	if i == 1 { //license
-	// 		if part == "UNKNOWN" {
-	// 			res.LicenseDeclared = "NOASSERTION"
-	// 		} else {
-	// 			res.LicenseDeclared = part
-	// 		}
-	// 	} else if i == 2 { //copyright
-	// 		res.Copyright = strings.Replace(part, "-format/1.0/", "", -1)
-	// 	}
-	// }
-	licenseStr := tool.FmtLicenses("AND", tool.GetLicenses(copyrightName))
-	if licenseStr == "" {
+	return res
+}
+
+// applyCopyright 根据 copyright 文件设置软件包及各文件的许可证与版权:
+// DEP-5 格式时按段落取各文件的许可证与版权, 否则扫描全文中的许可证
+func applyCopyright(copyright []byte, res *plugin.PkgInfo) {
+	applyCopyrightWith(copyright, res, (*tool.Dep5Copyright).Match)
+}
+
+// applyCopyrightWith 同 applyCopyright, match 为文件名与 DEP-5 段落的匹配方式, 安装路径与源码路径的匹配方式不同
+func applyCopyrightWith(copyright []byte, res *plugin.PkgInfo, match func(*tool.Dep5Copyright, string) *tool.Dep5Files) {
+	var licenseTexts map[string]string
+	if copyright != nil && tool.IsDep5(copyright) {
+		dep5 := tool.ParseDep5(copyright)
+		applyDep5(dep5, res, match)
+		licenseTexts = dep5.Licenses
+	} else if copyright != nil {
+		res.LicenseDeclared = tool.FmtLicenses("AND", tool.GetLicensesFromText(copyright))
+	}
+	if res.LicenseDeclared == "" {
 		res.LicenseDeclared = "NOASSERTION"
-	} else {
-		res.LicenseDeclared = licenseStr
 	}
-	return res, nil
+	res.NormalizeLicenses(licenseTexts)
+}
+
+// applyDep5 设置各文件的许可证与版权, 软件包的许可证与版权由包内文件匹配到的段落汇总而来,
+// 没有文件匹配时使用头部段落
+func applyDep5(c *tool.Dep5Copyright, res *plugin.PkgInfo, match func(*tool.Dep5Copyright, string) *tool.Dep5Files) {
+	var licenses, copyrights []string
+	seen := make(map[*tool.Dep5Files]bool)
+	for _, f := range res.FileList {
+		files := match(c, f.FileName)
+		if files == nil {
+			continue
+		}
+		f.License = files.License
+		if files.Copyright != "" {
+			f.Copyright = files.Copyright
+		}
+		if seen[files] {
+			continue
+		}
+		seen[files] = true
+		if files.License != "" && !containsString(licenses, files.License) {
+			licenses = append(licenses, files.License)
+		}
+		if files.Copyright != "" && !containsString(copyrights, files.Copyright) {
+			copyrights = append(copyrights, files.Copyright)
+		}
+	}
+	if len(licenses) == 0 && c.License != "" {
+		licenses = append(licenses, c.License)
+	}
+	if len(copyrights) == 0 && c.Copyright != "" {
+		copyrights = append(copyrights, c.Copyright)
+	}
+
+	// 复合表达式加上括号后再以 AND 连接
+	for i, l := range licenses {
+		if len(licenses) > 1 && strings.Contains(l, " ") {
+			licenses[i] = "(" + l + ")"
+		}
+	}
+	res.LicenseDeclared = strings.Join(licenses, " AND ")
+	res.Copyright = strings.Join(copyrights, "\n")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hashByStream 流式读取 data.tar 计算文件hash, 返回 copyright 文件内容
+func (d *Deb) hashByStream(pkgPath string, res *plugin.PkgInfo) ([]byte, error) {
+	stage, err := tool.NewHashStage(d.hashOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Release()
+
+	copyrightName := "/usr/share/doc/" + res.Name + "/copyright"
+	var copyright []byte
+	var names []string
+	var slots []int
+	index := make(map[string]int)
+	err = tool.WalkDebTar(pkgPath, "data.tar", func(hdr *tar.Header, r io.Reader) error {
+		name := tool.CleanTarPath(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			var reader io.Reader = r
+			var buf bytes.Buffer
+			if name == copyrightName {
+				reader = io.TeeReader(r, &buf)
+			}
+			slot, err := stage.Add(reader, hdr.Size)
+			if err != nil {
+				return err
+			}
+			if name == copyrightName {
+				copyright = buf.Bytes()
+			}
+			index[name] = slot
+		case tar.TypeLink:
+			// 硬链接与目标文件内容相同
+			slot, ok := index[tool.CleanTarPath(hdr.Linkname)]
+			if !ok {
+				return nil
+			}
+			index[name] = slot
+		default:
+			return nil
+		}
+		names = append(names, name)
+		slots = append(slots, index[name])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := stage.Wait()
+	if err != nil {
+		return nil, err
+	}
+	scans := stage.LicenseScans()
+	for i, name := range names {
+		f := &plugin.FileInfo{
+			FileName: name,
+			Hash:     checksums[slots[i]],
+		}
+		if scan := scans[slots[i]]; scan != nil {
+			f.SetLicenseScan(scan.Licenses, scan.Copyrights)
+		}
+		res.FileList = append(res.FileList, f)
+	}
+	return copyright, nil
 }
 
-func New() *Deb {
-	deb := new(Deb)
+func New(opts tool.HashOptions) *Deb {
+	deb := &Deb{hashOpts: opts}
 	return deb
 }
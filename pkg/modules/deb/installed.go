@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package deb
+
+import (
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// installedFile 已安装软件包中需要计算摘要的文件
+type installedFile struct {
+	pkg  int //所属软件包在结果中的序号
+	name string
+	slot int //摘要计算结果序号, 文件已被删除时为 -1
+	md5  string
+}
+
+// ParseInstalled 读取 root 下 dpkg 数据库中所有已安装的软件包, 文件摘要由本地文件计算,
+// 已被删除的文件使用 md5sums 中记录的摘要; 不依赖 dpkg 命令, root 可以是 chroot 或挂载的镜像
+func (d *Deb) ParseInstalled(root string) ([]plugin.PkgInfo, error) {
+	controls, err := tool.ReadDpkgStatus(root)
+	if err != nil {
+		return nil, err
+	}
+	stage, err := tool.NewHashStage(d.hashOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Release()
+
+	var pkgs []plugin.PkgInfo
+	var files []installedFile
+	for _, debCon := range controls {
+		names, md5sums, err := tool.DpkgPackageFiles(root, debCon.Name, debCon.Architecture)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			f := installedFile{pkg: len(pkgs), name: name, slot: -1, md5: md5sums[name]}
+			info, err := os.Lstat(filepath.Join(root, name))
+			if err == nil && !info.Mode().IsRegular() {
+				continue
+			}
+			if err == nil {
+				var r *os.File
+				if r, err = os.Open(filepath.Join(root, name)); err == nil {
+					f.slot, err = stage.Add(r, info.Size())
+					r.Close()
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			// 已被删除或无权读取的文件只有 md5sums 中的摘要, 配置文件没有摘要, 直接忽略
+			if err != nil {
+				log.Debug(debCon.Name, "file unreadable:", err)
+				if f.md5 == "" {
+					continue
+				}
+			}
+			files = append(files, f)
+		}
+		pkgs = append(pkgs, pkgInfoFromControl(debCon))
+	}
+	checksums, err := stage.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	scans := stage.LicenseScans()
+	for _, f := range files {
+		file := &plugin.FileInfo{FileName: f.name}
+		if f.slot < 0 {
+			file.Hash = []common.Checksum{{Algorithm: common.MD5, Value: f.md5}}
+		} else {
+			file.Hash = checksums[f.slot]
+			if scan := scans[f.slot]; scan != nil {
+				file.SetLicenseScan(scan.Licenses, scan.Copyrights)
+			}
+		}
+		pkgs[f.pkg].FileList = append(pkgs[f.pkg].FileList, file)
+	}
+	for i := range pkgs {
+		copyright, err := ioutil.ReadFile(filepath.Join(root, "usr/share/doc", pkgs[i].Name, "copyright"))
+		if err != nil {
+			copyright = nil
+		}
+		applyCopyright(copyright, &pkgs[i])
+	}
+	return pkgs, nil
+}
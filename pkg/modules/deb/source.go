@@ -0,0 +1,559 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// 源码树中需要读取内容的文件
+const (
+	sourceControl   = "./debian/control"
+	sourceChangelog = "./debian/changelog"
+	sourceCopyright = "./debian/copyright"
+)
+
+// 源码树中不属于源码包的目录: 版本控制目录及 quilt 的状态目录
+var sourceSkipDirs = map[string]bool{".git": true, ".svn": true, ".hg": true, ".bzr": true, ".pc": true}
+
+// DebSource deb 源码包, 支持 .dsc、orig/debian 压缩包及含有 debian/control 的源码目录
+type DebSource struct {
+	srcInfo  plugin.PkgInfo
+	hashOpts tool.HashOptions
+}
+
+func (d *DebSource) GetPMVersion() (string, error) {
+	output, err := exec.Command("dpkg-source", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (d *DebSource) GetPlugInfo() plugin.PlugInfo {
+	return plugin.PlugInfo{
+		PlugName: "DEBSRC",
+		PlugVer:  "0.0.1",
+	}
+}
+
+func (d *DebSource) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "debsrc", Extensions: []string{".dsc"}, Description: "Debian source package: .dsc, source tarball or unpacked source tree"}}
+}
+
+// Detect 含有 debian/control 的目录及带有 Source 字段的 .dsc 可以确认, 源码压缩包只能按文件名识别
+func (d *DebSource) Detect(pkgPath string) int {
+	info, err := os.Stat(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(pkgPath, "debian", "control")); err == nil {
+			return plugin.ConfidenceContent
+		}
+		return plugin.ConfidenceNone
+	}
+	if strings.HasSuffix(pkgPath, ".dsc") {
+		data, err := ioutil.ReadFile(pkgPath)
+		if err != nil {
+			return plugin.ConfidenceNone
+		}
+		paragraphs := tool.ParseDeb822(tool.StripPGPSignature(data))
+		if len(paragraphs) > 0 && paragraphs[0].Get("Source") != "" {
+			return plugin.ConfidenceContent
+		}
+		return plugin.ConfidenceName
+	}
+	if _, ok := tool.ParseDebSourceTarball(filepath.Base(pkgPath)); ok {
+		return plugin.ConfidenceName
+	}
+	return plugin.ConfidenceNone
+}
+
+func (d *DebSource) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	info, err := os.Stat(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	files, err := newSourceFiles(d.hashOpts)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer files.stage.Release()
+
+	var res plugin.PkgInfo
+	switch {
+	case info.IsDir():
+		if err := files.addTree(pkgPath); err != nil {
+			return res, err
+		}
+		res, err = files.treeInfo()
+	case strings.HasSuffix(pkgPath, ".dsc"):
+		res, err = files.addDsc(pkgPath)
+	default:
+		res, err = files.addTarball(pkgPath)
+	}
+	if err != nil {
+		return res, err
+	}
+	d.srcInfo = res
+
+	if err := files.fill(&res); err != nil {
+		return res, err
+	}
+	applyCopyrightWith(files.meta[sourceCopyright], &res, (*tool.Dep5Copyright).MatchSource)
+	return res, nil
+}
+
+// sourcePkgInfo 由 .dsc 或 debian/control 中的源码段落生成软件包信息, 版本来自 .dsc 或 debian/changelog
+func sourcePkgInfo(p tool.Deb822Paragraph, version string) plugin.PkgInfo {
+	info := plugin.PkgInfo{
+		Type:         "deb",
+		Name:         p.Get("Source"),
+		Version:      version,
+		Architecture: plugin.ArchSource,
+		Maintainer:   p.Get("Maintainer"),
+		Homepage:     p.Get("Homepage"),
+		Section:      p.Get("Section"),
+	}
+	relations := make(map[string][]tool.DebRelationGroup)
+	for _, field := range tool.DebSourceRelationFields {
+		groups, err := tool.ParseDebRelations(strings.Replace(p.Get(field), "\n", " ", -1))
+		if err != nil {
+			log.Warning("parse", field, "of", info.Name, "failed:", err)
+			continue
+		}
+		relations[field] = groups
+	}
+	info.Relations = relationsOf(relations, tool.DebSourceRelationFields)
+	return info
+}
+
+// sourceFiles 收集源码文件并计算摘要, 文件名为源码树中以 ./ 开头的相对路径
+type sourceFiles struct {
+	stage *tool.HashStage
+	names []string
+	slots map[string]int
+	meta  map[string][]byte //debian/control 等需要读取内容的文件
+}
+
+func newSourceFiles(opts tool.HashOptions) (*sourceFiles, error) {
+	stage, err := tool.NewHashStage(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceFiles{stage: stage, slots: make(map[string]int), meta: make(map[string][]byte)}, nil
+}
+
+// isSourceMeta 判断是否为需要读取内容的文件
+func isSourceMeta(name string) bool {
+	return name == sourceControl || name == sourceChangelog || name == sourceCopyright
+}
+
+// add 提交一个文件, 同名文件以后加入的为准, 与解包时 debian 压缩包覆盖 orig 中的内容一致
+func (s *sourceFiles) add(name string, r io.Reader, size int64) error {
+	var buf *bytes.Buffer
+	if isSourceMeta(name) {
+		buf = new(bytes.Buffer)
+		r = io.TeeReader(r, buf)
+	}
+	slot, err := s.stage.Add(r, size)
+	if err != nil {
+		return err
+	}
+	s.register(name, slot, buf)
+	return nil
+}
+
+// register 记录已提交的文件, content 为读取到的文件内容, 只保存 isSourceMeta 的文件
+func (s *sourceFiles) register(name string, slot int, content *bytes.Buffer) {
+	if content != nil && isSourceMeta(name) {
+		s.meta[name] = content.Bytes()
+	}
+	if _, ok := s.slots[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.slots[name] = slot
+}
+
+// removeDir 删除目录下已加入的文件, 3.0 (quilt) 格式解包时 orig 中的 debian 目录会被整体替换
+func (s *sourceFiles) removeDir(dir string) {
+	names := s.names[:0]
+	for _, name := range s.names {
+		if strings.HasPrefix(name, dir+"/") {
+			delete(s.slots, name)
+			delete(s.meta, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	s.names = names
+}
+
+// addTree 加入源码目录中的文件, 忽略版本控制目录与符号链接
+func (s *sourceFiles) addTree(dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if p != dir && sourceSkipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return s.add("./"+filepath.ToSlash(rel), f, info.Size())
+	})
+}
+
+// addTar 加入源码压缩包中的文件; strip 为 true 时去掉第一级目录(orig 与 native 压缩包中的 hello-2.10/),
+// 与 dpkg-source 一致, 只有所有条目都位于同一个顶层目录下时才去掉, 否则按原路径解压;
+// prefix 为解压到源码树中的目录; recorded 为 .dsc 中记录的摘要, 不为空时校验压缩包
+func (s *sourceFiles) addTar(tarPath string, strip bool, prefix string, recorded []common.Checksum) error {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hashes := map[common.ChecksumAlgorithm]hash.Hash{common.MD5: md5.New(), common.SHA1: sha1.New(), common.SHA256: sha256.New()}
+	writers := []io.Writer{hashes[common.MD5], hashes[common.SHA1], hashes[common.SHA256]}
+	raw := io.TeeReader(f, io.MultiWriter(writers...))
+	dr, err := tool.NewDecompressReader(tool.CompressorFromExt(tarPath), raw)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	// 读完压缩包才能确定是否去掉顶层目录, 文件先提交计算, 最后按解压后的路径记录
+	type tarFile struct {
+		name    string
+		slot    int
+		content *bytes.Buffer
+	}
+	var files []tarFile
+	tops := make(map[string]bool) //顶层条目, 值为是否为目录
+	target := func(name string, strip bool) string {
+		if strip {
+			name = name[strings.Index(name, "/")+1:]
+		}
+		if prefix != "" {
+			name = prefix + "/" + name
+		}
+		return "./" + name
+	}
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", tarPath, err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." {
+			continue
+		}
+		top, isDir := name, hdr.Typeflag == tar.TypeDir
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			top, isDir = name[:idx], true
+		}
+		tops[top] = tops[top] || isDir
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		file := tarFile{name: name}
+		var r io.Reader = tr
+		if isSourceMeta(target(name, false)) || strings.Contains(name, "/") && isSourceMeta(target(name, true)) {
+			file.content = new(bytes.Buffer)
+			r = io.TeeReader(r, file.content)
+		}
+		if file.slot, err = s.stage.Add(r, hdr.Size); err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	if strip {
+		strip = false
+		for _, isDir := range tops {
+			strip = len(tops) == 1 && isDir
+		}
+	}
+	for _, f := range files {
+		s.register(target(f.name, strip), f.slot, f.content)
+	}
+	if len(recorded) == 0 {
+		return nil
+	}
+	// 读完压缩包的剩余部分后再比较摘要
+	if _, err := io.Copy(ioutil.Discard, raw); err != nil {
+		return err
+	}
+	var actual []common.Checksum
+	for algo, h := range hashes {
+		actual = append(actual, common.Checksum{Algorithm: algo, Value: fmt.Sprintf("%x", h.Sum(nil))})
+	}
+	return checkDscChecksums(tarPath, recorded, actual)
+}
+
+// checkDscChecksums 比较文件摘要与 .dsc 中记录的摘要
+func checkDscChecksums(filePath string, recorded, actual []common.Checksum) error {
+	algo, ok := tool.CompareChecksums(recorded, actual)
+	switch {
+	case ok:
+		return nil
+	case algo == "":
+		return fmt.Errorf("%s: no common checksum algorithm with the dsc", filePath)
+	}
+	return fmt.Errorf("%s: %s checksum mismatch with the dsc", filePath, algo)
+}
+
+// addDsc 按 .dsc 中列出的文件加入源码, 文件需与 .dsc 在同一目录
+func (s *sourceFiles) addDsc(dscPath string) (plugin.PkgInfo, error) {
+	data, err := ioutil.ReadFile(dscPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	paragraphs := tool.ParseDeb822(tool.StripPGPSignature(data))
+	if len(paragraphs) == 0 || paragraphs[0].Get("Source") == "" || paragraphs[0].Get("Version") == "" {
+		return plugin.PkgInfo{}, fmt.Errorf("%s is not a valid dsc file", dscPath)
+	}
+	p := paragraphs[0]
+
+	files := tool.ParseDscFiles(p)
+	tarballs := make(map[string]tool.DebSourceTarball)
+	for _, f := range files {
+		if t, ok := tool.ParseDebSourceTarball(f.Name); ok {
+			tarballs[f.Name] = t
+		}
+	}
+	// 先解 orig 再解 debian 压缩包, 与 dpkg-source 的顺序一致
+	sort.SliceStable(files, func(i, j int) bool {
+		return tarballOrder(tarballs[files[i].Name]) < tarballOrder(tarballs[files[j].Name])
+	})
+	dir := filepath.Dir(dscPath)
+	for _, f := range files {
+		full := filepath.Join(dir, f.Name)
+		t, ok := tarballs[f.Name]
+		if !ok {
+			// 1.0 格式的 .diff.gz 等不是压缩包, 不展开, 只记录文件本身
+			log.Warning(f.Name, "is not a tarball, only its checksum is recorded")
+			if err := s.addFile(full, "./"+f.Name, f.Checksums); err != nil {
+				return plugin.PkgInfo{}, err
+			}
+			continue
+		}
+		if t.Kind == tool.DebTarballDebian {
+			s.removeDir("./debian")
+		}
+		if err := s.addTar(full, t.Kind != tool.DebTarballDebian, t.Component, f.Checksums); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+	}
+	return sourcePkgInfo(p, p.Get("Version")), nil
+}
+
+// tarballOrder 解包顺序: orig、orig-<component>、debian, 不是压缩包的文件放在最后
+func tarballOrder(t tool.DebSourceTarball) int {
+	switch {
+	case t.Kind == tool.DebTarballOrig && t.Component == "":
+		return 0
+	case t.Kind == tool.DebTarballOrig:
+		return 1
+	case t.Kind != "":
+		return 2
+	}
+	return 3
+}
+
+// addFile 加入不展开的单个文件, recorded 为 .dsc 中记录的摘要
+func (s *sourceFiles) addFile(filePath, name string, recorded []common.Checksum) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if len(recorded) > 0 {
+		actual, err := tool.PackageCheckSum(filePath)
+		if err != nil {
+			return err
+		}
+		if err := checkDscChecksums(filePath, recorded, actual); err != nil {
+			return err
+		}
+	}
+	return s.add(name, f, info.Size())
+}
+
+// addTarball 加入单独的源码压缩包: 同目录下有列出它的 .dsc 时按 .dsc 处理;
+// 否则 debian 压缩包与同目录下对应的 orig 压缩包一起解析, orig 压缩包使用同目录下版本最高的 debian 压缩包
+func (s *sourceFiles) addTarball(tarPath string) (plugin.PkgInfo, error) {
+	base := filepath.Base(tarPath)
+	dir := filepath.Dir(tarPath)
+	t, _ := tool.ParseDebSourceTarball(base)
+	if dsc := findDsc(dir, t.Source, base); dsc != "" {
+		log.Info("use", dsc, "for", base)
+		return s.addDsc(dsc)
+	}
+
+	switch t.Kind {
+	case tool.DebTarballNative:
+		if err := s.addTar(tarPath, true, "", nil); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+	case tool.DebTarballOrig:
+		debian := findDebianTarball(dir, t.Source, t.Version)
+		if debian == "" {
+			return plugin.PkgInfo{}, fmt.Errorf("%s has no dsc or debian tarball next to it", tarPath)
+		}
+		return s.addTarball(debian)
+	case tool.DebTarballDebian:
+		// debian 压缩包的版本带修订号, orig 压缩包只有上游版本
+		upstream := t.Version
+		if idx := strings.LastIndex(upstream, "-"); idx >= 0 {
+			upstream = upstream[:idx]
+		}
+		origs, err := filepath.Glob(filepath.Join(dir, t.Source+"_"+upstream+".orig*.tar*"))
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		sort.Slice(origs, func(i, j int) bool {
+			oi, _ := tool.ParseDebSourceTarball(filepath.Base(origs[i]))
+			oj, _ := tool.ParseDebSourceTarball(filepath.Base(origs[j]))
+			return tarballOrder(oi) < tarballOrder(oj)
+		})
+		for _, orig := range origs {
+			o, ok := tool.ParseDebSourceTarball(filepath.Base(orig))
+			if !ok || o.Kind != tool.DebTarballOrig || o.Version != upstream {
+				continue
+			}
+			if err := s.addTar(orig, true, o.Component, nil); err != nil {
+				return plugin.PkgInfo{}, err
+			}
+		}
+		s.removeDir("./debian")
+		if err := s.addTar(tarPath, false, "", nil); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+	}
+	return s.treeInfo()
+}
+
+// findDsc 查找同目录下列出该文件的 .dsc
+func findDsc(dir, source, name string) string {
+	dscs, _ := filepath.Glob(filepath.Join(dir, source+"_*.dsc"))
+	for _, dsc := range dscs {
+		data, err := ioutil.ReadFile(dsc)
+		if err != nil {
+			continue
+		}
+		paragraphs := tool.ParseDeb822(tool.StripPGPSignature(data))
+		if len(paragraphs) == 0 {
+			continue
+		}
+		for _, f := range tool.ParseDscFiles(paragraphs[0]) {
+			if f.Name == name {
+				return dsc
+			}
+		}
+	}
+	return ""
+}
+
+// findDebianTarball 查找同目录下与 orig 压缩包上游版本相同的 debian 压缩包, 有多个时取版本最高的
+func findDebianTarball(dir, source, upstream string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, source+"_"+upstream+"-*.debian.tar*"))
+	var best, bestVersion string
+	for _, m := range matches {
+		t, ok := tool.ParseDebSourceTarball(filepath.Base(m))
+		if !ok || t.Kind != tool.DebTarballDebian {
+			continue
+		}
+		if best == "" || tool.CompareDebVersions(t.Version, bestVersion) > 0 {
+			best, bestVersion = m, t.Version
+		}
+	}
+	return best
+}
+
+// treeInfo 由源码树中的 debian/control 与 debian/changelog 生成软件包信息
+func (s *sourceFiles) treeInfo() (plugin.PkgInfo, error) {
+	control, ok := s.meta[sourceControl]
+	if !ok {
+		return plugin.PkgInfo{}, fmt.Errorf("debian/control not found in the source")
+	}
+	paragraphs := tool.ParseDeb822(control)
+	if len(paragraphs) == 0 || paragraphs[0].Get("Source") == "" {
+		return plugin.PkgInfo{}, fmt.Errorf("debian/control has no Source field")
+	}
+	changelog, ok := s.meta[sourceChangelog]
+	if !ok {
+		return plugin.PkgInfo{}, fmt.Errorf("debian/changelog not found in the source")
+	}
+	_, version, err := tool.ParseDebChangelogHead(changelog)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	return sourcePkgInfo(paragraphs[0], version), nil
+}
+
+// fill 等待摘要计算完成, 按加入顺序填写文件列表
+func (s *sourceFiles) fill(res *plugin.PkgInfo) error {
+	checksums, err := s.stage.Wait()
+	if err != nil {
+		return err
+	}
+	scans := s.stage.LicenseScans()
+	for _, name := range s.names {
+		slot := s.slots[name]
+		f := &plugin.FileInfo{FileName: name, Hash: checksums[slot]}
+		if scan := scans[slot]; scan != nil {
+			f.SetLicenseScan(scan.Licenses, scan.Copyrights)
+		}
+		res.FileList = append(res.FileList, f)
+	}
+	return nil
+}
+
+func NewSource(opts tool.HashOptions) *DebSource {
+	return &DebSource{hashOpts: opts}
+}
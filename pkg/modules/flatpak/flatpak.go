@@ -0,0 +1,437 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package flatpak
+
+import (
+	"bytes"
+	"compress/gzip"
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// Flatpak flatpak 单文件包(.flatpak)及导出的应用目录(含 metadata 与 files/ 的部署目录或构建目录)
+type Flatpak struct {
+	flatpakInfo plugin.PkgInfo
+	hashOpts    tool.HashOptions
+}
+
+// flatpakTree 单文件包与应用目录的统一读取方式, 路径相对于部署目录, 如 files/manifest.json
+type flatpakTree interface {
+	readFile(name string) ([]byte, error)
+	// walkFiles 遍历 files/ 下的普通文件, name 以 / 开头, key 相同的文件内容相同
+	walkFiles(fn func(name, key string, size int64, open func() (io.ReadCloser, error)) error) error
+}
+
+type bundleTree struct {
+	delta *tool.OstreeDelta
+}
+
+func (b *bundleTree) readFile(name string) ([]byte, error) {
+	return b.delta.ReadFile(name)
+}
+
+func (b *bundleTree) walkFiles(fn func(name, key string, size int64, open func() (io.ReadCloser, error)) error) error {
+	return b.delta.Walk(func(e *tool.OstreeEntry) error {
+		if !e.Mode.IsRegular() || !strings.HasPrefix(e.Path, "/"+tool.FlatpakFilesDir+"/") {
+			return nil
+		}
+		return fn(e.Path, e.Checksum, e.Size, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(e.Open()), nil
+		})
+	})
+}
+
+type dirTree struct {
+	root string
+}
+
+func (d *dirTree) readFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(d.root, filepath.FromSlash(name)))
+}
+
+func (d *dirTree) walkFiles(fn func(name, key string, size int64, open func() (io.ReadCloser, error)) error) error {
+	return filepath.Walk(filepath.Join(d.root, tool.FlatpakFilesDir), func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		return fn("/"+filepath.ToSlash(rel), p, info.Size(), func() (io.ReadCloser, error) {
+			return os.Open(p)
+		})
+	})
+}
+
+func (f *Flatpak) GetPMVersion() (string, error) {
+	output, err := exec.Command("flatpak", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (f *Flatpak) GetPlugInfo() plugin.PlugInfo {
+	return plugin.PlugInfo{
+		PlugName: "FLATPAK",
+		PlugVer:  "0.0.1",
+	}
+}
+
+func (f *Flatpak) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "flatpak", Extensions: []string{".flatpak"}, Description: "Flatpak bundle or exported application directory"}}
+}
+
+// Detect 目录中含有 metadata 文件及 files 目录, 或文件为超级块中记录了 ref 的 OSTree 静态增量
+func (f *Flatpak) Detect(pkgPath string) int {
+	info, err := os.Stat(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	if info.IsDir() {
+		files, err := os.Stat(filepath.Join(pkgPath, tool.FlatpakFilesDir))
+		if err != nil || !files.IsDir() {
+			return plugin.ConfidenceNone
+		}
+		data, err := ioutil.ReadFile(filepath.Join(pkgPath, tool.FlatpakMetadata))
+		if err != nil {
+			return plugin.ConfidenceNone
+		}
+		if _, err := tool.ParseFlatpakMetadata(data); err != nil {
+			return plugin.ConfidenceNone
+		}
+		return plugin.ConfidenceContent
+	}
+	file, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	defer file.Close()
+	meta, err := tool.ReadOstreeDeltaMetadata(file, info.Size())
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	if _, ok := meta["ref"].(string); !ok {
+		return plugin.ConfidenceNone
+	}
+	return plugin.ConfidenceContent
+}
+
+func (f *Flatpak) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	info, err := os.Stat(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+
+	var tree flatpakTree
+	var ref *tool.FlatpakRef
+	var metadata, bundleAppData []byte
+	var props []plugin.Property
+	if info.IsDir() {
+		tree = &dirTree{root: pkgPath}
+		if metadata, err = tree.readFile(tool.FlatpakMetadata); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+	} else {
+		file, err := os.Open(pkgPath)
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		delta, err := tool.OpenOstreeDelta(file, info.Size())
+		file.Close()
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		defer delta.Close()
+		tree = &bundleTree{delta: delta}
+		// 单文件包的超级块中记录了 ref、metadata 及压缩后的 appdata, metadata 缺失时读取提交中的文件
+		if s, ok := delta.Metadata["ref"].(string); ok {
+			r, err := tool.ParseFlatpakRef(s)
+			if err != nil {
+				return plugin.PkgInfo{}, err
+			}
+			ref = &r
+			props = append(props, plugin.Property{Name: "flatpak:ref", Value: s})
+		}
+		props = append(props, plugin.Property{Name: "flatpak:commit", Value: delta.Commit})
+		if s, ok := delta.Metadata["metadata"].(string); ok {
+			metadata = []byte(s)
+		} else if metadata, err = tree.readFile(tool.FlatpakMetadata); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		bundleAppData, _ = delta.Metadata["appdata"].([]byte)
+	}
+	meta, err := tool.ParseFlatpakMetadata(metadata)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+
+	f.flatpakInfo = pkgInfoFromMeta(meta, ref, readAppStream(tree, meta.Name, bundleAppData))
+	res := f.flatpakInfo
+	res.Properties = append(props, res.Properties...)
+
+	// files/ 下的文件hash, 单文件包中内容相同的文件只计算一次
+	stage, err := tool.NewHashStage(f.hashOpts)
+	if err != nil {
+		return res, err
+	}
+	defer stage.Release()
+	var names []string
+	var slots []int
+	seen := make(map[string]int)
+	err = tree.walkFiles(func(name, key string, size int64, open func() (io.ReadCloser, error)) error {
+		slot, ok := seen[key]
+		if !ok {
+			r, err := open()
+			if err != nil {
+				return err
+			}
+			slot, err = stage.Add(r, size)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			seen[key] = slot
+		}
+		names = append(names, name)
+		slots = append(slots, slot)
+		return nil
+	})
+	checksums, waitErr := stage.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return res, err
+	}
+	scans := stage.LicenseScans()
+	for i, name := range names {
+		file := &plugin.FileInfo{FileName: name, Hash: checksums[slots[i]]}
+		if scan := scans[slots[i]]; scan != nil {
+			file.SetLicenseScan(scan.Licenses, scan.Copyrights)
+		}
+		res.FileList = append(res.FileList, file)
+	}
+
+	// flatpak-builder 构建的模块作为内嵌的软件包
+	if data, err := tree.readFile(tool.FlatpakManifest); err == nil {
+		modules, err := tool.ParseFlatpakManifest(data)
+		if err != nil {
+			log.Warning("parse", tool.FlatpakManifest, "failed:", err)
+		}
+		for _, m := range modules {
+			res.Packages = append(res.Packages, modulePkgInfo(m))
+		}
+	} else if !os.IsNotExist(err) {
+		log.Warning("read", tool.FlatpakManifest, "failed:", err)
+	}
+
+	// AppStream 中的 project_license 为 SPDX 表达式
+	res.NormalizeLicenses(nil)
+	return res, nil
+}
+
+// readAppStream 依次查找 metainfo、appdata、单文件包中的 appdata 及 app-info 目录, 均没有时返回 nil
+func readAppStream(tree flatpakTree, id string, bundleAppData []byte) *tool.AppStream {
+	var candidates [][]byte
+	for _, name := range []string{
+		"files/share/metainfo/" + id + ".metainfo.xml",
+		"files/share/metainfo/" + id + ".appdata.xml",
+		"files/share/appdata/" + id + ".appdata.xml",
+	} {
+		if data, err := tree.readFile(name); err == nil {
+			candidates = append(candidates, data)
+		}
+	}
+	if len(bundleAppData) > 0 {
+		candidates = append(candidates, bundleAppData)
+	}
+	if data, err := tree.readFile("files/share/app-info/xmls/" + id + ".xml.gz"); err == nil {
+		candidates = append(candidates, data)
+	}
+	for _, data := range candidates {
+		if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			data, err = ioutil.ReadAll(zr)
+			if err != nil {
+				continue
+			}
+		}
+		if as, err := tool.ParseAppStream(data, id); err == nil {
+			return as
+		}
+	}
+	return nil
+}
+
+// pkgInfoFromMeta 由 metadata、ref 及 AppStream 生成软件包信息, 运行时作为依赖, sdk 作为构建依赖
+func pkgInfoFromMeta(meta *tool.FlatpakMeta, ref *tool.FlatpakRef, as *tool.AppStream) plugin.PkgInfo {
+	info := plugin.PkgInfo{
+		Type:             "flatpak",
+		Name:             meta.Name,
+		Maintainer:       "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		DownloadLocation: "NOASSERTION",
+	}
+	runtime, runtimeErr := tool.ParseFlatpakRef(meta.Runtime)
+	if ref != nil {
+		info.Version, info.Architecture = ref.Branch, ref.Arch
+	} else if runtimeErr == nil {
+		info.Architecture = runtime.Arch
+	}
+	if as != nil {
+		if as.Version != "" {
+			info.Version = as.Version
+		}
+		if as.Developer != "" {
+			info.Maintainer = as.Developer
+		}
+		if as.ProjectLicense != "" {
+			info.LicenseDeclared = as.ProjectLicense
+		}
+		info.Homepage = as.Homepage
+		info.Description = strings.TrimSpace(as.Summary + "\n" + as.Description)
+	}
+
+	addRef := func(typ, s string) {
+		if r, err := tool.ParseFlatpakRef(s); err == nil && r.ID != meta.Name {
+			info.Relations = append(info.Relations, plugin.Relation{
+				Type:         typ,
+				Alternatives: []plugin.Dependency{{Name: r.ID, Arch: r.Arch, Operator: "=", Version: r.Branch}},
+			})
+		}
+	}
+	addRef(plugin.RelationRuntime, meta.Runtime)
+	addRef(plugin.RelationBuildDepends, meta.Sdk)
+	// 扩展依赖其所扩展的应用或运行时
+	if g := tool.KeyFileGroupByName(meta.Groups, "ExtensionOf"); g != nil {
+		addRef(plugin.RelationRuntime, g.Get("ref"))
+	}
+
+	prop := func(name, value string) {
+		if value != "" {
+			info.Properties = append(info.Properties, plugin.Property{Name: "flatpak:" + name, Value: value})
+		}
+	}
+	prop("kind", meta.Kind)
+	prop("runtime", meta.Runtime)
+	prop("sdk", meta.Sdk)
+	prop("command", meta.Command)
+	for _, g := range meta.Groups {
+		switch {
+		case g.Name == "Context":
+			for _, e := range g.Entries {
+				prop("permission:"+e.Key, strings.TrimSuffix(e.Value, ";"))
+			}
+		case g.Name == "Session Bus Policy":
+			for _, e := range g.Entries {
+				prop("session-bus:"+e.Key, e.Value)
+			}
+		case g.Name == "System Bus Policy":
+			for _, e := range g.Entries {
+				prop("system-bus:"+e.Key, e.Value)
+			}
+		case strings.HasPrefix(g.Name, "Extension "):
+			var attrs []string
+			for _, e := range g.Entries {
+				attrs = append(attrs, e.Key+"="+e.Value)
+			}
+			prop("extension:"+strings.TrimPrefix(g.Name, "Extension "), strings.Join(attrs, " "))
+		}
+	}
+	return info
+}
+
+// modulePkgInfo flatpak-builder 的模块, 以第一个带有 url 的源作为下载位置及校验和, 所有源记录为属性
+func modulePkgInfo(m tool.FlatpakModule) plugin.PkgInfo {
+	info := plugin.PkgInfo{
+		Name:             m.Name,
+		Maintainer:       "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		DownloadLocation: "NOASSERTION",
+	}
+	if m.Parent != "" {
+		info.Properties = append(info.Properties, plugin.Property{Name: "flatpak:parent-module", Value: m.Parent})
+	}
+	primary := true
+	for _, s := range m.Sources {
+		location := s.URL
+		if location == "" {
+			location = s.Path
+		}
+		attrs := []string{"type=" + s.Type}
+		if location != "" {
+			attrs = append(attrs, "location="+location)
+		}
+		for _, kv := range [][2]string{{"tag", s.Tag}, {"branch", s.Branch}, {"commit", s.Commit}} {
+			if kv[1] != "" {
+				attrs = append(attrs, kv[0]+"="+kv[1])
+			}
+		}
+		checksums := sourceChecksums(s)
+		for _, c := range checksums {
+			attrs = append(attrs, strings.ToLower(string(c.Algorithm))+"="+c.Value)
+		}
+		info.Properties = append(info.Properties, plugin.Property{Name: "flatpak:source", Value: strings.Join(attrs, " ")})
+
+		if !primary || s.URL == "" {
+			continue
+		}
+		primary = false
+		switch s.Type {
+		case "git":
+			info.DownloadLocation = "git+" + s.URL
+			if s.Commit != "" {
+				info.DownloadLocation += "@" + s.Commit
+			}
+			info.Version = s.Tag
+			if info.Version == "" {
+				info.Version = s.Commit
+			}
+		default:
+			info.DownloadLocation = s.URL
+			info.Version = tool.ArchiveVersion(m.Name, s.URL)
+			info.Hash = checksums
+		}
+	}
+	info.NormalizeLicenses(nil)
+	return info
+}
+
+// sourceChecksums 源中记录的校验和, 按算法名排序
+func sourceChecksums(s tool.FlatpakSource) []common.Checksum {
+	var res []common.Checksum
+	for algo, value := range map[common.ChecksumAlgorithm]string{
+		common.MD5:    s.MD5,
+		common.SHA1:   s.SHA1,
+		common.SHA256: s.SHA256,
+		common.SHA512: s.SHA512,
+	} {
+		if value != "" {
+			res = append(res, common.Checksum{Algorithm: algo, Value: strings.ToLower(value)})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Algorithm < res[j].Algorithm })
+	return res
+}
+
+func New(opts tool.HashOptions) *Flatpak {
+	flatpak := &Flatpak{hashOpts: opts}
+	return flatpak
+}
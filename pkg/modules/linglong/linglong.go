@@ -0,0 +1,367 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package linglong
+
+import (
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Linglong 玲珑的 layer 及 UAB 包, 使用纯 Go 解析其中的 erofs 或 squashfs 镜像, 无需 ll-cli 或挂载
+type Linglong struct {
+	linglongInfo plugin.PkgInfo
+	hashOpts     tool.HashOptions
+}
+
+// linglongEntry erofs 与 squashfs 中条目的统一表示
+type linglongEntry struct {
+	path string
+	mode os.FileMode
+	size int64
+	open func() io.Reader
+}
+
+// linglongFS 包中内嵌的文件系统
+type linglongFS interface {
+	walk(fn func(e linglongEntry) error) error
+	readFile(name string) ([]byte, error)
+	close()
+}
+
+type erofsFS struct {
+	fs *tool.EROFS
+}
+
+func (e *erofsFS) walk(fn func(e linglongEntry) error) error {
+	return e.fs.Walk(func(entry *tool.EROFSEntry) error {
+		return fn(linglongEntry{path: entry.Path, mode: entry.Mode, size: entry.Size, open: entry.Open})
+	})
+}
+
+func (e *erofsFS) readFile(name string) ([]byte, error) {
+	return e.fs.ReadFile(name)
+}
+
+func (e *erofsFS) close() {
+	e.fs.Close()
+}
+
+type squashFS struct {
+	fs *tool.SquashFS
+}
+
+func (s *squashFS) walk(fn func(e linglongEntry) error) error {
+	return s.fs.Walk(func(entry *tool.SquashFSEntry) error {
+		return fn(linglongEntry{path: entry.Path, mode: entry.Mode, size: entry.Size, open: entry.Open})
+	})
+}
+
+func (s *squashFS) readFile(name string) ([]byte, error) {
+	return s.fs.ReadFile(name)
+}
+
+func (s *squashFS) close() {
+	s.fs.Close()
+}
+
+// openFS 打开 offset 处的镜像, 玲珑默认使用 erofs, 也支持 squashfs
+func openFS(r io.ReaderAt, offset int64, pkgPath string) (linglongFS, error) {
+	switch {
+	case tool.IsEROFS(r, offset):
+		fs, err := tool.OpenEROFS(r, offset)
+		if err != nil {
+			return nil, err
+		}
+		return &erofsFS{fs: fs}, nil
+	case tool.IsSquashFS(r, offset):
+		fs, err := tool.OpenSquashFS(r, offset)
+		if err != nil {
+			return nil, err
+		}
+		return &squashFS{fs: fs}, nil
+	}
+	return nil, fmt.Errorf("no erofs or squashfs found at offset %d of %s", offset, pkgPath)
+}
+
+// linglongLayer 包中的一个 layer 及其在镜像中的目录
+type linglongLayer struct {
+	info     *tool.LinglongPackageInfo
+	minified bool
+	dir      string
+}
+
+func (l *Linglong) GetPMVersion() (string, error) {
+	output, err := exec.Command("ll-cli", "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (l *Linglong) GetPlugInfo() plugin.PlugInfo {
+	return plugin.PlugInfo{
+		PlugName: "LINGLONG",
+		PlugVer:  "0.0.1",
+	}
+}
+
+func (l *Linglong) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "linglong", Extensions: []string{".layer", ".uab"}, Description: "Linglong layer or UAB package"}}
+}
+
+// Detect 以 layer 魔数开头, 或为带有 linglong.meta 节的 UAB
+func (l *Linglong) Detect(pkgPath string) int {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	defer f.Close()
+	if !tool.IsLinglongLayer(f) && !tool.IsLinglongUAB(f) {
+		return plugin.ConfidenceNone
+	}
+	return plugin.ConfidenceContent
+}
+
+func (l *Linglong) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+
+	// layer 的镜像根目录即为 layer 目录, UAB 的 bundle 中每个 layer 位于 layers/<id>/<module>
+	var fs linglongFS
+	var layers []linglongLayer
+	var uab *tool.LinglongUAB
+	if tool.IsLinglongLayer(f) {
+		layer, err := tool.ReadLinglongLayer(f, st.Size())
+		if err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		if fs, err = openFS(f, layer.PayloadOffset, pkgPath); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		layers = append(layers, linglongLayer{info: layer.Info, dir: "/"})
+	} else {
+		if uab, err = tool.ReadLinglongUAB(f, st.Size()); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		bundle := io.NewSectionReader(f, uab.BundleOffset, uab.BundleSize)
+		if fs, err = openFS(bundle, 0, pkgPath); err != nil {
+			return plugin.PkgInfo{}, err
+		}
+		for _, layer := range uab.Layers {
+			module := layer.Info.Module
+			if module == "" {
+				module = "binary"
+			}
+			dir := path.Join("/layers", layer.Info.ID, module) + "/"
+			layers = append(layers, linglongLayer{info: layer.Info, minified: layer.Minified, dir: dir})
+		}
+	}
+	defer fs.close()
+
+	// 应用 layer 为主包, 随 UAB 打包的 base、runtime 作为内嵌的软件包
+	main := 0
+	for i, layer := range layers {
+		if layer.info.Kind == "app" {
+			main = i
+			break
+		}
+	}
+
+	// 包文件hash
+	stage, err := tool.NewHashStage(l.hashOpts)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer stage.Release()
+	var names []string
+	err = fs.walk(func(e linglongEntry) error {
+		if !e.mode.IsRegular() {
+			return nil
+		}
+		if _, err := stage.Add(e.open(), e.size); err != nil {
+			return err
+		}
+		names = append(names, e.path)
+		return nil
+	})
+	checksums, waitErr := stage.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+
+	info := layers[main].info
+	l.linglongInfo = pkgInfoFromLayer(info, readAppStream(fs, layers[main].dir, info.ID))
+	res := l.linglongInfo
+	if uab != nil {
+		prop := func(name, value string) {
+			if value != "" {
+				res.Properties = append(res.Properties, plugin.Property{Name: "linglong:" + name, Value: value})
+			}
+		}
+		prop("uab-version", uab.Version)
+		prop("uab-uuid", uab.UUID)
+		prop("uab-digest", uab.Digest)
+		prop("only-app", strconv.FormatBool(uab.OnlyApp))
+	}
+
+	scans := stage.LicenseScans()
+	for i, name := range names {
+		file := &plugin.FileInfo{FileName: name, Hash: checksums[i]}
+		if scans[i] != nil {
+			file.SetLicenseScan(scans[i].Licenses, scans[i].Copyrights)
+		}
+		res.FileList = append(res.FileList, file)
+	}
+
+	for i, layer := range layers {
+		if i == main {
+			continue
+		}
+		pkg := pkgInfoFromLayer(layer.info, nil)
+		pkg.Relations = nil
+		pkg.FileName = strings.TrimSuffix(layer.dir, "/")
+		pkg.Properties = append(pkg.Properties, plugin.Property{Name: "linglong:minified", Value: strconv.FormatBool(layer.minified)})
+		res.Packages = append(res.Packages, pkg)
+	}
+
+	// AppStream 中的 project_license 为 SPDX 表达式
+	res.NormalizeLicenses(nil)
+	return res, nil
+}
+
+// readAppStream 读取 layer 的 files/share 中与应用同名的 AppStream 文件
+func readAppStream(fs linglongFS, dir, id string) *tool.AppStream {
+	share := path.Join(dir, tool.LinglongFilesDir, "share")
+	candidates := []string{
+		share + "/metainfo/" + id + ".metainfo.xml",
+		share + "/metainfo/" + id + ".appdata.xml",
+		share + "/appdata/" + id + ".appdata.xml",
+	}
+	for _, name := range candidates {
+		data, err := fs.readFile(name)
+		if err != nil {
+			continue
+		}
+		as, err := tool.ParseAppStream(data, id)
+		if err != nil {
+			log.Warning("parse", name, "failed:", err)
+			continue
+		}
+		return as
+	}
+	return nil
+}
+
+// pkgInfoFromLayer 名称为 layer 的 id, base 与 runtime 作为依赖
+func pkgInfoFromLayer(info *tool.LinglongPackageInfo, as *tool.AppStream) plugin.PkgInfo {
+	res := plugin.PkgInfo{
+		Type:             "linglong",
+		Name:             info.ID,
+		Version:          info.Version,
+		Maintainer:       "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		DownloadLocation: "NOASSERTION",
+		Description:      info.Description,
+	}
+	switch len(info.Arch) {
+	case 0:
+	case 1:
+		res.Architecture = info.Arch[0]
+	default:
+		res.Architecture = "multi"
+	}
+	if as != nil {
+		if as.Developer != "" {
+			res.Maintainer = as.Developer
+		}
+		if as.ProjectLicense != "" {
+			res.LicenseDeclared = as.ProjectLicense
+		}
+		res.Homepage = as.Homepage
+		if as.Summary != "" || as.Description != "" {
+			res.Description = strings.TrimSpace(as.Summary + "\n" + as.Description)
+		}
+	}
+
+	addRef := func(typ, ref string) {
+		if ref == "" {
+			return
+		}
+		r, err := tool.ParseLinglongRef(ref)
+		if err != nil {
+			log.Warning(err)
+			return
+		}
+		dep := plugin.Dependency{Name: r.ID, Arch: r.Arch, Version: r.Version}
+		add := func(dep plugin.Dependency) {
+			res.Relations = append(res.Relations, plugin.Relation{Type: typ, Alternatives: []plugin.Dependency{dep}})
+		}
+		// 四段版本号为确定的版本; 较短的版本号匹配以其开头的版本, 如 23.1 匹配 23.1.x.x,
+		// 与 deb 的写法一样拆分为 >= 23.1 与 << 23.2 两条关系
+		switch {
+		case r.Version == "":
+			add(dep)
+		case strings.Count(r.Version, ".") >= 3:
+			dep.Operator = "="
+			add(dep)
+		default:
+			dep.Operator = ">="
+			add(dep)
+			if next, ok := nextVersionPrefix(r.Version); ok {
+				dep.Operator, dep.Version = "<<", next
+				add(dep)
+			}
+		}
+	}
+	addRef(plugin.RelationBase, info.Base)
+	addRef(plugin.RelationRuntime, info.Runtime)
+
+	prop := func(name, value string) {
+		if value != "" {
+			res.Properties = append(res.Properties, plugin.Property{Name: "linglong:" + name, Value: value})
+		}
+	}
+	prop("kind", info.Kind)
+	prop("module", info.Module)
+	prop("channel", info.Channel)
+	prop("base", info.Base)
+	prop("runtime", info.Runtime)
+	prop("command", strings.Join(info.Command, " "))
+	prop("schema-version", info.SchemaVersion)
+	return res
+}
+
+// nextVersionPrefix 返回最后一段加一的版本号, 如 23.1 返回 23.2, 最后一段不是数字时返回 false
+func nextVersionPrefix(version string) (string, bool) {
+	i := strings.LastIndex(version, ".") + 1
+	n, err := strconv.Atoi(version[i:])
+	if err != nil || n < 0 {
+		return "", false
+	}
+	return version[:i] + strconv.Itoa(n+1), true
+}
+
+func New(opts tool.HashOptions) *Linglong {
+	linglong := &Linglong{hashOpts: opts}
+	return linglong
+}
@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package modules
+
+import (
+	"deepin-sbom-tools/pkg/modules/appimage"
+	"deepin-sbom-tools/pkg/modules/deb"
+	"deepin-sbom-tools/pkg/modules/flatpak"
+	"deepin-sbom-tools/pkg/modules/linglong"
+	"deepin-sbom-tools/pkg/modules/rpm"
+	"deepin-sbom-tools/pkg/modules/snap"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+)
+
+// NewPlugins 创建所有插件的实例, 插件解析时会保存状态, 并发处理时每个软件包使用各自的实例;
+// opts 为计算包内文件摘要的选项
+func NewPlugins(opts tool.HashOptions) []plugin.Plugin {
+	return []plugin.Plugin{
+		deb.New(opts),
+		rpm.New(opts),
+		deb.NewSource(opts),
+		snap.New(opts),
+		flatpak.New(opts),
+		appimage.New(opts),
+		linglong.New(opts),
+	}
+}
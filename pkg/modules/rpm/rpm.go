@@ -5,11 +5,20 @@
 package rpm
 
 import (
+	"deepin-sbom-tools/pkg/log"
 	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"io"
 	"os/exec"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
 )
 
-type Rpm struct{}
+type Rpm struct {
+	rpmInfo  plugin.PkgInfo
+	hashOpts tool.HashOptions
+}
 
 func (r *Rpm) GetPMVersion() (string, error) {
 	output, err := exec.Command("rpm", "--version").Output()
@@ -22,22 +31,131 @@ func (r *Rpm) GetPMVersion() (string, error) {
 func (r *Rpm) GetPlugInfo() plugin.PlugInfo {
 	return plugin.PlugInfo{
 		PlugName: "RPM",
-		PlugVer:  "0.0.2",
+		PlugVer:  "0.1.0",
 	}
 
 }
 
-func (r *Rpm) IsValid(path string) bool {
-	_, err := r.GetPMVersion()
-	return err == nil
+func (r *Rpm) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "rpm", Extensions: []string{".rpm"}, Description: "RPM binary or source package"}}
+}
+
+// Detect lead 魔数之后紧跟签名头时确认为 RPM 包
+func (r *Rpm) Detect(path string) int {
+	if ok, err := tool.HasRpmSignatureHeader(path); err == nil && ok {
+		return plugin.ConfidenceContent
+	}
+	if ok, err := tool.IsRpmFile(path); err == nil && ok {
+		return plugin.ConfidenceMagic
+	}
+	return plugin.ConfidenceNone
 }
 
-func (r *Rpm) ParsePkgInfo(path string) (plugin.PkgInfo, error) {
+func (r *Rpm) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	rpmHdr, err := tool.ReadRpmHeader(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	r.rpmInfo.Type = "rpm"
+	r.rpmInfo.Name = rpmHdr.Name
+	r.rpmInfo.Version = rpmHdr.FullVersion()
+	r.rpmInfo.Architecture = rpmHdr.Arch
+	r.rpmInfo.Homepage = rpmHdr.URL
+	r.rpmInfo.Section = rpmHdr.Group
+	r.rpmInfo.Description = strings.TrimSpace(rpmHdr.Summary + "\n" + rpmHdr.Description)
+	// 安装大小与 deb 保持一致, 单位为 KiB
+	r.rpmInfo.InstalledSize = int((rpmHdr.Size + 1023) / 1024)
+	r.rpmInfo.Maintainer = rpmHdr.Packager
+	if r.rpmInfo.Maintainer == "" {
+		r.rpmInfo.Maintainer = rpmHdr.Vendor
+	}
+	if r.rpmInfo.Maintainer == "" {
+		r.rpmInfo.Maintainer = "NOASSERTION"
+	}
+	r.rpmInfo.LicenseDeclared = rpmHdr.License
+	if r.rpmInfo.LicenseDeclared == "" {
+		r.rpmInfo.LicenseDeclared = "NOASSERTION"
+	}
 
-	return plugin.PkgInfo{}, nil
+	r.rpmInfo.Relations = nil
+	seen := make(map[string]bool)
+	for _, dep := range rpmHdr.Requires {
+		if dep.IsRpmlib() {
+			continue
+		}
+		s := dep.String()
+		if !seen[s] {
+			seen[s] = true
+			d := plugin.Dependency{Name: dep.Name}
+			if op := dep.Operator(); op != "" && dep.Version != "" {
+				d.Operator = op
+				d.Version = dep.Version
+			}
+			r.rpmInfo.Relations = append(r.rpmInfo.Relations, plugin.Relation{
+				Type:         plugin.RelationDepends,
+				Alternatives: []plugin.Dependency{d},
+			})
+		}
+	}
+
+	res := r.rpmInfo
+
+	// 包文件hash, 直接读取负载计算; 负载无法解析时退回到头部记录的摘要
+	stage, err := tool.NewHashStage(r.hashOpts)
+	if err != nil {
+		return res, err
+	}
+	defer stage.Release()
+	index := make(map[string]int)
+	err = tool.WalkRpmPayload(pkgPath, func(names []string, size int64, reader io.Reader) error {
+		slot, err := stage.Add(reader, size)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			index[name] = slot
+		}
+		return nil
+	})
+	checksums, waitErr := stage.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		log.Warning("read rpm payload failed, use header digests:", err)
+		index = nil
+	}
+
+	scans := stage.LicenseScans()
+	for _, f := range rpmHdr.Files {
+		if !f.IsRegular() {
+			continue
+		}
+		var hash []common.Checksum
+		var scan *tool.FileLicenseScan
+		if slot, ok := index[f.Name]; ok {
+			hash = checksums[slot]
+			scan = scans[slot]
+		} else if f.Digest != "" {
+			hash = []common.Checksum{{Algorithm: rpmHdr.DigestAlgo, Value: f.Digest}}
+		} else {
+			continue
+		}
+		file := &plugin.FileInfo{
+			FileName: f.Name,
+			Hash:     hash,
+		}
+		if scan != nil {
+			file.SetLicenseScan(scan.Licenses, scan.Copyrights)
+		}
+		res.FileList = append(res.FileList, file)
+	}
+	// License 标签可能是 Fedora 旧式简称, 如 "GPLv2+ and ASL 2.0"
+	res.NormalizeLicenses(nil)
+	return res, nil
 }
 
-func New() *Rpm {
-	rpm := new(Rpm)
+func New(opts tool.HashOptions) *Rpm {
+	rpm := &Rpm{hashOpts: opts}
 	return rpm
 }
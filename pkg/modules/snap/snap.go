@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package snap
+
+import (
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/modules/deb"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Snap squashfs 格式的 snap 包, 使用纯 Go 的 squashfs 解析, 无需挂载或 root 权限
+type Snap struct {
+	snapInfo plugin.PkgInfo
+	hashOpts tool.HashOptions
+}
+
+func (s *Snap) GetPMVersion() (string, error) {
+	output, err := exec.Command("snap", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (s *Snap) GetPlugInfo() plugin.PlugInfo {
+	return plugin.PlugInfo{
+		PlugName: "SNAP",
+		PlugVer:  "0.0.1",
+	}
+}
+
+func (s *Snap) FileTypes() []plugin.FileType {
+	return []plugin.FileType{{Name: "snap", Extensions: []string{".snap"}, Description: "Snap package"}}
+}
+
+// Detect squashfs 镜像中含有 meta/snap.yaml 时为 snap 包
+func (s *Snap) Detect(pkgPath string) int {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	defer f.Close()
+	if !tool.IsSquashFS(f, 0) {
+		return plugin.ConfidenceNone
+	}
+	fs, err := tool.OpenSquashFS(f, 0)
+	if err != nil {
+		return plugin.ConfidenceNone
+	}
+	defer fs.Close()
+	if _, err := fs.ReadFile(tool.SnapYAML); err != nil {
+		return plugin.ConfidenceNone
+	}
+	return plugin.ConfidenceContent
+}
+
+func (s *Snap) ParsePkgInfo(pkgPath string) (plugin.PkgInfo, error) {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer f.Close()
+	fs, err := tool.OpenSquashFS(f, 0)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer fs.Close()
+
+	data, err := fs.ReadFile(tool.SnapYAML)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	meta, err := tool.ParseSnapYAML(data)
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	s.snapInfo = pkgInfoFromMeta(meta)
+	res := s.snapInfo
+
+	// 包文件hash, 直接读取 squashfs 中的文件内容
+	stage, err := tool.NewHashStage(s.hashOpts)
+	if err != nil {
+		return res, err
+	}
+	defer stage.Release()
+	var names []string
+	var debs []*tool.SquashFSEntry
+	err = fs.Walk(func(e *tool.SquashFSEntry) error {
+		if !e.Mode.IsRegular() {
+			return nil
+		}
+		if _, err := stage.Add(e.Open(), e.Size); err != nil {
+			return err
+		}
+		names = append(names, e.Path)
+		if strings.HasSuffix(e.Path, ".deb") {
+			debs = append(debs, e)
+		}
+		return nil
+	})
+	checksums, waitErr := stage.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return res, err
+	}
+	scans := stage.LicenseScans()
+	hashes := make(map[string]int)
+	for i, name := range names {
+		hashes[name] = i
+		file := &plugin.FileInfo{FileName: name, Hash: checksums[i]}
+		if scans[i] != nil {
+			file.SetLicenseScan(scans[i].Licenses, scans[i].Copyrights)
+		}
+		res.FileList = append(res.FileList, file)
+	}
+
+	// snap 中的 deb 包及 snapcraft 记录的 stage-packages 作为内嵌的软件包
+	for _, e := range debs {
+		p, err := s.parseDeb(e)
+		if err != nil {
+			log.Warning("parse", e.Path, "failed:", err)
+			continue
+		}
+		p.Hash = checksums[hashes[e.Path]]
+		res.Packages = append(res.Packages, p)
+	}
+	res.Packages = append(res.Packages, stagePackages(fs, res.Packages)...)
+
+	// snap.yaml 中的 license 为 SPDX 表达式
+	res.NormalizeLicenses(nil)
+	return res, nil
+}
+
+// pkgInfoFromMeta 由 snap.yaml 生成软件包信息, 基础快照与内容快照作为依赖
+func pkgInfoFromMeta(meta *tool.SnapMeta) plugin.PkgInfo {
+	info := plugin.PkgInfo{
+		Type:             "snap",
+		Name:             meta.Name,
+		Version:          meta.Version,
+		Maintainer:       "NOASSERTION", //snap.yaml 中没有发布者信息
+		LicenseDeclared:  meta.License,
+		DownloadLocation: "NOASSERTION",
+		Homepage:         meta.Website,
+		Description:      strings.TrimSpace(meta.Summary + "\n" + meta.Description),
+	}
+	if info.LicenseDeclared == "" {
+		info.LicenseDeclared = "NOASSERTION"
+	}
+	// 未声明架构时为 all, 多个架构时与 snapcraft 的文件名一样记为 multi
+	switch len(meta.Architectures) {
+	case 0:
+		info.Architecture = "all"
+	case 1:
+		info.Architecture = meta.Architectures[0]
+	default:
+		info.Architecture = "multi"
+	}
+
+	if base := meta.BaseSnap(); base != "" {
+		info.Relations = append(info.Relations, plugin.Relation{
+			Type:         plugin.RelationBase,
+			Alternatives: []plugin.Dependency{{Name: base}},
+		})
+	}
+	for _, name := range meta.ContentSnaps() {
+		info.Relations = append(info.Relations, plugin.Relation{
+			Type:         plugin.RelationContent,
+			Alternatives: []plugin.Dependency{{Name: name}},
+		})
+	}
+
+	prop := func(name, value string) {
+		if value != "" {
+			info.Properties = append(info.Properties, plugin.Property{Name: "snap:" + name, Value: value})
+		}
+	}
+	prop("type", meta.Type)
+	prop("base", meta.Base)
+	prop("architectures", strings.Join(meta.Architectures, ", "))
+	prop("confinement", meta.Confinement)
+	prop("grade", meta.Grade)
+	for _, app := range meta.Apps {
+		var attrs []string
+		if app.Command != "" {
+			attrs = append(attrs, "command="+app.Command)
+		}
+		if app.Daemon != "" {
+			attrs = append(attrs, "daemon="+app.Daemon)
+		}
+		if len(app.Plugs) > 0 {
+			attrs = append(attrs, "plugs="+strings.Join(app.Plugs, ","))
+		}
+		prop("app:"+app.Name, strings.Join(attrs, " "))
+	}
+	for _, plug := range meta.Plugs {
+		attrs := []string{"interface=" + plug.Interface}
+		if plug.Content != "" {
+			attrs = append(attrs, "content="+plug.Content)
+		}
+		if plug.DefaultProvider != "" {
+			attrs = append(attrs, "default-provider="+plug.DefaultProvider)
+		}
+		prop("plug:"+plug.Name, strings.Join(attrs, " "))
+	}
+	return info
+}
+
+// parseDeb 将 snap 中的 deb 包写入临时文件后解析, 只保留软件包本身的信息
+func (s *Snap) parseDeb(e *tool.SquashFSEntry) (plugin.PkgInfo, error) {
+	tmp, err := ioutil.TempFile("", "sbom-snap-*.deb")
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, e.Open())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	p, err := deb.New(s.hashOpts).ParsePkgInfo(tmp.Name())
+	if err != nil {
+		return plugin.PkgInfo{}, err
+	}
+	p.FileList = nil
+	p.Relations = nil
+	p.FileName = e.Path
+	return p, nil
+}
+
+// stagePackages 读取 snapcraft 记录的 stage-packages, 已作为 deb 文件解析过的软件包不再重复
+func stagePackages(fs *tool.SquashFS, debs []plugin.PkgInfo) []plugin.PkgInfo {
+	seen := make(map[string]bool)
+	for _, p := range debs {
+		seen[p.Name+"="+p.Version] = true
+	}
+	var res []plugin.PkgInfo
+	add := func(file string, pkgs []tool.SnapStagePackage) {
+		for _, p := range pkgs {
+			if seen[p.Name+"="+p.Version] {
+				continue
+			}
+			seen[p.Name+"="+p.Version] = true
+			res = append(res, plugin.PkgInfo{
+				Type:             "deb",
+				Name:             p.Name,
+				Version:          p.Version,
+				Architecture:     p.Arch,
+				LicenseDeclared:  "NOASSERTION",
+				DownloadLocation: "NOASSERTION",
+				Properties:       []plugin.Property{{Name: "snap:staged-from", Value: "/" + file}},
+			})
+		}
+	}
+	for _, file := range []string{tool.SnapManifestYAML, tool.SnapDpkgYAML, tool.SnapDpkgList} {
+		data, err := fs.ReadFile(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warning("read", file, "failed:", err)
+			}
+			continue
+		}
+		var pkgs []tool.SnapStagePackage
+		switch file {
+		case tool.SnapManifestYAML:
+			pkgs, err = tool.ParseSnapManifest(data)
+		case tool.SnapDpkgYAML:
+			pkgs, err = tool.ParseSnapDpkgYAML(data)
+		default:
+			pkgs = tool.ParseDpkgList(data)
+		}
+		if err != nil {
+			log.Warning("parse", file, "failed:", err)
+			continue
+		}
+		add(file, pkgs)
+	}
+	return res
+}
+
+func New(opts tool.HashOptions) *Snap {
+	snap := &Snap{hashOpts: opts}
+	return snap
+}
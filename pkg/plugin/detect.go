@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package plugin
+
+import (
+	"deepin-sbom-tools/pkg/log"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Candidate 能识别软件包的插件及其置信度
+type Candidate struct {
+	Plugin     Plugin
+	Confidence int
+}
+
+// Detect 返回能识别软件包的插件, 按置信度从高到低排列, 置信度相同时保持插件的顺序
+func Detect(plugins []Plugin, path string) []Candidate {
+	var res []Candidate
+	for _, plug := range plugins {
+		if c := plug.Detect(path); c > ConfidenceNone {
+			res = append(res, Candidate{Plugin: plug, Confidence: c})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Confidence > res[j].Confidence
+	})
+	return res
+}
+
+// ByType 返回支持 typ 类型的插件
+func ByType(plugins []Plugin, typ string) (Plugin, error) {
+	for _, plug := range plugins {
+		for _, t := range plug.FileTypes() {
+			if t.Name == typ {
+				return plug, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown package type %s, supported types: %s", typ, strings.Join(TypeNames(plugins), ", "))
+}
+
+// Select 选择解析软件包的插件, typ 不为空时强制使用该类型的插件;
+// 否则选择置信度最高的插件, 最高置信度有多个插件时报错, 需要使用 typ 指定
+func Select(plugins []Plugin, path, typ string) (Plugin, error) {
+	if typ != "" {
+		plug, err := ByType(plugins, typ)
+		if err != nil {
+			return nil, err
+		}
+		if plug.Detect(path) == ConfidenceNone {
+			log.Warning(path, "is not recognized as", typ, "package, parse it as forced by -type")
+		}
+		return plug, nil
+	}
+
+	candidates := Detect(plugins, path)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%s unknown package type", path)
+	}
+	for _, c := range candidates {
+		log.Debug("detect", path+":", c.Plugin.GetPlugInfo().PlugName, "confidence", c.Confidence)
+	}
+	best := candidates[0]
+	var ambiguous []string
+	for _, c := range candidates[1:] {
+		if c.Confidence == best.Confidence {
+			ambiguous = append(ambiguous, c.Plugin.GetPlugInfo().PlugName)
+		}
+	}
+	if len(ambiguous) > 0 {
+		names := append([]string{best.Plugin.GetPlugInfo().PlugName}, ambiguous...)
+		return nil, fmt.Errorf("%s is ambiguous: recognized by %s with confidence %d, use -type to choose one",
+			path, strings.Join(names, ", "), best.Confidence)
+	}
+	return best.Plugin, nil
+}
+
+// TypeNames 返回插件支持的所有类型名称
+func TypeNames(plugins []Plugin) []string {
+	var names []string
+	for _, plug := range plugins {
+		for _, t := range plug.FileTypes() {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// MatchExtension 判断文件名是否带有这些文件类型的扩展名
+func MatchExtension(types []FileType, name string) bool {
+	for _, t := range types {
+		for _, ext := range t.Extensions {
+			if strings.HasSuffix(name, ext) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasExtension 判断文件名是否带有插件支持的扩展名
+func HasExtension(plugins []Plugin, name string) bool {
+	for _, plug := range plugins {
+		if MatchExtension(plug.FileTypes(), name) {
+			return true
+		}
+	}
+	return false
+}
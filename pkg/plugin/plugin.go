@@ -4,14 +4,20 @@
 
 package plugin
 
-import "github.com/spdx/tools-golang/spdx/v2/common"
+import (
+	"deepin-sbom-tools/pkg/tool"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
 
 // 插件接口
 // 软件包通用接口
 type Plugin interface {
 	GetPlugInfo() PlugInfo                     //获取通用软件包管理器信息
 	GetPMVersion() (string, error)             //软件包管理器版本
-	IsValid(path string) bool                  //是否需要解析器解析当前软件包
+	FileTypes() []FileType                     //插件支持的文件类型
+	Detect(path string) int                    //根据文件内容识别软件包, 返回置信度, 0 表示不支持
 	ParsePkgInfo(path string) (PkgInfo, error) //解析包信息
 }
 
@@ -21,25 +27,194 @@ type PlugInfo struct {
 	PlugVer  string
 }
 
+// 插件支持的文件类型
+type FileType struct {
+	Name        string   //-type 参数使用的名称, 如 deb
+	Extensions  []string //常见的扩展名, 批量模式按扩展名收集软件包
+	Description string
+}
+
+// 插件识别软件包的置信度, 多个插件都能识别时选择置信度最高的插件
+const (
+	ConfidenceNone    = 0   //不支持
+	ConfidenceName    = 10  //只有文件名或扩展名符合, 如 .dsc
+	ConfidenceMagic   = 50  //文件头的魔数符合, 如 ELF 头中的 AppImage 魔数
+	ConfidenceContent = 100 //魔数及包中的元数据均已确认, 如 ar 归档的第一个成员为 debian-binary
+)
+
+// CopyrightInFileComment 文件注释中扫描到的版权行的前缀
+const CopyrightInFileComment = "copyright found in file:\n"
+
 // 软件包通用信息
 type FileInfo struct {
-	FileName string
-	Hash     []common.Checksum
+	FileName  string
+	Hash      []common.Checksum
+	License   string //文件的许可证表达式, 来自 copyright 文件
+	Copyright string
+
+	LicenseInfoInFile []string //文件中出现的许可证, 开启许可证扫描时才有
+	CopyrightInFile   []string //文件中扫描到的版权行, 开启许可证扫描时才有
+}
+
+// SetLicenseScan 记录文件的许可证扫描结果, 与 copyright 文件中的版权分开保存, 不受设置顺序影响
+func (f *FileInfo) SetLicenseScan(licenses []string, copyrights []string) {
+	f.LicenseInfoInFile = licenses
+	f.CopyrightInFile = copyrights
+}
+
+// CopyrightText 返回文件的版权信息, copyright 文件中没有时使用扫描到的版权行
+func (f *FileInfo) CopyrightText() string {
+	if f.Copyright != "" {
+		return f.Copyright
+	}
+	return strings.Join(f.CopyrightInFile, "\n")
+}
+
+// CopyrightComment 同时有 copyright 文件中的版权时, 扫描到的版权行记录在文件注释中
+func (f *FileInfo) CopyrightComment() string {
+	if f.Copyright == "" || len(f.CopyrightInFile) == 0 {
+		return ""
+	}
+	return CopyrightInFileComment + strings.Join(f.CopyrightInFile, "\n")
 }
 
 // 通用包信息
 type PkgInfo struct {
+	Type             string //软件包类型, 如 deb、rpm
 	Name             string
 	Version          string
-	Architecture     string
+	Architecture     string //源码包为 source
+	Source           string //二进制包对应的源码包名
+	SourceVersion    string
 	Maintainer       string //upstream
 	Copyright        string
-	Depends          []string
+	Relations        []Relation //依赖、冲突、提供等软件包关系
 	LicenseDeclared  string
 	DownloadLocation string
 	Homepage         string
 	Section          string
 	Description      string
 	InstalledSize    int
-	FileList         []*FileInfo //包文件
+	FileList         []*FileInfo    //包文件
+	OtherLicenses    []OtherLicense //许可证表达式中引用的 LicenseRef-
+	Properties       []Property     //格式特有的属性, 如 snap 的 confinement
+	Packages         []PkgInfo      //内嵌的软件包, 如 snap 中的 deb 包, 只记录包本身的信息
+
+	FileName string            //内嵌软件包在上级软件包中的路径
+	Hash     []common.Checksum //内嵌软件包文件的摘要
+}
+
+// 格式特有的属性, 名称带有格式前缀, 如 snap:confinement
+type Property struct {
+	Name  string
+	Value string
+}
+
+// PropertiesComment 将属性按 "名称: 值" 逐行输出, 用于没有自定义属性的 SPDX 文档的注释
+func (p *PkgInfo) PropertiesComment() string {
+	var lines []string
+	for _, prop := range p.Properties {
+		lines = append(lines, prop.Name+": "+prop.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ArchSource 源码包的架构
+const ArchSource = "source"
+
+// SourceInfoPrefix 二进制包来源说明的前缀, 其后为源码包名与版本, 如 "built package from: hello 2.10-3"
+const SourceInfoPrefix = "built package from: "
+
+// SourceInfo 返回二进制包的来源说明, 源码包本身或没有源码信息时为空
+func (p *PkgInfo) SourceInfo() string {
+	if p.Architecture == ArchSource || p.Source == "" {
+		return ""
+	}
+	return strings.TrimSpace(SourceInfoPrefix + p.Source + " " + p.SourceVersion)
+}
+
+// 不在 SPDX 许可证列表中的许可证
+type OtherLicense struct {
+	ID   string //LicenseRef-xxx
+	Name string
+	Text string
+}
+
+// NormalizeLicenses 将软件包及各文件的许可证转换为合法的 SPDX 表达式,
+// texts 为许可证名称对应的全文, 用于填写 LicenseRef- 的许可证文本
+func (p *PkgInfo) NormalizeLicenses(texts map[string]string) {
+	n := tool.NewLicenseNormalizer(texts)
+	p.LicenseDeclared = n.Expression(p.LicenseDeclared)
+	for _, f := range p.FileList {
+		f.License = n.Expression(f.License)
+		if len(f.LicenseInfoInFile) > 0 {
+			f.LicenseInfoInFile = n.LicenseIDs(f.LicenseInfoInFile)
+		}
+	}
+	p.OtherLicenses = nil
+	for _, l := range n.ExtractedLicenses() {
+		p.OtherLicenses = append(p.OtherLicenses, OtherLicense{ID: l.ID, Name: l.Name, Text: l.Text})
+	}
+}
+
+// 软件包关系类型, 与 deb control 中的字段名一致
+const (
+	RelationPreDepends = "Pre-Depends"
+	RelationDepends    = "Depends"
+	RelationRecommends = "Recommends"
+	RelationSuggests   = "Suggests"
+	RelationBreaks     = "Breaks"
+	RelationConflicts  = "Conflicts"
+	RelationProvides   = "Provides"
+	RelationBuiltUsing = "Built-Using"
+
+	// 源码包的构建关系
+	RelationBuildDepends        = "Build-Depends"
+	RelationBuildDependsArch    = "Build-Depends-Arch"
+	RelationBuildDependsIndep   = "Build-Depends-Indep"
+	RelationBuildConflicts      = "Build-Conflicts"
+	RelationBuildConflictsArch  = "Build-Conflicts-Arch"
+	RelationBuildConflictsIndep = "Build-Conflicts-Indep"
+
+	// snap、flatpak 等应用包依赖的基础快照、内容快照及运行时
+	RelationBase    = "Base"
+	RelationContent = "Content"
+	RelationRuntime = "Runtime"
+)
+
+// 关系中引用的软件包及版本约束
+type Dependency struct {
+	Name     string
+	Arch     string //架构限定, 如 any、amd64
+	Operator string //版本比较符, 如 >=、<<
+	Version  string
+}
+
+// String 以 deb 依赖的书写方式输出, 如 "libc6:any (>= 2.34)"
+func (d Dependency) String() string {
+	s := d.Name
+	if d.Arch != "" {
+		s += ":" + d.Arch
+	}
+	if d.Operator != "" {
+		s += " (" + d.Operator + " " + d.Version + ")"
+	}
+	return s
+}
+
+// 一条软件包关系, 有多个候选时满足其中之一即可;
+// 生成文档时第一个候选使用关系对应的类型, 其余候选作为可选依赖:
+// SPDX 2 为 OPTIONAL_DEPENDENCY_OF, SPDX 3 为 hasOptionalDependency, CycloneDX 的范围为 optional
+type Relation struct {
+	Type         string
+	Alternatives []Dependency
+}
+
+// String 以 deb 依赖的书写方式输出, 候选之间以 | 分隔
+func (r Relation) String() string {
+	var parts []string
+	for _, d := range r.Alternatives {
+		parts = append(parts, d.String())
+	}
+	return strings.Join(parts, " | ")
 }
@@ -0,0 +1,301 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package spdx
+
+import (
+	"crypto/sha1"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+	"deepin-sbom-tools/pkg/version"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// creationInfoID 文档内共享的创建信息空白节点
+const creationInfoID = "_:creationinfo"
+
+// creatorName 文档的创建者
+const creatorName = "deepin"
+
+// SPDX 2.3 与 3.0 摘要算法名称对应关系
+var hashAlgos = map[common.ChecksumAlgorithm]string{
+	common.MD2:         "md2",
+	common.MD4:         "md4",
+	common.MD5:         "md5",
+	common.MD6:         "md6",
+	common.SHA1:        "sha1",
+	common.SHA224:      "sha224",
+	common.SHA256:      "sha256",
+	common.SHA384:      "sha384",
+	common.SHA512:      "sha512",
+	common.SHA3_256:    "sha3_256",
+	common.SHA3_384:    "sha3_384",
+	common.SHA3_512:    "sha3_512",
+	common.BLAKE2b_256: "blake2b256",
+	common.BLAKE2b_384: "blake2b384",
+	common.BLAKE2b_512: "blake2b512",
+	common.BLAKE3:      "blake3",
+	common.ADLER32:     "adler32",
+}
+
+// 软件包关系对应的关系类型, Breaks、Conflicts、Provides 没有对应的类型, 使用 other
+var relationshipTypes = map[string]string{
+	plugin.RelationPreDepends: RelationshipHasPrerequisite,
+	plugin.RelationDepends:    RelationshipDependsOn,
+	plugin.RelationRecommends: RelationshipHasOptionalDependency,
+	plugin.RelationSuggests:   RelationshipHasOptionalDependency,
+	plugin.RelationBuiltUsing: RelationshipHasStaticLink,
+
+	plugin.RelationBuildDepends:      RelationshipDependsOn,
+	plugin.RelationBuildDependsArch:  RelationshipDependsOn,
+	plugin.RelationBuildDependsIndep: RelationshipDependsOn,
+
+	plugin.RelationBase:    RelationshipDependsOn,
+	plugin.RelationContent: RelationshipDependsOn,
+	plugin.RelationRuntime: RelationshipDependsOn,
+}
+
+// HashesFromChecksums 转换校验和, 规范中没有的算法使用 other 并在 comment 中记录算法名称
+func HashesFromChecksums(checksums []common.Checksum) []Hash {
+	var hashes []Hash
+	for _, c := range checksums {
+		h := Hash{Type: TypeHash, Algorithm: hashAlgos[c.Algorithm], HashValue: c.Value}
+		if h.Algorithm == "" {
+			h.Algorithm = HashAlgorithmOther
+			h.Comment = string(c.Algorithm)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
+
+// genSpdxID 与 SPDX 2.3 文档中的元素 ID 使用相同规则, 加上文档命名空间构成 IRI
+func genSpdxID(docID string, prefix string, s string) string {
+	hSHA1 := sha1.New()
+	hSHA1.Write([]byte(s))
+	return fmt.Sprintf("%s#SPDXRef-%s-%x", docID, prefix, hSHA1.Sum(nil))
+}
+
+func cpeIdentifier(name, version string) []ExternalIdentifier {
+	return []ExternalIdentifier{{
+		Type:                   TypeExternalIdentifier,
+		ExternalIdentifierType: ExternalIdentifierCPE23,
+		Identifier:             tool.CPE(name, version),
+	}}
+}
+
+func isAssertion(s string) bool {
+	return s != "" && s != "NOASSERTION" && s != "NONE"
+}
+
+// CreateDocument 根据软件包信息生成 SPDX 3.0 文档, 软件包为文档的根元素, purlNS 为 purl 中的发行版命名空间
+func CreateDocument(pkg plugin.PkgInfo, namespaceBase, purlNS string) (*Document, error) {
+	if pkg.Maintainer == "" {
+		return nil, errors.New("not enough parameters")
+	}
+	docName := pkg.Name + "_" + pkg.Version + "_" + pkg.Architecture
+	docID := namespaceBase + docName
+	element := func(typ, id, name string) Element {
+		return Element{Type: typ, SpdxID: id, CreationInfo: creationInfoID, Name: name}
+	}
+
+	creator := Agent{element(TypeOrganization, genSpdxID(docID, "Organization", creatorName), creatorName)}
+	toolAgent := Agent{element(TypeTool, genSpdxID(docID, "Tool", "deepin-sbom-tools"), "deepin-sbom-tools_"+version.VERSION)}
+	doc := &Document{
+		CreationInfo: CreationInfo{
+			ID:           creationInfoID,
+			Type:         TypeCreationInfo,
+			SpecVersion:  SpecVersion,
+			Created:      time.Now().UTC().Format(time.RFC3339),
+			CreatedBy:    []string{creator.SpdxID},
+			CreatedUsing: []string{toolAgent.SpdxID},
+		},
+		SpdxDocument: SpdxDocument{
+			Element:            element(TypeSpdxDocument, docID+"#SPDXRef-DOCUMENT", docName),
+			ProfileConformance: []string{ProfileCore, ProfileSoftware, ProfileSimpleLicensing},
+		},
+		Agents: []Agent{creator, toolAgent},
+	}
+	relationship := func(from string, typ string, to []string) {
+		doc.Relationships = append(doc.Relationships, Relationship{
+			Element:          element(TypeRelationship, fmt.Sprintf("%s#SPDXRef-Relationship-%d", docID, len(doc.Relationships)), ""),
+			From:             from,
+			To:               to,
+			RelationshipType: typ,
+		})
+	}
+
+	top := Package{
+		Element:        element(TypePackage, genSpdxID(docID, "PACKAGE", pkg.Name), pkg.Name),
+		PrimaryPurpose: PurposeInstall,
+		PackageVersion: pkg.Version,
+		HomePage:       pkg.Homepage,
+		CopyrightText:  pkg.Copyright,
+		SourceInfo:     pkg.SourceInfo(),
+	}
+	if pkg.Architecture == plugin.ArchSource {
+		top.PrimaryPurpose = PurposeSource
+	}
+	top.Description = pkg.Description
+	top.Comment = pkg.PropertiesComment()
+	if isAssertion(pkg.DownloadLocation) {
+		top.DownloadLocation = pkg.DownloadLocation
+	}
+	if pkg.Type != "" {
+		top.PackageURL = tool.PackageURL(pkg.Type, purlNS, pkg.Name, pkg.Version, pkg.Architecture)
+		top.ExternalIdentifier = cpeIdentifier(pkg.Name, pkg.Version)
+	}
+	// 相同的供应商只生成一个元素
+	supplierID := func(name string) string {
+		id := genSpdxID(docID, "Organization", name)
+		for _, a := range doc.Agents {
+			if a.SpdxID == id {
+				return id
+			}
+		}
+		doc.Agents = append(doc.Agents, Agent{element(TypeOrganization, id, name)})
+		return id
+	}
+	if isAssertion(pkg.Maintainer) {
+		top.SuppliedBy = supplierID(pkg.Maintainer)
+	}
+	doc.Packages = append(doc.Packages, top)
+	doc.SpdxDocument.RootElement = []string{top.SpdxID}
+	relationship(doc.SpdxDocument.SpdxID, RelationshipDescribes, []string{top.SpdxID})
+
+	// 相同的许可证表达式只生成一个元素
+	licenseID := func(expr string) string {
+		id := genSpdxID(docID, "LicenseExpression", expr)
+		for _, l := range doc.Licenses {
+			if l.SpdxID == id {
+				return id
+			}
+		}
+		doc.Licenses = append(doc.Licenses, LicenseExpression{
+			Element:           element(TypeLicenseExpression, id, ""),
+			LicenseExpression: expr,
+		})
+		return id
+	}
+	if isAssertion(pkg.LicenseDeclared) {
+		relationship(top.SpdxID, RelationshipHasDeclaredLicense, []string{licenseID(pkg.LicenseDeclared)})
+	}
+
+	// 第一个候选使用关系对应的类型, 其余候选满足第一个候选时不需要安装, 作为 hasOptionalDependency;
+	// 注释中保留原始关系
+	seen := make(map[string]bool)
+	for _, rel := range pkg.Relations {
+		var to []string
+		for _, dep := range rel.Alternatives {
+			id := genSpdxID(docID, "DEPEND", dep.String())
+			to = append(to, id)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			p := Package{Element: element(TypePackage, id, dep.Name)}
+			// 只有精确版本约束才能确定依赖的版本
+			if dep.Operator == "=" {
+				p.PackageVersion = dep.Version
+			} else if dep.Operator != "" {
+				p.Comment = "version constraint: " + dep.Operator + " " + dep.Version
+			}
+			if pkg.Type != "" && !strings.ContainsAny(dep.Name, "/()") {
+				p.PackageURL = tool.PackageURL(pkg.Type, purlNS, dep.Name, p.PackageVersion, "")
+				p.ExternalIdentifier = cpeIdentifier(dep.Name, p.PackageVersion)
+			}
+			doc.Packages = append(doc.Packages, p)
+		}
+		typ, ok := relationshipTypes[rel.Type]
+		if !ok {
+			typ = RelationshipOther
+		}
+		if typ != RelationshipOther && len(to) > 1 {
+			relationship(top.SpdxID, typ, to[:1])
+			doc.Relationships[len(doc.Relationships)-1].Comment = rel.Type + ": " + rel.String()
+			typ, to = RelationshipHasOptionalDependency, to[1:]
+		}
+		relationship(top.SpdxID, typ, to)
+		doc.Relationships[len(doc.Relationships)-1].Comment = rel.Type + ": " + rel.String()
+	}
+
+	// 内嵌的软件包由顶层软件包 contains, 软件包没有文件名属性, 路径记录在注释中
+	var nested []string
+	for _, p := range pkg.Packages {
+		n := Package{
+			Element:        element(TypePackage, genSpdxID(docID, "PACKAGE", p.Type+" "+p.Name+" "+p.Version+" "+p.Architecture+" "+p.FileName), p.Name),
+			PackageVersion: p.Version,
+			HomePage:       p.Homepage,
+			CopyrightText:  p.Copyright,
+			SourceInfo:     p.SourceInfo(),
+		}
+		n.Description = p.Description
+		n.VerifiedUsing = HashesFromChecksums(p.Hash)
+		n.Comment = p.PropertiesComment()
+		if p.FileName != "" {
+			n.Comment = strings.TrimSpace("file name: " + p.FileName + "\n" + n.Comment)
+		}
+		if isAssertion(p.DownloadLocation) {
+			n.DownloadLocation = p.DownloadLocation
+		}
+		if isAssertion(p.Maintainer) {
+			n.SuppliedBy = supplierID(p.Maintainer)
+		}
+		if p.Type != "" {
+			n.PackageURL = tool.PackageURL(p.Type, purlNS, p.Name, p.Version, p.Architecture)
+			n.ExternalIdentifier = cpeIdentifier(p.Name, p.Version)
+		}
+		doc.Packages = append(doc.Packages, n)
+		nested = append(nested, n.SpdxID)
+		if isAssertion(p.LicenseDeclared) {
+			relationship(n.SpdxID, RelationshipHasDeclaredLicense, []string{licenseID(p.LicenseDeclared)})
+		}
+	}
+	if len(nested) > 0 {
+		relationship(top.SpdxID, RelationshipContains, nested)
+	}
+
+	var files []string
+	for _, f := range pkg.FileList {
+		file := File{Element: element(TypeFile, genSpdxID(docID, "FILE", f.FileName), f.FileName)}
+		file.VerifiedUsing = HashesFromChecksums(f.Hash)
+		file.CopyrightText = f.CopyrightText()
+		file.Comment = f.CopyrightComment()
+		doc.Files = append(doc.Files, file)
+		files = append(files, file.SpdxID)
+		if isAssertion(f.License) {
+			relationship(file.SpdxID, RelationshipHasConcludedLicense, []string{licenseID(f.License)})
+		}
+		// 文件中扫描到的许可证作为文件声明的许可证
+		if len(f.LicenseInfoInFile) > 0 {
+			relationship(file.SpdxID, RelationshipHasDeclaredLicense, []string{licenseID(strings.Join(f.LicenseInfoInFile, " AND "))})
+		}
+	}
+	if len(files) > 0 {
+		relationship(top.SpdxID, RelationshipContains, files)
+	}
+
+	for _, a := range doc.Agents {
+		doc.SpdxDocument.Elements = append(doc.SpdxDocument.Elements, a.SpdxID)
+	}
+	for _, p := range doc.Packages {
+		doc.SpdxDocument.Elements = append(doc.SpdxDocument.Elements, p.SpdxID)
+	}
+	for _, f := range doc.Files {
+		doc.SpdxDocument.Elements = append(doc.SpdxDocument.Elements, f.SpdxID)
+	}
+	for _, l := range doc.Licenses {
+		doc.SpdxDocument.Elements = append(doc.SpdxDocument.Elements, l.SpdxID)
+	}
+	for _, r := range doc.Relationships {
+		doc.SpdxDocument.Elements = append(doc.SpdxDocument.Elements, r.SpdxID)
+	}
+	return doc, nil
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package spdx
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type jsonLD struct {
+	Context string        `json:"@context"`
+	Graph   []interface{} `json:"@graph"`
+}
+
+// WriteJSONLD 以 JSON-LD 格式输出文档, 元素按创建信息、参与者、文档、软件包、文件、许可证、关系的顺序排列
+func WriteJSONLD(doc *Document, w io.Writer) error {
+	out := jsonLD{Context: Context}
+	out.Graph = append(out.Graph, doc.CreationInfo)
+	for _, a := range doc.Agents {
+		out.Graph = append(out.Graph, a)
+	}
+	out.Graph = append(out.Graph, doc.SpdxDocument)
+	for _, p := range doc.Packages {
+		out.Graph = append(out.Graph, p)
+	}
+	for _, f := range doc.Files {
+		out.Graph = append(out.Graph, f)
+	}
+	for _, l := range doc.Licenses {
+		out.Graph = append(out.Graph, l)
+	}
+	for _, r := range doc.Relationships {
+		out.Graph = append(out.Graph, r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(out)
+}
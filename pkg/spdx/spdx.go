@@ -2,4 +2,150 @@
 //
 // SPDX-License-Identifier: GPL-3.0-or-later
 
+// Package spdx SPDX 3.0 文档模型, 包含 Core、Software 与 SimpleLicensing 配置中使用到的元素
 package spdx
+
+// SPDX 3.0 规范
+const (
+	SpecVersion = "3.0.1"
+	Context     = "https://spdx.org/rdf/3.0.1/spdx-context.jsonld"
+)
+
+// 元素类型, 非 Core 配置的类型带有配置名前缀
+const (
+	TypeCreationInfo       = "CreationInfo"
+	TypeOrganization       = "Organization"
+	TypeTool               = "Tool"
+	TypeSpdxDocument       = "SpdxDocument"
+	TypeRelationship       = "Relationship"
+	TypeHash               = "Hash"
+	TypeExternalIdentifier = "ExternalIdentifier"
+	TypePackage            = "software_Package"
+	TypeFile               = "software_File"
+	TypeLicenseExpression  = "simplelicensing_LicenseExpression"
+)
+
+// 文档遵循的配置
+const (
+	ProfileCore            = "core"
+	ProfileSoftware        = "software"
+	ProfileSimpleLicensing = "simpleLicensing"
+)
+
+// 关系类型
+const (
+	RelationshipDescribes             = "describes"
+	RelationshipContains              = "contains"
+	RelationshipDependsOn             = "dependsOn"
+	RelationshipHasDeclaredLicense    = "hasDeclaredLicense"
+	RelationshipHasConcludedLicense   = "hasConcludedLicense"
+	RelationshipHasPrerequisite       = "hasPrerequisite"
+	RelationshipHasOptionalDependency = "hasOptionalDependency"
+	RelationshipHasStaticLink         = "hasStaticLink"
+	RelationshipOther                 = "other"
+)
+
+// 摘要算法
+const (
+	HashAlgorithmOther = "other"
+)
+
+// 外部标识类型
+const (
+	ExternalIdentifierCPE23 = "cpe23"
+)
+
+// 软件用途
+const (
+	PurposeInstall = "install"
+	PurposeSource  = "source"
+)
+
+// CreationInfo 文档中所有元素共享的创建信息, 以空白节点形式引用
+type CreationInfo struct {
+	ID           string   `json:"@id"`
+	Type         string   `json:"type"`
+	SpecVersion  string   `json:"specVersion"`
+	Created      string   `json:"created"`
+	CreatedBy    []string `json:"createdBy"`
+	CreatedUsing []string `json:"createdUsing,omitempty"`
+}
+
+// Element 所有元素的公共属性, 引用其他元素时使用其 spdxId
+type Element struct {
+	Type               string               `json:"type"`
+	SpdxID             string               `json:"spdxId"`
+	CreationInfo       string               `json:"creationInfo"`
+	Name               string               `json:"name,omitempty"`
+	Description        string               `json:"description,omitempty"`
+	Comment            string               `json:"comment,omitempty"`
+	VerifiedUsing      []Hash               `json:"verifiedUsing,omitempty"`
+	ExternalIdentifier []ExternalIdentifier `json:"externalIdentifier,omitempty"`
+}
+
+// ExternalIdentifier 元素的外部标识, 如 CPE
+type ExternalIdentifier struct {
+	Type                   string `json:"type"`
+	ExternalIdentifierType string `json:"externalIdentifierType"`
+	Identifier             string `json:"identifier"`
+}
+
+// Hash 元素的摘要, 规范中没有的算法(如 SM3)使用 other 并在 comment 中注明
+type Hash struct {
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	HashValue string `json:"hashValue"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// Agent 组织或工具
+type Agent struct {
+	Element
+}
+
+type SpdxDocument struct {
+	Element
+	ProfileConformance []string `json:"profileConformance,omitempty"`
+	RootElement        []string `json:"rootElement,omitempty"`
+	Elements           []string `json:"element,omitempty"`
+}
+
+type Package struct {
+	Element
+	SuppliedBy       string `json:"suppliedBy,omitempty"`
+	PrimaryPurpose   string `json:"software_primaryPurpose,omitempty"`
+	CopyrightText    string `json:"software_copyrightText,omitempty"`
+	PackageVersion   string `json:"software_packageVersion,omitempty"`
+	DownloadLocation string `json:"software_downloadLocation,omitempty"`
+	HomePage         string `json:"software_homePage,omitempty"`
+	PackageURL       string `json:"software_packageUrl,omitempty"`
+	SourceInfo       string `json:"software_sourceInfo,omitempty"`
+}
+
+type File struct {
+	Element
+	CopyrightText string `json:"software_copyrightText,omitempty"`
+}
+
+type Relationship struct {
+	Element
+	From             string   `json:"from"`
+	To               []string `json:"to"`
+	RelationshipType string   `json:"relationshipType"`
+}
+
+type LicenseExpression struct {
+	Element
+	LicenseExpression string `json:"simplelicensing_licenseExpression"`
+}
+
+// Document 一个 SPDX 3.0 文档包含的全部元素, 序列化时平铺到 JSON-LD 的 @graph 中
+type Document struct {
+	CreationInfo  CreationInfo
+	SpdxDocument  SpdxDocument
+	Agents        []Agent
+	Packages      []Package
+	Files         []File
+	Licenses      []LicenseExpression
+	Relationships []Relationship
+}
@@ -0,0 +1,371 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package audit_cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/tool"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+// 报告输出格式
+const (
+	reportText = "text"
+	reportJSON = "json"
+)
+
+// 摘要不一致的来源
+const (
+	sourceFilesystem = "filesystem" //本地文件的实际摘要
+	sourceDpkg       = "dpkg"       //dpkg 数据库 md5sums 中的摘要
+)
+
+type auditOpt struct {
+	dir     string
+	root    string
+	format  string
+	output  string
+	verbose bool
+}
+
+// auditFile 审计发现的问题文件
+type auditFile struct {
+	File      string `json:"file"`
+	Package   string `json:"package,omitempty"`
+	SBOM      string `json:"sbom,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// auditReport 审计结果
+type auditReport struct {
+	Root       string      `json:"root"`
+	Documents  []string    `json:"documents"`
+	Skipped    []string    `json:"skipped,omitempty"`
+	Checked    int         `json:"checked"`
+	Modified   []auditFile `json:"modified"`
+	Missing    []auditFile `json:"missing"`
+	Unreadable []auditFile `json:"unreadable"`
+	Unowned    []auditFile `json:"unowned"`
+}
+
+// sbomDocument 读取到的 sbom 及其来源文件
+type sbomDocument struct {
+	name     string
+	document *v2_3.Document
+}
+
+func New() *auditOpt {
+	return &auditOpt{}
+}
+
+func (a *auditOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
+	flag.StringVar(&a.dir, "d", "", "the directory of sbom files or deb packages with embedded SBOM")
+	flag.StringVar(&a.root, "root", "/", "the root directory of the system to audit")
+	flag.StringVar(&a.format, "format", reportText, "the report format: "+reportText+", "+reportJSON)
+	flag.StringVar(&a.output, "o", "", "the file to save the report, print to stdout if empty")
+	flag.BoolVar(&a.verbose, "v", false, "enable verbose mode")
+
+	flag.Usage = func() {
+		fmt.Println("Usage:", os.Args[0], "audit [arguments]")
+		fmt.Println("Example:", os.Args[0], "audit -d /var/lib/sbom -format json -o report.json")
+		fmt.Println("arguments:")
+		flag.PrintDefaults()
+	}
+
+	// 解析命令行参数
+	flag.Parse(args)
+
+	// 必要参数判断
+	if a.dir == "" {
+		return fmt.Errorf("the sbom directory must exist")
+	}
+	if a.format != reportText && a.format != reportJSON {
+		return fmt.Errorf("unsupported report format %s, available: %s, %s", a.format, reportText, reportJSON)
+	}
+	return nil
+}
+
+func (a *auditOpt) Run() error {
+	docs, skipped, err := a.readDocuments()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no sbom found in %s", a.dir)
+	}
+	dpkgFiles, err := tool.ReadDpkgFiles(a.root)
+	if err != nil {
+		return err
+	}
+
+	// 各列表初始化为空切片, json 报告中输出 [] 而不是 null
+	report := &auditReport{
+		Root:       a.root,
+		Skipped:    skipped,
+		Modified:   []auditFile{},
+		Missing:    []auditFile{},
+		Unreadable: []auditFile{},
+		Unowned:    []auditFile{},
+	}
+	owned := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, d := range docs {
+		report.Documents = append(report.Documents, d.name)
+		pkgName := describedPackage(d.document)
+		for _, f := range d.document.Files {
+			name := path.Clean("/" + f.FileName)
+			owned[name] = true
+			dirs[path.Dir(name)] = true
+			report.Checked++
+			a.auditFile(report, auditFile{File: name, Package: pkgName, SBOM: d.name}, f.Checksums, dpkgFiles[name])
+		}
+	}
+	a.findUnowned(report, dirs, owned, dpkgFiles)
+
+	if err := a.writeReport(report); err != nil {
+		return err
+	}
+	if n := len(report.Modified) + len(report.Missing) + len(report.Unreadable) + len(report.Unowned); n > 0 {
+		return fmt.Errorf("audit found %d problems: %d modified, %d missing, %d unreadable, %d unowned",
+			n, len(report.Modified), len(report.Missing), len(report.Unreadable), len(report.Unowned))
+	}
+	return nil
+}
+
+// readDocuments 读取目录中所有可识别的 sbom 文件及 deb 包中嵌入的 sbom, 无法读取的文件记录在 skipped 中
+func (a *auditOpt) readDocuments() ([]sbomDocument, []string, error) {
+	infos, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var docs []sbomDocument
+	var skipped []string
+	for _, info := range infos {
+		name := filepath.Join(a.dir, info.Name())
+		if !info.Mode().IsRegular() || !doc.IsSBOMFile(name) {
+			continue
+		}
+		// 只用到其中的文件信息, CycloneDX 转换时的命名空间不影响审计结果
+		document, _, err := doc.ReadSBOMFile(name, "https://www.deepin.org/namespace/package/")
+		if err != nil {
+			log.Debug("skip", name+":", err)
+			skipped = append(skipped, name)
+			continue
+		}
+		docs = append(docs, sbomDocument{name: name, document: document})
+	}
+	return docs, skipped, nil
+}
+
+// describedPackage 返回 sbom 描述的软件包名
+func describedPackage(document *v2_3.Document) string {
+	for _, rel := range document.Relationships {
+		if rel.Relationship != common.TypeRelationshipDescribe {
+			continue
+		}
+		for _, pkg := range document.Packages {
+			if pkg.PackageSPDXIdentifier == rel.RefB.ElementRefID {
+				return pkg.PackageName
+			}
+		}
+	}
+	if len(document.Packages) > 0 {
+		return document.Packages[0].PackageName
+	}
+	return ""
+}
+
+// resolveDir 在 root 内解析目录中的符号链接, 绝对路径的链接目标相对于 root 而不是当前系统,
+// 返回 root 下的实际目录
+func resolveDir(root, dir string) (string, error) {
+	resolved := "/"
+	parts := strings.Split(dir, "/")
+	for links := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		// 与内核一样限制解析的链接数, 避免链接成环
+		if links++; links > 40 {
+			return "", &os.PathError{Op: "resolve", Path: dir, Err: syscall.ELOOP}
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// reportError 文件或上级目录不存在时记为缺失, 其他错误记为无法读取
+func reportError(report *auditReport, file auditFile, err error) {
+	log.Debug(err)
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		report.Missing = append(report.Missing, file)
+		return
+	}
+	file.Error = err.Error()
+	report.Unreadable = append(report.Unreadable, file)
+}
+
+// auditFile 比对 sbom 中记录的摘要与本地文件及 dpkg 数据库中的摘要;
+// 文件本身为符号链接时不跟随, sbom 中不记录链接的摘要
+func (a *auditOpt) auditFile(report *auditReport, file auditFile, recorded []common.Checksum, dpkgFile *tool.DpkgFile) {
+	dir, err := resolveDir(a.root, path.Dir(file.File))
+	if err != nil {
+		reportError(report, file, err)
+		return
+	}
+	name := filepath.Join(dir, path.Base(file.File))
+	info, err := os.Lstat(name)
+	if err != nil {
+		reportError(report, file, err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+	sha1, sha256, md5, sm3, err := tool.GetHashesForFilePath(name)
+	if err != nil {
+		reportError(report, file, err)
+		return
+	}
+	actual := []common.Checksum{
+		{Algorithm: common.SHA1, Value: sha1},
+		{Algorithm: common.SHA256, Value: sha256},
+		{Algorithm: common.MD5, Value: md5},
+		{Algorithm: "SM3", Value: sm3},
+	}
+	if algo, ok := tool.CompareChecksums(recorded, actual); !ok && algo != "" {
+		file.Source = sourceFilesystem
+		file.Algorithm = string(algo)
+		file.Expected = tool.ChecksumValue(recorded, algo)
+		file.Actual = tool.ChecksumValue(actual, algo)
+		report.Modified = append(report.Modified, file)
+		return
+	}
+	// 本地文件未被修改时, 再检查 dpkg 数据库中的摘要是否与 sbom 一致
+	if dpkgFile == nil || dpkgFile.MD5 == "" {
+		return
+	}
+	expected := tool.ChecksumValue(recorded, common.MD5)
+	if expected != "" && !strings.EqualFold(expected, dpkgFile.MD5) {
+		file.Source = sourceDpkg
+		file.Algorithm = string(common.MD5)
+		file.Expected = expected
+		file.Actual = dpkgFile.MD5
+		report.Modified = append(report.Modified, file)
+	}
+}
+
+// findUnowned 查找 sbom 文件所在目录中既不属于任何 sbom 也不在 dpkg 文件列表中的普通文件
+func (a *auditOpt) findUnowned(report *auditReport, dirs, owned map[string]bool, dpkgFiles map[string]*tool.DpkgFile) {
+	var names []string
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+	for _, dir := range names {
+		resolved, err := resolveDir(a.root, dir)
+		if err != nil {
+			continue
+		}
+		infos, err := ioutil.ReadDir(resolved)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := path.Join(dir, info.Name())
+			if !info.Mode().IsRegular() || owned[name] || dpkgFiles[name] != nil {
+				continue
+			}
+			report.Unowned = append(report.Unowned, auditFile{File: name})
+		}
+	}
+}
+
+func (a *auditOpt) writeReport(report *auditReport) (err error) {
+	var w io.Writer = os.Stdout
+	if a.output != "" {
+		f, err := os.Create(a.output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	for _, files := range [][]auditFile{report.Modified, report.Missing, report.Unreadable, report.Unowned} {
+		sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+	}
+
+	if a.format == reportJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(report)
+	}
+	bw := bufio.NewWriter(w)
+	for _, f := range report.Modified {
+		fmt.Fprintf(bw, "MODIFIED  %s  %s (%s %s: expected %s, actual %s)\n",
+			f.File, f.Package, f.Source, f.Algorithm, f.Expected, f.Actual)
+	}
+	for _, f := range report.Missing {
+		fmt.Fprintf(bw, "MISSING   %s  %s\n", f.File, f.Package)
+	}
+	for _, f := range report.Unreadable {
+		fmt.Fprintf(bw, "UNREADABLE  %s  %s (%s)\n", f.File, f.Package, f.Error)
+	}
+	for _, f := range report.Unowned {
+		fmt.Fprintf(bw, "UNOWNED   %s\n", f.File)
+	}
+	for _, name := range report.Skipped {
+		fmt.Fprintf(bw, "SKIPPED   %s\n", name)
+	}
+	fmt.Fprintf(bw, "%d sbom, %d files checked: %d modified, %d missing, %d unreadable, %d unowned\n",
+		len(report.Documents), report.Checked, len(report.Modified), len(report.Missing), len(report.Unreadable), len(report.Unowned))
+	return bw.Flush()
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package audit_cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/tool"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger("", log.LevelWarning)
+	os.Exit(m.Run())
+}
+
+// TestAuditFile 在 -root 指定的镜像中审计文件, 镜像内的绝对路径符号链接相对于镜像根目录解析
+func TestAuditFile(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"usr/bin/hello":      "hello",
+		"usr/bin/changed":    "changed",
+		"usr/bin/notdir":     "file",
+		"usr/lib/libfoo.so":  "foo",
+		"usr/share/doc/note": "note",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range map[string]string{
+		"lib":          "/usr/lib",
+		"doc":          "../../../usr/share/doc",
+		"usr/bin/link": "/usr/bin/hello",
+		"loop":         "/loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorded := map[string]string{
+		"/usr/bin/hello":    "hello",
+		"/usr/bin/changed":  "original",
+		"/usr/bin/gone":     "gone",
+		"/usr/bin/notdir/x": "x",
+		"/usr/bin/link":     "link",
+		"/lib/libfoo.so":    "foo",
+		"/doc/note":         "note",
+		"/loop/x":           "x",
+	}
+	a := &auditOpt{root: root}
+	report := &auditReport{}
+	for name, content := range recorded {
+		checksums, err := tool.GetChecksumsForReader(strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.auditFile(report, auditFile{File: name}, checksums, nil)
+	}
+	files := func(list []auditFile) []string {
+		var names []string
+		for _, f := range list {
+			names = append(names, f.File)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// 链接本身在 sbom 中不记录摘要, 不跟随; 镜像外不存在的 /usr/lib/libfoo.so 不应影响结果
+	if got, want := files(report.Modified), []string{"/usr/bin/changed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("modified = %q, want %q", got, want)
+	}
+	if got, want := files(report.Missing), []string{"/usr/bin/gone", "/usr/bin/notdir/x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing = %q, want %q", got, want)
+	}
+	if got := files(report.Unreadable); !reflect.DeepEqual(got, []string{"/loop/x"}) {
+		t.Errorf("unreadable = %q, want [/loop/x]", got)
+	} else if !strings.Contains(report.Unreadable[0].Error, "too many levels of symbolic links") {
+		t.Errorf("unreadable error = %q", report.Unreadable[0].Error)
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package convert_cmd
+
+import (
+	"bufio"
+	"deepin-sbom-tools/pkg/cyclonedx"
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+type convertOpt struct {
+	input   string
+	from    string
+	to      string
+	output  string
+	ns      string
+	verbose bool
+}
+
+func New() *convertOpt {
+	return &convertOpt{}
+}
+
+func (c *convertOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
+	formats := strings.Join(doc.Formats(), ", ")
+	flag.StringVar(&c.input, "i", "", "the sbom file which will be converted")
+	flag.StringVar(&c.from, "f", doc.FormatSPDXJSON, "the input file format: "+formats)
+	flag.StringVar(&c.to, "t", "", "the output file format: "+formats)
+	flag.StringVar(&c.output, "o", "./", "the directory to save converted file")
+	flag.StringVar(&c.ns, "ns", "https://www.deepin.org/namespace/package", "the sbom document namespace base url, used when converting to SPDX.")
+	flag.BoolVar(&c.verbose, "v", false, "enable verbose mode")
+
+	flag.Usage = func() {
+		fmt.Println("Usage:", os.Args[0], "convert [arguments]")
+		fmt.Println("Example:", os.Args[0], "convert -i example.spdx.json -t cyclonedx-json")
+		fmt.Println("arguments:")
+		flag.PrintDefaults()
+	}
+
+	// 解析命令行参数
+	flag.Parse(args)
+
+	// 必要参数判断
+	if c.input == "" {
+		return fmt.Errorf("the sbom file must exist")
+	}
+	if c.to == "" {
+		return fmt.Errorf("the output format must be specified")
+	}
+	if _, err := doc.FormatExt(c.from); err != nil {
+		return err
+	}
+	if _, err := doc.FormatExt(c.to); err != nil {
+		return err
+	}
+	if doc.IsSPDX3(c.from) || doc.IsSPDX3(c.to) {
+		return fmt.Errorf("converting %s documents is not supported", doc.FormatSPDX3JSONLD)
+	}
+	return nil
+}
+
+// outputName 将输入文件名的扩展名替换为目标格式的扩展名
+func (c *convertOpt) outputName() string {
+	name := filepath.Base(c.input)
+	fromExt, _ := doc.FormatExt(c.from)
+	toExt, _ := doc.FormatExt(c.to)
+	if strings.HasSuffix(name, fromExt) {
+		name = strings.TrimSuffix(name, fromExt)
+	} else {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name + toExt
+}
+
+func (c *convertOpt) Run() error {
+	if f, err := os.Stat(c.output); err != nil || !f.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.output)
+	}
+
+	in, err := os.Open(c.input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var bom *cyclonedx.BOM
+	var document *v2_3.Document
+	if doc.IsCycloneDX(c.from) {
+		bom, err = doc.ReadBOM(in, c.from)
+	} else {
+		document, err = doc.ReadDocument(in, c.from)
+	}
+	if err != nil {
+		return err
+	}
+
+	// SPDX 与 CycloneDX 之间相互转换, 同类格式之间直接重新输出
+	if doc.IsCycloneDX(c.to) && bom == nil {
+		bom = cyclonedx.FromSPDX(document)
+	} else if !doc.IsCycloneDX(c.to) && document == nil {
+		if !strings.HasSuffix(c.ns, "/") {
+			c.ns = c.ns + "/"
+		}
+		document, err = doc.FromCycloneDX(bom, c.ns)
+		if err != nil {
+			return err
+		}
+	}
+
+	outPath := filepath.Join(c.output, c.outputName())
+	if abs, err := filepath.Abs(outPath); err == nil {
+		if in, err := filepath.Abs(c.input); err == nil && in == abs {
+			return fmt.Errorf("the output file %s would overwrite the input file", outPath)
+		}
+	}
+	f, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if doc.IsCycloneDX(c.to) {
+		err = doc.WriteBOM(bom, w, c.to)
+	} else {
+		err = doc.WriteDocument(document, w, c.to)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	path, err := filepath.Abs(f.Name())
+	if err != nil {
+		return err
+	}
+	log.Infof("SBOM written to %s\n", path)
+	return nil
+}
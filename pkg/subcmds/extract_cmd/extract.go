@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package extract_cmd
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/tool"
+)
+
+type extractOpt struct {
+	input   string
+	output  string
+	verbose bool
+}
+
+func New() *extractOpt {
+	return &extractOpt{}
+}
+
+func (e *extractOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
+	flag.StringVar(&e.input, "i", "", "the deb package with embedded SBOM")
+	flag.StringVar(&e.output, "o", "", "the directory to save the SBOM and its signature, print the SBOM to stdout if empty")
+	flag.BoolVar(&e.verbose, "v", false, "enable verbose mode")
+
+	flag.Usage = func() {
+		fmt.Println("Usage:", os.Args[0], "extract [arguments]")
+		fmt.Println("Example:", os.Args[0], "extract -i example.deb -o ./")
+		fmt.Println("arguments:")
+		flag.PrintDefaults()
+	}
+
+	// 解析命令行参数
+	flag.Parse(args)
+
+	// 必要参数判断
+	if e.input == "" {
+		return fmt.Errorf("the deb package must exist")
+	}
+	return nil
+}
+
+func (e *extractOpt) Run() error {
+	sbom, err := tool.ExtractDebSBOM(e.input)
+	if err != nil {
+		return err
+	}
+	if e.output == "" {
+		_, err := os.Stdout.Write(sbom)
+		return err
+	}
+
+	sign, err := tool.GetDebSignInfo(e.input)
+	if err != nil && err != tool.ErrNoDebSign {
+		return err
+	}
+
+	// 文件名与 deb 包名一致, 签名文件名与 sign 子命令的输出一致
+	dirPath, err := filepath.Abs(e.output)
+	if err != nil {
+		return err
+	}
+	sbomFile := filepath.Join(dirPath, strings.TrimSuffix(filepath.Base(e.input), ".deb")+".spdx.json")
+	if err := ioutil.WriteFile(sbomFile, sbom, 0644); err != nil {
+		return err
+	}
+	log.Info("SBOM saved:", sbomFile)
+	if sign != nil {
+		if err := ioutil.WriteFile(sbomFile+".sign", sign, 0644); err != nil {
+			return err
+		}
+		log.Info("signature saved:", sbomFile+".sign")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package generate_cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/modules"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+
+	"github.com/panjf2000/ants"
+)
+
+// batchResult 批量模式中一个软件包的处理结果
+type batchResult struct {
+	Package string `json:"package"`
+	SBOM    string `json:"sbom,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+// batchReport 批量模式的汇总报告
+type batchReport struct {
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	Results   []batchResult `json:"results"`
+}
+
+// isBatch 输入为目录、通配符或 Packages 索引时使用批量模式, 能被插件识别的目录(如源码目录及 flatpak 应用目录)除外
+func (g *generateOpt) isBatch() bool {
+	if g.packages != "" || strings.ContainsAny(g.input, "*?[") {
+		return true
+	}
+	info, err := os.Stat(g.input)
+	return err == nil && info.IsDir() && len(plugin.Detect(g.batchPlugins(), g.input)) == 0
+}
+
+// batchPlugins 批量模式使用的插件, 指定 -type 时只使用该类型的插件
+func (g *generateOpt) batchPlugins() []plugin.Plugin {
+	plugins := modules.NewPlugins(g.hashOptions())
+	if g.typ == "" {
+		return plugins
+	}
+	plug, err := plugin.ByType(plugins, g.typ)
+	if err != nil {
+		return nil
+	}
+	return []plugin.Plugin{plug}
+}
+
+// batchInputs 收集批量模式需要处理的软件包
+func (g *generateOpt) batchInputs() ([]string, error) {
+	if g.packages != "" {
+		paragraphs, err := tool.ReadAptPackagesIndex(g.packages)
+		if err != nil {
+			return nil, err
+		}
+		var inputs []string
+		for _, p := range paragraphs {
+			// Filename 为相对于仓库根目录的路径, 如 pool/main/h/hello/hello_2.10-3_amd64.deb
+			if name := p.Get("Filename"); name != "" {
+				inputs = append(inputs, filepath.Join(g.repo, name))
+			}
+		}
+		return inputs, nil
+	}
+	if info, err := os.Stat(g.input); err == nil && info.IsDir() {
+		plugins := g.batchPlugins()
+		var inputs []string
+		err := filepath.Walk(g.input, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && plugin.HasExtension(plugins, path) {
+				inputs = append(inputs, path)
+			}
+			return nil
+		})
+		return inputs, err
+	}
+	matches, err := filepath.Glob(g.input)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+			inputs = append(inputs, m)
+		}
+	}
+	return inputs, nil
+}
+
+// reserveOutput 批量模式中登记输出文件名, 不同软件包生成相同的文件名时在扩展名前加上序号, 避免互相覆盖
+func (g *generateOpt) reserveOutput(fileName, pkgFilePath string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.outputs == nil {
+		return fileName
+	}
+	name := fileName
+	if other, ok := g.outputs[name]; ok {
+		ext, _ := doc.FormatExt(g.format)
+		base := strings.TrimSuffix(fileName, ext)
+		for i := 2; ok; i++ {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+			_, ok = g.outputs[name]
+		}
+		log.Warning(pkgFilePath, "has the same sbom file name", fileName, "as", other+", saved as", name)
+	}
+	g.outputs[name] = pkgFilePath
+	return name
+}
+
+// generateBatch 并发生成多个软件包的 sbom, 默认出错后继续处理其他软件包, 最后输出汇总
+func (g *generateOpt) generateBatch() error {
+	inputs, err := g.batchInputs()
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no package found")
+	}
+	g.outputs = make(map[string]string)
+
+	results := make([]batchResult, len(inputs))
+	var failed int32
+	var wg sync.WaitGroup
+	pool, err := ants.NewPoolWithFunc(g.parallel, func(arg interface{}) {
+		defer wg.Done()
+		i := arg.(int)
+		results[i].Package = inputs[i]
+		if g.failFast && atomic.LoadInt32(&failed) > 0 {
+			results[i].Skipped = true
+			return
+		}
+		path, err := g.generatePackage(inputs[i], modules.NewPlugins(g.hashOptions()))
+		if err != nil {
+			atomic.AddInt32(&failed, 1)
+			log.Error(inputs[i]+":", err)
+			results[i].Error = err.Error()
+			return
+		}
+		results[i].SBOM = path
+	})
+	if err != nil {
+		return err
+	}
+	defer pool.Release()
+	for i := range inputs {
+		wg.Add(1)
+		if err := pool.Invoke(i); err != nil {
+			wg.Done()
+			return err
+		}
+	}
+	wg.Wait()
+
+	report := batchReport{Total: len(inputs), Results: results}
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			report.Skipped++
+		case r.Error != "":
+			report.Failed++
+			log.Warning("failed:", r.Package+":", r.Error)
+		default:
+			report.Succeeded++
+		}
+	}
+	log.Infof("batch finished: %d packages, %d succeeded, %d failed, %d skipped\n",
+		report.Total, report.Succeeded, report.Failed, report.Skipped)
+	if g.report != "" {
+		data, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(g.report, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		log.Info("batch report saved:", g.report)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d packages failed", report.Failed, report.Total)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package generate_cmd
+
+import (
+	"os"
+	"testing"
+
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger("", log.LevelWarning)
+	os.Exit(m.Run())
+}
+
+// TestReserveOutput 批量模式中文件名相同的软件包各自生成 sbom, 不互相覆盖
+func TestReserveOutput(t *testing.T) {
+	type output struct {
+		input, fileName, want string
+	}
+	tests := []struct {
+		format  string
+		outputs []output //依次登记的软件包
+	}{
+		{doc.FormatSPDXJSON, []output{
+			{"a/hello_1.0_amd64.deb", "hello_1.0_amd64.spdx.json", "hello_1.0_amd64.spdx.json"},
+			{"b/hello_1.0_amd64.deb", "hello_1.0_amd64.spdx.json", "hello_1.0_amd64-2.spdx.json"},
+			{"libfoo_2.0_amd64.deb", "libfoo_2.0_amd64.spdx.json", "libfoo_2.0_amd64.spdx.json"},
+			{"c/hello_1.0_amd64.deb", "hello_1.0_amd64.spdx.json", "hello_1.0_amd64-3.spdx.json"},
+		}},
+		{doc.FormatCycloneDXXML, []output{
+			{"a/hello_1.0_amd64.deb", "hello_1.0_amd64.cdx.xml", "hello_1.0_amd64.cdx.xml"},
+			{"b/hello_1.0_amd64.deb", "hello_1.0_amd64.cdx.xml", "hello_1.0_amd64-2.cdx.xml"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			g := &generateOpt{format: tt.format, outputs: make(map[string]string)}
+			for _, o := range tt.outputs {
+				if got := g.reserveOutput(o.fileName, o.input); got != o.want {
+					t.Errorf("%s: got %s, want %s", o.input, got, o.want)
+				}
+			}
+		})
+	}
+
+	// 单个软件包不登记文件名
+	g := &generateOpt{format: doc.FormatSPDXJSON}
+	for i := 0; i < 2; i++ {
+		if got := g.reserveOutput("hello_1.0_amd64.spdx.json", "hello_1.0_amd64.deb"); got != "hello_1.0_amd64.spdx.json" {
+			t.Errorf("got %s, want hello_1.0_amd64.spdx.json", got)
+		}
+	}
+}
@@ -6,29 +6,53 @@ package generate_cmd
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
 	"path/filepath"
 	"strings"
 
+	"deepin-sbom-tools/pkg/cyclonedx"
 	"deepin-sbom-tools/pkg/doc"
 	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/modules"
 	"deepin-sbom-tools/pkg/modules/deb"
-	"deepin-sbom-tools/pkg/modules/rpm"
 	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/signverify"
+	"deepin-sbom-tools/pkg/spdx"
+	"deepin-sbom-tools/pkg/tool"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-
-	"github.com/spdx/tools-golang/json"
+	"runtime"
+	"sync"
 )
 
 var Plugins []plugin.Plugin
 
 type generateOpt struct {
-	input   string
-	output  string
-	format  string
-	ns      string
-	verbose bool
+	input     string
+	output    string
+	format    string
+	typ       string
+	ns        string
+	purlNS    string
+	jobs      int
+	scan      bool
+	embed     bool
+	prik      string
+	installed bool
+	root      string
+	packages  string
+	repo      string
+	parallel  int
+	failFast  bool
+	report    string
+	verbose   bool
+
+	mu      sync.Mutex
+	outputs map[string]string //批量模式中已生成的 sbom 文件名及对应的软件包
 }
 
 func New() *generateOpt {
@@ -36,15 +60,37 @@ func New() *generateOpt {
 }
 
 func (g *generateOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
-	flag.StringVar(&g.input, "i", "", "the package file which will be analyzed")
-	flag.StringVar(&g.output, "o", "./", "the directory to save SPDX file")
-	flag.StringVar(&g.format, "f", "spdx-json", "the SPDX file format")
+	flag.StringVar(&g.input, "i", "", "the package file which will be analyzed, or a directory or glob pattern of packages for batch mode; "+
+		"deb source packages can be given as a .dsc, a source tarball or an unpacked source tree with debian/control")
+	flag.StringVar(&g.output, "o", "./", "the directory to save SBOM file")
+	flag.StringVar(&g.typ, "type", "", "force the package type instead of detecting it from the content: "+strings.Join(plugin.TypeNames(modules.NewPlugins(tool.DefaultHashOptions())), ", "))
+	flag.StringVar(&g.format, "f", doc.FormatSPDXJSON, "the SBOM file format: "+strings.Join(doc.Formats(), ", "))
 	flag.StringVar(&g.ns, "ns", "https://www.deepin.org/namespace/package", "the sbom document namespace base url.")
+	flag.StringVar(&g.purlNS, "purl-ns", tool.DefaultPurlNamespace, "the distribution namespace used in package urls, such as deepin or uos")
+	flag.IntVar(&g.jobs, "j", runtime.NumCPU(), "the number of workers used to hash package files")
+	flag.BoolVar(&g.scan, "scan-licenses", false, "scan the licenses and copyright lines of text files in the package")
+	flag.BoolVar(&g.embed, "embed", false, "embed the SBOM into the deb package as "+tool.DebSBOMMember+", always in spdx-json format")
+	flag.StringVar(&g.prik, "prik", "", "the private key used to sign the embedded SBOM, only used with -embed")
+	flag.BoolVar(&g.installed, "installed", false, "generate one SBOM for all packages installed in the system from the dpkg database, instead of -i")
+	flag.StringVar(&g.root, "root", "/", "the root directory of the system, only used with -installed, such as a chroot or a mounted image")
+	flag.StringVar(&g.packages, "packages", "", "the APT Packages index (may be compressed) listing the packages for batch mode, instead of -i")
+	flag.StringVar(&g.repo, "repo", "./", "the APT repository root that Filename in the Packages index is relative to")
+	flag.IntVar(&g.parallel, "parallel", 4, "the number of packages processed concurrently in batch mode")
+	flag.BoolVar(&g.failFast, "fail-fast", false, "stop batch mode at the first failed package")
+	flag.StringVar(&g.report, "report", "", "the file to save the json summary report of batch mode")
 	flag.BoolVar(&g.verbose, "v", false, "enable verbose mode")
 
 	flag.Usage = func() {
 		fmt.Println("Usage:", os.Args[0], "generate [arguments]")
 		fmt.Println("Example:", os.Args[0], "generate -i example.deb")
+		fmt.Println("Example:", os.Args[0], "generate -i hello_2.10-3.dsc")
+		fmt.Println("Example:", os.Args[0], "generate -i hello_42.snap -f cyclonedx-json")
+		fmt.Println("Example:", os.Args[0], "generate -i org.example.Hello.flatpak")
+		fmt.Println("Example:", os.Args[0], "generate -i Hello-1.0-x86_64.AppImage")
+		fmt.Println("Example:", os.Args[0], "generate -i org.deepin.calculator_5.7.21.1_x86_64_binary.layer")
+		fmt.Println("Example:", os.Args[0], "generate -i hello_2.10.orig.tar.gz -type debsrc")
+		fmt.Println("Example:", os.Args[0], "generate -i pool/ -o sboms -report report.json")
+		fmt.Println("Example:", os.Args[0], "generate -installed -root /mnt/image")
 		fmt.Println("arguments:")
 		flag.PrintDefaults()
 	}
@@ -53,78 +99,259 @@ func (g *generateOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	flag.Parse(args)
 
 	// 必要参数判断
-	if g.input == "" {
+	if g.input != "" && g.packages != "" {
+		return fmt.Errorf("-i can't be used with -packages")
+	}
+	if g.parallel < 1 {
+		return fmt.Errorf("the number of concurrent packages must be greater than 0")
+	}
+	if g.installed {
+		if g.input != "" || g.packages != "" || g.embed {
+			return fmt.Errorf("-installed can't be used with -i, -packages or -embed")
+		}
+		if doc.IsSPDX3(g.format) {
+			return fmt.Errorf("-installed doesn't support format %s", g.format)
+		}
+	} else if g.input == "" && g.packages == "" {
 		return fmt.Errorf("the package file must exist")
 	}
+	if g.jobs < 1 {
+		return fmt.Errorf("the number of workers must be greater than 0")
+	}
+	if _, err := doc.FormatExt(g.format); err != nil {
+		return err
+	}
+	if g.typ != "" {
+		if g.installed {
+			return fmt.Errorf("-type can't be used with -installed")
+		}
+		if _, err := plugin.ByType(modules.NewPlugins(tool.DefaultHashOptions()), g.typ); err != nil {
+			return err
+		}
+	}
+	if g.prik != "" && !g.embed {
+		return fmt.Errorf("the private key can only be used with -embed")
+	}
 	return nil
 }
 
 func (g *generateOpt) Run() error {
-	Plugins = []plugin.Plugin{
-		deb.New(),
-		rpm.New(),
-	}
-
-	pkgFilePath := g.input
+	Plugins = modules.NewPlugins(g.hashOptions())
 
 	if f, err := os.Stat(g.output); err != nil || !f.IsDir() {
 		return err
 	}
+	if !strings.HasSuffix(g.ns, "/") {
+		g.ns = g.ns + "/"
+	}
+	if g.installed {
+		return g.generateInstalled()
+	}
+	if g.isBatch() {
+		return g.generateBatch()
+	}
+	_, err := g.generatePackage(g.input, Plugins)
+	return err
+}
 
+// hashOptions 由 -j 与 -scan-licenses 得到计算包内文件摘要的选项
+func (g *generateOpt) hashOptions() tool.HashOptions {
+	return tool.HashOptions{Workers: g.jobs, ScanLicenses: g.scan}
+}
+
+// generatePackage 生成一个软件包的 sbom, 返回 sbom 文件路径
+func (g *generateOpt) generatePackage(pkgFilePath string, plugins []plugin.Plugin) (string, error) {
 	//2. pakcage process
 	/*
 		获取输入文件
-		遍历可用插件 IsValid()
+		按内容识别软件包选择插件 Detect(), 或使用 -type 指定的插件
 		解析软件包，获取包依赖 ParsePkgInfo()
 	*/
-	var plug plugin.Plugin
-	isFoundPlug := false
-	for _, plug = range Plugins {
-		if plug.IsValid(pkgFilePath) {
-			isFoundPlug = true
-			plugInfo := plug.GetPlugInfo()
-			log.Debug(plugInfo.PlugName, plugInfo.PlugVer)
-			break
-		}
+	if _, err := os.Stat(pkgFilePath); err != nil {
+		return "", err
+	}
+	plug, err := plugin.Select(plugins, pkgFilePath, g.typ)
+	if err != nil {
+		return "", err
 	}
-	if !isFoundPlug {
-		return fmt.Errorf("%s unknown package type", pkgFilePath)
+	plugInfo := plug.GetPlugInfo()
+	log.Debug(plugInfo.PlugName, plugInfo.PlugVer)
+	if _, ok := plug.(*deb.Deb); g.embed && !ok {
+		return "", fmt.Errorf("only deb packages support embedding SBOM")
 	}
 
 	pkgInfo, err := plug.ParsePkgInfo(pkgFilePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	//3. create document
-	if !strings.HasSuffix(g.ns, "/") {
-		g.ns = g.ns + "/"
+	var write func(w io.Writer) error
+	if doc.IsCycloneDX(g.format) {
+		bom := cyclonedx.CreateBOM(pkgInfo, g.purlNS)
+		write = func(w io.Writer) error {
+			return doc.WriteBOM(bom, w, g.format)
+		}
+	} else if doc.IsSPDX3(g.format) {
+		document, err := spdx.CreateDocument(pkgInfo, g.ns, g.purlNS)
+		if err != nil {
+			return "", err
+		}
+		write = func(w io.Writer) error {
+			return doc.WriteSPDX3(document, w, g.format)
+		}
+	} else {
+		document, err := doc.CreateDocument(pkgInfo, g.ns, g.purlNS)
+		if err != nil {
+			return "", err
+		}
+		write = func(w io.Writer) error {
+			return doc.WriteDocument(document, w, g.format)
+		}
 	}
-	document, err := doc.CreateDocument(pkgInfo, g.ns)
+
+	fileName, err := doc.FileName(pkgInfo, g.format)
 	if err != nil {
-		return err
+		return "", err
+	}
+	fileName = g.reserveOutput(fileName, pkgFilePath)
+	// 嵌入的 sbom 与输出文件格式相同时直接使用输出内容
+	var embedded bytes.Buffer
+	var capture io.Writer
+	if g.embed && g.format == doc.FormatSPDXJSON {
+		capture = &embedded
+	}
+	path, err := g.writeSBOM(fileName, write, capture)
+	if err != nil {
+		return "", err
 	}
 
-	f, err := os.OpenFile(g.output+"/"+"sbom.spdx.json", os.O_CREATE|os.O_WRONLY, 0644)
+	if g.embed {
+		var sbom []byte
+		if capture != nil {
+			sbom = embedded.Bytes()
+		}
+		return path, g.embedSBOM(pkgFilePath, pkgInfo, sbom)
+	}
+	return path, nil
+}
+
+// writeSBOM 将 sbom 写入输出目录中的 fileName, capture 不为空时同时写入 capture, 返回 sbom 文件路径
+func (g *generateOpt) writeSBOM(fileName string, write func(w io.Writer) error, capture io.Writer) (string, error) {
+	f, err := os.OpenFile(filepath.Join(g.output, fileName), os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return err
+		return "", err
 	}
 	f.Truncate(0)
 
 	defer f.Close()
-	w := bufio.NewWriter(f)
-	err = json.Write(document, w, json.EscapeHTML(false), json.Indent("\t"))
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	if capture != nil {
+		w = io.MultiWriter(w, capture)
+	}
+	err = write(w)
+	if err != nil {
+		return "", err
+	}
+	err = bw.Flush()
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(f.Name())
+	if err != nil {
+		return "", err
+	}
+	log.Infof("SBOM written to %s\n", path)
+	return path, nil
+}
+
+// generateInstalled 由 dpkg 数据库生成整个系统的 sbom, 操作系统信息来自 os-release
+func (g *generateOpt) generateInstalled() error {
+	pkgs, err := deb.New(g.hashOptions()).ParseInstalled(g.root)
 	if err != nil {
 		return err
 	}
-	err = w.Flush()
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no installed package found in %s", g.root)
+	}
+	system := plugin.PkgInfo{Name: "linux"}
+	osRelease, err := tool.ReadOSRelease(g.root)
+	if err != nil {
+		log.Warning("read os-release failed:", err)
+	}
+	if id := osRelease["ID"]; id != "" {
+		system.Name = id
+	}
+	system.Version = osRelease["VERSION_ID"]
+	if system.Version == "" {
+		system.Version = osRelease["VERSION_CODENAME"]
+	}
+	system.Description = osRelease["PRETTY_NAME"]
+	system.Homepage = osRelease["HOME_URL"]
+	// 系统架构与 dpkg 自身的架构相同
+	for _, p := range pkgs {
+		if p.Name == "dpkg" {
+			system.Architecture = p.Architecture
+		}
+	}
+
+	document, err := doc.CreateSystemDocument(system, pkgs, g.ns, g.purlNS)
 	if err != nil {
 		return err
 	}
-	path, err := filepath.Abs(f.Name())
+	write := func(w io.Writer) error {
+		return doc.WriteDocument(document, w, g.format)
+	}
+	if doc.IsCycloneDX(g.format) {
+		bom := cyclonedx.FromSPDX(document)
+		write = func(w io.Writer) error {
+			return doc.WriteBOM(bom, w, g.format)
+		}
+	}
+	fileName, err := doc.FileName(system, g.format)
 	if err != nil {
 		return err
 	}
-	log.Infof("SBOM written to %s\n", path)
+	log.Info(len(pkgs), "installed packages,", len(document.Files), "files")
+	_, err = g.writeSBOM(fileName, write, nil)
+	return err
+}
+
+// embedSBOM 将 spdx-json 格式的 sbom 嵌入 deb 包, sbom 为空时重新生成; 指定私钥时同时写入签名
+func (g *generateOpt) embedSBOM(pkgFilePath string, pkgInfo plugin.PkgInfo, sbom []byte) error {
+	if sbom == nil {
+		document, err := doc.CreateDocument(pkgInfo, g.ns, g.purlNS)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := doc.WriteDocument(document, &buf, doc.FormatSPDXJSON); err != nil {
+			return err
+		}
+		sbom = buf.Bytes()
+	}
+
+	var sign []byte
+	if g.prik != "" {
+		prikey, err := ioutil.ReadFile(g.prik)
+		if err != nil {
+			return err
+		}
+		keyHander, err := signverify.DetectKeyType(prikey, nil)
+		if err != nil {
+			return err
+		}
+		signature, err := keyHander.Sign(sbom)
+		if err != nil {
+			return err
+		}
+		sign = []byte(base64.RawStdEncoding.EncodeToString(signature))
+	}
+
+	if err := tool.EmbedDebSBOM(pkgFilePath, sbom, sign); err != nil {
+		return err
+	}
+	log.Infof("SBOM embedded into %s\n", pkgFilePath)
 	return nil
 }
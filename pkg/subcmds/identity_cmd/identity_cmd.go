@@ -6,15 +6,20 @@ package identity_cmd
 
 import (
 	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/modules"
+	"deepin-sbom-tools/pkg/plugin"
 	"deepin-sbom-tools/pkg/tool"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 type identityOpt struct {
 	filePath string
 	verify   string
+	typ      string
+	purlNS   string
 	verbose  bool
 }
 
@@ -25,6 +30,8 @@ func New() *identityOpt {
 func (u *identityOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	flag.StringVar(&u.filePath, "f", "", "package to be identitied")
 	flag.StringVar(&u.verify, "verify", "", "verify package identitiy")
+	flag.StringVar(&u.typ, "type", "", "force the package type used for the package url: "+strings.Join(plugin.TypeNames(modules.NewPlugins(tool.DefaultHashOptions())), ", "))
+	flag.StringVar(&u.purlNS, "purl-ns", tool.DefaultPurlNamespace, "the distribution namespace used in package url")
 	flag.BoolVar(&u.verbose, "v", false, "enable verbose mode")
 
 	flag.Usage = func() {
@@ -63,5 +70,20 @@ func (u *identityOpt) Run() error {
 
 	}
 	log.Info("generate pacakgeID:", debSha1)
+
+	// 识别出软件包类型时同时输出 purl
+	plugins := modules.NewPlugins(tool.DefaultHashOptions())
+	if u.typ == "" && len(plugin.Detect(plugins, u.filePath)) == 0 {
+		return nil
+	}
+	plug, err := plugin.Select(plugins, u.filePath, u.typ)
+	if err != nil {
+		return err
+	}
+	pkgInfo, err := plug.ParsePkgInfo(u.filePath)
+	if err != nil {
+		return err
+	}
+	log.Info("package purl:", tool.PackageURL(pkgInfo.Type, u.purlNS, pkgInfo.Name, pkgInfo.Version, pkgInfo.Architecture))
 	return nil
 }
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package plugins_cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"deepin-sbom-tools/pkg/modules"
+	"deepin-sbom-tools/pkg/plugin"
+	"deepin-sbom-tools/pkg/tool"
+)
+
+type pluginsOpt struct {
+	input   string
+	verbose bool
+}
+
+func New() *pluginsOpt {
+	return &pluginsOpt{}
+}
+
+func (p *pluginsOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
+	flag.StringVar(&p.input, "i", "", "show the detection confidence of each plugin for the package instead of listing the types")
+	flag.BoolVar(&p.verbose, "v", false, "enable verbose mode")
+
+	flag.Usage = func() {
+		fmt.Println("Usage:", os.Args[0], "plugins [arguments]")
+		fmt.Println("Example:", os.Args[0], "plugins")
+		fmt.Println("Example:", os.Args[0], "plugins -i example.deb")
+		fmt.Println("arguments:")
+		flag.PrintDefaults()
+	}
+
+	// 解析命令行参数
+	flag.Parse(args)
+	return nil
+}
+
+// Run 列出插件支持的文件类型, 指定软件包时列出各插件的识别置信度
+func (p *pluginsOpt) Run() error {
+	plugins := modules.NewPlugins(tool.DefaultHashOptions())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if p.input != "" {
+		if _, err := os.Stat(p.input); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "PLUGIN\tTYPE\tCONFIDENCE")
+		for _, plug := range plugins {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", plug.GetPlugInfo().PlugName, strings.Join(plugin.TypeNames([]plugin.Plugin{plug}), ","), plug.Detect(p.input))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		plug, err := plugin.Select(plugins, p.input, "")
+		if err != nil {
+			return err
+		}
+		fmt.Println("selected:", plug.GetPlugInfo().PlugName)
+		return nil
+	}
+
+	fmt.Fprintln(w, "TYPE\tPLUGIN\tVERSION\tEXTENSIONS\tDESCRIPTION")
+	for _, plug := range plugins {
+		info := plug.GetPlugInfo()
+		for _, t := range plug.FileTypes() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, info.PlugName, info.PlugVer, strings.Join(t.Extensions, " "), t.Description)
+		}
+	}
+	return w.Flush()
+}
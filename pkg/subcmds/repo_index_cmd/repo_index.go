@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package repo_index_cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+)
+
+type repoIndexOpt struct {
+	dir     string
+	output  string
+	format  string
+	name    string
+	ns      string
+	verbose bool
+}
+
+func New() *repoIndexOpt {
+	return &repoIndexOpt{}
+}
+
+func (r *repoIndexOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
+	flag.StringVar(&r.dir, "d", "", "the directory of sbom files or deb packages with embedded SBOM, searched recursively")
+	flag.StringVar(&r.output, "o", "./", "the directory to save the index document")
+	flag.StringVar(&r.format, "f", doc.FormatSPDXJSON, "the SPDX file format of the index document: spdx-json, spdx-tv, spdx-yaml, spdx-rdf")
+	flag.StringVar(&r.name, "name", "repo-index", "the name of the index document, also used as the file name")
+	flag.StringVar(&r.ns, "ns", "https://www.deepin.org/namespace/repo", "the sbom document namespace base url.")
+	flag.BoolVar(&r.verbose, "v", false, "enable verbose mode")
+
+	flag.Usage = func() {
+		fmt.Println("Usage:", os.Args[0], "repo-index [arguments]")
+		fmt.Println("Example:", os.Args[0], "repo-index -d sboms -o ./ -name deepin-23-main")
+		fmt.Println("arguments:")
+		flag.PrintDefaults()
+	}
+
+	// 解析命令行参数
+	flag.Parse(args)
+
+	// 必要参数判断
+	if r.dir == "" {
+		return fmt.Errorf("the sbom directory must exist")
+	}
+	if _, err := doc.FormatExt(r.format); err != nil {
+		return err
+	}
+	if doc.IsCycloneDX(r.format) || doc.IsSPDX3(r.format) {
+		return fmt.Errorf("the index document only supports SPDX 2.3 formats")
+	}
+	if r.name == "" {
+		return fmt.Errorf("the index document name must not be empty")
+	}
+	return nil
+}
+
+func (r *repoIndexOpt) Run() error {
+	if !strings.HasSuffix(r.ns, "/") {
+		r.ns = r.ns + "/"
+	}
+	inputs, err := collectInputs(r.dir, r.ns)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no sbom found in %s", r.dir)
+	}
+
+	document, stats, err := doc.CreateIndexDocument(r.name, inputs, r.ns)
+	if err != nil {
+		return err
+	}
+	ext, err := doc.FormatExt(r.format)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.output, r.name+ext)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	if err := doc.WriteDocument(document, bw, r.format); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	log.Infof("%d documents indexed, %d dependencies resolved, %d unresolved, %d packages linked to source\n",
+		len(inputs), stats.Resolved, stats.Unresolved, stats.Sources)
+	log.Infof("SBOM written to %s\n", path)
+	return nil
+}
+
+// collectInputs 读取目录中的 sbom 文件; CycloneDX 文档没有可供引用的 SPDX 命名空间, 跳过
+func collectInputs(dir, ns string) ([]doc.IndexInput, error) {
+	var inputs []doc.IndexInput
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !doc.IsSBOMFile(path) {
+			return nil
+		}
+		if format, ok := doc.FormatFromName(path); ok && doc.IsCycloneDX(format) {
+			log.Warning("skip", path+": CycloneDX documents cannot be referenced by the SPDX index")
+			return nil
+		}
+		document, data, err := doc.ReadSBOMFile(path, ns)
+		if err != nil {
+			log.Warning("skip", path+":", err)
+			return nil
+		}
+		inputs = append(inputs, doc.IndexInput{Document: document, Data: data})
+		return nil
+	})
+	return inputs, err
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package repo_index_cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"deepin-sbom-tools/pkg/cyclonedx"
+	"deepin-sbom-tools/pkg/doc"
+	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/plugin"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+func TestMain(m *testing.M) {
+	log.NewLogger("", log.LevelWarning)
+	os.Exit(m.Run())
+}
+
+// TestCollectInputs 同一软件包的 SPDX 与 CycloneDX 文档在同一目录中时, 索引只引用 SPDX 文档
+func TestCollectInputs(t *testing.T) {
+	const ns = "https://example.org/spdx/"
+	pkg := plugin.PkgInfo{Type: "deb", Name: "hello", Version: "2.10-3", Architecture: "amd64", Maintainer: "Debian"}
+	document, err := doc.CreateDocument(pkg, ns, "deepin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var spdx, cdx bytes.Buffer
+	if err := doc.WriteDocument(document, &spdx, doc.FormatSPDXJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.WriteBOM(cyclonedx.FromSPDX(document), &cdx, doc.FormatCycloneDXJSON); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"hello.spdx.json": spdx.Bytes(), "hello.cdx.json": cdx.Bytes()} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inputs, err := collectInputs(dir, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || !bytes.Equal(inputs[0].Data, spdx.Bytes()) {
+		t.Fatalf("got %d inputs, want the SPDX document only", len(inputs))
+	}
+	index, _, err := doc.CreateIndexDocument("repo-index", inputs, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.ExternalDocumentReferences) != 1 || index.ExternalDocumentReferences[0].URI != document.DocumentNamespace {
+		t.Errorf("external document refs = %+v", index.ExternalDocumentReferences)
+	}
+	describes := 0
+	for _, rel := range index.Relationships {
+		if rel.Relationship == common.TypeRelationshipDescribe {
+			describes++
+		}
+	}
+	if describes != 1 {
+		t.Errorf("%d DESCRIBES relationships, want 1", describes)
+	}
+}
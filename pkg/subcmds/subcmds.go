@@ -6,8 +6,13 @@ package subcmds
 
 import (
 	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/subcmds/audit_cmd"
+	"deepin-sbom-tools/pkg/subcmds/convert_cmd"
+	"deepin-sbom-tools/pkg/subcmds/extract_cmd"
 	"deepin-sbom-tools/pkg/subcmds/generate_cmd"
 	"deepin-sbom-tools/pkg/subcmds/identity_cmd"
+	"deepin-sbom-tools/pkg/subcmds/plugins_cmd"
+	"deepin-sbom-tools/pkg/subcmds/repo_index_cmd"
 	"deepin-sbom-tools/pkg/subcmds/sign_cmd"
 	"deepin-sbom-tools/pkg/subcmds/validate_cmd"
 	"deepin-sbom-tools/pkg/subcmds/verify_cmd"
@@ -70,6 +75,31 @@ func init() {
 		CmdDesc: "verify signature of sbom file",
 		CmdFunc: verify_cmd.New(),
 	})
+	Register(CmdInfo{
+		CmdName: "convert",
+		CmdDesc: "convert sbom file between SPDX and CycloneDX formats",
+		CmdFunc: convert_cmd.New(),
+	})
+	Register(CmdInfo{
+		CmdName: "extract",
+		CmdDesc: "extract the sbom embedded in a deb package",
+		CmdFunc: extract_cmd.New(),
+	})
+	Register(CmdInfo{
+		CmdName: "audit",
+		CmdDesc: "audit installed files against sbom files",
+		CmdFunc: audit_cmd.New(),
+	})
+	Register(CmdInfo{
+		CmdName: "repo-index",
+		CmdDesc: "create a repository index sbom referencing the package sbom files",
+		CmdFunc: repo_index_cmd.New(),
+	})
+	Register(CmdInfo{
+		CmdName: "plugins",
+		CmdDesc: "list the supported package types or detect the type of a package",
+		CmdFunc: plugins_cmd.New(),
+	})
 }
 
 func Register(info CmdInfo) {
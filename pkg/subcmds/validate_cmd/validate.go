@@ -5,13 +5,12 @@
 package validate_cmd
 
 import (
+	"deepin-sbom-tools/pkg/doc"
 	"deepin-sbom-tools/pkg/log"
 	"flag"
 	"fmt"
 	"os"
-
-	"github.com/spdx/tools-golang/json"
-	"github.com/spdx/tools-golang/spdxlib"
+	"strings"
 )
 
 type validateOpt struct {
@@ -26,7 +25,7 @@ func New() *validateOpt {
 
 func (v *validateOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	flag.StringVar(&v.input, "i", "", "the sbom file which will be validated")
-	flag.StringVar(&v.format, "f", "spdx-json", "the SPDX file format")
+	flag.StringVar(&v.format, "f", doc.FormatSPDXJSON, "the SPDX file format: "+strings.Join(doc.Formats(), ", "))
 	flag.BoolVar(&v.verbose, "v", false, "enable verbose mode")
 
 	flag.Usage = func() {
@@ -43,6 +42,9 @@ func (v *validateOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	if v.input == "" {
 		return fmt.Errorf("the sbom file must exist")
 	}
+	if _, err := doc.FormatExt(v.format); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -53,11 +55,11 @@ func (v *validateOpt) Run() error {
 		return err
 	}
 	defer f.Close()
-	doc, err := json.Read(f)
+	document, err := doc.ReadDocument(f, v.format)
 	if err != nil {
 		return err
 	}
-	err = spdxlib.ValidateDocument(doc)
+	err = doc.ValidateDocument(document)
 	if err != nil {
 		log.Info(v.input, "validate failed")
 		return err
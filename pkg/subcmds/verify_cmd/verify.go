@@ -5,21 +5,32 @@
 package verify_cmd
 
 import (
+	"bytes"
 	"encoding/base64"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 
+	"deepin-sbom-tools/pkg/doc"
 	"deepin-sbom-tools/pkg/log"
+	"deepin-sbom-tools/pkg/modules/deb"
+	"deepin-sbom-tools/pkg/plugin"
 	"deepin-sbom-tools/pkg/signverify"
+	"deepin-sbom-tools/pkg/tool"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
 )
 
 type verifyOpt struct {
 	f       string
 	s       string
 	pubk    string
+	deb     string
+	jobs    int
 	verbose bool
 }
 
@@ -32,11 +43,14 @@ func (v *verifyOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	flag.StringVar(&v.f, "f", "", "the original file , this argument must be present")
 	flag.StringVar(&v.s, "s", "", "the signature file to be verified, this argument must be present")
 	flag.StringVar(&v.pubk, "pubk", "", "the sign public key")
+	flag.StringVar(&v.deb, "deb", "", "the deb package with embedded SBOM, verify the SBOM signature and the package files against it")
+	flag.IntVar(&v.jobs, "j", runtime.NumCPU(), "the number of workers used to hash package files, only used with -deb")
 	flag.BoolVar(&v.verbose, "v", false, "enable verbose mode")
 
 	flag.Usage = func() {
 		fmt.Println("Usage:", os.Args[0], "verify [arguments]")
 		fmt.Println("Example:", os.Args[0], "verify -f sbom.spdx.json  -s sbom.spdx.json.sign -pubk key")
+		fmt.Println("        ", os.Args[0], "verify -deb example.deb -pubk key")
 		fmt.Println("arguments:")
 		flag.PrintDefaults()
 	}
@@ -45,6 +59,15 @@ func (v *verifyOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 	flag.Parse(args)
 
 	// 必要参数判断
+	if v.jobs < 1 {
+		return fmt.Errorf("the number of workers must be greater than 0")
+	}
+	if v.deb != "" {
+		if v.pubk == "" {
+			return fmt.Errorf("the pubkey must exist")
+		}
+		return nil
+	}
 	if v.f == "" || v.s == "" || v.pubk == "" {
 		return fmt.Errorf("both file, signature and pubkey must exist")
 	}
@@ -52,6 +75,9 @@ func (v *verifyOpt) ParseArgs(flag *flag.FlagSet, args []string) error {
 }
 
 func (v *verifyOpt) Run() error {
+	if v.deb != "" {
+		return v.verifyDeb()
+	}
 
 	filePath, err := filepath.Abs(v.f)
 	if err != nil {
@@ -71,7 +97,16 @@ func (v *verifyOpt) Run() error {
 	if err != nil {
 		return err
 	}
-	signature, err := base64.RawStdEncoding.DecodeString(string(signData))
+	if err := v.verifySignature(data, signData); err != nil {
+		return err
+	}
+	log.Info(v.f, "verify success")
+	return nil
+}
+
+// verifySignature 使用公钥验证 sign 子命令生成的 base64 签名
+func (v *verifyOpt) verifySignature(data, signData []byte) error {
+	signature, err := base64.RawStdEncoding.DecodeString(string(bytes.TrimSpace(signData)))
 	if err != nil {
 		return err
 	}
@@ -86,10 +121,91 @@ func (v *verifyOpt) Run() error {
 	if err != nil {
 		return err
 	}
-	err = keyHander.Verify(data, signature)
+	return keyHander.Verify(data, signature)
+}
+
+// verifyDeb 验证 deb 包中嵌入的 sbom 签名, 并重新计算包内文件摘要与 sbom 中的文件逐一比对
+func (v *verifyOpt) verifyDeb() error {
+	sbom, err := tool.ExtractDebSBOM(v.deb)
 	if err != nil {
 		return err
 	}
-	log.Info(v.f, "verify success")
+	signData, err := tool.GetDebSignInfo(v.deb)
+	if err != nil {
+		return err
+	}
+	if err := v.verifySignature(sbom, signData); err != nil {
+		return fmt.Errorf("verify embedded SBOM signature failed: %v", err)
+	}
+	log.Info(v.deb, "embedded SBOM signature verify success")
+
+	document, err := doc.ReadDocument(bytes.NewReader(sbom), doc.FormatSPDXJSON)
+	if err != nil {
+		return err
+	}
+	pkgInfo, err := deb.New(tool.HashOptions{Workers: v.jobs}).ParsePkgInfo(v.deb)
+	if err != nil {
+		return err
+	}
+
+	diff := compareFiles(document.Files, pkgInfo.FileList)
+	for _, name := range diff.missing {
+		log.Warning("missing file:", name)
+	}
+	for _, name := range diff.extra {
+		log.Warning("extra file:", name)
+	}
+	for _, m := range diff.mismatch {
+		algo := string(m.algo)
+		if algo == "" {
+			algo = "no common checksum algorithm"
+		}
+		log.Warning("checksum mismatch:", m.name, algo)
+	}
+	if n := len(diff.missing) + len(diff.extra) + len(diff.mismatch); n > 0 {
+		return fmt.Errorf("package files don't match the embedded SBOM: %d missing, %d extra, %d checksum mismatch",
+			len(diff.missing), len(diff.extra), len(diff.mismatch))
+	}
+	log.Info(v.deb, "package files match the embedded SBOM,", len(document.Files), "files checked")
 	return nil
 }
+
+// fileMismatch 摘要不一致的文件, algo 为空表示两边没有相同的摘要算法
+type fileMismatch struct {
+	name string
+	algo common.ChecksumAlgorithm
+}
+
+// fileDiff sbom 中记录的文件与重新计算摘要的包内文件的比对结果
+type fileDiff struct {
+	missing  []string //sbom 中有而包内没有的文件
+	extra    []string //包内有而 sbom 中没有的文件
+	mismatch []fileMismatch
+}
+
+// compareFiles 按文件名比对 sbom 中的文件与包内文件, 结果按 sbom 及包内文件的顺序排列
+func compareFiles(recorded []*v2_3.File, actual []*plugin.FileInfo) fileDiff {
+	var diff fileDiff
+	hashes := make(map[string][]common.Checksum)
+	for _, f := range actual {
+		hashes[f.FileName] = f.Hash
+	}
+	seen := make(map[string]bool)
+	for _, f := range recorded {
+		seen[f.FileName] = true
+		hash, ok := hashes[f.FileName]
+		if !ok {
+			diff.missing = append(diff.missing, f.FileName)
+			continue
+		}
+		if algo, ok := tool.CompareChecksums(f.Checksums, hash); !ok {
+			diff.mismatch = append(diff.mismatch, fileMismatch{name: f.FileName, algo: algo})
+		}
+	}
+	for _, f := range actual {
+		if !seen[f.FileName] {
+			diff.extra = append(diff.extra, f.FileName)
+		}
+	}
+	return diff
+}
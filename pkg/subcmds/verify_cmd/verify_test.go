@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package verify_cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"deepin-sbom-tools/pkg/plugin"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+	"github.com/spdx/tools-golang/spdx/v2/v2_3"
+)
+
+func TestCompareFiles(t *testing.T) {
+	sha1 := func(v string) common.Checksum { return common.Checksum{Algorithm: common.SHA1, Value: v} }
+	md5 := func(v string) common.Checksum { return common.Checksum{Algorithm: common.MD5, Value: v} }
+	recorded := []*v2_3.File{
+		{FileName: "/usr/bin/hello", Checksums: []common.Checksum{sha1("aa"), md5("11")}},
+		{FileName: "/usr/share/doc/hello/README", Checksums: []common.Checksum{sha1("bb")}},
+		{FileName: "/usr/share/man/man1/hello.1.gz", Checksums: []common.Checksum{sha1("cc")}},
+		{FileName: "/usr/share/info/hello.info", Checksums: []common.Checksum{md5("22")}},
+	}
+	tests := []struct {
+		name   string
+		actual []*plugin.FileInfo
+		want   fileDiff
+	}{
+		{
+			"match",
+			[]*plugin.FileInfo{
+				{FileName: "/usr/bin/hello", Hash: []common.Checksum{sha1("AA"), md5("11")}},
+				{FileName: "/usr/share/doc/hello/README", Hash: []common.Checksum{sha1("bb"), md5("33")}},
+				{FileName: "/usr/share/man/man1/hello.1.gz", Hash: []common.Checksum{sha1("cc")}},
+				{FileName: "/usr/share/info/hello.info", Hash: []common.Checksum{md5("22")}},
+			},
+			fileDiff{},
+		},
+		{
+			"missing",
+			[]*plugin.FileInfo{
+				{FileName: "/usr/bin/hello", Hash: []common.Checksum{sha1("aa")}},
+				{FileName: "/usr/share/info/hello.info", Hash: []common.Checksum{md5("22")}},
+			},
+			fileDiff{missing: []string{"/usr/share/doc/hello/README", "/usr/share/man/man1/hello.1.gz"}},
+		},
+		{
+			"extra",
+			[]*plugin.FileInfo{
+				{FileName: "/usr/bin/hello", Hash: []common.Checksum{sha1("aa")}},
+				{FileName: "/usr/bin/hello-extra", Hash: []common.Checksum{sha1("dd")}},
+				{FileName: "/usr/share/doc/hello/README", Hash: []common.Checksum{sha1("bb")}},
+				{FileName: "/usr/share/man/man1/hello.1.gz", Hash: []common.Checksum{sha1("cc")}},
+				{FileName: "/usr/share/info/hello.info", Hash: []common.Checksum{md5("22")}},
+			},
+			fileDiff{extra: []string{"/usr/bin/hello-extra"}},
+		},
+		{
+			"mismatch",
+			[]*plugin.FileInfo{
+				{FileName: "/usr/bin/hello", Hash: []common.Checksum{sha1("aa"), md5("12")}},
+				{FileName: "/usr/share/doc/hello/README", Hash: []common.Checksum{sha1("bc")}},
+				{FileName: "/usr/share/man/man1/hello.1.gz", Hash: []common.Checksum{sha1("cc")}},
+				{FileName: "/usr/share/info/hello.info", Hash: []common.Checksum{sha1("ee")}},
+			},
+			fileDiff{mismatch: []fileMismatch{
+				{"/usr/bin/hello", common.MD5},
+				{"/usr/share/doc/hello/README", common.SHA1},
+				// 没有相同的摘要算法时无法确认文件未被修改
+				{"/usr/share/info/hello.info", ""},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareFiles(recorded, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareFiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// AppImage 的类型, 记录在 ELF 头第 8 字节起的 "AI" 及类型号中
+const (
+	AppImageType1 = 1 //ISO 9660 镜像, 运行时位于系统区
+	AppImageType2 = 2 //运行时 ELF 之后追加 squashfs
+)
+
+// AppImage 运行时中记录更新信息与签名的 ELF 节
+const (
+	appImageUpdateSection       = ".upd_info"
+	appImageSignatureSection    = ".sha256_sig"
+	appImageSignatureKeySection = ".sig_key"
+)
+
+// PGP 包类型及签名子包类型
+const (
+	pgpTagSignature               = 2
+	pgpTagPublicKey               = 6
+	pgpSubpacketIssuer            = 16
+	pgpSubpacketIssuerFingerprint = 33
+)
+
+// type 1 的更新信息位于 ISO 主卷描述符的应用使用区
+const (
+	appImageType1UpdateOffset = 33651
+	appImageType1UpdateSize   = 512
+)
+
+// AppImageInfo AppImage 运行时中的信息
+type AppImageInfo struct {
+	Type          int
+	Arch          string //运行时 ELF 的架构, 与 appimagetool 的命名一致
+	PayloadOffset int64  //type 2 中 squashfs 的偏移
+	UpdateInfo    string
+	Signature     []byte //ASCII 格式的 PGP 分离签名
+	SignatureKey  []byte //ASCII 格式的 PGP 公钥
+}
+
+// AppImageType 按魔数判断 AppImage 类型, 不是 AppImage 时返回 0
+func AppImageType(r io.ReaderAt) int {
+	magic := make([]byte, 11)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return 0
+	}
+	if string(magic[:4]) != elf.ELFMAG || string(magic[8:10]) != "AI" {
+		return 0
+	}
+	switch magic[10] {
+	case AppImageType1, AppImageType2:
+		return int(magic[10])
+	}
+	return 0
+}
+
+var elfArchNames = map[elf.Machine]string{
+	elf.EM_X86_64:  "x86_64",
+	elf.EM_386:     "i686",
+	elf.EM_AARCH64: "aarch64",
+	elf.EM_ARM:     "armhf",
+	elf.EM_RISCV:   "riscv64",
+	258:            "loongarch64", //EM_LOONGARCH
+}
+
+// ReadAppImage 读取 AppImage 运行时中的架构、squashfs 偏移、更新信息及签名
+func ReadAppImage(r io.ReaderAt, size int64) (*AppImageInfo, error) {
+	info := &AppImageInfo{Type: AppImageType(r)}
+	if info.Type == 0 {
+		return nil, errors.New("not an AppImage")
+	}
+	f, err := elf.NewFile(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AppImage runtime: %v", err)
+	}
+	info.Arch = elfArchNames[f.Machine]
+	if info.Arch == "" {
+		info.Arch = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+	}
+
+	if info.Type == AppImageType1 {
+		buf := make([]byte, appImageType1UpdateSize)
+		if _, err := r.ReadAt(buf, appImageType1UpdateOffset); err != nil {
+			return nil, err
+		}
+		info.UpdateInfo = string(trimNUL(buf))
+		return info, nil
+	}
+
+	if info.PayloadOffset, err = elfEnd(r, f); err != nil {
+		return nil, err
+	}
+	// 节头表等位置来自文件头, 构造的文件中可能超出文件末尾或溢出为负数
+	if info.PayloadOffset < 0 || info.PayloadOffset > size {
+		return nil, errors.New("invalid AppImage runtime: ELF extends beyond the end of file")
+	}
+	for _, s := range []struct {
+		name string
+		dst  *[]byte
+	}{{appImageSignatureSection, &info.Signature}, {appImageSignatureKeySection, &info.SignatureKey}} {
+		if sec := f.Section(s.name); sec != nil {
+			data, err := sec.Data()
+			if err != nil {
+				return nil, err
+			}
+			*s.dst = trimNUL(data)
+		}
+	}
+	if sec := f.Section(appImageUpdateSection); sec != nil {
+		data, err := sec.Data()
+		if err != nil {
+			return nil, err
+		}
+		info.UpdateInfo = string(trimNUL(data))
+	}
+	return info, nil
+}
+
+// elfEnd 运行时 ELF 的结束位置, 即节头表之后, 与 appimagetool 计算 squashfs 偏移的方式一致;
+// 节或程序段的内容位于节头表之后时取其结束位置
+func elfEnd(r io.ReaderAt, f *elf.File) (int64, error) {
+	var shoff int64
+	var shentsize, shnum uint16
+	hdr := make([]byte, 64)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return 0, err
+	}
+	if f.Class == elf.ELFCLASS64 {
+		shoff = int64(f.ByteOrder.Uint64(hdr[0x28:]))
+		shentsize, shnum = f.ByteOrder.Uint16(hdr[0x3a:]), f.ByteOrder.Uint16(hdr[0x3c:])
+	} else {
+		shoff = int64(f.ByteOrder.Uint32(hdr[0x20:]))
+		shentsize, shnum = f.ByteOrder.Uint16(hdr[0x2e:]), f.ByteOrder.Uint16(hdr[0x30:])
+	}
+	end := shoff + int64(shentsize)*int64(shnum)
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOBITS && int64(s.Offset+s.FileSize) > end {
+			end = int64(s.Offset + s.FileSize)
+		}
+	}
+	for _, p := range f.Progs {
+		if int64(p.Off+p.Filesz) > end {
+			end = int64(p.Off + p.Filesz)
+		}
+	}
+	return end, nil
+}
+
+func trimNUL(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return bytes.TrimSpace(b)
+}
+
+// readPGPPacket 读取 ASCII 格式 PGP 数据中的第一个包, 返回包类型及内容, 支持新旧两种包头
+func readPGPPacket(armored []byte) (byte, []byte, error) {
+	block, err := armor.Decode(bytes.NewReader(armored))
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, errPGPPacket
+	}
+	var tag byte
+	var n, pos int
+	if data[0]&0x40 != 0 {
+		tag, pos = data[0]&0x3f, 2
+		switch l := int(data[1]); {
+		case l < 192:
+			n = l
+		case l < 224 && len(data) > 2:
+			n, pos = (l-192)<<8+int(data[2])+192, 3
+		case l == 255 && len(data) > 5:
+			n, pos = int(binary.BigEndian.Uint32(data[2:])), 6
+		default:
+			return 0, nil, errPGPPacket
+		}
+	} else {
+		tag = (data[0] >> 2) & 0x0f
+		switch data[0] & 0x03 {
+		case 0:
+			n, pos = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, errPGPPacket
+			}
+			n, pos = int(binary.BigEndian.Uint16(data[1:])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, errPGPPacket
+			}
+			n, pos = int(binary.BigEndian.Uint32(data[1:])), 5
+		default:
+			n, pos = len(data)-1, 1
+		}
+	}
+	if n < 0 || pos+n > len(data) {
+		return 0, nil, errPGPPacket
+	}
+	return tag, data[pos : pos+n], nil
+}
+
+var errPGPPacket = errors.New("invalid PGP packet")
+
+// PGPSignatureIssuer 返回 ASCII 格式 PGP 签名的签发者, 优先使用签发者指纹, 否则为密钥 ID;
+// 只解析子包, 不依赖签名算法
+func PGPSignatureIssuer(armored []byte) (string, error) {
+	tag, body, err := readPGPPacket(armored)
+	if err != nil {
+		return "", err
+	}
+	if tag != pgpTagSignature || len(body) < 1 {
+		return "", errPGPPacket
+	}
+	if body[0] == 3 {
+		if len(body) < 15 {
+			return "", errPGPPacket
+		}
+		return fmt.Sprintf("%X", body[7:15]), nil
+	}
+	if body[0] != 4 || len(body) < 6 {
+		return "", errPGPPacket
+	}
+	var keyID string
+	rest := body[4:]
+	// 依次为 hashed 与 unhashed 子包区
+	for area := 0; area < 2; area++ {
+		if len(rest) < 2 {
+			return "", errPGPPacket
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if 2+n > len(rest) {
+			return "", errPGPPacket
+		}
+		sub := rest[2 : 2+n]
+		rest = rest[2+n:]
+		for len(sub) > 0 {
+			l, pos := int(sub[0]), 1
+			switch {
+			case l >= 255 && len(sub) >= 5:
+				l, pos = int(binary.BigEndian.Uint32(sub[1:])), 5
+			case l >= 192 && len(sub) >= 2:
+				l, pos = (l-192)<<8+int(sub[1])+192, 2
+			}
+			if l < 1 || pos+l > len(sub) {
+				return "", errPGPPacket
+			}
+			content := sub[pos : pos+l]
+			switch content[0] & 0x7f {
+			case pgpSubpacketIssuerFingerprint:
+				if len(content) > 2 {
+					return fmt.Sprintf("%X", content[2:]), nil
+				}
+			case pgpSubpacketIssuer:
+				if len(content) == 9 {
+					keyID = fmt.Sprintf("%X", content[1:])
+				}
+			}
+			sub = sub[pos+l:]
+		}
+	}
+	if keyID == "" {
+		return "", errors.New("no issuer in PGP signature")
+	}
+	return keyID, nil
+}
+
+// PGPKeyFingerprint 返回 ASCII 格式 PGP 公钥中主密钥的 v4 指纹, 即 SHA1(0x99 || 长度 || 公钥包)
+func PGPKeyFingerprint(armored []byte) (string, error) {
+	tag, body, err := readPGPPacket(armored)
+	if err != nil {
+		return "", err
+	}
+	if tag != pgpTagPublicKey || len(body) < 1 || body[0] != 4 || len(body) > 0xffff {
+		return "", errors.New("only v4 PGP public keys are supported")
+	}
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	h.Write(body)
+	return fmt.Sprintf("%X", h.Sum(nil)), nil
+}
+
+var sharedLibraryName = regexp.MustCompile(`^(.+?)\.so((?:\.[0-9]+)*)$`)
+
+// ParseSharedLibraryName 从 libfoo.so.1.2.3 形式的文件名中取出库名及版本, 不是共享库时 ok 为 false
+func ParseSharedLibraryName(name string) (lib, version string, ok bool) {
+	m := sharedLibraryName.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], strings.TrimPrefix(m[2], "."), true
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func FuzzReadAppImage(f *testing.F) {
+	for _, name := range []string{"type1.AppImage", "type2.AppImage"} {
+		data, err := ioutil.ReadFile("testdata/appimage/" + name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		// type 1 只需要系统区中的运行时及主卷描述符中的更新信息
+		if end := appImageType1UpdateOffset + appImageType1UpdateSize; len(data) > end {
+			data = data[:end]
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info, err := ReadAppImage(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		if info.Type == AppImageType2 && (info.PayloadOffset < 0 || info.PayloadOffset > int64(len(data))) {
+			t.Fatalf("PayloadOffset %d outside file of %d bytes", info.PayloadOffset, len(data))
+		}
+	})
+}
+
+// FuzzPGPPacket 输入为未编码的 PGP 包, 编码为 ASCII 格式后解析
+func FuzzPGPPacket(f *testing.F) {
+	data, err := ioutil.ReadFile("testdata/appimage/type2.AppImage")
+	if err != nil {
+		f.Fatal(err)
+	}
+	info, err := ReadAppImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, armored := range [][]byte{info.Signature, info.SignatureKey} {
+		block, err := armor.Decode(bytes.NewReader(armored))
+		if err != nil {
+			f.Fatal(err)
+		}
+		packet, err := ioutil.ReadAll(block.Body)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(packet)
+	}
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, "PGP SIGNATURE", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(packet)
+		w.Close()
+		PGPSignatureIssuer(buf.Bytes())
+		PGPKeyFingerprint(buf.Bytes())
+	})
+}
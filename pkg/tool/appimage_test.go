@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// testSignerFingerprint type2.AppImage 中签名及公钥对应的 ed25519 密钥
+const testSignerFingerprint = "E6706B86B60D97005D07489E71650156A9A4FB23"
+
+func readTestAppImage(t *testing.T, name string) *AppImageInfo {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/appimage/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadAppImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestReadAppImage(t *testing.T) {
+	info := readTestAppImage(t, "type1.AppImage")
+	if info.Type != AppImageType1 || info.Arch != "x86_64" || info.UpdateInfo != "zsync|https://example.org/t1.zsync" {
+		t.Errorf("type 1: unexpected info %+v", info)
+	}
+
+	// type 2 的运行时带有 .upd_info、.sha256_sig 及 .sig_key 节, 之后为 squashfs
+	info = readTestAppImage(t, "type2.AppImage")
+	if info.Type != AppImageType2 || info.Arch != "x86_64" || info.UpdateInfo != "zsync|https://example.org/Hello.AppImage.zsync" {
+		t.Errorf("type 2: unexpected info %+v", info)
+	}
+	data, _ := ioutil.ReadFile("testdata/appimage/type2.AppImage")
+	if info.PayloadOffset != 27928 || string(data[info.PayloadOffset:info.PayloadOffset+4]) != "hsqs" {
+		t.Errorf("PayloadOffset = %d", info.PayloadOffset)
+	}
+	if issuer, err := PGPSignatureIssuer(info.Signature); err != nil || issuer != testSignerFingerprint {
+		t.Errorf("PGPSignatureIssuer() = %q, %v", issuer, err)
+	}
+	if fpr, err := PGPKeyFingerprint(info.SignatureKey); err != nil || fpr != testSignerFingerprint {
+		t.Errorf("PGPKeyFingerprint() = %q, %v", fpr, err)
+	}
+
+	// 第一个程序段的大小超出文件末尾
+	phoff := binary.LittleEndian.Uint64(data[0x20:])
+	binary.LittleEndian.PutUint64(data[phoff+0x20:], 1<<54)
+	if info, err := ReadAppImage(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Errorf("program header out of range: PayloadOffset = %d", info.PayloadOffset)
+	}
+}
+
+func TestAppImageType(t *testing.T) {
+	elfHeader := "\x7fELF\x02\x01\x01\x00"
+	tests := []struct {
+		data string
+		want int
+	}{
+		{elfHeader + "AI\x01", AppImageType1},
+		{elfHeader + "AI\x02", AppImageType2},
+		{elfHeader + "AI\x03", 0},
+		{elfHeader + "\x00\x00\x00", 0},
+		{"\x00ELF\x02\x01\x01\x00AI\x02", 0},
+		{"\x7fELF", 0},
+	}
+	for _, tt := range tests {
+		if got := AppImageType(strings.NewReader(tt.data)); got != tt.want {
+			t.Errorf("AppImageType(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+	// 魔数正确但不是有效的 ELF
+	if _, err := ReadAppImage(strings.NewReader(elfHeader+"AI\x02"), 11); err == nil {
+		t.Error("ReadAppImage: expected an error")
+	}
+}
+
+// armorPGP 将 PGP 包编码为 ASCII 格式
+func armorPGP(t *testing.T, blockType string, packet []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(packet)
+	w.Close()
+	return buf.Bytes()
+}
+
+// pgpPacket 使用新格式包头编码 PGP 包
+func pgpPacket(tag byte, body []byte) []byte {
+	return append([]byte{0xc0 | tag, byte(len(body))}, body...)
+}
+
+// pgpSignature 构造 v4 签名包的内容, 签名算法及签名值不影响签发者的解析
+func pgpSignature(hashed, unhashed []byte) []byte {
+	body := []byte{4, 0, 22, 8, byte(len(hashed) >> 8), byte(len(hashed))}
+	body = append(body, hashed...)
+	body = append(body, byte(len(unhashed)>>8), byte(len(unhashed)))
+	return append(append(body, unhashed...), 0, 0)
+}
+
+func TestPGPSignatureIssuer(t *testing.T) {
+	keyID := []byte{0x71, 0x65, 0x01, 0x56, 0xa9, 0xa4, 0xfb, 0x23}
+	issuer := append([]byte{9, pgpSubpacketIssuer}, keyID...)
+	creationTime := []byte{5, 2, 0x6a, 0xd3, 0x57, 0x8a}
+	v3 := append([]byte{3, 5, 0, 0x6a, 0xd3, 0x57, 0x8a}, keyID...)
+	v3 = append(v3, 22, 8, 0, 0)
+	v4 := pgpSignature(creationTime, issuer)
+	long := make([]byte, 300)
+	long = append(long, v4...)
+
+	tests := []struct {
+		name   string
+		packet []byte
+		want   string
+	}{
+		{"issuer key ID", pgpPacket(pgpTagSignature, v4), "71650156A9A4FB23"},
+		{"old format header", append([]byte{0x80 | pgpTagSignature<<2, byte(len(v4))}, v4...), "71650156A9A4FB23"},
+		{"two octet length", append([]byte{0x80 | pgpTagSignature<<2 | 1, 0, byte(len(v4))}, v4...), "71650156A9A4FB23"},
+		{"v3 signature", pgpPacket(pgpTagSignature, v3), "71650156A9A4FB23"},
+		{"no issuer", pgpPacket(pgpTagSignature, pgpSignature(creationTime, nil)), ""},
+		{"truncated subpacket", pgpPacket(pgpTagSignature, pgpSignature([]byte{9, pgpSubpacketIssuer}, nil)), ""},
+		{"not a signature", pgpPacket(pgpTagPublicKey, v4), ""},
+		{"packet length out of range", append([]byte{0xc0 | pgpTagSignature, 100}, v4...), ""},
+		{"five octet length out of range", append([]byte{0xc0 | pgpTagSignature, 255, 0xff, 0xff, 0xff, 0xff}, long...), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PGPSignatureIssuer(armorPGP(t, "PGP SIGNATURE", tt.packet))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := PGPSignatureIssuer([]byte("not armored")); err == nil {
+		t.Error("not armored: expected an error")
+	}
+}
+
+func TestPGPKeyFingerprint(t *testing.T) {
+	v3Key := []byte{3, 0x6a, 0xd3, 0x57, 0x8a, 0, 0, 1}
+	if _, err := PGPKeyFingerprint(armorPGP(t, "PGP PUBLIC KEY BLOCK", pgpPacket(pgpTagPublicKey, v3Key))); err == nil {
+		t.Error("v3 key: expected an error")
+	}
+	if _, err := PGPKeyFingerprint(armorPGP(t, "PGP PUBLIC KEY BLOCK", pgpPacket(pgpTagSignature, pgpSignature(nil, nil)))); err == nil {
+		t.Error("signature packet: expected an error")
+	}
+}
+
+func TestParseSharedLibraryName(t *testing.T) {
+	tests := []struct {
+		name, lib, version string
+		ok                 bool
+	}{
+		{"libfoo.so", "libfoo", "", true},
+		{"libfoo.so.1", "libfoo", "1", true},
+		{"libfoo.so.1.2.3", "libfoo", "1.2.3", true},
+		{"libfoo-2.0.so.0", "libfoo-2.0", "0", true},
+		{"libc.so.6", "libc", "6", true},
+		{"libfoo.so.1a", "", "", false},
+		{"libfoo.so.", "", "", false},
+		{"libfoo.a", "", "", false},
+		{".so", "", "", false},
+		{"readme.txt", "", "", false},
+	}
+	for _, tt := range tests {
+		lib, version, ok := ParseSharedLibraryName(tt.name)
+		if lib != tt.lib || version != tt.version || ok != tt.ok {
+			t.Errorf("ParseSharedLibraryName(%q) = %q, %q, %v, want %q, %q, %v", tt.name, lib, version, ok, tt.lib, tt.version, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// AppStream AppStream 元信息(metainfo/appdata)中与 sbom 相关的字段, 均取未翻译的值
+type AppStream struct {
+	ID             string
+	Name           string
+	Summary        string
+	Description    string
+	ProjectLicense string //SPDX 许可证表达式
+	Homepage       string
+	Developer      string
+	Version        string //第一个(即最新的) release 的版本
+}
+
+// appStreamText 可翻译的文本, 带有 xml:lang 的为翻译
+type appStreamText struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type appStreamComponent struct {
+	ID             string          `xml:"id"`
+	Name           []appStreamText `xml:"name"`
+	Summary        []appStreamText `xml:"summary"`
+	Description    []appStreamText `xml:"description>p"`
+	ProjectLicense string          `xml:"project_license"`
+	URLs           []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"url"`
+	DeveloperName []appStreamText `xml:"developer_name"`
+	Developer     []appStreamText `xml:"developer>name"`
+	Releases      []struct {
+		Version string `xml:"version,attr"`
+	} `xml:"releases>release"`
+}
+
+// untranslated 返回未翻译的文本, 多段时以空行连接
+func untranslated(texts []appStreamText) string {
+	var parts []string
+	for _, t := range texts {
+		if t.Lang == "" {
+			if s := strings.Join(strings.Fields(t.Value), " "); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ParseAppStream 解析 AppStream 元信息, data 可以是单个 component 或 components 集合,
+// 集合中优先选择 id 与 id(或 id.desktop)一致的组件, 否则使用第一个组件
+func ParseAppStream(data []byte, id string) (*AppStream, error) {
+	var components []appStreamComponent
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "component" {
+			continue
+		}
+		var c appStreamComponent
+		if err := dec.DecodeElement(&c, &start); err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	if len(components) == 0 {
+		return nil, errors.New("no AppStream component found")
+	}
+	c := components[0]
+	for _, v := range components {
+		if v.ID == id || v.ID == id+".desktop" {
+			c = v
+			break
+		}
+	}
+
+	as := &AppStream{
+		ID:             strings.TrimSpace(c.ID),
+		Name:           untranslated(c.Name),
+		Summary:        untranslated(c.Summary),
+		Description:    untranslated(c.Description),
+		ProjectLicense: strings.TrimSpace(c.ProjectLicense),
+		Developer:      untranslated(c.Developer),
+	}
+	if as.Developer == "" {
+		as.Developer = untranslated(c.DeveloperName)
+	}
+	for _, u := range c.URLs {
+		if u.Type == "homepage" {
+			as.Homepage = strings.TrimSpace(u.Value)
+			break
+		}
+	}
+	if len(c.Releases) > 0 {
+		as.Version = c.Releases[0].Version
+	}
+	return as, nil
+}
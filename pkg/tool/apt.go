@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// ReadAptPackagesIndex 读取 APT 仓库的 Packages 索引, 按扩展名解压 Packages.gz、Packages.xz 等
+func ReadAptPackagesIndex(indexPath string) ([]Deb822Paragraph, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewDecompressReader(CompressorFromExt(indexPath), f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDeb822(data), nil
+}
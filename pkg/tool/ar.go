@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// ArHeader ar 归档成员头
+type ArHeader struct {
+	Name    string
+	Size    int64
+	ModTime int64
+	Uid     int
+	Gid     int
+	Mode    int64
+}
+
+// ArReader ar 归档(deb 外层格式)读取器
+type ArReader struct {
+	r       io.Reader
+	remain  int64
+	padding int64
+}
+
+// NewArReader 校验 ar 魔数并返回读取器
+func NewArReader(r io.Reader) (*ArReader, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != arMagic {
+		return nil, errors.New("not an ar archive")
+	}
+	return &ArReader{r: r}, nil
+}
+
+// Next 跳到下一个成员, 结束时返回 io.EOF
+func (a *ArReader) Next() (*ArHeader, error) {
+	if _, err := io.CopyN(ioutil.Discard, a.r, a.remain+a.padding); err != nil {
+		return nil, err
+	}
+	a.remain, a.padding = 0, 0
+
+	raw := make([]byte, arHeaderSize)
+	if _, err := io.ReadFull(a.r, raw); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated ar header")
+		}
+		return nil, err
+	}
+	if string(raw[58:60]) != "`\n" {
+		return nil, errors.New("bad ar member header")
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(raw[48:58])), 10, 64)
+	if err != nil || size < 0 {
+		return nil, errors.New("bad ar member size")
+	}
+	hdr := &ArHeader{
+		Name: strings.TrimSpace(string(raw[0:16])),
+		Size: size,
+	}
+	// 时间、属主与权限只用于重写归档, 解析失败时保持为 0
+	hdr.ModTime, _ = strconv.ParseInt(strings.TrimSpace(string(raw[16:28])), 10, 64)
+	hdr.Uid, _ = strconv.Atoi(strings.TrimSpace(string(raw[28:34])))
+	hdr.Gid, _ = strconv.Atoi(strings.TrimSpace(string(raw[34:40])))
+	hdr.Mode, _ = strconv.ParseInt(strings.TrimSpace(string(raw[40:48])), 8, 64)
+	a.remain = size
+	a.padding = size % 2
+
+	// BSD 长文件名: "#1/<len>", 文件名位于数据开头
+	if strings.HasPrefix(hdr.Name, "#1/") {
+		n, err := strconv.ParseInt(hdr.Name[3:], 10, 64)
+		if err != nil || n > size {
+			return nil, errors.New("bad ar member name")
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(a.r, name); err != nil {
+			return nil, err
+		}
+		hdr.Name = strings.TrimRight(string(name), "\x00")
+		hdr.Size -= n
+		a.remain -= n
+	}
+	// GNU 格式以 "/" 结尾
+	if hdr.Name != "/" && hdr.Name != "//" {
+		hdr.Name = strings.TrimSuffix(hdr.Name, "/")
+	}
+	return hdr, nil
+}
+
+func (a *ArReader) Read(p []byte) (int, error) {
+	if a.remain <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > a.remain {
+		p = p[:a.remain]
+	}
+	n, err := a.r.Read(p)
+	a.remain -= int64(n)
+	if err == io.EOF && a.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// ArWriter ar 归档写入器, 成员名使用 deb 包的写法, 不超过 16 个字符且不以 / 结尾
+type ArWriter struct {
+	w       io.Writer
+	remain  int64
+	padding int64
+}
+
+// NewArWriter 写入 ar 魔数并返回写入器
+func NewArWriter(w io.Writer) (*ArWriter, error) {
+	if _, err := io.WriteString(w, arMagic); err != nil {
+		return nil, err
+	}
+	return &ArWriter{w: w}, nil
+}
+
+// WriteHeader 结束上一个成员并写入新成员的头, 之后写入的内容不能超过 hdr.Size
+func (a *ArWriter) WriteHeader(hdr *ArHeader) error {
+	if err := a.finish(); err != nil {
+		return err
+	}
+	if len(hdr.Name) > 16 || strings.ContainsAny(hdr.Name, " /") {
+		return fmt.Errorf("bad ar member name %q", hdr.Name)
+	}
+	mode := hdr.Mode
+	if mode == 0 {
+		mode = 0100644
+	}
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", hdr.Name, hdr.ModTime, hdr.Uid, hdr.Gid, mode, hdr.Size)
+	if len(header) != arHeaderSize {
+		return errors.New("ar member header too long")
+	}
+	if _, err := io.WriteString(a.w, header); err != nil {
+		return err
+	}
+	a.remain = hdr.Size
+	a.padding = hdr.Size % 2
+	return nil
+}
+
+func (a *ArWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > a.remain {
+		return 0, errors.New("write too long for ar member")
+	}
+	n, err := a.w.Write(p)
+	a.remain -= int64(n)
+	return n, err
+}
+
+// finish 检查当前成员是否写完, 并写入对齐用的换行
+func (a *ArWriter) finish() error {
+	if a.remain > 0 {
+		return errors.New("missed writing ar member data")
+	}
+	if a.padding > 0 {
+		if _, err := io.WriteString(a.w, "\n"); err != nil {
+			return err
+		}
+		a.padding = 0
+	}
+	return nil
+}
+
+// Close 结束最后一个成员, 不关闭底层的 io.Writer
+func (a *ArWriter) Close() error {
+	return a.finish()
+}
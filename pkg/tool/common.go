@@ -15,10 +15,10 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/google/licensecheck"
 	"github.com/spdx/tools-golang/spdx/v2/common"
-	"github.com/spdx/tools-golang/utils"
 	"github.com/tjfoc/gmsm/sm3"
 )
 
@@ -53,17 +53,81 @@ func CutPrefix(s, prefix string) string {
 	return s
 }
 
+// 流式计算摘要的缓冲区, 内存占用与文件大小无关
+const hashBufferSize = 256 * 1024
+
+var hashBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, hashBufferSize)
+		return &buf
+	},
+}
+
 func GetHashesForFilePath(p string) (string, string, string, string, error) {
-	var sha1, sha256, md5 string
-	var data []byte
-	var err error
-	if sha1, sha256, md5, err = utils.GetHashesForFilePath(p); err != nil {
+	f, err := os.Open(p)
+	if err != nil {
 		return "", "", "", "", err
 	}
-	if data, err = ioutil.ReadFile(p); err != nil {
+	defer f.Close()
+	return GetHashesForReader(f)
+}
+
+// GetHashesForReader 从数据流中一次性计算 SHA1、SHA256、MD5、SM3 摘要
+func GetHashesForReader(r io.Reader) (string, string, string, string, error) {
+	hSHA1 := sha1.New()
+	hSHA256 := sha256.New()
+	hMD5 := md5.New()
+	hSM3 := sm3.New()
+
+	buf := hashBufferPool.Get().(*[]byte)
+	defer hashBufferPool.Put(buf)
+	// 只包装 Reader, 避免 *os.File 等实现的 WriterTo 绕过固定缓冲区
+	if _, err := io.CopyBuffer(io.MultiWriter(hSHA1, hSHA256, hMD5, hSM3), struct{ io.Reader }{r}, *buf); err != nil {
 		return "", "", "", "", err
 	}
-	return sha1, sha256, md5, fmt.Sprintf("%x", sm3.Sm3Sum(data)), nil
+	return hex.EncodeToString(hSHA1.Sum(nil)), hex.EncodeToString(hSHA256.Sum(nil)),
+		hex.EncodeToString(hMD5.Sum(nil)), hex.EncodeToString(hSM3.Sum(nil)), nil
+}
+
+// GetChecksumsForReader 流式计算文件校验和, 顺序为 SHA1、SHA256、MD5、SM3
+func GetChecksumsForReader(r io.Reader) ([]common.Checksum, error) {
+	sha1, sha256, md5, sm3, err := GetHashesForReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return []common.Checksum{
+		{Algorithm: common.SHA1, Value: sha1},
+		{Algorithm: common.SHA256, Value: sha256},
+		{Algorithm: common.MD5, Value: md5},
+		{Algorithm: "SM3", Value: sm3},
+	}, nil
+}
+
+// CompareChecksums 比对记录的摘要与实际摘要, 只比较两者都有的算法;
+// 不一致或没有可比较的算法时返回 false 及对应的算法, 后者算法为空
+func CompareChecksums(recorded, actual []common.Checksum) (common.ChecksumAlgorithm, bool) {
+	compared := false
+	for _, c := range recorded {
+		value := ChecksumValue(actual, c.Algorithm)
+		if value == "" {
+			continue
+		}
+		if !strings.EqualFold(value, c.Value) {
+			return c.Algorithm, false
+		}
+		compared = true
+	}
+	return "", compared
+}
+
+// ChecksumValue 返回指定算法的摘要值, 没有时返回空
+func ChecksumValue(checksums []common.Checksum, algo common.ChecksumAlgorithm) string {
+	for _, c := range checksums {
+		if c.Algorithm == algo {
+			return c.Value
+		}
+	}
+	return ""
 }
 
 func CalculateSHA256(filePath string) (string, error) {
@@ -102,7 +166,12 @@ func GetLicenses(file string) []string {
 		fmt.Println(err)
 		return nil
 	}
-	cov := licensecheck.Scan([]byte(text))
+	return GetLicensesFromText(text)
+}
+
+// GetLicensesFromText 扫描文本中出现的许可证, 按出现顺序去重
+func GetLicensesFromText(text []byte) []string {
+	cov := licensecheck.Scan(text)
 	// fmt.Printf("%.1f%% of text covered by licenses:\n", cov.Percent)
 	var licenseList []string
 	for _, m := range cov.Match {
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// NewDecompressReader 按压缩算法名称(gzip/bzip2/xz/lzma/zstd)创建解压流,
+// 算法名称为空或 none 时原样返回
+func NewDecompressReader(compressor string, r io.Reader) (io.ReadCloser, error) {
+	switch compressor {
+	case "", "none":
+		return ioutil.NopCloser(r), nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "bzip2":
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	case "xz":
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xzReader), nil
+	case "lzma":
+		lzmaReader, err := lzma.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(lzmaReader), nil
+	case "zstd":
+		zstdReader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{zstdReader}, nil
+	}
+	return nil, fmt.Errorf("unsupported compressor: %s", compressor)
+}
+
+// CompressorFromExt 根据文件扩展名(如 data.tar.xz)推断压缩算法名称
+func CompressorFromExt(name string) string {
+	switch path.Ext(name) {
+	case ".gz":
+		return "gzip"
+	case ".bz2":
+		return "bzip2"
+	case ".xz":
+		return "xz"
+	case ".lzma":
+		return "lzma"
+	case ".zst":
+		return "zstd"
+	}
+	return ""
+}
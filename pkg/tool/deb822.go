@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// Deb822Paragraph deb822 格式(control、copyright 等文件)中的一个段落, 字段名不区分大小写
+type Deb822Paragraph struct {
+	names  []string
+	fields map[string]string
+}
+
+// Get 返回字段值, 多行字段的续行以换行分隔, 单独的 . 行表示空行
+func (p Deb822Paragraph) Get(name string) string {
+	return p.fields[strings.ToLower(name)]
+}
+
+// Has 判断段落中是否有该字段
+func (p Deb822Paragraph) Has(name string) bool {
+	_, ok := p.fields[strings.ToLower(name)]
+	return ok
+}
+
+// Names 按出现顺序返回字段名
+func (p Deb822Paragraph) Names() []string {
+	return p.names
+}
+
+// ParseDeb822 解析 deb822 格式文本, 段落之间以空行分隔, # 开头的行为注释
+func ParseDeb822(text []byte) []Deb822Paragraph {
+	var paragraphs []Deb822Paragraph
+	var cur *Deb822Paragraph
+	var name string
+	scn := bufio.NewScanner(bytes.NewReader(text))
+	scn.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scn.Scan() {
+		line := strings.TrimRight(scn.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			cur = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if cur == nil || name == "" {
+				continue
+			}
+			cont := strings.TrimSpace(line)
+			if cont == "." {
+				cont = ""
+			}
+			cur.fields[name] += "\n" + cont
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			continue
+		}
+		if cur == nil {
+			paragraphs = append(paragraphs, Deb822Paragraph{fields: make(map[string]string)})
+			cur = &paragraphs[len(paragraphs)-1]
+		}
+		field := strings.TrimSpace(line[:idx])
+		name = strings.ToLower(field)
+		if _, ok := cur.fields[name]; !ok {
+			cur.names = append(cur.names, field)
+		}
+		cur.fields[name] = strings.TrimSpace(line[idx+1:])
+	}
+	return paragraphs
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DebRelationFields 需要解析的 deb 软件包关系字段, 按输出顺序排列
+var DebRelationFields = []string{
+	"Pre-Depends",
+	"Depends",
+	"Recommends",
+	"Suggests",
+	"Breaks",
+	"Conflicts",
+	"Provides",
+	"Built-Using",
+}
+
+// DebSourceRelationFields 源码包中需要解析的构建关系字段, 按输出顺序排列
+var DebSourceRelationFields = []string{
+	"Build-Depends",
+	"Build-Depends-Arch",
+	"Build-Depends-Indep",
+	"Build-Conflicts",
+	"Build-Conflicts-Arch",
+	"Build-Conflicts-Indep",
+}
+
+func isDebRelationField(name string) bool {
+	for _, f := range DebRelationFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DebRelation 关系字段中的一项, 如 libc6:any (>= 2.34) [amd64 !i386] <!nocheck>
+type DebRelation struct {
+	Name          string
+	ArchQualifier string //架构限定, 如 any、native、amd64
+	Operator      string //<<、<=、=、>=、>>
+	Version       string
+	Architectures []string   //架构限制列表, 如 amd64、!i386
+	Profiles      [][]string //构建配置限制, 每组之间为或关系, 组内为且关系
+}
+
+// DebRelationGroup 以 | 分隔的候选关系, 满足其中之一即可
+type DebRelationGroup []DebRelation
+
+var debRelationRegexp = regexp.MustCompile(`^([^\s:(\[<]+)(?::([^\s(\[<]+))?` +
+	`\s*(?:\(\s*(<<|<=|=|>=|>>|<|>)\s*([^\s()]+)\s*\))?` +
+	`\s*(?:\[([^\[\]]*)\])?` +
+	`\s*((?:<[^<>]*>\s*)*)$`)
+
+var debProfileRegexp = regexp.MustCompile(`<([^<>]*)>`)
+
+// parseDebRelation 解析单个关系
+func parseDebRelation(s string) (DebRelation, error) {
+	s = strings.TrimSpace(s)
+	m := debRelationRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return DebRelation{}, fmt.Errorf("invalid relation %q", s)
+	}
+	rel := DebRelation{
+		Name:          m[1],
+		ArchQualifier: m[2],
+		Operator:      m[3],
+		Version:       m[4],
+		Architectures: strings.Fields(m[5]),
+	}
+	// < 与 > 是已废弃的写法, 含义分别为 <= 与 >=
+	switch rel.Operator {
+	case "<":
+		rel.Operator = "<="
+	case ">":
+		rel.Operator = ">="
+	}
+	for _, p := range debProfileRegexp.FindAllStringSubmatch(m[6], -1) {
+		rel.Profiles = append(rel.Profiles, strings.Fields(p[1]))
+	}
+	return rel, nil
+}
+
+// ParseDebRelations 解析 Depends 等关系字段, 以 , 分隔的各组之间为且关系, 组内以 | 分隔的候选之间为或关系
+func ParseDebRelations(field string) ([]DebRelationGroup, error) {
+	var groups []DebRelationGroup
+	for _, g := range strings.Split(field, ",") {
+		if strings.TrimSpace(g) == "" {
+			continue
+		}
+		var group DebRelationGroup
+		for _, alt := range strings.Split(g, "|") {
+			rel, err := parseDebRelation(alt)
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, rel)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
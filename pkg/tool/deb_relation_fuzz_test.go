@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseDebRelations(f *testing.F) {
+	f.Add("libc6 (>= 2.34), libfoo1 | libbar1 (= 1.0-1)")
+	f.Add("python3:any (>= 3.9) [amd64 !i386] <!nocheck> <cross>")
+	f.Fuzz(func(t *testing.T, field string) {
+		groups, err := ParseDebRelations(field)
+		if err != nil {
+			return
+		}
+		for _, g := range groups {
+			if len(g) == 0 {
+				t.Fatalf("empty group in %q", field)
+			}
+			for _, rel := range g {
+				if rel.Name == "" || strings.ContainsAny(rel.Name, " ,|") {
+					t.Fatalf("invalid name %q in %q", rel.Name, field)
+				}
+				if (rel.Operator == "") != (rel.Version == "") {
+					t.Fatalf("operator %q without version %q in %q", rel.Operator, rel.Version, field)
+				}
+			}
+		}
+	})
+}
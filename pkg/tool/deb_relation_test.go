@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+// normalizeRelations 将空的架构与构建配置列表统一为 nil, 便于比较
+func normalizeRelations(groups []DebRelationGroup) []DebRelationGroup {
+	for _, g := range groups {
+		for i := range g {
+			if len(g[i].Architectures) == 0 {
+				g[i].Architectures = nil
+			}
+			if len(g[i].Profiles) == 0 {
+				g[i].Profiles = nil
+			}
+		}
+	}
+	return groups
+}
+
+func TestParseDebRelations(t *testing.T) {
+	tests := []struct {
+		field string
+		want  []DebRelationGroup
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"libc6", []DebRelationGroup{{{Name: "libc6"}}}},
+		{
+			"libc6 (>= 2.34), libfoo1 | libbar1 (= 1.0-1)",
+			[]DebRelationGroup{
+				{{Name: "libc6", Operator: ">=", Version: "2.34"}},
+				{{Name: "libfoo1"}, {Name: "libbar1", Operator: "=", Version: "1.0-1"}},
+			},
+		},
+		{
+			"python3:any(>=3.9)",
+			[]DebRelationGroup{{{Name: "python3", ArchQualifier: "any", Operator: ">=", Version: "3.9"}}},
+		},
+		{
+			"libc6 (< 2.0), libd (> 1.0)",
+			[]DebRelationGroup{
+				{{Name: "libc6", Operator: "<=", Version: "2.0"}},
+				{{Name: "libd", Operator: ">=", Version: "1.0"}},
+			},
+		},
+		{
+			"debhelper-compat (= 13),\n dh-python [amd64 !i386] <!nocheck> <cross pkg.foo>",
+			[]DebRelationGroup{
+				{{Name: "debhelper-compat", Operator: "=", Version: "13"}},
+				{{
+					Name:          "dh-python",
+					Architectures: []string{"amd64", "!i386"},
+					Profiles:      [][]string{{"!nocheck"}, {"cross", "pkg.foo"}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseDebRelations(tt.field)
+		if err != nil {
+			t.Errorf("ParseDebRelations(%q): %v", tt.field, err)
+			continue
+		}
+		if got = normalizeRelations(got); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDebRelations(%q) = %+v, want %+v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParseDebRelationsInvalid(t *testing.T) {
+	for _, field := range []string{
+		"libc6 (>= 2.34",
+		"libc6 (~ 1.0)",
+		"libfoo | ",
+		"libc6 [amd64",
+		"lib c6",
+	} {
+		if got, err := ParseDebRelations(field); err == nil {
+			t.Errorf("ParseDebRelations(%q) = %+v, expected an error", field, got)
+		}
+	}
+}
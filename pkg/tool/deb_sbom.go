@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+// deb 包中嵌入 sbom 使用的 ar 成员, 位于 data.tar 之后, dpkg 安装时会忽略
+const (
+	DebSBOMMember = "sbom.tar.xz"    //内含 sbom.spdx.json 的 tar.xz
+	DebSBOMFile   = "sbom.spdx.json" //sbom.tar.xz 中的 sbom 文件名
+	DebSignMember = "sign"           //sbom.spdx.json 的签名, 与 sign 子命令的输出格式相同
+)
+
+var ErrNoDebSign = errors.New(DebSignMember + " don't exist in deb")
+
+// sbomTarXz 将 sbom 打包为只有 sbom.spdx.json 一个文件的 tar.xz
+func sbomTarXz(sbom []byte, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	xw, err := xz.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(xw)
+	err = tw.WriteHeader(&tar.Header{
+		Name:     DebSBOMFile,
+		Mode:     0644,
+		Size:     int64(len(sbom)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+		Format:   tar.FormatGNU,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(sbom); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := xw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EmbedDebSBOM 将 sbom 及其签名写入 deb 包末尾, sign 为空时不写入签名;
+// 包中已有的 sbom 与签名会被替换, 其他成员保持原样。先写入同目录下的临时文件再替换原文件
+func EmbedDebSBOM(debPath string, sbom []byte, sign []byte) (err error) {
+	src, err := os.Open(debPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(debPath), "."+filepath.Base(debPath)+".")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	ar, err := NewArReader(bufio.NewReader(src))
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(tmp)
+	aw, err := NewArWriter(bw)
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, err := ar.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name == DebSBOMMember || hdr.Name == DebSignMember {
+			continue
+		}
+		if err := aw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(aw, ar); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	sbomTar, err := sbomTarXz(sbom, now)
+	if err != nil {
+		return err
+	}
+	writeMember := func(name string, data []byte) error {
+		err := aw.WriteHeader(&ArHeader{Name: name, Size: int64(len(data)), ModTime: now.Unix(), Mode: 0100644})
+		if err != nil {
+			return err
+		}
+		_, err = aw.Write(data)
+		return err
+	}
+	if err := writeMember(DebSBOMMember, sbomTar); err != nil {
+		return err
+	}
+	if len(sign) > 0 {
+		if err := writeMember(DebSignMember, sign); err != nil {
+			return err
+		}
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), debPath)
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// DebSourceFile .dsc 中列出的源码包文件, 如 orig 与 debian 压缩包
+type DebSourceFile struct {
+	Name      string
+	Size      int64
+	Checksums []common.Checksum
+}
+
+// .dsc 中记录文件摘要的字段及对应的算法
+var dscChecksumFields = []struct {
+	field string
+	algo  common.ChecksumAlgorithm
+}{
+	{"Files", common.MD5},
+	{"Checksums-Sha1", common.SHA1},
+	{"Checksums-Sha256", common.SHA256},
+}
+
+// ParseDscFiles 解析 .dsc 中的 Files 与 Checksums-* 字段, 每行为 "摘要 大小 文件名", 按 Files 中的顺序返回
+func ParseDscFiles(p Deb822Paragraph) []DebSourceFile {
+	var files []DebSourceFile
+	index := make(map[string]int)
+	for _, c := range dscChecksumFields {
+		for _, line := range strings.Split(p.Get(c.field), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			i, ok := index[fields[2]]
+			if !ok {
+				size, _ := strconv.ParseInt(fields[1], 10, 64)
+				i = len(files)
+				index[fields[2]] = i
+				files = append(files, DebSourceFile{Name: fields[2], Size: size})
+			}
+			files[i].Checksums = append(files[i].Checksums, common.Checksum{Algorithm: c.algo, Value: strings.ToLower(fields[0])})
+		}
+	}
+	return files
+}
+
+// StripPGPSignature 去掉 OpenPGP 明文签名(.dsc、.changes 通常带有签名), 返回签名的原文; 没有签名时原样返回
+func StripPGPSignature(data []byte) []byte {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		return data
+	}
+	var out bytes.Buffer
+	inHeader, inBody := true, false
+	scn := bufio.NewScanner(bytes.NewReader(data))
+	scn.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scn.Scan() {
+		line := strings.TrimRight(scn.Text(), "\r")
+		switch {
+		case !inBody && line == "-----BEGIN PGP SIGNED MESSAGE-----":
+			inHeader = true
+		case inHeader:
+			// Hash: 等头部字段以空行结束
+			if strings.TrimSpace(line) == "" {
+				inHeader, inBody = false, true
+			}
+		case inBody && line == "-----BEGIN PGP SIGNATURE-----":
+			return out.Bytes()
+		case inBody:
+			// 以 - 开头的行在签名时被转义为 "- -"
+			out.WriteString(strings.TrimPrefix(line, "- "))
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes()
+}
+
+var debChangelogRegexp = regexp.MustCompile(`^(\S+)\s+\(([^()\s]+)\)`)
+
+// ParseDebChangelogHead 解析 debian/changelog 的第一条记录, 返回源码包名与版本,
+// 如 "hello (2.10-3) unstable; urgency=medium"
+func ParseDebChangelogHead(data []byte) (string, string, error) {
+	scn := bufio.NewScanner(bytes.NewReader(data))
+	for scn.Scan() {
+		line := scn.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := debChangelogRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return "", "", fmt.Errorf("invalid changelog entry %q", line)
+		}
+		return m[1], m[2], nil
+	}
+	return "", "", fmt.Errorf("empty changelog")
+}
+
+// SplitDebSource 拆分二进制包 Source 字段中的源码包名与版本, 如 "hello (2.10-3)", 没有版本时返回空
+func SplitDebSource(field string) (string, string) {
+	field = strings.TrimSpace(field)
+	idx := strings.Index(field, "(")
+	if idx < 0 {
+		return field, ""
+	}
+	return strings.TrimSpace(field[:idx]), strings.Trim(strings.TrimSpace(field[idx:]), "()")
+}
+
+// 源码压缩包的种类
+const (
+	DebTarballOrig   = "orig"   //上游源码, 包括 orig-<component> 附加组件
+	DebTarballDebian = "debian" //3.0 (quilt) 格式中的 debian 目录
+	DebTarballNative = "native" //3.0 (native) 格式的完整源码
+)
+
+// DebSourceTarball 由文件名解析出的源码压缩包信息
+type DebSourceTarball struct {
+	Source    string
+	Version   string //orig 压缩包中为上游版本, 不带 epoch
+	Kind      string
+	Component string //orig-<component> 中的组件名, 解压到源码树的同名目录中
+}
+
+var debSourceTarRegexp = regexp.MustCompile(`^([a-z0-9][a-z0-9.+-]+)_([^_/]+?)(\.orig(?:-([A-Za-z0-9][A-Za-z0-9-]*))?|\.debian)?\.tar(?:\.(?:gz|bz2|xz|lzma|zst))?$`)
+
+// ParseDebSourceTarball 按 dpkg-source 的命名规则解析源码压缩包文件名,
+// 如 hello_2.10.orig.tar.gz、hello_2.10-3.debian.tar.xz、hello_1.0.tar.xz; 不是源码压缩包时返回 false
+func ParseDebSourceTarball(name string) (DebSourceTarball, bool) {
+	m := debSourceTarRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return DebSourceTarball{}, false
+	}
+	t := DebSourceTarball{Source: m[1], Version: m[2], Kind: DebTarballNative, Component: m[4]}
+	switch {
+	case m[3] == ".debian":
+		t.Kind = DebTarballDebian
+	case m[3] != "":
+		t.Kind = DebTarballOrig
+	}
+	return t, true
+}
@@ -13,9 +13,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
+	"path"
 	"strconv"
 	"strings"
 
@@ -41,13 +39,32 @@ func IsDebFile(filePath string) (bool, error) {
 	return string(magicBytes) == "!<arch>", nil
 }
 
+// HasDebianBinary 判断 ar 归档的第一个成员是否为 debian-binary, 用于区分 deb 包与静态库等其他 ar 归档
+func HasDebianBinary(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	// 8 字节的全局头之后为 60 字节的成员头, 前 16 字节为成员名
+	hdr := make([]byte, 8+16)
+	if _, err := io.ReadFull(file, hdr); err != nil {
+		return false, err
+	}
+	name := strings.TrimRight(string(hdr[8:]), " ")
+	return string(hdr[:8]) == "!<arch>\n" && strings.TrimSuffix(name, "/") == "debian-binary", nil
+}
+
 type DebControl struct {
+	relationFields map[string]string //关系字段原文, 可能跨多行
+
 	Name          string
 	Version       string
+	Source        string //源码包, 版本与二进制包不同时带版本, 如 hello (2.10-3)
 	Architecture  string
 	Maintainer    string //upstream
-	Depends       []string
-	DependsOrig   string
+	Relations     map[string][]DebRelationGroup
 	Homepage      string
 	Section       string
 	Description   string
@@ -56,9 +73,11 @@ type DebControl struct {
 
 // Add to the field
 func (d *DebControl) addToField(name string, data string) {
-	switch name {
-	case "Description":
+	switch {
+	case name == "Description":
 		d.Description += " " + strings.TrimSpace(data)
+	case isDebRelationField(name):
+		d.relationFields[name] += " " + strings.TrimSpace(data)
 	}
 }
 
@@ -67,11 +86,21 @@ func (d *DebControl) setField(data ...string) error {
 	if len(data) != 2 {
 		return errors.New("data must have two elements only")
 	}
-	switch strings.TrimSpace(data[0]) {
+	name := strings.TrimSpace(data[0])
+	if isDebRelationField(name) {
+		if d.relationFields == nil {
+			d.relationFields = make(map[string]string)
+		}
+		d.relationFields[name] = strings.TrimSpace(data[1])
+		return nil
+	}
+	switch name {
 	case "Package":
 		d.Name = strings.TrimSpace(data[1])
 	case "Version":
 		d.Version = strings.TrimSpace(data[1])
+	case "Source":
+		d.Source = strings.TrimSpace(data[1])
 	case "Architecture":
 		d.Architecture = strings.TrimSpace(data[1])
 	case "Section":
@@ -82,9 +111,6 @@ func (d *DebControl) setField(data ...string) error {
 		d.Maintainer = strings.TrimSpace(data[1])
 	case "Description":
 		d.Description = strings.TrimSpace(data[1])
-	case "Depends":
-		d.DependsOrig = strings.TrimSpace(data[1])
-		d.Depends = parseDepends(strings.TrimSpace(data[1]))
 	case "Installed-Size":
 		i, err := strconv.Atoi(strings.TrimSpace(data[1]))
 		if err == nil {
@@ -97,27 +123,109 @@ func (d *DebControl) setField(data ...string) error {
 	return nil
 }
 
-// 解析Depends字段的函数
-func parseDepends(depends string) []string {
-	var vals []string
-	if strings.Contains(depends, ",") || strings.Contains(depends, "|") || strings.Contains(depends, "(") {
-		vals = regexp.MustCompile(`[\\,\\|]`).Split(depends, -1)
-	} else {
-		vals = strings.Split(depends, " ")
-	}
-	var result []string
-	for _, val := range vals {
-		val = strings.TrimSpace(val)
-		if val != "" {
-			result = append(result, strings.TrimSpace(val))
+// parseRelations 解析收集到的关系字段, 无法解析的字段记录警告后忽略
+func (d *DebControl) parseRelations() {
+	d.Relations = make(map[string][]DebRelationGroup)
+	for name, field := range d.relationFields {
+		groups, err := ParseDebRelations(field)
+		if err != nil {
+			log.Warning("parse", name, "of", d.Name, "failed:", err)
+			continue
+		}
+		d.Relations[name] = groups
+	}
+}
+
+// CleanTarPath 将 tar 条目名(如 ./usr/bin/foo)规范为以 / 开头的路径
+func CleanTarPath(name string) string {
+	return path.Clean("/" + strings.TrimPrefix(name, "./"))
+}
+
+// readArMember 读取 deb 包中第一个匹配的 ar 成员
+func readArMember(debPath string, match func(name string) bool, fn func(hdr *ArHeader, r io.Reader) error) error {
+	f, err := os.Open(debPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ar, err := NewArReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, err := ar.Next()
+		if err == io.EOF {
+			return errArMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if match(hdr.Name) {
+			return fn(hdr, ar)
 		}
 	}
-	return result
 }
 
+var errArMemberNotFound = errors.New("ar member not found")
+
+// WalkDebTar 遍历 deb 包中 control.tar.* 或 data.tar.* 的条目, 无需解压到磁盘
+func WalkDebTar(debPath, member string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	err := readArMember(debPath, func(name string) bool {
+		return name == member || strings.HasPrefix(name, member+".")
+	}, func(hdr *ArHeader, r io.Reader) error {
+		dr, err := NewDecompressReader(CompressorFromExt(hdr.Name), r)
+		if err != nil {
+			return err
+		}
+		defer dr.Close()
+
+		tr := tar.NewReader(dr)
+		for {
+			th, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(th, tr); err != nil {
+				return err
+			}
+		}
+	})
+	if err == errArMemberNotFound {
+		return errors.New(member + " don't exist in deb")
+	}
+	return err
+}
+
+// ReadDebControl 读取 deb 包 control.tar 中的 control 文件
+func ReadDebControl(debPath string) ([]byte, error) {
+	var control []byte
+	err := WalkDebTar(debPath, "control.tar", func(hdr *tar.Header, r io.Reader) error {
+		if control != nil || CleanTarPath(hdr.Name) != "/control" {
+			return nil
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		control = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if control == nil {
+		return nil, errors.New("control file don't exist in deb")
+	}
+	return control, nil
+}
+
+// ParseControlInfo 读取 deb 包元信息, 直接解析包内 control 文件, 不依赖主机上的 dpkg
 func ParseControlInfo(debPath string) (DebControl, error) {
-	//读取deb包元信息
-	output, err := exec.Command("dpkg", "-f", debPath).Output()
+	output, err := ReadDebControl(debPath)
 	if err != nil {
 		return DebControl{}, err
 	}
@@ -142,72 +250,74 @@ func ParseControlInfo(debPath string) (DebControl, error) {
 			debCon.setField(namedata...) // field
 		}
 	}
+	debCon.parseRelations()
 	return debCon, nil
 }
 
-// 提取 DEB 包的sbom信息
-func extractControlInfo(debFile string) ([]byte, error) {
-	var sbomTar = "sbom.tar.xz"
+// ExtractDebSBOM 提取 DEB 包中嵌入的sbom信息
+func ExtractDebSBOM(debFile string) ([]byte, error) {
+	var sbomTar = DebSBOMMember
 	var sbomContent bytes.Buffer
-	// 解压 deb文件
-	tmpDir, err := ioutil.TempDir("", "arDeb_")
-	if err != nil {
-		return nil, err
-	}
-	absPath, _ := filepath.Abs(debFile)
-	defer os.RemoveAll(tmpDir)
+	found := false
 
-	cmd := exec.Command("ar", "-x", absPath)
-	cmd.Dir = tmpDir
-	err = cmd.Run()
-	if err != nil {
-		return nil, errors.New("decompress deb file error")
-	}
+	err := readArMember(debFile, func(name string) bool {
+		return name == sbomTar
+	}, func(hdr *ArHeader, r io.Reader) error {
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			return err
+		}
+		// 解压 tar 归档
+		tarReader := tar.NewReader(xzReader)
 
-	sbomTarFile, err := os.Open(tmpDir + "/" + sbomTar)
-	if err != nil {
+		// 读取 sbom 文件内容
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			// fmt.Println(header.Name)
+			if CleanTarPath(header.Name) == "/"+DebSBOMFile {
+				// 读取 sbom 文件内容
+				found = true
+				_, err := io.Copy(&sbomContent, tarReader)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == errArMemberNotFound {
 		log.Debug(err)
 		return nil, errors.New(sbomTar + " don't exist in deb")
 	}
-	defer sbomTarFile.Close()
-
-	xzReader, err := xz.NewReader(sbomTarFile)
 	if err != nil {
 		return nil, err
 	}
-	// 解压 tar 归档
-	tarReader := tar.NewReader(xzReader)
-
-	// 读取 sbom 文件内容
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		// fmt.Println(header.Name)
-		if header.Name == "sbom.spdx.json" {
-			// 读取 sbom 文件内容
-			_, err := io.Copy(&sbomContent, tarReader)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if !found {
+		return nil, errors.New(DebSBOMFile + " don't exist in " + sbomTar)
 	}
 
 	return sbomContent.Bytes(), nil
 }
 
+// GetDebSignInfo 读取 DEB 包中嵌入的sbom签名, 没有签名时返回 ErrNoDebSign
 func GetDebSignInfo(deb string) ([]byte, error) {
-	f, err := os.Open(deb)
-	if err != nil {
-		return nil, err
+	var output []byte
+	err := readArMember(deb, func(name string) bool {
+		return name == DebSignMember
+	}, func(hdr *ArHeader, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		output = data
+		return err
+	})
+	if err == errArMemberNotFound {
+		return nil, ErrNoDebSign
 	}
-	defer f.Close()
-
-	output, err := exec.Command("ar", "-p", deb, "sign").Output()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"strconv"
+	"strings"
+)
+
+// splitDebVersion 将 [epoch:]upstream[-revision] 拆分为三部分, 没有 epoch 时为 0
+func splitDebVersion(v string) (int, string, string) {
+	epoch := 0
+	if idx := strings.Index(v, ":"); idx >= 0 {
+		epoch, _ = strconv.Atoi(v[:idx])
+		v = v[idx+1:]
+	}
+	revision := ""
+	if idx := strings.LastIndex(v, "-"); idx >= 0 {
+		v, revision = v[:idx], v[idx+1:]
+	}
+	return epoch, v, revision
+}
+
+// debCharOrder 非数字部分逐字符比较的权重: ~ 最小, 其次是字符串结尾(及数字), 然后字母, 最后其他字符
+func debCharOrder(s string, i int) int {
+	if i >= len(s) || isDigit(s[i]) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case c == '~':
+		return -1
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	}
+	return int(c) + 256
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareDebPart 按 dpkg 的规则比较 upstream 或 revision, 非数字与数字部分交替比较
+func compareDebPart(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ca, cb := debCharOrder(a, i), debCharOrder(b, j)
+			if ca != cb {
+				return ca - cb
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		diff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if diff == 0 {
+				diff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
+
+// CompareDebVersions 比较两个 deb 版本号, a 小于、等于、大于 b 时分别返回负数、0、正数
+func CompareDebVersions(a, b string) int {
+	ea, ua, ra := splitDebVersion(a)
+	eb, ub, rb := splitDebVersion(b)
+	// epoch 可能很大, 相减会溢出
+	if ea < eb {
+		return -1
+	} else if ea > eb {
+		return 1
+	}
+	if c := compareDebPart(ua, ub); c != 0 {
+		return c
+	}
+	return compareDebPart(ra, rb)
+}
+
+// DebVersionSatisfies 判断版本是否满足依赖中的版本约束, 如 >= 2.34; 没有约束时总是满足
+func DebVersionSatisfies(version, operator, constraint string) bool {
+	if operator == "" {
+		return true
+	}
+	c := CompareDebVersions(version, constraint)
+	switch operator {
+	case "<<":
+		return c < 0
+	case "<=", "<":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=", ">":
+		return c >= 0
+	case ">>":
+		return c > 0
+	}
+	return false
+}
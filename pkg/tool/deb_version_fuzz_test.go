@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import "testing"
+
+func FuzzCompareDebVersions(f *testing.F) {
+	f.Add("1:2.10-3", "2.10-3~bpo1")
+	f.Add("1.0~rc1+dfsg", "1.0.0-1ubuntu1")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if c := CompareDebVersions(a, a); c != 0 {
+			t.Fatalf("CompareDebVersions(%q, %q) = %d", a, a, c)
+		}
+		if ab, ba := sign(CompareDebVersions(a, b)), sign(CompareDebVersions(b, a)); ab != -ba {
+			t.Fatalf("CompareDebVersions(%q, %q) = %d, but reversed gives %d", a, b, ab, ba)
+		}
+	})
+}
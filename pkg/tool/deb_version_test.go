@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import "testing"
+
+// sign 将比较结果统一为 -1、0、1
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+	return 0
+}
+
+// 期望结果与 dpkg --compare-versions 一致
+func TestCompareDebVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0-1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+b1", -1},
+		{"1:0.9", "2.0", 1},
+		{"2.0-1", "2.0-1~bpo1", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0.0", "1.0", 1},
+		{"1.01", "1.1", 0},
+		{"1.2.3-1ubuntu1", "1.2.3-1", 1},
+		{"0:1.0", "1.0", 0},
+		{"1.0~~", "1.0~", -1},
+		{"1.0-1.1", "1.0-1a", 1},
+		{"10", "9", 1},
+		{"1.0+dfsg-2", "1.0-2", 1},
+		{"1.0-a", "1.0-1", 1},
+		{"9223372036854775807:1", "-9223372036854775808:1", 1},
+	}
+	for _, tt := range tests {
+		if got := sign(CompareDebVersions(tt.a, tt.b)); got != tt.want {
+			t.Errorf("CompareDebVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(CompareDebVersions(tt.b, tt.a)); got != -tt.want {
+			t.Errorf("CompareDebVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestDebVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version, operator, constraint string
+		want                          bool
+	}{
+		{"2.36-9", "", "", true},
+		{"2.36-9", ">=", "2.34", true},
+		{"2.36-9", ">>", "2.36-9", false},
+		{"2.36-9", "<<", "2.37", true},
+		{"2.36-9", "<=", "2.36-9", true},
+		{"2.36-9", "=", "2.36-9", true},
+		{"2.36-9", "=", "2.36", false},
+		{"2.36-9", "<", "2.36-9", true},
+		{"2.36-9", ">", "2.36-10", false},
+		{"2.36-9", "!=", "2.36-9", false},
+	}
+	for _, tt := range tests {
+		if got := DebVersionSatisfies(tt.version, tt.operator, tt.constraint); got != tt.want {
+			t.Errorf("DebVersionSatisfies(%q, %q, %q) = %v, want %v", tt.version, tt.operator, tt.constraint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Dep5Files DEP-5 中的 Files 段落
+type Dep5Files struct {
+	Patterns  []string
+	Copyright string
+	License   string //许可证表达式, 已转换为 SPDX 表达式的写法
+	patterns  []*regexp.Regexp
+}
+
+// Dep5Copyright 机器可读的 debian/copyright 文件,
+// 参考 https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+type Dep5Copyright struct {
+	UpstreamName string
+	Copyright    string            //头部段落中的版权信息
+	License      string            //头部段落中的许可证表达式
+	Files        []*Dep5Files      //按出现顺序排列, 后面的段落优先
+	Licenses     map[string]string //许可证简称对应的许可证全文
+}
+
+// IsDep5 判断 copyright 文件是否为 DEP-5 格式, 即第一个段落中有指向 copyright-format 的 Format 字段
+func IsDep5(text []byte) bool {
+	paragraphs := ParseDeb822(text)
+	if len(paragraphs) == 0 {
+		return false
+	}
+	format := paragraphs[0].Get("Format")
+	if format == "" {
+		format = paragraphs[0].Get("Format-Specification")
+	}
+	return strings.Contains(format, "copyright-format") || strings.Contains(format, "dep5")
+}
+
+// ParseDep5 解析 DEP-5 格式的 copyright 文件
+func ParseDep5(text []byte) *Dep5Copyright {
+	c := &Dep5Copyright{Licenses: make(map[string]string)}
+	for i, p := range ParseDeb822(text) {
+		name, licenseText := splitDep5License(p.Get("License"))
+		if licenseText != "" {
+			c.Licenses[name] = licenseText
+		}
+		switch {
+		case i == 0 && !p.Has("Files"):
+			c.UpstreamName = p.Get("Upstream-Name")
+			c.Copyright = p.Get("Copyright")
+			c.License = Dep5LicenseExpression(name)
+		case p.Has("Files"):
+			files := &Dep5Files{
+				Patterns:  strings.Fields(p.Get("Files")),
+				Copyright: p.Get("Copyright"),
+				License:   Dep5LicenseExpression(name),
+			}
+			for _, pattern := range files.Patterns {
+				files.patterns = append(files.patterns, dep5PatternRegexp(pattern))
+			}
+			c.Files = append(c.Files, files)
+		}
+	}
+	return c
+}
+
+// splitDep5License License 字段的第一行为许可证简称, 其后为许可证全文
+func splitDep5License(value string) (string, string) {
+	idx := strings.Index(value, "\n")
+	if idx < 0 {
+		return strings.TrimSpace(value), ""
+	}
+	return strings.TrimSpace(value[:idx]), strings.TrimSpace(value[idx+1:])
+}
+
+// dep5PatternRegexp 将 Files 中的通配符转换为正则, * 可以匹配 /, ? 匹配单个字符, \ 用于转义
+func dep5PatternRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// match 判断源码路径是否匹配该段落
+func (f *Dep5Files) match(path string) bool {
+	for _, re := range f.patterns {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 返回安装路径对应的 Files 段落, 没有匹配时返回 nil。
+// Files 中是源码树中的路径, 安装路径无法直接对应, 因此依次尝试安装路径的各级后缀,
+// 如 /usr/share/foo/data/a.png 依次尝试 usr/share/foo/data/a.png、share/foo/data/a.png、...、a.png;
+// 与 DEP-5 规范一致, 多个段落匹配时以最后一个为准
+func (c *Dep5Copyright) Match(installPath string) *Dep5Files {
+	var candidates []string
+	rel := strings.TrimPrefix(installPath, "/")
+	for rel != "" {
+		candidates = append(candidates, rel)
+		idx := strings.Index(rel, "/")
+		if idx < 0 {
+			break
+		}
+		rel = rel[idx+1:]
+	}
+	for i := len(c.Files) - 1; i >= 0; i-- {
+		for _, p := range candidates {
+			if c.Files[i].match(p) {
+				return c.Files[i]
+			}
+		}
+	}
+	return nil
+}
+
+// MatchSource 返回源码树中的路径对应的 Files 段落, 如 ./src/main.c, 没有匹配时返回 nil
+func (c *Dep5Copyright) MatchSource(sourcePath string) *Dep5Files {
+	rel := strings.TrimPrefix(strings.TrimPrefix(sourcePath, "./"), "/")
+	for i := len(c.Files) - 1; i >= 0; i-- {
+		if c.Files[i].match(rel) {
+			return c.Files[i]
+		}
+	}
+	return nil
+}
+
+// Dep5LicenseExpression 将 DEP-5 许可证简称的组合转换为 SPDX 表达式的写法:
+// or/and 转换为 OR/AND, 逗号用于提高优先级, 如 "GPL-2+ or Artistic, and BSD"
+// 转换为 "(GPL-2+ OR Artistic) AND BSD"; 简称本身不做转换
+func Dep5LicenseExpression(s string) string {
+	var expr string
+	for i, segment := range strings.Split(s, ",") {
+		words := strings.Fields(segment)
+		if len(words) == 0 {
+			continue
+		}
+		op := ""
+		if i > 0 && (strings.EqualFold(words[0], "and") || strings.EqualFold(words[0], "or")) {
+			op = strings.ToUpper(words[0])
+			words = words[1:]
+		}
+		var tokens []string
+		for j := 0; j < len(words); j++ {
+			switch w := strings.ToLower(words[j]); {
+			case w == "and" || w == "or":
+				tokens = append(tokens, strings.ToUpper(w))
+			case w == "with":
+				// with OpenSSL exception 转换为 WITH OpenSSL-exception
+				tokens = append(tokens, "WITH")
+				if j+2 < len(words) && strings.EqualFold(words[j+2], "exception") {
+					tokens = append(tokens, words[j+1]+"-exception")
+					j += 2
+				}
+			default:
+				tokens = append(tokens, words[j])
+			}
+		}
+		part := strings.Join(tokens, " ")
+		if expr == "" {
+			expr = part
+			continue
+		}
+		if op == "" {
+			op = "AND"
+		}
+		if strings.Contains(expr, " ") {
+			expr = "(" + expr + ")"
+		}
+		if strings.Contains(part, " ") {
+			part = "(" + part + ")"
+		}
+		expr = expr + " " + op + " " + part
+	}
+	return expr
+}
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DpkgInfoDir 返回 root 下 dpkg 数据库中各软件包信息文件所在的目录
+func DpkgInfoDir(root string) string {
+	return filepath.Join(root, "var/lib/dpkg/info")
+}
+
+// DpkgStatusFile 返回 root 下 dpkg 记录软件包状态的文件
+func DpkgStatusFile(root string) string {
+	return filepath.Join(root, "var/lib/dpkg/status")
+}
+
+// DebControlFromParagraph 由 deb822 段落(如 dpkg status 中的一个软件包)生成 DebControl
+func DebControlFromParagraph(p Deb822Paragraph) DebControl {
+	var debCon DebControl
+	for _, name := range p.Names() {
+		// 续行与 control 文件的解析结果一样以空格连接
+		debCon.setField(name, strings.Replace(p.Get(name), "\n", " ", -1))
+	}
+	debCon.parseRelations()
+	return debCon
+}
+
+// ReadDpkgStatus 读取 dpkg status 文件中已安装(包括已解包未配置)的软件包, 不依赖 dpkg 命令
+func ReadDpkgStatus(root string) ([]DebControl, error) {
+	data, err := ioutil.ReadFile(DpkgStatusFile(root))
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []DebControl
+	for _, p := range ParseDeb822(data) {
+		// Status 字段为 "期望状态 错误标志 状态", 如 "install ok installed"
+		status := strings.Fields(p.Get("Status"))
+		if len(status) != 3 || status[2] == "not-installed" || status[2] == "config-files" {
+			continue
+		}
+		pkgs = append(pkgs, DebControlFromParagraph(p))
+	}
+	return pkgs, nil
+}
+
+// DpkgPackageFiles 读取一个已安装软件包的文件列表及 md5sums 中的摘要;
+// Multi-Arch: same 的软件包信息文件名带有 :arch 后缀, 此时优先使用
+func DpkgPackageFiles(root, name, arch string) ([]string, map[string]string, error) {
+	info := filepath.Join(DpkgInfoDir(root), name+":"+arch)
+	if _, err := os.Stat(info + ".list"); err != nil {
+		info = filepath.Join(DpkgInfoDir(root), name)
+	}
+	var files []string
+	err := readDpkgInfoFile(info+".list", func(line string) {
+		files = append(files, path.Clean(line))
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	md5sums := make(map[string]string)
+	err = readDpkgInfoFile(info+".md5sums", func(line string) {
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			md5sums[path.Clean("/"+strings.TrimSpace(fields[1]))] = fields[0]
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	return files, md5sums, nil
+}
+
+// DpkgFile dpkg 数据库中记录的已安装文件
+type DpkgFile struct {
+	Package string //所属软件包, 多架构软件包带有 :arch 后缀
+	MD5     string //md5sums 中的摘要, 配置文件等没有摘要时为空
+}
+
+// dpkgInfoPackage 从 info 目录中的文件名得到软件包名, 如 libc6:amd64.list
+func dpkgInfoPackage(name, ext string) string {
+	return strings.TrimSuffix(filepath.Base(name), ext)
+}
+
+// readDpkgInfoFiles 逐行读取 info 目录中所有指定后缀的文件
+func readDpkgInfoFiles(root, ext string, fn func(pkg, line string)) error {
+	files, err := filepath.Glob(filepath.Join(DpkgInfoDir(root), "*"+ext))
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		pkg := dpkgInfoPackage(name, ext)
+		err := readDpkgInfoFile(name, func(line string) {
+			fn(pkg, line)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDpkgInfoFile 逐行读取 info 目录中的一个文件, 忽略空行
+func readDpkgInfoFile(name string, fn func(line string)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scn := bufio.NewScanner(f)
+	for scn.Scan() {
+		if line := strings.TrimSpace(scn.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scn.Err()
+}
+
+// ReadDpkgFiles 读取 dpkg 数据库中所有软件包的文件列表(*.list)及摘要(*.md5sums),
+// 返回以 / 开头的路径到文件信息的映射; 目录也在 *.list 中, 调用方需自行区分
+func ReadDpkgFiles(root string) (map[string]*DpkgFile, error) {
+	files := make(map[string]*DpkgFile)
+	err := readDpkgInfoFiles(root, ".list", func(pkg, line string) {
+		files[path.Clean(line)] = &DpkgFile{Package: pkg}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// md5sums 每行为 "摘要  相对路径"
+	err = readDpkgInfoFiles(root, ".md5sums", func(pkg, line string) {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return
+		}
+		name := path.Clean("/" + strings.TrimSpace(fields[1]))
+		if f, ok := files[name]; ok {
+			f.MD5 = fields[0]
+			return
+		}
+		files[name] = &DpkgFile{Package: pkg, MD5: fields[0]}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
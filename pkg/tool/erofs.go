@@ -0,0 +1,972 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// erofs 格式, 参考 linux 内核 fs/erofs/erofs_fs.h
+const (
+	erofsSuperOffset = 1024
+	erofsMagic       = 0xE0F5E1E2
+	erofsInodeSlot   = 32 //nid 以 32 字节为单位
+	erofsDirentSize  = 12
+	erofsReadAhead   = 1 << 20 //非压缩文件每次读取的最大字节数
+)
+
+// 超级块中的不兼容特性
+const (
+	erofsFeatureZeroPadding = 0x01
+	erofsFeatureDeviceTable = 0x08
+	erofsFeatureFragments   = 0x20
+)
+
+// inode 的数据布局
+const (
+	erofsFlatPlain         = 0
+	erofsCompressedFull    = 1
+	erofsFlatInline        = 2
+	erofsCompressedCompact = 3
+	erofsChunkBased        = 4
+)
+
+// 分块文件的格式
+const (
+	erofsChunkBlkBitsMask = 0x1f
+	erofsChunkIndexes     = 0x20
+	erofsNullAddr         = 0xffffffff
+)
+
+// 压缩文件映射头中的标志
+const (
+	zErofsAdviseCompacted2B    = 0x01
+	zErofsAdviseBigPcluster1   = 0x02
+	zErofsAdviseBigPcluster2   = 0x04
+	zErofsAdviseInlinePcluster = 0x08
+	zErofsAdviseInterlaced     = 0x10
+	zErofsAdviseFragment       = 0x20
+	zErofsFragmentInodeBit     = 7
+)
+
+// 逻辑簇类型
+const (
+	zErofsTypePlain = iota
+	zErofsTypeHead1
+	zErofsTypeNonHead
+	zErofsTypeHead2
+)
+
+// 非头部逻辑簇的 delta[0] 中带有该标志时记录的是物理簇的块数
+const zErofsD0CBlkCnt = 1 << 11
+
+// 压缩算法
+const (
+	zErofsLZ4 = iota
+	zErofsLZMA
+	zErofsDeflate
+	zErofsZstd
+)
+
+var errEROFS = errors.New("corrupted erofs image")
+
+// EROFS 纯 Go 实现的 erofs 只读解析, 无需挂载或 root 权限; 支持非压缩、分块及 lz4、lzma、deflate、zstd
+// 压缩的文件, 包括大物理簇、尾部内联(ztailpacking)及分片(fragments), 不支持多设备镜像
+type EROFS struct {
+	r           io.ReaderAt
+	offset      int64 //镜像在文件中的偏移, 如 layer 文件中 erofs 位于元数据之后
+	blkBits     uint
+	metaBlkAddr int64
+	rootNid     uint64
+	packedNid   uint64
+	zeroPadding bool
+	fragments   bool
+	zstd        *zstd.Decoder
+
+	packed    *erofsInode //保存分片的 packed inode
+	fragStart int64       //最近读取的 packed inode 区段
+	fragData  []byte
+}
+
+// erofsInode 解析 inode 时需要的字段
+type erofsInode struct {
+	pos    int64 //inode 在镜像中的位置
+	mode   uint32
+	size   int64
+	layout int
+	iu     uint32 //非压缩文件的起始块, 分块文件的分块格式
+	isize  int64  //inode 及内联 xattr 的大小
+	z      *erofsZInfo
+}
+
+// erofsZInfo 压缩文件的映射头及索引
+type erofsZInfo struct {
+	advise        uint16
+	algs          [2]int
+	lclusterBits  uint
+	compact       bool
+	indexPos      int64 //索引在镜像中的位置
+	index         []byte
+	totalIdx      int64
+	initial4B     int64 //紧凑索引开头用于对齐的 4 字节索引数
+	compacted2B   int64
+	idataSize     int64 //内联在索引之后的尾部物理簇
+	idataOff      int64
+	tailHeadLcn   int64 //最后一个区段的头部逻辑簇
+	fragmentOff   int64
+	wholeFragment bool
+}
+
+// erofsLcluster 一个逻辑簇的索引
+type erofsLcluster struct {
+	typ         int
+	clusterOfs  int64
+	delta0      int64
+	pblk        int64
+	cblks       int64 //非头部逻辑簇中记录的物理簇块数
+	nextPackOff int64
+}
+
+// EROFSEntry erofs 中的一个条目
+type EROFSEntry struct {
+	Path string //以 / 开头的路径
+	Mode os.FileMode
+	Size int64  //普通文件的大小
+	Link string //符号链接的目标
+
+	fs    *EROFS
+	inode *erofsInode
+}
+
+// Open 返回普通文件内容的读取流, 其他类型的条目返回空内容
+func (e *EROFSEntry) Open() io.Reader {
+	if !e.Mode.IsRegular() {
+		return bytes.NewReader(nil)
+	}
+	return &erofsFileReader{fs: e.fs, inode: e.inode}
+}
+
+// IsEROFS 判断文件在 offset 处是否为 erofs 镜像
+func IsEROFS(r io.ReaderAt, offset int64) bool {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, offset+erofsSuperOffset); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(magic) == erofsMagic
+}
+
+// OpenEROFS 读取 offset 处的 erofs 超级块
+func OpenEROFS(r io.ReaderAt, offset int64) (*EROFS, error) {
+	sb := make([]byte, 128)
+	if _, err := r.ReadAt(sb, offset+erofsSuperOffset); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(sb) != erofsMagic {
+		return nil, errors.New("not an erofs image")
+	}
+	blkBits := uint(sb[12])
+	if blkBits < 9 || blkBits > 16 {
+		return nil, fmt.Errorf("invalid erofs block size bits %d", blkBits)
+	}
+	incompat := binary.LittleEndian.Uint32(sb[80:])
+	if incompat&erofsFeatureDeviceTable != 0 && binary.LittleEndian.Uint16(sb[86:]) > 0 {
+		return nil, errors.New("multi-device erofs images are not supported")
+	}
+	return &EROFS{
+		r:           r,
+		offset:      offset,
+		blkBits:     blkBits,
+		metaBlkAddr: int64(binary.LittleEndian.Uint32(sb[40:])),
+		rootNid:     uint64(binary.LittleEndian.Uint16(sb[14:])),
+		packedNid:   binary.LittleEndian.Uint64(sb[96:]),
+		zeroPadding: incompat&erofsFeatureZeroPadding != 0,
+		fragments:   incompat&erofsFeatureFragments != 0,
+	}, nil
+}
+
+// Close 释放解压器
+func (fs *EROFS) Close() {
+	if fs.zstd != nil {
+		fs.zstd.Close()
+	}
+}
+
+func (fs *EROFS) blockSize() int64 {
+	return 1 << fs.blkBits
+}
+
+// readAt 读取镜像中 pos 处的 n 个字节, pos 相对于镜像起始
+func (fs *EROFS) readAt(pos int64, n int64) ([]byte, error) {
+	if pos < 0 || n < 0 || n > 1<<30 {
+		return nil, errEROFS
+	}
+	buf := make([]byte, n)
+	if _, err := fs.r.ReadAt(buf, fs.offset+pos); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readInode 读取 nid 对应的 inode, 紧凑 inode 为 32 字节, 扩展 inode 为 64 字节
+func (fs *EROFS) readInode(nid uint64) (*erofsInode, error) {
+	pos := fs.metaBlkAddr<<fs.blkBits + int64(nid)*erofsInodeSlot
+	buf, err := fs.readAt(pos, 32)
+	if err != nil {
+		return nil, err
+	}
+	format := binary.LittleEndian.Uint16(buf)
+	ino := &erofsInode{
+		pos:    pos,
+		mode:   uint32(binary.LittleEndian.Uint16(buf[4:])),
+		size:   int64(binary.LittleEndian.Uint32(buf[8:])),
+		layout: int(format>>1) & 7,
+		iu:     binary.LittleEndian.Uint32(buf[16:]),
+		isize:  32,
+	}
+	if format&1 != 0 {
+		ext, err := fs.readAt(pos, 64)
+		if err != nil {
+			return nil, err
+		}
+		ino.size = int64(binary.LittleEndian.Uint64(ext[8:]))
+		ino.isize = 64
+	}
+	if n := int64(binary.LittleEndian.Uint16(buf[2:])); n > 0 {
+		ino.isize += 12 + 4*(n-1)
+	}
+	if ino.size < 0 {
+		return nil, errEROFS
+	}
+	if ino.layout > erofsChunkBased {
+		return nil, fmt.Errorf("unsupported erofs data layout %d", ino.layout)
+	}
+	return ino, nil
+}
+
+func alignUp(v, n int64) int64 {
+	return (v + n - 1) / n * n
+}
+
+// extent 返回文件中包含 pos 的一段内容及其起始位置
+func (fs *EROFS) extent(ino *erofsInode, pos int64) ([]byte, int64, error) {
+	end := pos + erofsReadAhead
+	if end > ino.size {
+		end = ino.size
+	}
+	switch ino.layout {
+	case erofsFlatPlain:
+		data, err := fs.readAt(int64(ino.iu)<<fs.blkBits+pos, end-pos)
+		return data, pos, err
+	case erofsFlatInline:
+		// 最后一个块内联在 inode 之后
+		tail := (ino.size - 1) >> fs.blkBits << fs.blkBits
+		if pos >= tail {
+			data, err := fs.readAt(ino.pos+ino.isize+pos-tail, ino.size-pos)
+			return data, pos, err
+		}
+		if end > tail {
+			end = tail
+		}
+		data, err := fs.readAt(int64(ino.iu)<<fs.blkBits+pos, end-pos)
+		return data, pos, err
+	case erofsChunkBased:
+		return fs.chunkExtent(ino, pos, end)
+	}
+	return fs.zExtent(ino, pos)
+}
+
+// chunkExtent 读取分块文件中 pos 所在的块, 块索引紧随 inode 之后, 为 4 字节的块地址或 8 字节的块索引
+func (fs *EROFS) chunkExtent(ino *erofsInode, pos, end int64) ([]byte, int64, error) {
+	format := ino.iu & 0xffff
+	chunkBits := fs.blkBits + uint(format&erofsChunkBlkBitsMask)
+	unit := int64(4)
+	if format&erofsChunkIndexes != 0 {
+		unit = 8
+	}
+	nr := pos >> chunkBits
+	if chunkEnd := (nr + 1) << chunkBits; end > chunkEnd {
+		end = chunkEnd
+	}
+	idx, err := fs.readAt(alignUp(ino.pos+ino.isize, unit)+nr*unit, unit)
+	if err != nil {
+		return nil, 0, err
+	}
+	blk := binary.LittleEndian.Uint32(idx)
+	if unit == 8 {
+		blk = binary.LittleEndian.Uint32(idx[4:])
+	}
+	if blk == erofsNullAddr {
+		// 空洞
+		return make([]byte, end-pos), pos, nil
+	}
+	data, err := fs.readAt(int64(blk)<<fs.blkBits+(pos-nr<<chunkBits), end-pos)
+	return data, pos, err
+}
+
+// zInfo 读取压缩文件的映射头及全部索引, 有尾部内联或分片时找出最后一个区段
+func (fs *EROFS) zInfo(ino *erofsInode) (*erofsZInfo, error) {
+	if ino.z != nil {
+		return ino.z, nil
+	}
+	hpos := alignUp(ino.pos+ino.isize, 8)
+	h, err := fs.readAt(hpos, 8)
+	if err != nil {
+		return nil, err
+	}
+	z := &erofsZInfo{tailHeadLcn: -1}
+	if h[7]>>zErofsFragmentInodeBit != 0 {
+		// 整个文件位于分片中
+		z.advise = zErofsAdviseFragment
+		z.wholeFragment = true
+		z.tailHeadLcn = 0
+		z.fragmentOff = int64(binary.LittleEndian.Uint64(h) ^ 1<<63)
+		ino.z = z
+		return z, nil
+	}
+	z.advise = binary.LittleEndian.Uint16(h[4:])
+	z.algs = [2]int{int(h[6] & 15), int(h[6] >> 4)}
+	z.lclusterBits = fs.blkBits + uint(h[7]&7)
+	z.compact = ino.layout == erofsCompressedCompact
+	z.totalIdx = (ino.size + 1<<z.lclusterBits - 1) >> z.lclusterBits
+
+	var end int64
+	if z.compact {
+		z.totalIdx = (ino.size + fs.blockSize() - 1) >> fs.blkBits
+		z.indexPos = hpos + 8
+		z.initial4B = (32 - z.indexPos%32) / 4
+		if z.initial4B == 8 {
+			z.initial4B = 0
+		}
+		if z.advise&zErofsAdviseCompacted2B != 0 && z.initial4B < z.totalIdx {
+			z.compacted2B = (z.totalIdx - z.initial4B) / 16 * 16
+		}
+		if z.totalIdx > 0 {
+			off, shift := z.compactOffset(z.totalIdx - 1)
+			pack := int64(2) << shift
+			if shift == 1 {
+				pack = 16 << shift
+			}
+			abs := z.indexPos + off
+			end = abs - abs%pack + pack
+		}
+	} else {
+		z.indexPos = hpos + 16
+		end = z.indexPos + z.totalIdx*8
+	}
+	if z.totalIdx == 0 {
+		end = z.indexPos
+	}
+	if z.index, err = fs.readAt(z.indexPos, end-z.indexPos); err != nil {
+		return nil, err
+	}
+
+	if z.advise&(zErofsAdviseInlinePcluster|zErofsAdviseFragment) != 0 && ino.size > 0 {
+		m, err := z.load((ino.size - 1) >> z.lclusterBits)
+		if err != nil {
+			return nil, err
+		}
+		if z.advise&zErofsAdviseInlinePcluster != 0 {
+			z.idataSize = int64(binary.LittleEndian.Uint16(h[2:]))
+			z.idataOff = m.nextPackOff
+		}
+		head, headLcn, _, err := z.head(ino.size - 1)
+		if err != nil {
+			return nil, err
+		}
+		z.tailHeadLcn = headLcn
+		if z.advise&zErofsAdviseFragment != 0 {
+			// 非紧凑索引的分片偏移为 64 位, 高 32 位记录在最后一个区段的头部逻辑簇中
+			z.fragmentOff = int64(binary.LittleEndian.Uint32(h))
+			if !z.compact {
+				z.fragmentOff |= head.pblk << 32
+			}
+		}
+	}
+	ino.z = z
+	return z, nil
+}
+
+// compactOffset 紧凑索引中逻辑簇相对索引起始的位置及索引大小的对数,
+// 依次为对齐用的 4 字节索引、2 字节索引及其余的 4 字节索引
+func (z *erofsZInfo) compactOffset(lcn int64) (int64, uint) {
+	if lcn < z.initial4B {
+		return lcn * 4, 2
+	}
+	pos := z.initial4B * 4
+	lcn -= z.initial4B
+	if lcn < z.compacted2B {
+		return pos + lcn*2, 1
+	}
+	return pos + z.compacted2B*2 + (lcn-z.compacted2B)*4, 2
+}
+
+// load 读取一个逻辑簇的索引
+func (z *erofsZInfo) load(lcn int64) (erofsLcluster, error) {
+	if lcn < 0 || lcn >= z.totalIdx {
+		return erofsLcluster{}, errEROFS
+	}
+	if z.compact {
+		return z.loadCompact(lcn)
+	}
+	pos := lcn * 8
+	di := z.index[pos : pos+8]
+	m := erofsLcluster{typ: int(binary.LittleEndian.Uint16(di) & 3), nextPackOff: z.indexPos + pos + 8}
+	if m.typ == zErofsTypeNonHead {
+		m.clusterOfs = 1 << z.lclusterBits
+		m.delta0 = int64(binary.LittleEndian.Uint16(di[4:]))
+		if m.delta0&zErofsD0CBlkCnt != 0 {
+			if z.advise&(zErofsAdviseBigPcluster1|zErofsAdviseBigPcluster2) == 0 {
+				return m, errEROFS
+			}
+			m.cblks = m.delta0 &^ zErofsD0CBlkCnt
+			m.delta0 = 1
+		}
+		return m, nil
+	}
+	m.clusterOfs = int64(binary.LittleEndian.Uint16(di[2:]))
+	if m.clusterOfs >= 1<<z.lclusterBits {
+		return m, errEROFS
+	}
+	m.pblk = int64(binary.LittleEndian.Uint32(di[4:]))
+	return m, nil
+}
+
+// decodeCompacted 解码紧凑索引包中 pos 位处的一项, 低位为簇内偏移或 delta, 其后两位为类型
+func decodeCompacted(in []byte, lobits, pos uint) (int64, int) {
+	v := binary.LittleEndian.Uint32(in[pos/8:]) >> (pos & 7)
+	return int64(v & (1<<lobits - 1)), int(v>>lobits) & 3
+}
+
+// loadCompact 读取紧凑索引, 每个索引包末尾 4 字节为包内第一个物理簇的块地址,
+// 头部逻辑簇的块地址由包内之前的物理簇推算
+func (z *erofsZInfo) loadCompact(lcn int64) (erofsLcluster, error) {
+	off, shift := z.compactOffset(lcn)
+	var vcnt int64
+	switch {
+	case shift == 2 && z.lclusterBits <= 14:
+		vcnt = 2
+	case shift == 1 && z.lclusterBits <= 12:
+		vcnt = 16
+	default:
+		return erofsLcluster{}, errors.New("unsupported erofs compacted index")
+	}
+	packSize := vcnt << shift
+	abs := z.indexPos + off
+	start := abs - abs%packSize - z.indexPos
+	if start < 0 || start+packSize > int64(len(z.index)) {
+		return erofsLcluster{}, errEROFS
+	}
+	pack := z.index[start : start+packSize]
+	m := erofsLcluster{nextPackOff: z.indexPos + start + packSize}
+	lobits := z.lclusterBits
+	if lobits < 12 {
+		lobits = 12
+	}
+	encodebits := uint((packSize - 4) * 8 / vcnt)
+	i := int((abs - z.indexPos - start) >> shift)
+	bigPcluster := z.advise&zErofsAdviseBigPcluster1 != 0
+
+	lo, typ := decodeCompacted(pack, lobits, encodebits*uint(i))
+	m.typ = typ
+	if typ == zErofsTypeNonHead {
+		m.clusterOfs = 1 << z.lclusterBits
+		if lo&zErofsD0CBlkCnt != 0 {
+			if !bigPcluster {
+				return m, errEROFS
+			}
+			m.cblks = lo &^ zErofsD0CBlkCnt
+			m.delta0 = 1
+			return m, nil
+		}
+		if int64(i+1) != vcnt {
+			m.delta0 = lo
+			return m, nil
+		}
+		// 包内最后一项记录的是 delta[1], delta[0] 由前一项推算
+		lo, typ = decodeCompacted(pack, lobits, encodebits*uint(i-1))
+		if typ != zErofsTypeNonHead {
+			lo = 0
+		} else if lo&zErofsD0CBlkCnt != 0 {
+			lo = 1
+		}
+		m.delta0 = lo + 1
+		return m, nil
+	}
+
+	m.clusterOfs = lo
+	var nblk int64
+	if !bigPcluster {
+		nblk = 1
+		for i > 0 {
+			i--
+			lo, typ = decodeCompacted(pack, lobits, encodebits*uint(i))
+			if typ == zErofsTypeNonHead {
+				i -= int(lo)
+			}
+			if i >= 0 {
+				nblk++
+			}
+		}
+	} else {
+		for i > 0 {
+			i--
+			lo, typ = decodeCompacted(pack, lobits, encodebits*uint(i))
+			if typ == zErofsTypeNonHead {
+				if lo&zErofsD0CBlkCnt != 0 {
+					i--
+					nblk += lo &^ zErofsD0CBlkCnt
+					continue
+				}
+				if lo <= 1 {
+					return m, errEROFS
+				}
+				i -= int(lo) - 2
+				continue
+			}
+			nblk++
+		}
+	}
+	m.pblk = int64(binary.LittleEndian.Uint32(pack[packSize-4:])) + nblk
+	return m, nil
+}
+
+// head 查找 ofs 所在区段的头部逻辑簇, 同时返回途中读到的物理簇块数
+func (z *erofsZInfo) head(ofs int64) (erofsLcluster, int64, int64, error) {
+	lcn := ofs >> z.lclusterBits
+	m, err := z.load(lcn)
+	if err != nil {
+		return m, 0, 0, err
+	}
+	cblks := m.cblks
+	distance := m.delta0
+	if m.typ != zErofsTypeNonHead {
+		if ofs&(1<<z.lclusterBits-1) >= m.clusterOfs {
+			return m, lcn, cblks, nil
+		}
+		// ofs 位于该逻辑簇中新区段开始之前
+		distance = 1
+	}
+	for {
+		if distance == 0 || lcn < distance {
+			return m, 0, 0, errEROFS
+		}
+		lcn -= distance
+		if m, err = z.load(lcn); err != nil {
+			return m, 0, 0, err
+		}
+		if m.cblks != 0 {
+			cblks = m.cblks
+		}
+		if m.typ != zErofsTypeNonHead {
+			return m, lcn, cblks, nil
+		}
+		distance = m.delta0
+	}
+}
+
+// zExtent 解压压缩文件中 pos 所在的区段, 区段结束于下一个头部逻辑簇或文件末尾
+func (fs *EROFS) zExtent(ino *erofsInode, pos int64) ([]byte, int64, error) {
+	z, err := fs.zInfo(ino)
+	if err != nil {
+		return nil, 0, err
+	}
+	fragment := z.advise&zErofsAdviseFragment != 0
+	if fragment && z.tailHeadLcn == 0 {
+		data, err := fs.readFragment(ino, z.fragmentOff, ino.size)
+		return data, 0, err
+	}
+	head, headLcn, cblks, err := z.head(pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	la := headLcn<<z.lclusterBits | head.clusterOfs
+	end := ino.size
+	for lcn := headLcn + 1; lcn<<z.lclusterBits < ino.size; lcn++ {
+		m, err := z.load(lcn)
+		if err != nil {
+			return nil, 0, err
+		}
+		if m.typ != zErofsTypeNonHead {
+			if e := lcn<<z.lclusterBits + m.clusterOfs; e < end {
+				end = e
+			}
+			break
+		}
+	}
+	llen := end - la
+	if llen <= 0 || la > pos {
+		return nil, 0, errEROFS
+	}
+
+	var src []byte
+	switch {
+	case z.advise&zErofsAdviseInlinePcluster != 0 && headLcn == z.tailHeadLcn:
+		src, err = fs.readAt(z.idataOff, z.idataSize)
+	case fragment && headLcn == z.tailHeadLcn:
+		data, err := fs.readFragment(ino, z.fragmentOff, llen)
+		return data, la, err
+	default:
+		plen := int64(1) << z.lclusterBits
+		big := z.advise&zErofsAdviseBigPcluster2 != 0
+		if head.typ == zErofsTypeHead1 {
+			big = z.advise&zErofsAdviseBigPcluster1 != 0
+		}
+		if big {
+			if cblks == 0 {
+				// 头部之后的逻辑簇仍为头部时物理簇只有一个逻辑簇大小
+				cblks = 1 << (z.lclusterBits - fs.blkBits)
+				if headLcn+1 < z.totalIdx {
+					m, err := z.load(headLcn + 1)
+					if err != nil {
+						return nil, 0, err
+					}
+					if m.typ == zErofsTypeNonHead {
+						if m.cblks == 0 {
+							return nil, 0, errEROFS
+						}
+						cblks = m.cblks
+					}
+				}
+			}
+			plen = cblks << fs.blkBits
+		}
+		src, err = fs.readAt(head.pblk<<fs.blkBits, plen)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := fs.decompress(z, head.typ, src, la, llen)
+	return data, la, err
+}
+
+// decompress 解压一个物理簇, 得到区段的 llen 个字节; 未压缩的物理簇在 interlaced 时按块内偏移存放
+func (fs *EROFS) decompress(z *erofsZInfo, typ int, src []byte, la, llen int64) ([]byte, error) {
+	if typ == zErofsTypePlain {
+		if llen > int64(len(src)) {
+			return nil, errEROFS
+		}
+		if z.advise&zErofsAdviseInterlaced == 0 {
+			return src[:llen], nil
+		}
+		cur := fs.blockSize() - la%fs.blockSize()
+		rel := int64(len(src)) - cur
+		n := cur
+		if n > llen {
+			n = llen
+		}
+		if rel < 0 || rel+n > int64(len(src)) {
+			return nil, errEROFS
+		}
+		out := make([]byte, 0, llen)
+		out = append(out, src[rel:rel+n]...)
+		return append(out, src[:llen-n]...), nil
+	}
+
+	alg := z.algs[0]
+	if typ == zErofsTypeHead2 {
+		alg = z.algs[1]
+	}
+	// 压缩数据位于物理簇末尾, 开头以 0 填充
+	if fs.zeroPadding || alg != zErofsLZ4 {
+		limit := len(src)
+		if int64(limit) > fs.blockSize() {
+			limit = int(fs.blockSize())
+		}
+		i := 0
+		for i < limit && src[i] == 0 {
+			i++
+		}
+		if i == limit {
+			return nil, errEROFS
+		}
+		src = src[i:]
+	}
+
+	var r io.Reader
+	switch alg {
+	case zErofsLZ4:
+		return lz4DecodeBlock(src, int(llen))
+	case zErofsLZMA:
+		// MicroLZMA: 第一个字节为属性取反, 替换了区间编码器输出的第一个字节 0
+		dictCap := llen
+		if dictCap < lzma.MinDictCap {
+			dictCap = lzma.MinDictCap
+		}
+		stream := make([]byte, 13, 14+len(src))
+		stream[0] = ^src[0]
+		binary.LittleEndian.PutUint32(stream[1:], uint32(dictCap))
+		binary.LittleEndian.PutUint64(stream[5:], uint64(llen))
+		stream = append(append(stream, 0), src[1:]...)
+		lr, err := lzma.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			return nil, err
+		}
+		r = lr
+	case zErofsDeflate:
+		r = flate.NewReader(bytes.NewReader(src))
+	case zErofsZstd:
+		if fs.zstd == nil {
+			d, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			fs.zstd = d
+		}
+		out, err := fs.zstd.DecodeAll(src, nil)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(out)) < llen {
+			return nil, errEROFS
+		}
+		return out[:llen], nil
+	default:
+		return nil, fmt.Errorf("unsupported erofs compression algorithm %d", alg)
+	}
+	out := make([]byte, llen)
+	if _, err := io.ReadFull(r, out); err != nil {
+		// 一个字节也没解压出来时 ReadFull 返回 io.EOF, 会被当作文件结束
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// readFragment 读取 packed inode 中 off 处的 n 个字节, 连续读取同一区段中的分片时复用
+func (fs *EROFS) readFragment(ino *erofsInode, off, n int64) ([]byte, error) {
+	if !fs.fragments || ino == fs.packed {
+		return nil, errEROFS
+	}
+	if fs.packed == nil {
+		packed, err := fs.readInode(fs.packedNid)
+		if err != nil {
+			return nil, err
+		}
+		fs.packed = packed
+	}
+	if off < 0 || n < 0 || off+n > fs.packed.size {
+		return nil, errEROFS
+	}
+	out := make([]byte, 0, n)
+	for pos := off; pos < off+n; {
+		if pos < fs.fragStart || pos >= fs.fragStart+int64(len(fs.fragData)) {
+			data, start, err := fs.extent(fs.packed, pos)
+			if err != nil {
+				return nil, err
+			}
+			if start > pos || start+int64(len(data)) <= pos {
+				return nil, errEROFS
+			}
+			fs.fragStart, fs.fragData = start, data
+		}
+		chunk := fs.fragData[pos-fs.fragStart:]
+		if rem := off + n - pos; int64(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
+		out = append(out, chunk...)
+		pos += int64(len(chunk))
+	}
+	return out, nil
+}
+
+// erofsFileReader 按区段读取文件内容
+type erofsFileReader struct {
+	fs    *EROFS
+	inode *erofsInode
+	pos   int64
+	buf   []byte //当前区段中 pos 之后的内容
+}
+
+func (f *erofsFileReader) Read(p []byte) (int, error) {
+	if f.pos >= f.inode.size {
+		return 0, io.EOF
+	}
+	if len(f.buf) == 0 {
+		data, start, err := f.fs.extent(f.inode, f.pos)
+		if err != nil {
+			return 0, err
+		}
+		if start > f.pos || start+int64(len(data)) <= f.pos {
+			return 0, errEROFS
+		}
+		f.buf = data[f.pos-start:]
+		if rem := f.inode.size - f.pos; int64(len(f.buf)) > rem {
+			f.buf = f.buf[:rem]
+		}
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	f.pos += int64(n)
+	return n, nil
+}
+
+// erofsDirEntry 目录中的一项
+type erofsDirEntry struct {
+	name string
+	nid  uint64
+}
+
+// readDir 读取目录的各项, 每个块开头为目录项数组, 第一项的名称偏移即为目录项数组的大小,
+// 最后一个名称结束于块末尾或 NUL
+func (fs *EROFS) readDir(ino *erofsInode) ([]erofsDirEntry, error) {
+	data, err := ioutil.ReadAll(&erofsFileReader{fs: fs, inode: ino})
+	if err != nil {
+		return nil, err
+	}
+	var res []erofsDirEntry
+	for start := int64(0); start < int64(len(data)); start += fs.blockSize() {
+		blk := data[start:]
+		if int64(len(blk)) > fs.blockSize() {
+			blk = blk[:fs.blockSize()]
+		}
+		if len(blk) < erofsDirentSize {
+			return nil, errEROFS
+		}
+		n := int(binary.LittleEndian.Uint16(blk[8:])) / erofsDirentSize
+		if n == 0 || n*erofsDirentSize > len(blk) {
+			return nil, errEROFS
+		}
+		for i := 0; i < n; i++ {
+			de := blk[i*erofsDirentSize:]
+			nameOff := int(binary.LittleEndian.Uint16(de[8:]))
+			end := len(blk)
+			if i+1 < n {
+				end = int(binary.LittleEndian.Uint16(blk[(i+1)*erofsDirentSize+8:]))
+			}
+			if nameOff < n*erofsDirentSize || nameOff > end || end > len(blk) {
+				return nil, errEROFS
+			}
+			name := blk[nameOff:end]
+			if i+1 == n {
+				if j := bytes.IndexByte(name, 0); j >= 0 {
+					name = name[:j]
+				}
+			}
+			if s := string(name); s != "." && s != ".." && s != "" {
+				res = append(res, erofsDirEntry{name: s, nid: binary.LittleEndian.Uint64(de)})
+			}
+		}
+	}
+	return res, nil
+}
+
+// Walk 按目录顺序遍历所有条目, 包括目录本身, 根目录除外
+func (fs *EROFS) Walk(fn func(e *EROFSEntry) error) error {
+	root, err := fs.readInode(fs.rootNid)
+	if err != nil {
+		return err
+	}
+	if !posixMode(root.mode).IsDir() {
+		return errors.New("erofs root is not a directory")
+	}
+	return fs.walkDir("/", root, map[uint64]bool{fs.rootNid: true}, fn, 0)
+}
+
+// walkDir visited 为已遍历目录的 nid, 目录不能硬链接, 重复出现时镜像中有环;
+// 只限制层数时, 多个目录项指向同一目录仍会使遍历的条目数指数增长
+func (fs *EROFS) walkDir(dir string, ino *erofsInode, visited map[uint64]bool, fn func(e *EROFSEntry) error, depth int) error {
+	if depth > 256 {
+		return errEROFS
+	}
+	entries, err := fs.readDir(ino)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		e, err := fs.entry(path.Join(dir, de.name), de.nid)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		if e.Mode.IsDir() {
+			if visited[de.nid] {
+				return fmt.Errorf("erofs directory loop at %s", e.Path)
+			}
+			visited[de.nid] = true
+			if err := fs.walkDir(e.Path, e.inode, visited, fn, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *EROFS) entry(name string, nid uint64) (*EROFSEntry, error) {
+	ino, err := fs.readInode(nid)
+	if err != nil {
+		return nil, err
+	}
+	e := &EROFSEntry{Path: name, Mode: posixMode(ino.mode), fs: fs, inode: ino}
+	switch {
+	case e.Mode.IsRegular():
+		e.Size = ino.size
+	case e.Mode&os.ModeSymlink != 0:
+		if ino.size > 4096 {
+			return nil, errEROFS
+		}
+		link, err := ioutil.ReadAll(&erofsFileReader{fs: fs, inode: ino})
+		if err != nil {
+			return nil, err
+		}
+		e.Link = string(link)
+	}
+	return e, nil
+}
+
+// ReadFile 读取镜像中的一个普通文件, 不跟随符号链接, 文件不存在时返回 os.ErrNotExist
+func (fs *EROFS) ReadFile(name string) ([]byte, error) {
+	ino, err := fs.readInode(fs.rootNid)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if !posixMode(ino.mode).IsDir() {
+			return nil, os.ErrNotExist
+		}
+		entries, err := fs.readDir(ino)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, de := range entries {
+			if de.name == part {
+				if ino, err = fs.readInode(de.nid); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, os.ErrNotExist
+		}
+	}
+	if !posixMode(ino.mode).IsRegular() {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.ReadAll(&erofsFileReader{fs: fs, inode: ino})
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzEROFS(f *testing.F) {
+	for _, name := range []string{"plain.erofs", "lz4.erofs", "algs.erofs"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "erofs", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fs, err := OpenEROFS(bytes.NewReader(data), 0)
+		if err != nil {
+			return
+		}
+		defer fs.Close()
+		fs.Walk(func(e *EROFSEntry) error {
+			n, err := io.Copy(ioutil.Discard, e.Open())
+			if err == nil && n != e.Size && e.Mode.IsRegular() {
+				t.Fatalf("%s: read %d bytes, size %d", e.Path, n, e.Size)
+			}
+			return nil
+		})
+		fs.ReadFile("dir/d.txt")
+	})
+}
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/erofs 中的镜像由同一目录树生成, 各普通文件依次使用不同的存储方式:
+//
+//	plain.erofs 平铺、尾部内联及 4K/8K 分块
+//	lz4.erofs   lz4 压缩, 包括 full/compact 索引、大物理簇、尾部打包、交错及整块压缩
+//	algs.erofs  lzma、deflate、zstd 压缩, 以及存放在 packed inode 中的片段与扩展属性
+//
+// 文件内容以 sha256 的前 16 位记录
+var erofsTestTree = []string{
+	"/a.txt f 9000 271d0debdda748ed",
+	"/b.txt f 20000 ba63cad3b398fa04",
+	"/c.bin f 12000 f2c1a5e16624e68e",
+	"/dir d",
+	"/dir/d.txt f 5000 2f7de1a487b6dd0d",
+	"/dir/sub d",
+	"/dir/sub/empty f 0 e3b0c44298fc1c14",
+	"/hello.txt f 12 bb41e76f3d49ab7c",
+	"/link l hello.txt",
+}
+
+func readTestEROFS(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "erofs", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// walkTestEROFS 遍历镜像并读取所有普通文件
+func walkTestEROFS(data []byte) ([]string, error) {
+	fs, err := OpenEROFS(bytes.NewReader(data), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+	var got []string
+	err = fs.Walk(func(e *EROFSEntry) error {
+		switch {
+		case e.Mode.IsDir():
+			got = append(got, e.Path+" d")
+		case e.Mode&os.ModeSymlink != 0:
+			got = append(got, e.Path+" l "+e.Link)
+		default:
+			content, err := ioutil.ReadAll(e.Open())
+			if err != nil {
+				return err
+			}
+			if int64(len(content)) != e.Size {
+				return fmt.Errorf("%s: read %d bytes, want %d", e.Path, len(content), e.Size)
+			}
+			sum := sha256.Sum256(content)
+			got = append(got, fmt.Sprintf("%s f %d %.8x", e.Path, e.Size, sum[:]))
+		}
+		return nil
+	})
+	return got, err
+}
+
+func TestEROFSWalk(t *testing.T) {
+	for _, name := range []string{"plain.erofs", "lz4.erofs", "algs.erofs"} {
+		t.Run(name, func(t *testing.T) {
+			data := readTestEROFS(t, name)
+			if !IsEROFS(bytes.NewReader(data), 0) {
+				t.Fatal("IsEROFS() = false")
+			}
+			got, err := walkTestEROFS(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, erofsTestTree) {
+				t.Errorf("Walk() = %q, want %q", got, erofsTestTree)
+			}
+
+			fs, err := OpenEROFS(bytes.NewReader(data), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fs.Close()
+			if hello, err := fs.ReadFile("hello.txt"); err != nil || string(hello) != "hello erofs\n" {
+				t.Errorf("ReadFile(hello.txt) = %q, %v", hello, err)
+			}
+			for _, missing := range []string{"dir/missing", "hello.txt/x", "link", "dir"} {
+				if _, err := fs.ReadFile(missing); !os.IsNotExist(err) {
+					t.Errorf("ReadFile(%q) error = %v, want not exist", missing, err)
+				}
+			}
+		})
+	}
+}
+
+// 损坏的镜像应返回错误, 不能死循环或按声明的大小分配内存
+func TestEROFSCorrupt(t *testing.T) {
+	data := readTestEROFS(t, "plain.erofs")
+	// 根目录中 dir 的目录项指向根目录自身
+	loop := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(loop[4188:], 0)
+	// a.txt 的紧凑 inode 位于元数据区第 6 个槽位, 文件大小改为 4GiB-1
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(huge[4096+6*32+8:], 0xffffffff)
+	badBlockSize := append([]byte(nil), data...)
+	badBlockSize[erofsSuperOffset+12] = 30
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"directory loop", loop},
+		{"huge file", huge},
+		{"bad block size", badBlockSize},
+	}
+	for _, name := range []string{"plain.erofs", "lz4.erofs", "algs.erofs"} {
+		image := readTestEROFS(t, name)
+		for _, n := range []int{erofsSuperOffset + 50, len(image) / 4, len(image) / 2} {
+			tests = append(tests, struct {
+				name string
+				data []byte
+			}{fmt.Sprintf("%s truncated at %d", name, n), image[:n]})
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := walkTestEROFS(tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := walkTestEROFS(loop); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Walk() error = %v, want directory loop", err)
+	}
+}
+
+// TestEROFSEmptyPcluster 物理簇解压不出任何数据时报错, 而不是当作文件已读完
+func TestEROFSEmptyPcluster(t *testing.T) {
+	data := readTestEROFS(t, "algs.erofs")
+	// b.txt 以 deflate 压缩, 物理簇位于第 3 块, 改为只含一个空的结束块
+	pcluster := data[3*4096 : 4*4096]
+	for i := range pcluster {
+		pcluster[i] = 0
+	}
+	pcluster[len(pcluster)-2] = 0x03
+	fs, err := OpenEROFS(bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if content, err := fs.ReadFile("b.txt"); err == nil {
+		t.Errorf("expected an error, read %d bytes", len(content))
+	}
+}
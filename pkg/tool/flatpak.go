@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// flatpak 应用目录及单文件包中的文件
+const (
+	FlatpakMetadata = "metadata"
+	FlatpakFilesDir = "files"
+	FlatpakManifest = "files/manifest.json" //flatpak-builder 记录的构建清单
+)
+
+// FlatpakRef flatpak 的引用, 如 org.gnome.Platform/x86_64/45
+type FlatpakRef struct {
+	Kind   string //app 或 runtime, 只有完整的引用才有
+	ID     string
+	Arch   string
+	Branch string
+}
+
+// ParseFlatpakRef 解析 [app/|runtime/]id/arch/branch 形式的引用
+func ParseFlatpakRef(ref string) (FlatpakRef, error) {
+	parts := strings.Split(ref, "/")
+	var r FlatpakRef
+	if len(parts) == 4 {
+		r.Kind = parts[0]
+		parts = parts[1:]
+	}
+	if len(parts) != 3 || parts[0] == "" {
+		return r, errors.New("invalid flatpak ref: " + ref)
+	}
+	r.ID, r.Arch, r.Branch = parts[0], parts[1], parts[2]
+	return r, nil
+}
+
+// FlatpakMeta flatpak metadata 文件中的信息
+type FlatpakMeta struct {
+	Kind    string //app 或 runtime
+	Name    string
+	Runtime string //运行时引用
+	Sdk     string
+	Command string
+	Groups  []KeyFileGroup //全部组, 用于记录权限与扩展
+}
+
+// ParseFlatpakMetadata 解析 metadata 文件, 应用为 [Application] 组, 运行时为 [Runtime] 组
+func ParseFlatpakMetadata(data []byte) (*FlatpakMeta, error) {
+	groups := ParseKeyFile(data)
+	meta := &FlatpakMeta{Kind: "app", Groups: groups}
+	g := KeyFileGroupByName(groups, "Application")
+	if g == nil {
+		meta.Kind = "runtime"
+		g = KeyFileGroupByName(groups, "Runtime")
+	}
+	if g == nil || g.Get("name") == "" {
+		return nil, errors.New("flatpak metadata has no [Application] or [Runtime] name")
+	}
+	meta.Name = g.Get("name")
+	meta.Runtime = g.Get("runtime")
+	meta.Sdk = g.Get("sdk")
+	meta.Command = g.Get("command")
+	return meta, nil
+}
+
+// FlatpakSource flatpak-builder 模块的一个源
+type FlatpakSource struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	Tag    string `json:"tag"`
+	Branch string `json:"branch"`
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512"`
+}
+
+// FlatpakModule flatpak-builder 构建的模块, Parent 为上级模块名, 顶层模块为空
+type FlatpakModule struct {
+	Name    string
+	Parent  string
+	Sources []FlatpakSource
+}
+
+type flatpakManifestModule struct {
+	Name     string            `json:"name"`
+	Disabled bool              `json:"disabled"`
+	Sources  []json.RawMessage `json:"sources"`
+	Modules  []json.RawMessage `json:"modules"`
+}
+
+// ParseFlatpakManifest 解析 manifest.json 中的模块, 嵌套的模块按深度优先展开, 跳过禁用的模块;
+// 以文件名引用的模块和源在构建后的清单中已展开, 仍为文件名的忽略
+func ParseFlatpakManifest(data []byte) ([]FlatpakModule, error) {
+	var manifest struct {
+		Modules []json.RawMessage `json:"modules"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	var res []FlatpakModule
+	var walk func(raw []json.RawMessage, parent string)
+	walk = func(raw []json.RawMessage, parent string) {
+		for _, r := range raw {
+			var m flatpakManifestModule
+			if json.Unmarshal(r, &m) != nil || m.Disabled || m.Name == "" {
+				continue
+			}
+			module := FlatpakModule{Name: m.Name, Parent: parent}
+			for _, s := range m.Sources {
+				var src FlatpakSource
+				if json.Unmarshal(s, &src) == nil && src.Type != "" {
+					module.Sources = append(module.Sources, src)
+				}
+			}
+			res = append(res, module)
+			walk(m.Modules, m.Name)
+		}
+	}
+	walk(manifest.Modules, "")
+	return res, nil
+}
+
+var archiveExt = regexp.MustCompile(`(\.tar)?\.(gz|bz2|xz|zst|lz|lzma|tgz|tbz2|txz|zip|7z)$`)
+
+// ArchiveVersion 从 name-version.tar.xz 形式的压缩包文件名中取出版本, 无法确定时为空
+func ArchiveVersion(name, url string) string {
+	base := archiveExt.ReplaceAllString(path.Base(url), "")
+	if base == path.Base(url) {
+		return ""
+	}
+	for _, sep := range []string{"-", "_"} {
+		if prefix := name + sep; len(base) > len(prefix) && strings.EqualFold(base[:len(prefix)], prefix) {
+			return base[len(prefix):]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,346 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GVariant 序列化格式的解码, 用于读取 OSTree 对象及 flatpak 单文件包, 只支持小端序。
+// 解码结果: 基本类型为对应的 Go 类型, ay 为 []byte, 其他数组、元组及字典项为 []interface{},
+// v 为 GVariantValue, maybe 类型为 nil 或其中的值
+
+// GVariantValue 变体中的值及其类型
+type GVariantValue struct {
+	Type  string
+	Value interface{}
+}
+
+var errGVariant = errors.New("invalid gvariant data")
+
+// gvMaxDepth 类型及变体的最大嵌套层数, 与 GLib 的 G_VARIANT_MAX_RECURSION_DEPTH 相同
+const gvMaxDepth = 128
+
+// gvTypeEnd 返回 t 中第一个完整类型的长度
+func gvTypeEnd(t string) (int, error) {
+	return gvTypeEndDepth(t, 0)
+}
+
+func gvTypeEndDepth(t string, depth int) (int, error) {
+	if t == "" || depth > gvMaxDepth {
+		return 0, errGVariant
+	}
+	switch t[0] {
+	case 'a', 'm':
+		n, err := gvTypeEndDepth(t[1:], depth+1)
+		return n + 1, err
+	case '(', '{':
+		closing := byte(')')
+		if t[0] == '{' {
+			closing = '}'
+		}
+		i := 1
+		for i < len(t) && t[i] != closing {
+			n, err := gvTypeEndDepth(t[i:], depth+1)
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+		if i >= len(t) {
+			return 0, fmt.Errorf("unterminated gvariant type %q", t)
+		}
+		return i + 1, nil
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'h', 'x', 't', 'd', 's', 'o', 'g', 'v':
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unsupported gvariant type %q", t)
+}
+
+// gvMembers 拆分元组或字典项的成员类型
+func gvMembers(t string) ([]string, error) {
+	var members []string
+	inner := t[1 : len(t)-1]
+	for inner != "" {
+		n, err := gvTypeEnd(inner)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, inner[:n])
+		inner = inner[n:]
+	}
+	return members, nil
+}
+
+// gvType 类型的对齐字节数、定长大小(变长类型为 0)及元组或字典项的成员
+type gvType struct {
+	align   int
+	fixed   int
+	members []string
+}
+
+// gvTypes 解码时按类型字符串缓存 gvType, 数组中的每个元素不再重复分析类型,
+// 否则嵌套很深的元素类型会使解码时间随数据大小成倍增长。类型须已通过 gvTypeEnd 检查
+type gvTypes map[string]*gvType
+
+func (c gvTypes) get(t string) *gvType {
+	if info, ok := c[t]; ok {
+		return info
+	}
+	info := &gvType{align: 1}
+	switch t[0] {
+	case 'y', 'b':
+		info.fixed = 1
+	case 'n', 'q':
+		info.align, info.fixed = 2, 2
+	case 'i', 'u', 'h':
+		info.align, info.fixed = 4, 4
+	case 'x', 't', 'd':
+		info.align, info.fixed = 8, 8
+	case 'v':
+		info.align = 8
+	case 'a', 'm':
+		info.align = c.get(t[1:]).align
+	case '(', '{':
+		info.members, _ = gvMembers(t)
+		size := 0
+		for _, m := range info.members {
+			mi := c.get(m)
+			if mi.align > info.align {
+				info.align = mi.align
+			}
+			if size >= 0 && mi.fixed > 0 {
+				size = gvAlignUp(size, mi.align) + mi.fixed
+			} else {
+				size = -1
+			}
+		}
+		switch {
+		case len(info.members) == 0:
+			info.fixed = 1
+		case size > 0:
+			info.fixed = gvAlignUp(size, info.align)
+		}
+	}
+	c[t] = info
+	return info
+}
+
+// gvAlign 返回类型的对齐字节数
+func gvAlign(t string) int {
+	return gvTypes{}.get(t).align
+}
+
+func gvAlignUp(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+// gvOffsetSize 容器中帧偏移的字节数, 由容器大小决定
+func gvOffsetSize(size int) int {
+	switch {
+	case size == 0:
+		return 0
+	case size <= math.MaxUint8:
+		return 1
+	case size <= math.MaxUint16:
+		return 2
+	case size <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+func gvReadOffset(b []byte, size int) int {
+	switch size {
+	case 1:
+		return int(b[0])
+	case 2:
+		return int(binary.LittleEndian.Uint16(b))
+	case 4:
+		return int(binary.LittleEndian.Uint32(b))
+	}
+	return int(binary.LittleEndian.Uint64(b))
+}
+
+// ParseGVariant 按类型 t 解码 GVariant 数据
+func ParseGVariant(t string, data []byte) (interface{}, error) {
+	return gvTypes{}.parse(t, data, 0)
+}
+
+// parse 解码 depth 层变体中的值, 类型与变体的嵌套超过 gvMaxDepth 时报错
+func (c gvTypes) parse(t string, data []byte, depth int) (interface{}, error) {
+	if n, err := gvTypeEndDepth(t, depth); err != nil {
+		return nil, err
+	} else if n != len(t) {
+		return nil, fmt.Errorf("invalid gvariant type %q", t)
+	}
+	return c.decode(t, data, depth)
+}
+
+func (c gvTypes) decode(t string, data []byte, depth int) (interface{}, error) {
+	if fixed := c.get(t).fixed; fixed > 0 && len(data) != fixed {
+		return nil, errGVariant
+	}
+	switch t[0] {
+	case 'y':
+		return data[0], nil
+	case 'b':
+		return data[0] != 0, nil
+	case 'n':
+		return int16(binary.LittleEndian.Uint16(data)), nil
+	case 'q':
+		return binary.LittleEndian.Uint16(data), nil
+	case 'i', 'h':
+		return int32(binary.LittleEndian.Uint32(data)), nil
+	case 'u':
+		return binary.LittleEndian.Uint32(data), nil
+	case 'x':
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case 't':
+		return binary.LittleEndian.Uint64(data), nil
+	case 'd':
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case 's', 'o', 'g':
+		if len(data) == 0 || data[len(data)-1] != 0 {
+			return nil, errGVariant
+		}
+		return string(data[:len(data)-1]), nil
+	case 'v':
+		// 值之后为 0 字节及类型字符串
+		i := len(data) - 1
+		for i >= 0 && data[i] != 0 {
+			i--
+		}
+		if i < 0 {
+			return nil, errGVariant
+		}
+		vt := string(data[i+1:])
+		v, err := c.parse(vt, data[:i], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return GVariantValue{Type: vt, Value: v}, nil
+	case 'm':
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if c.get(t[1:]).fixed > 0 {
+			return c.decode(t[1:], data, depth)
+		}
+		return c.decode(t[1:], data[:len(data)-1], depth)
+	case 'a':
+		return c.decodeArray(t[1:], data, depth)
+	case '(', '{':
+		return c.decodeTuple(t, data, depth)
+	}
+	return nil, fmt.Errorf("unsupported gvariant type %q", t)
+}
+
+func (c gvTypes) decodeArray(elem string, data []byte, depth int) (interface{}, error) {
+	if elem == "y" {
+		return data, nil
+	}
+	var res []interface{}
+	info := c.get(elem)
+	if fixed := info.fixed; fixed > 0 {
+		if len(data)%fixed != 0 {
+			return nil, errGVariant
+		}
+		for pos := 0; pos < len(data); pos += fixed {
+			v, err := c.decode(elem, data[pos:pos+fixed], depth)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	}
+	if len(data) == 0 {
+		return res, nil
+	}
+	// 变长元素的结束位置依次记录在数组末尾, 最后一个偏移即偏移表的起始位置
+	osz := gvOffsetSize(len(data))
+	table := gvReadOffset(data[len(data)-osz:], osz)
+	if table > len(data) || (len(data)-table)%osz != 0 {
+		return nil, errGVariant
+	}
+	start := 0
+	for pos := table; pos < len(data); pos += osz {
+		end := gvReadOffset(data[pos:], osz)
+		start = gvAlignUp(start, info.align)
+		if start > end || end > table {
+			return nil, errGVariant
+		}
+		v, err := c.decode(elem, data[start:end], depth)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+		start = end
+	}
+	return res, nil
+}
+
+func (c gvTypes) decodeTuple(t string, data []byte, depth int) (interface{}, error) {
+	members := c.get(t).members
+	// 除最后一个成员外, 变长成员的结束位置倒序记录在元组末尾
+	osz := gvOffsetSize(len(data))
+	framing := 0
+	for i, m := range members {
+		if i < len(members)-1 && c.get(m).fixed == 0 {
+			framing++
+		}
+	}
+	// 空数据中没有帧偏移
+	limit := len(data) - framing*osz
+	if limit < 0 || framing > 0 && osz == 0 {
+		return nil, errGVariant
+	}
+	res := make([]interface{}, 0, len(members))
+	pos, k := 0, 0
+	for i, m := range members {
+		info := c.get(m)
+		pos = gvAlignUp(pos, info.align)
+		var end int
+		if fixed := info.fixed; fixed > 0 {
+			end = pos + fixed
+		} else if i == len(members)-1 {
+			end = limit
+		} else {
+			k++
+			end = gvReadOffset(data[len(data)-k*osz:], osz)
+		}
+		if pos > end || end > limit {
+			return nil, errGVariant
+		}
+		v, err := c.decode(m, data[pos:end], depth)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+		pos = end
+	}
+	return res, nil
+}
+
+// GVariantDict 将 a{sv} 的解码结果转换为 map, 值为变体中的值
+func GVariantDict(v interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	entries, _ := v.([]interface{})
+	for _, e := range entries {
+		kv, ok := e.([]interface{})
+		if !ok || len(kv) != 2 {
+			continue
+		}
+		key, _ := kv[0].(string)
+		if val, ok := kv[1].(GVariantValue); ok {
+			res[key] = val.Value
+		}
+	}
+	return res
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func FuzzParseGVariant(f *testing.F) {
+	for _, seed := range []struct{ typ, hex string }{
+		{"(su)", "616263000700000004"},
+		{"(nybdmsms)", "fbff090100000000000000000000f83f6d000013"},
+		{"a{sv}", "72656600000000006170702f782f7838365f36342f737461626c6500007304006e00000000000000030000000075020062797465730000000001020061790600740000000000000000000000000100000074021f2f3f53"},
+		{"(a{sv}tayay(a{sv}aya(say)sstayay)aya(uayttay)a(yaytt))", "6b0000000000000076000073020d0000050000000000000001010101010101010101010101010101010101010101010101010101010101017375626a00626f647900000000000000630000000000000002020202020202020202020202020202020202020202020202020202020202020303030303030303030303030303030303030303030303030303030303030303380a050000000000000000000404040404040404040404040404040404040404040404040404040404040404000000000a000000000000001400000000000000020505050505050505050505050505050505050505050505050505050505050505245a0000000000f3969638180e"},
+	} {
+		data, err := hex.DecodeString(seed.hex)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(seed.typ, data)
+	}
+	f.Fuzz(func(t *testing.T, typ string, data []byte) {
+		if v, err := ParseGVariant(typ, data); err == nil {
+			GVariantDict(v)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试数据均由 GLib 的 g_variant_get_data 生成
+func TestParseGVariant(t *testing.T) {
+	zero32 := make([]byte, 32)
+	seq32 := make([]byte, 32)
+	for i := range seq32 {
+		seq32[i] = byte(i)
+	}
+	// 超过 255 字节的容器使用 2 字节的帧偏移
+	var as bytes.Buffer
+	var asOffsets []byte
+	var asWant []interface{}
+	for i := 0; i < 300; i++ {
+		s := strings.Repeat("x", i)
+		as.WriteString(s + "\x00")
+		var off [2]byte
+		binary.LittleEndian.PutUint16(off[:], uint16(as.Len()))
+		asOffsets = append(asOffsets, off[:]...)
+		asWant = append(asWant, s)
+	}
+	as.Write(asOffsets)
+
+	tests := []struct {
+		typ  string
+		hex  string
+		data []byte
+		want interface{}
+	}{
+		{typ: "(su)", hex: "616263000700000004", want: []interface{}{"abc", uint32(7)}},
+		{
+			typ:  "(nybdmsms)",
+			hex:  "fbff090100000000000000000000f83f6d000013",
+			want: []interface{}{int16(-5), byte(9), true, 1.5, "m", nil},
+		},
+		{
+			typ: "(a(uuu)aa(ayay)ayay)",
+			hex: "0000000000000000a48100000100000002000000ffa1000000" +
+				strings.Repeat("68656c6c6f", 100) + "6f70730d0219001800",
+			want: []interface{}{
+				[]interface{}{
+					[]interface{}{uint32(0), uint32(0), uint32(0100644)},
+					[]interface{}{uint32(1), uint32(2), uint32(0120777)},
+				},
+				[]interface{}{[]interface{}(nil)},
+				bytes.Repeat([]byte("hello"), 100),
+				[]byte("ops"),
+			},
+		},
+		{
+			typ: "(a(say)a(sayay))",
+			hex: "6100000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f026262000000000000000000000000000000000000000000000000000000000000000000032347646972000000000000000000000000000000000000000000000000000000000000000000070707070707070707070707070707070707070707070707070707070707070724044649",
+			want: []interface{}{
+				[]interface{}{
+					[]interface{}{"a", seq32},
+					[]interface{}{"bb", zero32},
+				},
+				[]interface{}{
+					[]interface{}{"dir", zero32, bytes.Repeat([]byte{7}, 32)},
+				},
+			},
+		},
+		{
+			typ:  "(yay)",
+			data: append([]byte("x"), bytes.Repeat([]byte("z"), 70000)...),
+			want: []interface{}{byte('x'), bytes.Repeat([]byte("z"), 70000)},
+		},
+		{typ: "as", data: as.Bytes(), want: asWant},
+		{
+			// flatpak 单文件包超级块的结构
+			typ: "(a{sv}tayay(a{sv}aya(say)sstayay)aya(uayttay)a(yaytt))",
+			hex: "6b0000000000000076000073020d0000050000000000000001010101010101010101010101010101010101010101010101010101010101017375626a00626f647900000000000000630000000000000002020202020202020202020202020202020202020202020202020202020202020303030303030303030303030303030303030303030303030303030303030303380a050000000000000000000404040404040404040404040404040404040404040404040404040404040404000000000a000000000000001400000000000000020505050505050505050505050505050505050505050505050505050505050505245a0000000000f3969638180e",
+			want: []interface{}{
+				[]interface{}{[]interface{}{"k", GVariantValue{Type: "s", Value: "v"}}},
+				uint64(5),
+				[]byte{},
+				bytes.Repeat([]byte{1}, 32),
+				[]interface{}{
+					[]interface{}(nil),
+					[]byte{},
+					[]interface{}(nil),
+					"subj",
+					"body",
+					uint64(99),
+					bytes.Repeat([]byte{2}, 32),
+					bytes.Repeat([]byte{3}, 32),
+				},
+				[]byte{},
+				[]interface{}{
+					[]interface{}{uint32(0), bytes.Repeat([]byte{4}, 32), uint64(10), uint64(20), append([]byte{2}, bytes.Repeat([]byte{5}, 32)...)},
+				},
+				[]interface{}(nil),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			data := tt.data
+			if tt.hex != "" {
+				var err error
+				if data, err = hex.DecodeString(tt.hex); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ParseGVariant(tt.typ, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGVariantDict(t *testing.T) {
+	data, _ := hex.DecodeString("72656600000000006170702f782f7838365f36342f737461626c6500007304006e00000000000000030000000075020062797465730000000001020061790600740000000000000000000000000100000074021f2f3f53")
+	v, err := ParseGVariant("a{sv}", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ref":   "app/x/x86_64/stable",
+		"n":     uint32(3),
+		"bytes": []byte{0, 1, 2},
+		"t":     uint64(1 << 40),
+	}
+	if got := GVariantDict(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestParseGVariantErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		data []byte
+	}{
+		{"bad type", "(su", nil},
+		{"trailing type", "uu", make([]byte, 8)},
+		{"unsupported type", "r", nil},
+		{"fixed size", "u", []byte{1, 2, 3}},
+		{"unterminated string", "s", []byte("abc")},
+		{"missing framing offset", "(su)", []byte("abc\x00\x07\x00\x00\x00")},
+		{"offset out of range", "(su)", []byte("abc\x00\x07\x00\x00\x00\xff")},
+		{"array offset out of range", "as", []byte("a\x00\x09")},
+		{"fixed array size", "au", []byte{1, 2, 3, 4, 5}},
+		{"variant without type", "v", []byte{1, 2, 3}},
+		{"nested variant type", "v", []byte("\x00" + strings.Repeat("a", gvMaxDepth+1) + "y")},
+		{"deep type", strings.Repeat("m", gvMaxDepth+1) + "y", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, err := ParseGVariant(tt.typ, tt.data); err == nil {
+				t.Errorf("expected an error, got %#v", v)
+			}
+		})
+	}
+}
+
+// TestParseGVariantNestedVariants 变体逐层嵌套时深度累加, 超过 gvMaxDepth 报错
+func TestParseGVariantNestedVariants(t *testing.T) {
+	nest := func(n int) []byte {
+		data := []byte{1}
+		typ := "y"
+		for i := 0; i < n; i++ {
+			data = append(append(data, 0), typ...)
+			typ = "v"
+		}
+		return data
+	}
+	if _, err := ParseGVariant("v", nest(gvMaxDepth)); err != nil {
+		t.Errorf("%d nested variants: %v", gvMaxDepth, err)
+	}
+	if _, err := ParseGVariant("v", nest(gvMaxDepth+2)); err == nil {
+		t.Errorf("%d nested variants: expected an error", gvMaxDepth+2)
+	}
+}
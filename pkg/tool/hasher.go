@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
+
+	"github.com/panjf2000/ants"
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+// 超过该大小的文件不读入内存, 在读取协程中直接计算摘要
+const parallelHashMaxSize = 4 * 1024 * 1024
+
+// HashOptions 文件摘要计算的选项
+type HashOptions struct {
+	Workers      int  //并发数, 小于等于 1 时在读取协程中顺序计算
+	ScanLicenses bool //是否扫描文本文件中的许可证与版权信息
+}
+
+// DefaultHashOptions 默认按 CPU 数并发计算摘要, 不扫描许可证
+func DefaultHashOptions() HashOptions {
+	return HashOptions{Workers: runtime.NumCPU()}
+}
+
+type hashJob struct {
+	index int
+	data  []byte
+	scan  bool
+}
+
+// HashStage 使用协程池并发计算文件摘要, 结果按提交顺序保存。
+// 归档流只能顺序读取, 因此由调用方逐个提交条目, 池满时提交会阻塞,
+// 内存中最多同时存在 并发数+1 个文件内容。
+// 开启许可证扫描时, 同时在协程中扫描文本文件的许可证与版权信息
+type HashStage struct {
+	pool    *ants.PoolWithFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	scan    bool
+	results [][]common.Checksum
+	scans   []*FileLicenseScan
+	err     error
+}
+
+func NewHashStage(opts HashOptions) (*HashStage, error) {
+	s := &HashStage{scan: opts.ScanLicenses}
+	if opts.Workers <= 1 {
+		return s, nil
+	}
+	pool, err := ants.NewPoolWithFunc(opts.Workers, func(arg interface{}) {
+		defer s.wg.Done()
+		s.run(arg.(hashJob))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.pool = pool
+	return s, nil
+}
+
+func (s *HashStage) run(job hashJob) {
+	checksums, err := GetChecksumsForReader(bytes.NewReader(job.data))
+	var scan *FileLicenseScan
+	if job.scan {
+		scan = ScanLicenses(job.data)
+	}
+	s.setResult(job.index, checksums, scan, err)
+}
+
+func (s *HashStage) setResult(index int, checksums []common.Checksum, scan *FileLicenseScan, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	s.results[index] = checksums
+	s.scans[index] = scan
+}
+
+// Add 读取一个文件内容并提交摘要计算, 返回结果序号
+func (s *HashStage) Add(r io.Reader, size int64) (int, error) {
+	s.mu.Lock()
+	index := len(s.results)
+	s.results = append(s.results, nil)
+	s.scans = append(s.scans, nil)
+	s.mu.Unlock()
+
+	scan := s.scan && size <= licenseScanMaxSize
+	if (s.pool == nil && !scan) || size > parallelHashMaxSize {
+		checksums, err := GetChecksumsForReader(r)
+		s.setResult(index, checksums, nil, err)
+		return index, err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return index, err
+	}
+	job := hashJob{index: index, data: data, scan: scan}
+	if s.pool == nil {
+		s.run(job)
+		return index, nil
+	}
+	s.wg.Add(1)
+	if err := s.pool.Invoke(job); err != nil {
+		s.wg.Done()
+		return index, err
+	}
+	return index, nil
+}
+
+// Wait 等待所有摘要计算完成, 按提交顺序返回结果
+func (s *HashStage) Wait() ([][]common.Checksum, error) {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results, s.err
+}
+
+// LicenseScans 返回许可证扫描结果, 与 Wait 返回的摘要一一对应, 未扫描或没有结果的为 nil;
+// 需在 Wait 之后调用
+func (s *HashStage) LicenseScans() []*FileLicenseScan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans
+}
+
+func (s *HashStage) Release() {
+	if s.pool != nil {
+		s.pool.Release()
+	}
+}
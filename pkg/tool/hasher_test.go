@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// TestHashStageOrder 小文件在协程池中计算、大文件在提交时直接计算, 读取失败的条目没有结果,
+// 各结果均应保存在提交时返回的序号处
+func TestHashStageOrder(t *testing.T) {
+	const count = 40
+	errRead := errors.New("read failed")
+	for _, workers := range []int{1, 4} {
+		for _, scan := range []bool{false, true} {
+			t.Run(fmt.Sprintf("workers=%d scan=%v", workers, scan), func(t *testing.T) {
+				s, err := NewHashStage(HashOptions{Workers: workers, ScanLicenses: scan})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Release()
+
+				want := make([][]byte, count)
+				for i := 0; i < count; i++ {
+					var r io.Reader
+					size := int64(0)
+					switch {
+					case i%7 == 3:
+						// 小文件与大文件各有读取失败的
+						size = int64(100 + (i%2)*parallelHashMaxSize)
+						r = iotest.ErrReader(errRead)
+					case i%5 == 0:
+						want[i] = bytes.Repeat([]byte{byte(i)}, parallelHashMaxSize+i)
+					default:
+						want[i] = []byte(fmt.Sprintf("SPDX-License-Identifier: MIT\nfile %d\n", i))
+					}
+					if want[i] != nil {
+						size = int64(len(want[i]))
+						r = bytes.NewReader(want[i])
+					}
+					index, err := s.Add(r, size)
+					if index != i {
+						t.Fatalf("Add() index = %d, want %d", index, i)
+					}
+					if (err != nil) != (want[i] == nil) {
+						t.Errorf("Add(%d) error = %v", i, err)
+					}
+				}
+
+				results, err := s.Wait()
+				if err != errRead {
+					t.Errorf("Wait() error = %v, want %v", err, errRead)
+				}
+				if len(results) != count {
+					t.Fatalf("got %d results, want %d", len(results), count)
+				}
+				scans := s.LicenseScans()
+				for i, data := range want {
+					if data == nil {
+						if results[i] != nil {
+							t.Errorf("%d: failed read has checksums %v", i, results[i])
+						}
+						continue
+					}
+					checksums, err := GetChecksumsForReader(bytes.NewReader(data))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !reflect.DeepEqual(results[i], checksums) {
+						t.Errorf("%d: checksums = %v, want %v", i, results[i], checksums)
+					}
+					// 只有开启扫描时的小文件才有扫描结果
+					scanned := scan && len(data) <= licenseScanMaxSize
+					if got := scans[i] != nil; got != scanned {
+						t.Errorf("%d: scanned = %v, want %v", i, got, scanned)
+					} else if scanned && !reflect.DeepEqual(scans[i].Licenses, []string{"MIT"}) {
+						t.Errorf("%d: licenses = %q", i, scans[i].Licenses)
+					}
+				}
+			})
+		}
+	}
+}
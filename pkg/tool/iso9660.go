@@ -0,0 +1,342 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ISO 9660 的卷描述符从第 16 个扇区开始, 扇区固定为 2048 字节
+const (
+	isoSectorSize      = 2048
+	isoDescriptorStart = 16 * isoSectorSize
+	isoDescriptorPVD   = 1
+	isoDescriptorEnd   = 255
+)
+
+// 目录记录的标志位
+const (
+	isoFlagDirectory   = 0x02
+	isoFlagMultiExtent = 0x80
+)
+
+// Rock Ridge 记录的 POSIX 文件类型
+const (
+	posixTypeMask = 0170000
+	posixDir      = 0040000
+	posixLink     = 0120000
+	posixRegular  = 0100000
+)
+
+var errISO9660 = errors.New("invalid iso9660 image")
+
+// ISO9660 纯 Go 实现的 ISO 9660 只读解析, 支持 Rock Ridge 扩展中的文件名、权限及符号链接,
+// 用于读取 type 1 的 AppImage
+type ISO9660 struct {
+	r         io.ReaderAt
+	blockSize int64
+	volume    int64 //卷的字节数, 目录及文件不能超出
+	root      isoRecord
+}
+
+type isoRecord struct {
+	extent int64
+	size   int64
+	flags  byte
+	name   string
+	mode   os.FileMode
+	link   string
+}
+
+// ISO9660Entry 镜像中的一个条目
+type ISO9660Entry struct {
+	Path string //以 / 开头的路径
+	Mode os.FileMode
+	Size int64  //普通文件的大小
+	Link string //符号链接的目标
+
+	r      io.ReaderAt
+	offset int64
+}
+
+// Open 返回普通文件内容的读取流
+func (e *ISO9660Entry) Open() io.Reader {
+	return &isoFileReader{r: io.NewSectionReader(e.r, e.offset, e.Size), remain: e.Size}
+}
+
+// isoFileReader 文件内容超出镜像末尾时返回 io.ErrUnexpectedEOF, 而不是只读到其中一部分
+type isoFileReader struct {
+	r      *io.SectionReader
+	remain int64
+}
+
+func (f *isoFileReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	f.remain -= int64(n)
+	if err == io.EOF && f.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// IsISO9660 判断文件是否为 ISO 9660 镜像
+func IsISO9660(r io.ReaderAt) bool {
+	magic := make([]byte, 6)
+	if _, err := r.ReadAt(magic, isoDescriptorStart); err != nil {
+		return false
+	}
+	return string(magic[1:]) == "CD001"
+}
+
+// OpenISO9660 读取主卷描述符
+func OpenISO9660(r io.ReaderAt) (*ISO9660, error) {
+	desc := make([]byte, isoSectorSize)
+	for pos := int64(isoDescriptorStart); ; pos += isoSectorSize {
+		if _, err := r.ReadAt(desc, pos); err != nil {
+			return nil, err
+		}
+		if string(desc[1:6]) != "CD001" || desc[0] == isoDescriptorEnd {
+			return nil, errISO9660
+		}
+		if desc[0] == isoDescriptorPVD {
+			break
+		}
+	}
+	iso := &ISO9660{r: r, blockSize: int64(binary.LittleEndian.Uint16(desc[128:]))}
+	if iso.blockSize == 0 {
+		return nil, errISO9660
+	}
+	iso.volume = int64(binary.LittleEndian.Uint32(desc[80:])) * iso.blockSize
+	root, ok := iso.parseRecord(desc[156 : 156+34])
+	if !ok || root.flags&isoFlagDirectory == 0 {
+		return nil, errISO9660
+	}
+	iso.root = root
+	return iso, nil
+}
+
+// parseRecord 解析一条目录记录, 有 Rock Ridge 信息时使用其中的文件名、权限及链接目标
+func (iso *ISO9660) parseRecord(b []byte) (isoRecord, bool) {
+	if len(b) < 34 || int(b[0]) > len(b) || 33+int(b[32]) > int(b[0]) {
+		return isoRecord{}, false
+	}
+	b = b[:b[0]]
+	rec := isoRecord{
+		extent: int64(binary.LittleEndian.Uint32(b[2:])),
+		size:   int64(binary.LittleEndian.Uint32(b[10:])),
+		flags:  b[25],
+	}
+	id := b[33 : 33+int(b[32])]
+	switch {
+	case len(id) == 1 && id[0] == 0:
+		rec.name = "."
+	case len(id) == 1 && id[0] == 1:
+		rec.name = ".."
+	default:
+		// 没有 Rock Ridge 时去掉 ;1 版本号及无扩展名时的结尾点
+		name := string(id)
+		if i := strings.IndexByte(name, ';'); i >= 0 {
+			name = name[:i]
+		}
+		rec.name = strings.TrimSuffix(name, ".")
+	}
+	rec.mode = 0644
+	if rec.flags&isoFlagDirectory != 0 {
+		rec.mode = os.ModeDir | 0755
+	}
+	// 系统使用区在文件标识符之后, 标识符长度为偶数时有一个填充字节
+	su := 33 + len(id)
+	if len(id)%2 == 0 {
+		su++
+	}
+	if su < len(b) {
+		iso.parseRockRidge(b[su:], &rec, 0)
+	}
+	return rec, true
+}
+
+// parseRockRidge 解析系统使用区中的 NM、PX、SL 及续接区 CE 记录
+func (iso *ISO9660) parseRockRidge(su []byte, rec *isoRecord, depth int) {
+	var name, link []string
+	var linkComp strings.Builder
+	hasName := false
+	for len(su) >= 4 {
+		n := int(su[2])
+		if n < 4 || n > len(su) {
+			break
+		}
+		entry := su[4:n]
+		switch string(su[:2]) {
+		case "NM":
+			if len(entry) >= 1 && entry[0]&0x06 == 0 {
+				name = append(name, string(entry[1:]))
+				hasName = true
+			}
+		case "PX":
+			if len(entry) >= 8 {
+				rec.mode = posixMode(binary.LittleEndian.Uint32(entry))
+			}
+		case "SL":
+			// 组件标志: 0x01 续接, 0x02 当前目录, 0x04 上级目录, 0x08 根目录(内容为空)
+			for c := entry[1:]; len(c) >= 2 && 2+int(c[1]) <= len(c); c = c[2+int(c[1]):] {
+				switch {
+				case c[0]&0x02 != 0:
+					linkComp.WriteString(".")
+				case c[0]&0x04 != 0:
+					linkComp.WriteString("..")
+				default:
+					linkComp.Write(c[2 : 2+int(c[1])])
+				}
+				if c[0]&0x01 == 0 {
+					link = append(link, linkComp.String())
+					linkComp.Reset()
+				}
+			}
+		case "CE":
+			// 延续区域位于一个逻辑块内
+			if len(entry) >= 24 && depth < 8 && int64(binary.LittleEndian.Uint32(entry[16:])) <= iso.blockSize {
+				pos := int64(binary.LittleEndian.Uint32(entry))*iso.blockSize + int64(binary.LittleEndian.Uint32(entry[8:]))
+				area := make([]byte, binary.LittleEndian.Uint32(entry[16:]))
+				if _, err := iso.r.ReadAt(area, pos); err == nil {
+					iso.parseRockRidge(area, rec, depth+1)
+				}
+			}
+		case "ST":
+			su = nil
+			continue
+		}
+		su = su[n:]
+	}
+	if hasName {
+		rec.name = strings.Join(name, "")
+	}
+	if len(link) > 0 {
+		rec.link = strings.Join(link, "/")
+		if link[0] == "" {
+			rec.link = "/" + strings.TrimPrefix(rec.link, "/")
+		}
+	}
+}
+
+// posixMode 将 POSIX 的 st_mode 转换为 os.FileMode
+func posixMode(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0777)
+	switch m & posixTypeMask {
+	case posixDir:
+		mode |= os.ModeDir
+	case posixLink:
+		mode |= os.ModeSymlink
+	case posixRegular:
+	default:
+		mode |= os.ModeIrregular
+	}
+	return mode
+}
+
+// readDir 读取目录中的记录, 跳过 . 与 .., 记录不跨扇区, 长度为 0 时跳到下一个扇区
+func (iso *ISO9660) readDir(dir isoRecord) ([]isoRecord, error) {
+	// 目录大小来自镜像, 按实际读到的数据分配内存
+	offset := dir.extent * iso.blockSize
+	if offset > iso.volume || dir.size > iso.volume-offset {
+		return nil, errISO9660
+	}
+	data, err := ioutil.ReadAll(io.NewSectionReader(iso.r, offset, dir.size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != dir.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var res []isoRecord
+	for pos := 0; pos < len(data); {
+		if data[pos] == 0 {
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		rec, ok := iso.parseRecord(data[pos:])
+		if !ok {
+			return nil, errISO9660
+		}
+		pos += int(data[pos])
+		if rec.name == "." || rec.name == ".." {
+			continue
+		}
+		if rec.flags&isoFlagMultiExtent != 0 {
+			return nil, errors.New("multi-extent files in iso9660 image are not supported")
+		}
+		res = append(res, rec)
+	}
+	return res, nil
+}
+
+func (iso *ISO9660) entry(name string, rec isoRecord) *ISO9660Entry {
+	e := &ISO9660Entry{Path: name, Mode: rec.mode, Link: rec.link, r: iso.r}
+	if rec.mode.IsRegular() {
+		e.Size = rec.size
+		e.offset = rec.extent * iso.blockSize
+	}
+	return e
+}
+
+// Walk 按目录顺序遍历所有条目, 包括目录本身, 根目录除外
+func (iso *ISO9660) Walk(fn func(e *ISO9660Entry) error) error {
+	return iso.walkDir("/", iso.root, map[int64]bool{iso.root.extent: true}, fn)
+}
+
+// walkDir visited 为已遍历目录的起始块, 目录不能硬链接, 重复出现时镜像中有环
+func (iso *ISO9660) walkDir(dir string, rec isoRecord, visited map[int64]bool, fn func(e *ISO9660Entry) error) error {
+	records, err := iso.readDir(rec)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		name := path.Join(dir, r.name)
+		if err := fn(iso.entry(name, r)); err != nil {
+			return err
+		}
+		if r.mode.IsDir() {
+			if visited[r.extent] {
+				return fmt.Errorf("iso9660 directory loop at %s", name)
+			}
+			visited[r.extent] = true
+			if err := iso.walkDir(name, r, visited, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFile 读取镜像中的一个普通文件, 不跟随符号链接, 文件不存在时返回 os.ErrNotExist
+func (iso *ISO9660) ReadFile(name string) ([]byte, error) {
+	rec := iso.root
+	for _, part := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		records, err := iso.readDir(rec)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, r := range records {
+			if r.name == part {
+				rec, found = r, true
+				break
+			}
+		}
+		if !found {
+			return nil, os.ErrNotExist
+		}
+	}
+	if !rec.mode.IsRegular() {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.ReadAll(iso.entry(name, rec).Open())
+}
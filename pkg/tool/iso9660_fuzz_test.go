@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func FuzzISO9660(f *testing.F) {
+	data, err := ioutil.ReadFile("testdata/appimage/type1.AppImage")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		iso, err := OpenISO9660(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		iso.Walk(func(e *ISO9660Entry) error {
+			n, err := io.Copy(ioutil.Discard, e.Open())
+			if err == nil && n != e.Size {
+				t.Fatalf("%s: read %d bytes, size %d", e.Path, n, e.Size)
+			}
+			return nil
+		})
+		iso.ReadFile("usr/bin/hello")
+	})
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// type1.AppImage 为 type 1 的 AppImage, 系统区为运行时 ELF, 目录记录带 Rock Ridge 扩展,
+// 其中的长文件名放在 CE 续接区, 符号链接目标拆分为多个 SL 组件
+func readTestISO(t *testing.T) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/appimage/type1.AppImage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func walkTestISO(data []byte) ([]string, error) {
+	iso, err := OpenISO9660(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var got []string
+	err = iso.Walk(func(e *ISO9660Entry) error {
+		s := fmt.Sprintf("%s %v", e.Path, e.Mode)
+		switch {
+		case e.Mode.IsRegular():
+			content, err := ioutil.ReadAll(e.Open())
+			if err != nil {
+				return err
+			}
+			if int64(len(content)) != e.Size {
+				return fmt.Errorf("%s: read %d bytes, want %d", e.Path, len(content), e.Size)
+			}
+			s += fmt.Sprintf(" %d", e.Size)
+		case e.Mode&os.ModeSymlink != 0:
+			s += " -> " + e.Link
+		}
+		got = append(got, s)
+		return nil
+	})
+	return got, err
+}
+
+func TestISO9660Walk(t *testing.T) {
+	data := readTestISO(t)
+	if !IsISO9660(bytes.NewReader(data)) {
+		t.Fatal("IsISO9660() = false")
+	}
+	got, err := walkTestISO(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/AppRun -rwxr-xr-x 44",
+		"/hello.desktop Lrwxrwxrwx -> usr/share/applications/hello.desktop",
+		"/usr drwxr-xr-x",
+		"/usr/bin drwxr-xr-x",
+		"/usr/bin/hello -rwxr-xr-x 21",
+		"/usr/lib drwxr-xr-x",
+		"/usr/lib/libfoo.so.1 Lrwxrwxrwx -> libfoo.so.1.2.3",
+		"/usr/lib/libfoo.so.1.2.3 -rw-r--r-- 15",
+		"/usr/lib/readme.txt -rw-r--r-- 10",
+		"/usr/lib/x86_64-linux-gnu drwxr-xr-x",
+		"/usr/lib/x86_64-linux-gnu/libbar.so.0 -rw-r--r-- 15",
+		"/usr/share drwxr-xr-x",
+		"/usr/share/applications drwxr-xr-x",
+		"/usr/share/applications/hello.desktop -rw-r--r-- 134",
+		"/usr/share/doc drwxr-xr-x",
+		"/usr/share/doc/averyveryveryverylongfilename_for_continuation_area_test.txt -rw-r--r-- 5",
+		"/usr/share/metainfo drwxr-xr-x",
+		"/usr/share/metainfo/hello.appdata.xml -rw-r--r-- 351",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestISO9660ReadFile(t *testing.T) {
+	iso, err := OpenISO9660(bytes.NewReader(readTestISO(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := iso.ReadFile("usr/bin/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(hello) != "#!/bin/sh\necho hello\n" {
+		t.Errorf("usr/bin/hello = %q", hello)
+	}
+	for _, name := range []string{"usr/missing", "hello.desktop", "usr/bin"} {
+		if _, err := iso.ReadFile(name); !os.IsNotExist(err) {
+			t.Errorf("ReadFile(%q) error = %v, want not exist", name, err)
+		}
+	}
+}
+
+func TestISO9660Corrupt(t *testing.T) {
+	data := readTestISO(t)
+	// /usr 目录记录中的起始块(小端与大端各一份)改为根目录所在的第 20 块
+	loop := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(loop[41338:], 20)
+	binary.BigEndian.PutUint32(loop[41342:], 20)
+	noBlockSize := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(noBlockSize[isoDescriptorStart+128:], 0)
+	tinyVolume := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(tinyVolume[isoDescriptorStart+80:], 20)
+	badRecord := append([]byte(nil), data...)
+	// 根目录中第一条记录的长度短于记录头
+	badRecord[20*isoSectorSize] = 33
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no descriptor", data[:isoDescriptorStart]},
+		{"truncated directory", data[:20*isoSectorSize+100]},
+		{"directory loop", loop},
+		{"zero block size", noBlockSize},
+		{"directory outside volume", tinyVolume},
+		{"bad record length", badRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := walkTestISO(tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestISO9660TruncatedFile 文件内容超出镜像末尾时报错, 而不是返回其中的一部分
+func TestISO9660TruncatedFile(t *testing.T) {
+	data := readTestISO(t)
+	// hello.appdata.xml 位于最后一个扇区
+	iso, err := OpenISO9660(bytes.NewReader(data[:len(data)-isoSectorSize+100]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := iso.ReadFile("usr/share/metainfo/hello.appdata.xml"); err == nil {
+		t.Errorf("expected an error, read %d bytes", len(content))
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import "strings"
+
+// KeyFileGroup GKeyFile 格式(如 flatpak metadata、.desktop 文件)中的一组键值
+type KeyFileGroup struct {
+	Name    string
+	Entries []KeyFileEntry //按文件中的顺序
+}
+
+type KeyFileEntry struct {
+	Key   string
+	Value string
+}
+
+// Get 返回键的值, 不存在时为空
+func (g *KeyFileGroup) Get(key string) string {
+	for _, e := range g.Entries {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// ParseKeyFile 解析 GKeyFile 格式, 忽略注释及组外的键值
+func ParseKeyFile(data []byte) []KeyFileGroup {
+	var groups []KeyFileGroup
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			groups = append(groups, KeyFileGroup{Name: line[1 : len(line)-1]})
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx <= 0 || len(groups) == 0 {
+			continue
+		}
+		g := &groups[len(groups)-1]
+		g.Entries = append(g.Entries, KeyFileEntry{
+			Key:   strings.TrimSpace(line[:idx]),
+			Value: strings.TrimSpace(line[idx+1:]),
+		})
+	}
+	return groups
+}
+
+// KeyFileGroupByName 返回指定名称的组, 不存在时为 nil
+func KeyFileGroupByName(groups []KeyFileGroup, name string) *KeyFileGroup {
+	for i := range groups {
+		if groups[i].Name == name {
+			return &groups[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,439 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/licensecheck"
+)
+
+// licensecheck 中不属于 SPDX 许可证列表的 ID
+var nonSPDXLicenseIDs = map[string]bool{
+	"Aladdin-9":                true,
+	"Anti996":                  true,
+	"BSD-3-Clause-NoTrademark": true,
+	"CommonsClause":            true,
+	"GPL-2.0-or-3.0":           true,
+	"GooglePatentClause":       true,
+	"GooglePatentsFile":        true,
+	"MIT-NoAd":                 true,
+}
+
+// licensecheck 中没有, 但在软件包中常见的 SPDX 许可证 ID
+var extraSPDXLicenseIDs = []string{
+	"Bitstream-Vera",
+	"GFDL-1.1-only", "GFDL-1.1-or-later",
+	"GFDL-1.2-only", "GFDL-1.2-or-later",
+	"GFDL-1.3-only", "GFDL-1.3-or-later",
+	"Linux-man-pages-copyleft",
+	"MIT-Modern-Variant",
+	"MIT-open-group",
+	"OFL-1.1-RFN", "OFL-1.1-no-RFN",
+	"Python-2.0.1",
+	"Ubuntu-font-1.0",
+	"Unicode-3.0",
+}
+
+// 常见的 SPDX 许可证例外 ID
+var spdxExceptionIDs = []string{
+	"389-exception",
+	"Autoconf-exception-2.0",
+	"Autoconf-exception-3.0",
+	"Bison-exception-2.2",
+	"Bootloader-exception",
+	"Classpath-exception-2.0",
+	"CLISP-exception-2.0",
+	"DigiRule-FOSS-exception",
+	"eCos-exception-2.0",
+	"Fawkes-Runtime-exception",
+	"FLTK-exception",
+	"Font-exception-2.0",
+	"freertos-exception-2.0",
+	"GCC-exception-2.0",
+	"GCC-exception-3.1",
+	"gnu-javamail-exception",
+	"GPL-3.0-linking-exception",
+	"GPL-3.0-linking-source-exception",
+	"GPL-CC-1.0",
+	"i2p-gpl-java-exception",
+	"Libtool-exception",
+	"Linux-syscall-note",
+	"LLVM-exception",
+	"LZMA-exception",
+	"mif-exception",
+	"Nokia-Qt-exception-1.1",
+	"OCaml-LGPL-linking-exception",
+	"OCCT-exception-1.0",
+	"openvpn-openssl-exception",
+	"PS-or-PDF-font-exception-20170817",
+	"Qt-GPL-exception-1.0",
+	"Qt-LGPL-exception-1.1",
+	"Qwt-exception-1.0",
+	"Swift-exception",
+	"u-boot-exception-2.0",
+	"Universal-FOSS-exception-1.0",
+	"WxWindows-exception-3.1",
+	"x11vnc-openssl-exception",
+}
+
+// Debian 与 Fedora 中常用的许可证简称, 键为小写;
+// GPL 系列的简称由 gnuLicenseRegexp 处理, 不在此列出
+var licenseAliases = map[string]string{
+	"artistic":       "Artistic-1.0",
+	"asl 1.1":        "Apache-1.1",
+	"asl 2.0":        "Apache-2.0",
+	"boost":          "BSL-1.0",
+	"cc0":            "CC0-1.0",
+	"expat":          "MIT",
+	"gpl-2.0-or-3.0": "GPL-2.0-only OR GPL-3.0-only",
+	"mit/x11":        "MIT",
+	"perl":           "Artistic-1.0-Perl OR GPL-1.0-or-later",
+	"psf":            "PSF-2.0",
+	"python":         "Python-2.0",
+	"zope":           "ZPL-2.1",
+}
+
+// 带版本号的许可证族简称, 键为小写, 如 Zope-2.1 对应 ZPL-2.1
+var licenseFamilyAliases = map[string]string{
+	"zope": "ZPL",
+	"asl":  "Apache",
+}
+
+var (
+	spdxLicenseIDs   = make(map[string]string) //小写 ID 到规范写法
+	spdxExceptionMap = make(map[string]string)
+)
+
+func init() {
+	for _, l := range licensecheck.BuiltinLicenses() {
+		if !nonSPDXLicenseIDs[l.ID] {
+			spdxLicenseIDs[strings.ToLower(l.ID)] = l.ID
+		}
+	}
+	for _, id := range extraSPDXLicenseIDs {
+		spdxLicenseIDs[strings.ToLower(id)] = id
+	}
+	for _, id := range spdxExceptionIDs {
+		spdxExceptionMap[strings.ToLower(id)] = id
+	}
+}
+
+// GPL 系列的简称, 如 GPL-2+、LGPL-2.1、GPLv3+、GFDL-NIV-1.2、GPL-2.0-or-later
+var gnuLicenseRegexp = regexp.MustCompile(`(?i)^(AGPL|LGPL|GPL|GFDL)(-NIV)?(?:-?v?(\d+(?:\.\d+)?))?(\+|-or-later|-only)?$`)
+
+var invalidLicenseRefChars = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// gnuLicenseID 将 GPL 系列简称转换为 SPDX ID, 如 GPL-2+ 转换为 GPL-2.0-or-later
+func gnuLicenseID(name string) (string, bool) {
+	m := gnuLicenseRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	family, niv, version, suffix := strings.ToUpper(m[1]), m[2] != "", m[3], strings.ToLower(m[4])
+	orLater := suffix == "+" || suffix == "-or-later"
+	if version == "" {
+		// 没有版本号时只有 GPL+ 这类写法可以确定为任意版本
+		if !orLater {
+			return "", false
+		}
+		version = "1.0"
+		if family == "LGPL" {
+			version = "2.0"
+		} else if family == "GFDL" {
+			version = "1.1"
+		}
+	}
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+	id := family + "-" + version
+	if niv {
+		id += "-no-invariants"
+	}
+	if orLater {
+		id += "-or-later"
+	} else {
+		id += "-only"
+	}
+	if canonical, ok := spdxLicenseIDs[strings.ToLower(id)]; ok {
+		return canonical, true
+	}
+	return "", false
+}
+
+// lookupLicenseID 查找许可证 ID 的规范写法, 简称中的版本号可以省略 .0, 如 Apache-2、CC-BY-SA-3
+func lookupLicenseID(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	if id, ok := spdxLicenseIDs[lower]; ok {
+		return id, true
+	}
+	for alias, family := range licenseFamilyAliases {
+		if strings.HasPrefix(lower, alias+"-") || strings.HasPrefix(lower, alias+" ") {
+			lower = strings.ToLower(family) + "-" + lower[len(alias)+1:]
+			break
+		}
+	}
+	for _, candidate := range []string{lower, lower + ".0"} {
+		if id, ok := spdxLicenseIDs[candidate]; ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// lookupExceptionID 查找许可证例外的规范写法, 没有版本号时只在唯一匹配时使用, 如 Font-exception 对应 Font-exception-2.0
+func lookupExceptionID(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	if id, ok := spdxExceptionMap[lower]; ok {
+		return id, true
+	}
+	var found string
+	for key, id := range spdxExceptionMap {
+		if strings.HasPrefix(key, lower+"-") {
+			if found != "" {
+				return "", false
+			}
+			found = id
+		}
+	}
+	return found, found != ""
+}
+
+// normalizeLicenseName 将单个许可证名称转换为 SPDX ID 或表达式, 无法识别时返回 false
+func normalizeLicenseName(name string) (string, bool) {
+	if id, ok := gnuLicenseID(name); ok {
+		return id, true
+	}
+	if expr, ok := licenseAliases[strings.ToLower(name)]; ok {
+		return expr, true
+	}
+	if id, ok := lookupLicenseID(name); ok {
+		return id, true
+	}
+	// GPL 系列以外的许可证使用 SPDX 的 + 表示该版本或更新版本
+	if strings.HasSuffix(name, "+") {
+		if id, ok := lookupLicenseID(strings.TrimSuffix(name, "+")); ok {
+			return id + "+", true
+		}
+	}
+	return "", false
+}
+
+// ExtractedLicense 不在 SPDX 许可证列表中的许可证, 对应 SPDX 的 LicenseRef-
+type ExtractedLicense struct {
+	ID   string //LicenseRef-xxx
+	Name string //原始名称
+	Text string //许可证全文, 没有时为空
+}
+
+// LicenseNormalizer 将 Debian 简称、licensecheck 的 ID 等转换为合法的 SPDX 许可证表达式,
+// 无法识别的许可证转换为 LicenseRef-, 并记录其原始名称与全文
+type LicenseNormalizer struct {
+	texts     map[string]string
+	extracted []ExtractedLicense
+	refs      map[string]bool
+}
+
+// NewLicenseNormalizer 创建转换器, texts 为许可证名称对应的全文, 如 DEP-5 中独立的 License 段落
+func NewLicenseNormalizer(texts map[string]string) *LicenseNormalizer {
+	n := &LicenseNormalizer{texts: make(map[string]string), refs: make(map[string]bool)}
+	for name, text := range texts {
+		n.texts[strings.ToLower(name)] = text
+		// DEP-5 表达式中的 with X exception 已转换为 WITH X-exception
+		n.texts[strings.ToLower(Dep5LicenseExpression(name))] = text
+	}
+	return n
+}
+
+// licenseRef 为无法识别的许可证生成 LicenseRef-, 并记录原始名称与全文
+func (n *LicenseNormalizer) licenseRef(name string) string {
+	s := strings.Replace(name, "+", "-or-later", -1)
+	s = strings.Trim(invalidLicenseRefChars.ReplaceAllString(s, "-"), "-")
+	if s == "" {
+		s = "unknown"
+	}
+	id := "LicenseRef-" + s
+	if !n.refs[id] {
+		n.refs[id] = true
+		n.extracted = append(n.extracted, ExtractedLicense{ID: id, Name: name, Text: n.texts[strings.ToLower(name)]})
+	}
+	return id
+}
+
+// atom 转换单个许可证及其例外, exception 为空时表示没有 WITH
+func (n *LicenseNormalizer) atom(name, exception string) string {
+	switch {
+	case name == "NOASSERTION" || name == "NONE":
+		return name
+	case strings.HasPrefix(name, "LicenseRef-") || strings.HasPrefix(name, "DocumentRef-"):
+		if exception == "" {
+			return name
+		}
+	}
+	id, ok := normalizeLicenseName(name)
+	if exception == "" {
+		if !ok {
+			return n.licenseRef(name)
+		}
+		return id
+	}
+	// WITH 左侧只能是单个许可证, 例外也必须在 SPDX 例外列表中, 否则整体作为一个 LicenseRef
+	exceptionID, found := lookupExceptionID(exception)
+	if ok && found && !strings.Contains(id, " ") {
+		return id + " WITH " + exceptionID
+	}
+	return n.licenseRef(name + " WITH " + exception)
+}
+
+func tokenizeLicense(expr string) []string {
+	expr = strings.Replace(expr, "(", " ( ", -1)
+	expr = strings.Replace(expr, ")", " ) ", -1)
+	return strings.Fields(expr)
+}
+
+func isLicenseOperator(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "AND", "OR", "WITH", "(", ")":
+		return true
+	}
+	return false
+}
+
+// Expression 将许可证表达式转换为合法的 SPDX 表达式; and/or/with 不区分大小写,
+// 相邻的多个单词视为一个许可证名称, 如 rpm 中的 "ASL 2.0 and MIT"
+func (n *LicenseNormalizer) Expression(expr string) string {
+	tokens := tokenizeLicense(expr)
+	var out []string
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		if isLicenseOperator(tok) {
+			out = append(out, strings.ToUpper(tok))
+			i++
+			continue
+		}
+		// 读取许可证名称以及可能的 WITH 例外
+		readName := func() string {
+			var words []string
+			for i < len(tokens) && !isLicenseOperator(tokens[i]) {
+				words = append(words, tokens[i])
+				i++
+			}
+			return strings.Join(words, " ")
+		}
+		name := readName()
+		var exception string
+		if i+1 < len(tokens) && strings.EqualFold(tokens[i], "WITH") && !isLicenseOperator(tokens[i+1]) {
+			i++
+			exception = readName()
+		}
+		atom := n.atom(name, exception)
+		if strings.Contains(atom, " OR ") || strings.Contains(atom, " AND ") {
+			if len(tokens) > 1 {
+				atom = "(" + atom + ")"
+			}
+		}
+		out = append(out, atom)
+	}
+	// 去掉单个许可证外多余的括号, 如 DEP-5 汇总时加上的 (GPL-2+ WITH X-exception) 转换为 LicenseRef- 后
+	// 遇到 ) 时与前两项组成 ( X ) 则合并, 嵌套的括号逐层去掉
+	var merged []string
+	for _, tok := range out {
+		if k := len(merged); tok == ")" && k >= 2 && merged[k-2] == "(" {
+			merged = append(merged[:k-2], merged[k-1])
+			continue
+		}
+		merged = append(merged, tok)
+	}
+	out = merged
+	// 括号不配对、运算符缺少操作数等无法组成合法表达式时, 整体作为一个 LicenseRef
+	if len(out) > 0 && !wellFormedLicense(tokenizeLicense(strings.Join(out, " "))) {
+		return n.licenseRef(strings.Join(tokens, " "))
+	}
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(strings.Join(out, " "))
+}
+
+var licenseIDRegexp = regexp.MustCompile(`^[A-Za-z0-9.\-]+\+?$`)
+
+// wellFormedLicense 检查转换后的记号是否组成合法的 SPDX 表达式:
+// 表达式为以 AND、OR 连接的项, 项为括号中的表达式或带有可选 WITH 例外的许可证, 例外须在 SPDX 例外列表中
+func wellFormedLicense(tokens []string) bool {
+	pos := 0
+	var expr func() bool
+	id := func() bool {
+		if pos < len(tokens) && !isLicenseOperator(tokens[pos]) && licenseIDRegexp.MatchString(tokens[pos]) {
+			pos++
+			return true
+		}
+		return false
+	}
+	term := func() bool {
+		if pos < len(tokens) && tokens[pos] == "(" {
+			pos++
+			if !expr() || pos >= len(tokens) || tokens[pos] != ")" {
+				return false
+			}
+			pos++
+			return true
+		}
+		if !id() {
+			return false
+		}
+		if pos < len(tokens) && tokens[pos] == "WITH" {
+			pos++
+			if pos >= len(tokens) {
+				return false
+			}
+			_, ok := spdxExceptionMap[strings.ToLower(tokens[pos])]
+			pos++
+			return ok
+		}
+		return true
+	}
+	expr = func() bool {
+		if !term() {
+			return false
+		}
+		for pos < len(tokens) && (tokens[pos] == "AND" || tokens[pos] == "OR") {
+			pos++
+			if !term() {
+				return false
+			}
+		}
+		return true
+	}
+	return expr() && pos == len(tokens)
+}
+
+// LicenseIDs 转换许可证列表, 表达式拆分为单个许可证并去掉例外, 去重后排序,
+// 用于文件中出现的许可证等只能填写单个许可证的场合
+func (n *LicenseNormalizer) LicenseIDs(licenses []string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, l := range licenses {
+		tokens := tokenizeLicense(n.Expression(l))
+		for i := 0; i < len(tokens); i++ {
+			switch tok := tokens[i]; tok {
+			case "AND", "OR", "(", ")":
+			case "WITH":
+				i++
+			default:
+				if !seen[tok] {
+					seen[tok] = true
+					ids = append(ids, tok)
+				}
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ExtractedLicenses 返回转换过程中生成的 LicenseRef-, 按生成顺序排列
+func (n *LicenseNormalizer) ExtractedLicenses() []ExtractedLicense {
+	return n.extracted
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import "testing"
+
+func FuzzLicenseNormalizerExpression(f *testing.F) {
+	for _, s := range []string{"GPL-2+ or Artistic", "ASL 2.0 and MIT", "(MIT OR Apache-2.0) AND Zlib", "GPL-3+ WITH Font-exception", "MIT WITH"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, expr string) {
+		n := NewLicenseNormalizer(nil)
+		got := n.Expression(expr)
+		tokens := tokenizeLicense(got)
+		if len(tokens) == 0 {
+			if len(tokenizeLicense(expr)) != 0 {
+				t.Fatalf("Expression(%q) is empty", expr)
+			}
+			return
+		}
+		if !wellFormedLicense(tokens) {
+			t.Fatalf("Expression(%q) = %q is not a valid expression", expr, got)
+		}
+		if again := n.Expression(got); again != got {
+			t.Fatalf("Expression(%q) = %q, normalizing again gives %q", expr, got, again)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 超过该大小的文件不做许可证扫描
+const licenseScanMaxSize = 1024 * 1024
+
+// 只显示版权行的前若干字符
+const copyrightLineMaxLen = 200
+
+// FileLicenseScan 单个文件的许可证扫描结果
+type FileLicenseScan struct {
+	Licenses   []string //licensecheck 识别出的许可证与 SPDX-License-Identifier 标签中的许可证
+	Copyrights []string //版权声明行
+}
+
+var spdxTagRegexp = regexp.MustCompile(`SPDX-License-Identifier:\s*(.+?)\s*(?:\*/|-->|$)`)
+
+// 以 Copyright、(c) 或 © 开头并带有年份的行
+var copyrightRegexp = regexp.MustCompile(`(?i)^(?:copyright|\(c\)|©).*\b(?:19|20)\d{2}\b`)
+
+// isTextContent 与 git 一样, 前 8000 字节中没有 NUL 字节即认为是文本
+func isTextContent(data []byte) bool {
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return len(data) > 0 && bytes.IndexByte(head, 0) < 0
+}
+
+// trimCommentLeader 去掉行首的注释符号, 如 #、//、/*、*、;、--、dnl
+func trimCommentLeader(line string) string {
+	line = strings.TrimSpace(line)
+	for _, leader := range []string{"dnl ", "//", "/*", "#", "*", ";", "--", "%", "\""} {
+		if strings.HasPrefix(line, leader) {
+			line = strings.TrimSpace(strings.TrimPrefix(line, leader))
+		}
+	}
+	return strings.TrimSpace(strings.TrimSuffix(line, "*/"))
+}
+
+// spdxTagLicenses 返回 SPDX-License-Identifier 标签表达式中的各个许可证, 不包括 WITH 后的例外
+func spdxTagLicenses(expr string) []string {
+	var licenses []string
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+		case "WITH":
+			i++
+		default:
+			licenses = append(licenses, fields[i])
+		}
+	}
+	return licenses
+}
+
+// ScanLicenses 扫描文本中的许可证与版权行, 非文本内容返回 nil
+func ScanLicenses(data []byte) *FileLicenseScan {
+	if !isTextContent(data) {
+		return nil
+	}
+	licenses := make(map[string]bool)
+	for _, l := range GetLicensesFromText(data) {
+		licenses[l] = true
+	}
+
+	res := &FileLicenseScan{}
+	seen := make(map[string]bool)
+	scn := bufio.NewScanner(bytes.NewReader(data))
+	scn.Buffer(make([]byte, 64*1024), licenseScanMaxSize)
+	for scn.Scan() {
+		line := scn.Text()
+		if m := spdxTagRegexp.FindStringSubmatch(line); m != nil {
+			for _, l := range spdxTagLicenses(m[1]) {
+				licenses[l] = true
+			}
+			continue
+		}
+		line = trimCommentLeader(line)
+		if !copyrightRegexp.MatchString(line) || seen[line] {
+			continue
+		}
+		seen[line] = true
+		if r := []rune(line); len(r) > copyrightLineMaxLen {
+			line = string(r[:copyrightLineMaxLen])
+		}
+		res.Copyrights = append(res.Copyrights, line)
+	}
+	for l := range licenses {
+		res.Licenses = append(res.Licenses, l)
+	}
+	sort.Strings(res.Licenses)
+	if len(res.Licenses) == 0 && len(res.Copyrights) == 0 {
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLicenseNormalizerExpression(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"GPL-2+", "GPL-2.0-or-later"},
+		{"GPL-2", "GPL-2.0-only"},
+		{"GPLv3+", "GPL-3.0-or-later"},
+		{"LGPL-2.1", "LGPL-2.1-only"},
+		{"GPL-2.0-or-later", "GPL-2.0-or-later"},
+		{"GFDL-NIV-1.2", "GFDL-1.2-no-invariants-only"},
+		{"GPL+", "GPL-1.0-or-later"},
+		{"Apache-2", "Apache-2.0"},
+		{"ASL 2.0 and MIT", "Apache-2.0 AND MIT"},
+		{"BSD-3-clause", "BSD-3-Clause"},
+		{"Expat", "MIT"},
+		{"Zope-2.1", "ZPL-2.1"},
+		{"MPL-1.1+", "MPL-1.1+"},
+		{"mit or bsd-2-clause", "MIT OR BSD-2-Clause"},
+		{"GPL-2+ or Artistic", "GPL-2.0-or-later OR Artistic-1.0"},
+		{"GPL-3+ WITH Font-exception", "GPL-3.0-or-later WITH Font-exception-2.0"},
+		{"(MIT OR Apache-2.0) AND Zlib", "(MIT OR Apache-2.0) AND Zlib"},
+		{"NOASSERTION", "NOASSERTION"},
+		{"LicenseRef-Foo", "LicenseRef-Foo"},
+		// 无法识别的许可证与例外
+		{"GPL", "LicenseRef-GPL"},
+		{"public-domain", "LicenseRef-public-domain"},
+		{"some weird/license!", "LicenseRef-some-weird-license"},
+		{"(GPL-2+ WITH X-exception)", "LicenseRef-GPL-2-or-later-WITH-X-exception"},
+		// 不完整的表达式整体作为 LicenseRef
+		{"GPL-2 and", "LicenseRef-GPL-2-and"},
+		{"MIT WITH", "LicenseRef-MIT-WITH"},
+		{"((", "LicenseRef-unknown"},
+		{"0 WITH 0 WITH 0", "LicenseRef-0-WITH-0-WITH-0"},
+	}
+	for _, tt := range tests {
+		n := NewLicenseNormalizer(nil)
+		if got := n.Expression(tt.in); got != tt.want {
+			t.Errorf("Expression(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLicenseNormalizerExtracted(t *testing.T) {
+	n := NewLicenseNormalizer(map[string]string{"Custom": "custom text"})
+	n.Expression("Custom or MIT")
+	n.Expression("Custom")
+	n.Expression("public-domain")
+	want := []ExtractedLicense{
+		{ID: "LicenseRef-Custom", Name: "Custom", Text: "custom text"},
+		{ID: "LicenseRef-public-domain", Name: "public-domain"},
+	}
+	if got := n.ExtractedLicenses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractedLicenses() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLicenseNormalizerLicenseIDs(t *testing.T) {
+	n := NewLicenseNormalizer(nil)
+	got := n.LicenseIDs([]string{"GPL-2+ or Artistic", "MIT WITH Font-exception", "MIT", "Expat"})
+	want := []string{"Artistic-1.0", "GPL-2.0-or-later", "MIT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LicenseIDs() = %q, want %q", got, want)
+	}
+}
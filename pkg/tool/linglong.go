@@ -0,0 +1,276 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 玲珑 layer 文件以 NUL 填充到 40 字节的魔数开头, 其后为 4 字节小端的元数据长度、JSON 元数据及文件系统镜像
+const (
+	LinglongLayerMagic     = "<<< deepin linglong layer archive >>>"
+	linglongLayerMagicSize = 40
+	linglongLayerHeader    = linglongLayerMagicSize + 4
+	linglongMaxMetaSize    = 16 << 20
+)
+
+// UAB 为 ELF 加载器, 元数据及 bundle 镜像位于其中的节
+const (
+	LinglongUABMetaSection   = "linglong.meta"
+	LinglongUABBundleSection = "linglong.bundle"
+)
+
+// 玲珑包中的文件
+const (
+	LinglongInfoJSON = "info.json"
+	LinglongFilesDir = "files"
+)
+
+// LinglongPackageInfo 玲珑包的 info.json, 兼容旧版本的 appid 与 module 字段
+type LinglongPackageInfo struct {
+	ID            string
+	Name          string
+	Version       string
+	Arch          []string
+	Kind          string //app、runtime、base 或 extension
+	Module        string //binary 或 develop
+	Channel       string
+	Base          string //如 main:org.deepin.base/23.1.0/x86_64
+	Runtime       string
+	Description   string
+	Command       []string
+	Size          int64
+	SchemaVersion string
+}
+
+// linglongRawInfo info.json 的原始字段, arch 在部分版本中为字符串
+type linglongRawInfo struct {
+	ID            string          `json:"id"`
+	AppID         string          `json:"appid"`
+	Name          string          `json:"name"`
+	Version       string          `json:"version"`
+	Arch          json.RawMessage `json:"arch"`
+	Kind          string          `json:"kind"`
+	Module        string          `json:"packageInfoV2Module"`
+	OldModule     string          `json:"module"`
+	Channel       string          `json:"channel"`
+	Base          string          `json:"base"`
+	Runtime       string          `json:"runtime"`
+	Description   string          `json:"description"`
+	Command       []string        `json:"command"`
+	Size          int64           `json:"size"`
+	SchemaVersion string          `json:"schema_version"`
+}
+
+func (raw *linglongRawInfo) info() (*LinglongPackageInfo, error) {
+	info := &LinglongPackageInfo{
+		ID:            raw.ID,
+		Name:          raw.Name,
+		Version:       raw.Version,
+		Kind:          raw.Kind,
+		Module:        raw.Module,
+		Channel:       raw.Channel,
+		Base:          raw.Base,
+		Runtime:       raw.Runtime,
+		Description:   raw.Description,
+		Command:       raw.Command,
+		Size:          raw.Size,
+		SchemaVersion: raw.SchemaVersion,
+	}
+	if info.ID == "" {
+		info.ID = raw.AppID
+	}
+	if info.Module == "" {
+		info.Module = raw.OldModule
+	}
+	if len(raw.Arch) > 0 {
+		if err := json.Unmarshal(raw.Arch, &info.Arch); err != nil {
+			var arch string
+			if json.Unmarshal(raw.Arch, &arch) != nil {
+				return nil, fmt.Errorf("invalid linglong arch: %s", raw.Arch)
+			}
+			info.Arch = []string{arch}
+		}
+	}
+	if info.ID == "" || info.Version == "" {
+		return nil, errors.New("linglong package info has no id or version")
+	}
+	return info, nil
+}
+
+// ParseLinglongPackageInfo 解析 info.json
+func ParseLinglongPackageInfo(data []byte) (*LinglongPackageInfo, error) {
+	var raw linglongRawInfo
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw.info()
+}
+
+// LinglongRef base、runtime 引用, 形如 main:org.deepin.base/23.1.0/x86_64, 渠道与架构可以省略
+type LinglongRef struct {
+	Channel string
+	ID      string
+	Version string
+	Arch    string
+}
+
+// ParseLinglongRef 解析 [channel:]id[/version[/arch]] 形式的引用
+func ParseLinglongRef(ref string) (LinglongRef, error) {
+	var r LinglongRef
+	if i := strings.Index(ref, ":"); i >= 0 {
+		r.Channel, ref = ref[:i], ref[i+1:]
+	}
+	parts := strings.Split(ref, "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return r, errors.New("invalid linglong ref: " + ref)
+	}
+	r.ID = parts[0]
+	if len(parts) > 1 {
+		r.Version = parts[1]
+	}
+	if len(parts) > 2 {
+		r.Arch = parts[2]
+	}
+	return r, nil
+}
+
+// LinglongLayer layer 文件的元数据及文件系统镜像的位置
+type LinglongLayer struct {
+	Info          *LinglongPackageInfo
+	Version       string //layer 格式版本
+	PayloadOffset int64
+}
+
+// IsLinglongLayer 判断文件是否以 layer 魔数开头
+func IsLinglongLayer(r io.ReaderAt) bool {
+	magic := make([]byte, linglongLayerMagicSize)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return string(trimNUL(magic)) == LinglongLayerMagic
+}
+
+// ReadLinglongLayer 读取 layer 文件头部的 JSON 元数据, 文件系统镜像紧随其后
+func ReadLinglongLayer(r io.ReaderAt, size int64) (*LinglongLayer, error) {
+	if !IsLinglongLayer(r) {
+		return nil, errors.New("not a linglong layer")
+	}
+	hdr := make([]byte, 4)
+	if _, err := r.ReadAt(hdr, linglongLayerMagicSize); err != nil {
+		return nil, err
+	}
+	n := int64(binary.LittleEndian.Uint32(hdr))
+	if n > linglongMaxMetaSize || linglongLayerHeader+n > size {
+		return nil, fmt.Errorf("invalid linglong layer metadata size %d", n)
+	}
+	data := make([]byte, n)
+	if _, err := r.ReadAt(data, linglongLayerHeader); err != nil {
+		return nil, err
+	}
+	var meta struct {
+		Info    linglongRawInfo `json:"info"`
+		Version string          `json:"version"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid linglong layer metadata: %v", err)
+	}
+	info, err := meta.Info.info()
+	if err != nil {
+		return nil, err
+	}
+	return &LinglongLayer{Info: info, Version: meta.Version, PayloadOffset: linglongLayerHeader + n}, nil
+}
+
+// LinglongUABLayer UAB 中的一个 layer, minified 表示为裁剪过的依赖
+type LinglongUABLayer struct {
+	Info     *LinglongPackageInfo
+	Minified bool
+}
+
+// LinglongUAB UAB 的元数据及 bundle 镜像的位置
+type LinglongUAB struct {
+	Version      string
+	UUID         string
+	Digest       string //bundle 镜像的 sha256
+	OnlyApp      bool
+	Layers       []LinglongUABLayer
+	BundleOffset int64
+	BundleSize   int64
+}
+
+// IsLinglongUAB 判断文件是否为带有 linglong.meta 节的 ELF
+func IsLinglongUAB(r io.ReaderAt) bool {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil || string(magic) != elf.ELFMAG {
+		return false
+	}
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return false
+	}
+	return f.Section(LinglongUABMetaSection) != nil
+}
+
+// ReadLinglongUAB 读取 linglong.meta 节中的元数据及 bundle 节的位置
+func ReadLinglongUAB(r io.ReaderAt, size int64) (*LinglongUAB, error) {
+	f, err := elf.NewFile(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("invalid linglong UAB: %v", err)
+	}
+	sec := f.Section(LinglongUABMetaSection)
+	if sec == nil {
+		return nil, errors.New("no " + LinglongUABMetaSection + " section in UAB")
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+	var meta struct {
+		Version  string            `json:"version"`
+		UUID     string            `json:"uuid"`
+		Digest   string            `json:"digest"`
+		OnlyApp  bool              `json:"onlyApp"`
+		Sections map[string]string `json:"sections"`
+		Layers   []struct {
+			Info     linglongRawInfo `json:"info"`
+			Minified bool            `json:"minified"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(trimNUL(data), &meta); err != nil {
+		return nil, fmt.Errorf("invalid linglong UAB metadata: %v", err)
+	}
+	uab := &LinglongUAB{Version: meta.Version, UUID: meta.UUID, Digest: meta.Digest, OnlyApp: meta.OnlyApp}
+	for _, l := range meta.Layers {
+		info, err := l.Info.info()
+		if err != nil {
+			return nil, err
+		}
+		uab.Layers = append(uab.Layers, LinglongUABLayer{Info: info, Minified: l.Minified})
+	}
+	if len(uab.Layers) == 0 {
+		return nil, errors.New("no layers in linglong UAB")
+	}
+
+	name := meta.Sections["bundle"]
+	if name == "" {
+		name = LinglongUABBundleSection
+	}
+	bundle := f.Section(name)
+	if bundle == nil || bundle.Type == elf.SHT_NOBITS {
+		return nil, errors.New("no " + name + " section in UAB")
+	}
+	uab.BundleOffset, uab.BundleSize = int64(bundle.Offset), int64(bundle.Size)
+	if uab.BundleOffset+uab.BundleSize > size {
+		return nil, errors.New("linglong UAB bundle out of range")
+	}
+	return uab, nil
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import "errors"
+
+// LZ4 块格式中匹配的最小长度
+const lz4MinMatch = 4
+
+var errLZ4 = errors.New("invalid lz4 block")
+
+// lz4DecodeBlock 解压 LZ4 块格式(无帧头)的数据, 得到 n 个字节后停止,
+// erofs 的物理簇可能只被部分引用, 因此允许输入在 n 个字节之后还有数据
+func lz4DecodeBlock(src []byte, n int) ([]byte, error) {
+	dst, err := lz4Decode(src, n, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(dst) < n {
+		return nil, errLZ4
+	}
+	return dst[:n], nil
+}
+
+// lz4DecodeAll 解压整个 LZ4 块, 如 squashfs 的数据块, 解压后超过 max 字节时报错
+func lz4DecodeAll(src []byte, max int) ([]byte, error) {
+	return lz4Decode(src, max, true)
+}
+
+// lz4Decode all 为 false 时得到 n 个字节后停止, 为 true 时解压到输入结束, 结果不能超过 n 个字节
+func lz4Decode(src []byte, n int, all bool) ([]byte, error) {
+	dst := make([]byte, 0, n)
+	for i := 0; all || len(dst) < n; {
+		if i >= len(src) {
+			return nil, errLZ4
+		}
+		token := src[i]
+		i++
+		lit := int(token >> 4)
+		if lit == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errLZ4
+				}
+				b := src[i]
+				i++
+				lit += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if lit > len(src)-i || all && lit > n-len(dst) {
+			return nil, errLZ4
+		}
+		dst = append(dst, src[i:i+lit]...)
+		i += lit
+		// 最后一个序列只有字面量
+		if len(dst) >= n || i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errLZ4
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errLZ4
+		}
+		match := int(token & 15)
+		if match == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errLZ4
+				}
+				b := src[i]
+				i++
+				match += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		match += lz4MinMatch
+		if all && match > n-len(dst) {
+			return nil, errLZ4
+		}
+		start := len(dst) - offset
+		if offset >= match {
+			dst = append(dst, dst[start:start+match]...)
+			continue
+		}
+		// 重叠的匹配逐字节复制
+		for k := 0; k < match; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func FuzzLZ4Decode(f *testing.F) {
+	for _, tt := range lz4TestBlocks {
+		src, err := hex.DecodeString(tt.hex)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src, uint16(len(tt.want)))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, n uint16) {
+		all, err := lz4DecodeAll(src, int(n))
+		if err == nil && len(all) > int(n) {
+			t.Fatalf("lz4DecodeAll: got %d bytes, max %d", len(all), n)
+		}
+		block, err := lz4DecodeBlock(src, int(n))
+		if err != nil {
+			return
+		}
+		if len(block) != int(n) {
+			t.Fatalf("lz4DecodeBlock: got %d bytes, want %d", len(block), n)
+		}
+		// 两种方式解压出的公共部分应相同
+		if all != nil && !bytes.Equal(all, block[:len(all)]) {
+			t.Fatal("lz4DecodeAll and lz4DecodeBlock disagree")
+		}
+	})
+}
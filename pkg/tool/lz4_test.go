@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// lz4TestBlocks 由 liblz4 的 LZ4_compress_default 生成
+var lz4TestBlocks = []struct {
+	name string
+	hex  string
+	want []byte
+}{
+	{"empty", "00", []byte{}},
+	{"repeat", "6f68656c6c6f200600ff0f50656c6c6f20", []byte(strings.Repeat("hello ", 50))},
+	{"overlapping match", "1f610100ffffffd2506161616161", bytes.Repeat([]byte("a"), 1000)},
+	{
+		"long literal",
+		"ff1e54686520717569636b2062726f776e20666f78206a756d7073206f76657220746865206c617a7920646f672e202d00187020616761696e2e",
+		[]byte("The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog again."),
+	},
+	{
+		"mixed",
+		"ff1a000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f2021222324252627780100ff190f540110502324252627",
+		append(append(lz4TestSeq(40), bytes.Repeat([]byte("x"), 300)...), lz4TestSeq(40)...),
+	},
+}
+
+func lz4TestSeq(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestLZ4DecodeAll(t *testing.T) {
+	for _, tt := range lz4TestBlocks {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := lz4DecodeAll(src, len(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			// 解压后超过上限时报错
+			if len(tt.want) > 0 {
+				if _, err := lz4DecodeAll(src, len(tt.want)-1); err == nil {
+					t.Error("expected an error when exceeding max")
+				}
+			}
+		})
+	}
+}
+
+func TestLZ4DecodeBlock(t *testing.T) {
+	for _, tt := range lz4TestBlocks {
+		t.Run(tt.name, func(t *testing.T) {
+			src, _ := hex.DecodeString(tt.hex)
+			// 只取前面一部分, 或者输入之后还有其他数据
+			for _, n := range []int{len(tt.want) / 3, len(tt.want)} {
+				got, err := lz4DecodeBlock(append(src, 0xff, 0xff), n)
+				if err != nil {
+					t.Fatalf("n = %d: %v", n, err)
+				}
+				if !bytes.Equal(got, tt.want[:n]) {
+					t.Errorf("n = %d: got %q, want %q", n, got, tt.want[:n])
+				}
+			}
+			if _, err := lz4DecodeBlock(src, len(tt.want)+1); err == nil {
+				t.Error("expected an error when the block is too short")
+			}
+		})
+	}
+}
+
+func TestLZ4DecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"truncated literal", []byte{0x50, 'a', 'b'}},
+		{"truncated literal length", []byte{0xf0}},
+		{"truncated offset", []byte{0x10, 'a', 0x01}},
+		{"zero offset", []byte{0x10, 'a', 0x00, 0x00, 0x00}},
+		{"offset before start", []byte{0x10, 'a', 0x02, 0x00, 0x00}},
+		{"truncated match length", []byte{0x1f, 'a', 0x01, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := lz4DecodeAll(tt.src, 1<<16); err == nil {
+				t.Errorf("lz4DecodeAll() = %q, expected an error", got)
+			}
+			if got, err := lz4DecodeBlock(tt.src, 100); err == nil {
+				t.Errorf("lz4DecodeBlock() = %q, expected an error", got)
+			}
+		})
+	}
+}
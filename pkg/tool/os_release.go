@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadOSRelease 读取 root 下的 os-release 文件, 返回字段名到值的映射;
+// 优先使用 /etc/os-release, 不存在时使用 /usr/lib/os-release
+func ReadOSRelease(root string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "etc/os-release"))
+	if err != nil {
+		var libErr error
+		data, libErr = ioutil.ReadFile(filepath.Join(root, "usr/lib/os-release"))
+		if libErr != nil {
+			return nil, err
+		}
+	}
+	fields := make(map[string]string)
+	scn := bufio.NewScanner(bytes.NewReader(data))
+	for scn.Scan() {
+		line := strings.TrimSpace(scn.Text())
+		idx := strings.Index(line, "=")
+		if idx <= 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value := line[idx+1:]
+		// 值可以用引号包围, 引号内的转义与 shell 相同
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `"'`)
+		}
+		fields[line[:idx]] = value
+	}
+	return fields, scn.Err()
+}
@@ -0,0 +1,673 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// OSTree 对象及静态增量的 GVariant 类型
+const (
+	ostreeDeltaSuperblockType = "(a{sv}tayay" + ostreeCommitType + "aya(uayttay)a(yaytt))"
+	ostreeCommitType          = "(a{sv}aya(say)sstayay)"
+	ostreeDirTreeType         = "(a(say)a(sayay))"
+	ostreeDirMetaType         = "(uuua(ayay))"
+	ostreeDeltaPartType       = "(a(uuu)aa(ayay)ayay)"
+)
+
+// OSTree 对象类型
+const (
+	ostreeObjFile    = 1
+	ostreeObjDirTree = 2
+	ostreeObjDirMeta = 3
+	ostreeObjCommit  = 4
+)
+
+// 静态增量的操作码, 从零生成的增量(如 flatpak 单文件包)不使用读取源与 bspatch
+const (
+	ostreeOpOpenSpliceAndClose = 'S'
+	ostreeOpOpen               = 'o'
+	ostreeOpWrite              = 'w'
+	ostreeOpSetReadSource      = 'r'
+	ostreeOpUnsetReadSource    = 'R'
+	ostreeOpClose              = 'c'
+	ostreeOpBspatch            = 'B'
+)
+
+// 增量分块的压缩方式
+const (
+	ostreePartUncompressed = 0
+	ostreePartXz           = 'x'
+)
+
+const ostreeChecksumLen = 32
+
+type ostreeObject struct {
+	typ byte
+	sum [ostreeChecksumLen]byte
+}
+
+// ostreeContent 内容对象, 普通文件的内容写在临时文件中
+type ostreeContent struct {
+	mode   uint32
+	offset int64
+	size   int64
+	link   string //符号链接的目标
+}
+
+// ostreeDeltaPart 超级块 a{sv} 中内联的分块, 值为 (yay): 压缩方式及数据
+type ostreeDeltaPart struct {
+	compression byte
+	data        *io.SectionReader
+}
+
+// ostreeMaxEntries 遍历提交时的最大条目数, 内容相同的目录共用同一个 dirtree 对象,
+// 构造的提交可以让目录数随层数指数增长
+const ostreeMaxEntries = 1 << 22
+
+// OstreeDelta 内联了所有分块的 OSTree 静态增量, 如 flatpak 单文件包;
+// 分块依次解压执行, 内存中只保留一个解压后的分块及元数据对象,
+// 普通文件的内容写入临时文件, 使用完后需调用 Close。
+// 分块默认不超过 32MiB, 但大于分块大小的单个文件独占一个分块, 此时内存占用与该文件大小相当
+type OstreeDelta struct {
+	Metadata map[string]interface{} //超级块中的 a{sv}, flatpak 在其中记录 ref、metadata、appdata 等, 不含内联分块
+	Commit   string                 //提交的校验和
+
+	commit  []interface{}
+	parts   map[string]ostreeDeltaPart
+	meta    map[ostreeObject][]byte
+	content map[ostreeObject]ostreeContent
+	spool   *os.File
+	spoolW  *bufio.Writer
+	spooled int64
+}
+
+// OstreeEntry 提交中的一个条目
+type OstreeEntry struct {
+	Path     string //以 / 开头的路径
+	Mode     os.FileMode
+	Size     int64  //普通文件的大小
+	Link     string //符号链接的目标
+	Checksum string //内容对象的校验和, 内容相同的文件校验和相同
+
+	data *io.SectionReader
+}
+
+// Open 返回普通文件内容的读取流
+func (e *OstreeEntry) Open() io.Reader {
+	return io.NewSectionReader(e.data, 0, e.Size)
+}
+
+// readOstreeSuperblockEnds 超级块末尾倒序记录了 6 个变长成员的结束位置, 其中目标提交的校验和固定为 32 字节
+func readOstreeSuperblockEnds(r io.ReaderAt, size int64) ([6]int64, error) {
+	var ends [6]int64
+	osz := int64(gvOffsetSize(int(size)))
+	if size < 64 || size < int64(len(ends))*osz {
+		return ends, errGVariant
+	}
+	buf := make([]byte, int64(len(ends))*osz)
+	if _, err := r.ReadAt(buf, size-int64(len(buf))); err != nil {
+		return ends, err
+	}
+	for k := range ends {
+		ends[k] = int64(gvReadOffset(buf[int64(len(buf))-int64(k+1)*osz:], int(osz)))
+		if k > 0 && ends[k] < ends[k-1] {
+			return ends, errGVariant
+		}
+	}
+	from := int64(gvAlignUp(int(ends[0]), 8)) + 8
+	if ends[len(ends)-1] > size-int64(len(buf)) || ends[1]-from != 0 && ends[1]-from != ostreeChecksumLen ||
+		ends[2]-ends[1] != ostreeChecksumLen {
+		return ends, errGVariant
+	}
+	return ends, nil
+}
+
+// readOstreeDeltaDict 逐项读取超级块开头 end 字节的 a{sv}, 内联分块只记录其位置, 不读入内存
+func readOstreeDeltaDict(r io.ReaderAt, end int64) (map[string]interface{}, map[string]ostreeDeltaPart, error) {
+	dict := make(map[string]interface{})
+	parts := make(map[string]ostreeDeltaPart)
+	if end == 0 {
+		return dict, parts, nil
+	}
+	readAt := func(off, n int64) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := r.ReadAt(buf, off)
+		return buf, err
+	}
+	// 字典项的结束位置记录在末尾的偏移表中, 偏移表过大时不是合法的超级块
+	osz := int64(gvOffsetSize(int(end)))
+	buf, err := readAt(end-osz, osz)
+	if err != nil {
+		return nil, nil, err
+	}
+	table := int64(gvReadOffset(buf, int(osz)))
+	if table > end || (end-table)%osz != 0 || end-table > 1<<20 {
+		return nil, nil, errGVariant
+	}
+	offsets, err := readAt(table, end-table)
+	if err != nil {
+		return nil, nil, err
+	}
+	var start int64
+	for pos := 0; pos < len(offsets); pos += int(osz) {
+		// 字典项 {sv}: 键的结束位置记录在项的末尾, 变体按 8 字节对齐
+		start = int64(gvAlignUp(int(start), 8))
+		next := int64(gvReadOffset(offsets[pos:], int(osz)))
+		if start > next || next > table {
+			return nil, nil, errGVariant
+		}
+		n := next - start
+		eosz := int64(gvOffsetSize(int(n)))
+		if n < eosz+1 {
+			return nil, nil, errGVariant
+		}
+		buf, err := readAt(next-eosz, eosz)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyEnd := int64(gvReadOffset(buf, int(eosz)))
+		vstart, vend := int64(gvAlignUp(int(keyEnd), 8)), n-eosz
+		if keyEnd < 1 || vstart > vend {
+			return nil, nil, errGVariant
+		}
+		key, err := readAt(start, keyEnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key[len(key)-1] != 0 {
+			return nil, nil, errGVariant
+		}
+		name := string(key[:len(key)-1])
+
+		// 变体末尾为 0 字节及类型字符串
+		tail := vend - vstart
+		if tail > 256 {
+			tail = 256
+		}
+		buf, err = readAt(start+vend-tail, tail)
+		if err != nil {
+			return nil, nil, err
+		}
+		zero := bytes.LastIndexByte(buf, 0)
+		if zero < 0 {
+			return nil, nil, errGVariant
+		}
+		value := start + vend - tail + int64(zero)
+		if strings.HasPrefix(name, "deltas/") && string(buf[zero+1:]) == "(yay)" && value > start+vstart {
+			compression, err := readAt(start+vstart, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			parts[name] = ostreeDeltaPart{
+				compression: compression[0],
+				data:        io.NewSectionReader(r, start+vstart+1, value-start-vstart-1),
+			}
+		} else {
+			entry, err := readAt(start, n)
+			if err != nil {
+				return nil, nil, err
+			}
+			v, err := ParseGVariant("{sv}", entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			for k, v := range GVariantDict([]interface{}{v}) {
+				dict[k] = v
+			}
+		}
+		start = next
+	}
+	return dict, parts, nil
+}
+
+// ReadOstreeDeltaMetadata 只读取超级块中的 a{sv}, 用于快速判断文件是否为 OSTree 静态增量
+func ReadOstreeDeltaMetadata(r io.ReaderAt, size int64) (map[string]interface{}, error) {
+	ends, err := readOstreeSuperblockEnds(r, size)
+	if err != nil {
+		return nil, err
+	}
+	dict, _, err := readOstreeDeltaDict(r, ends[0])
+	return dict, err
+}
+
+// OpenOstreeDelta 解析超级块并依次执行所有内联分块, 只支持从零生成的增量
+func OpenOstreeDelta(r io.ReaderAt, size int64) (*OstreeDelta, error) {
+	d, err := openOstreeDelta(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ostree static delta: %v", err)
+	}
+	return d, nil
+}
+
+func openOstreeDelta(r io.ReaderAt, size int64) (*OstreeDelta, error) {
+	ends, err := readOstreeSuperblockEnds(r, size)
+	if err != nil {
+		return nil, err
+	}
+	d := &OstreeDelta{
+		meta:    make(map[ostreeObject][]byte),
+		content: make(map[ostreeObject]ostreeContent),
+	}
+	if d.Metadata, d.parts, err = readOstreeDeltaDict(r, ends[0]); err != nil {
+		return nil, err
+	}
+	if e, ok := d.Metadata["ostree.endianness"].(byte); ok && e == 'B' {
+		return nil, errors.New("big endian ostree static delta is not supported")
+	}
+
+	// a{sv} 之后的成员: 时间戳、源提交、目标提交、目标提交对象、依赖的增量、分块头及回退对象
+	base := int64(gvAlignUp(int(ends[0]), 8))
+	rest := make([]byte, size-base)
+	if _, err := r.ReadAt(rest, base); err != nil {
+		return nil, err
+	}
+	member := func(t string, from, to int64) (interface{}, error) {
+		from = int64(gvAlignUp(int(from), gvAlign(t)))
+		if from < base || from > to || to > size {
+			return nil, errGVariant
+		}
+		return ParseGVariant(t, rest[from-base:to-base])
+	}
+	if ends[1] != base+8 {
+		return nil, errors.New("only ostree static deltas generated from scratch are supported")
+	}
+	to := rest[ends[1]-base : ends[2]-base]
+	d.Commit = hex.EncodeToString(to)
+	commit, err := member(ostreeCommitType, ends[2], ends[3])
+	if err != nil {
+		return nil, err
+	}
+	d.commit = commit.([]interface{})
+	headers, err := member("a(uayttay)", ends[4], ends[5])
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := member("a(yaytt)", ends[5], size-int64(len(ends)*gvOffsetSize(int(size))))
+	if err != nil {
+		return nil, err
+	}
+	if len(fallback.([]interface{})) > 0 {
+		return nil, errors.New("ostree static deltas with fallback objects are not supported")
+	}
+
+	if d.spool, err = ioutil.TempFile("", "ostree-delta-"); err != nil {
+		return nil, err
+	}
+	d.spoolW = bufio.NewWriter(d.spool)
+	for i, e := range headers.([]interface{}) {
+		entry := e.([]interface{})
+		objects := entry[4].([]byte)
+		part, err := d.inlinePart(i)
+		if err == nil {
+			err = d.execPart(part, objects)
+		}
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("part %d: %v", i, err)
+		}
+	}
+	if err := d.spoolW.Flush(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close 删除保存文件内容的临时文件
+func (d *OstreeDelta) Close() {
+	if d.spool != nil {
+		d.spool.Close()
+		os.Remove(d.spool.Name())
+		d.spool = nil
+	}
+}
+
+// inlinePart 返回解压后的第 i 个内联分块, 其键为 deltas/.../<i>
+func (d *OstreeDelta) inlinePart(i int) ([]byte, error) {
+	suffix := "/" + strconv.Itoa(i)
+	for key, part := range d.parts {
+		if !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		part.data.Seek(0, io.SeekStart)
+		switch part.compression {
+		case ostreePartUncompressed:
+			return ioutil.ReadAll(part.data)
+		case ostreePartXz:
+			r, err := xz.NewReader(part.data)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.ReadAll(r)
+		default:
+			return nil, fmt.Errorf("unsupported ostree delta part compression %q", part.compression)
+		}
+	}
+	return nil, fmt.Errorf("ostree delta part %d is not inline", i)
+}
+
+func readVarUint(ops []byte, pos *int) (uint64, error) {
+	v, n := binary.Uvarint(ops[*pos:])
+	if n <= 0 {
+		return 0, errGVariant
+	}
+	*pos += n
+	return v, nil
+}
+
+// writeContent 保存内容对象, 普通文件写入临时文件, 符号链接的目标保存在内存中
+func (d *OstreeDelta) writeContent(c *ostreeContent, data []byte) error {
+	if c.mode&0170000 == 0120000 {
+		c.link += string(data)
+		c.size += int64(len(data))
+		return nil
+	}
+	if c.size == 0 {
+		c.offset = d.spooled
+	}
+	if _, err := d.spoolW.Write(data); err != nil {
+		return err
+	}
+	d.spooled += int64(len(data))
+	c.size += int64(len(data))
+	return nil
+}
+
+// execPart 执行分块中的操作, 依次写出 objects 中列出的对象
+func (d *OstreeDelta) execPart(data []byte, objects []byte) error {
+	v, err := ParseGVariant(ostreeDeltaPartType, data)
+	if err != nil {
+		return err
+	}
+	part := v.([]interface{})
+	modes := part[0].([]interface{})
+	payload := part[2].([]byte)
+	ops := part[3].([]byte)
+	if len(objects)%(ostreeChecksumLen+1) != 0 {
+		return errGVariant
+	}
+
+	index := 0
+	next := func() (ostreeObject, error) {
+		var obj ostreeObject
+		pos := index * (ostreeChecksumLen + 1)
+		if pos >= len(objects) {
+			return obj, errors.New("too many objects in operations")
+		}
+		obj.typ = objects[pos]
+		copy(obj.sum[:], objects[pos+1:pos+1+ostreeChecksumLen])
+		return obj, nil
+	}
+	slice := func(offset, length uint64) ([]byte, error) {
+		if offset > uint64(len(payload)) || length > uint64(len(payload))-offset {
+			return nil, errGVariant
+		}
+		return payload[offset : offset+length], nil
+	}
+	// 文件的属主与权限以大端序保存
+	mode := func(i uint64) (uint32, error) {
+		if i >= uint64(len(modes)) {
+			return 0, errGVariant
+		}
+		return bits.ReverseBytes32(modes[i].([]interface{})[2].(uint32)), nil
+	}
+
+	var open *ostreeContent
+	var openObj ostreeObject
+	var openSize uint64
+	for pos := 0; pos < len(ops); {
+		op := ops[pos]
+		pos++
+		var args [4]uint64
+		switch op {
+		case ostreeOpOpenSpliceAndClose:
+			if open != nil {
+				return errors.New("splice while an object is open")
+			}
+			obj, err := next()
+			if err != nil {
+				return err
+			}
+			nargs := 4
+			if obj.typ != ostreeObjFile {
+				nargs = 2
+			}
+			for i := 0; i < nargs; i++ {
+				if args[i], err = readVarUint(ops, &pos); err != nil {
+					return err
+				}
+			}
+			if obj.typ != ostreeObjFile {
+				// 元数据对象: 长度、偏移, 分块处理完后即释放, 需要复制
+				data, err := slice(args[1], args[0])
+				if err != nil {
+					return err
+				}
+				d.meta[obj] = append([]byte(nil), data...)
+			} else {
+				// 内容对象: 权限序号、扩展属性序号、大小、偏移
+				m, err := mode(args[0])
+				if err != nil {
+					return err
+				}
+				data, err := slice(args[3], args[2])
+				if err != nil {
+					return err
+				}
+				c := ostreeContent{mode: m}
+				if err := d.writeContent(&c, data); err != nil {
+					return err
+				}
+				d.content[obj] = c
+			}
+			index++
+		case ostreeOpOpen:
+			if open != nil {
+				return errors.New("open while an object is open")
+			}
+			if openObj, err = next(); err != nil {
+				return err
+			}
+			for i := 0; i < 3; i++ {
+				if args[i], err = readVarUint(ops, &pos); err != nil {
+					return err
+				}
+			}
+			m, err := mode(args[0])
+			if err != nil {
+				return err
+			}
+			// 对象的内容均来自本分块, 大小不会超过分块的数据
+			if args[2] > uint64(len(payload)) {
+				return errGVariant
+			}
+			open, openSize = &ostreeContent{mode: m}, args[2]
+		case ostreeOpWrite:
+			for i := 0; i < 2; i++ {
+				if args[i], err = readVarUint(ops, &pos); err != nil {
+					return err
+				}
+			}
+			chunk, err := slice(args[1], args[0])
+			if err != nil {
+				return err
+			}
+			if open == nil {
+				return errors.New("write without open object")
+			}
+			if uint64(open.size)+uint64(len(chunk)) > openSize {
+				return errors.New("write beyond the object size")
+			}
+			if err := d.writeContent(open, chunk); err != nil {
+				return err
+			}
+		case ostreeOpClose:
+			if open == nil {
+				return errors.New("close without open object")
+			}
+			d.content[openObj] = *open
+			open = nil
+			index++
+		case ostreeOpSetReadSource, ostreeOpUnsetReadSource, ostreeOpBspatch:
+			return fmt.Errorf("unsupported operation %q, the delta is not generated from scratch", op)
+		default:
+			return fmt.Errorf("unknown operation %q", op)
+		}
+	}
+	return nil
+}
+
+func (d *OstreeDelta) metaObject(typ byte, sum []byte, t string) ([]interface{}, error) {
+	obj := ostreeObject{typ: typ}
+	if len(sum) != ostreeChecksumLen {
+		return nil, errGVariant
+	}
+	copy(obj.sum[:], sum)
+	data, ok := d.meta[obj]
+	if !ok {
+		return nil, fmt.Errorf("ostree object %x is missing", sum)
+	}
+	v, err := ParseGVariant(t, data)
+	if err != nil {
+		return nil, err
+	}
+	return v.([]interface{}), nil
+}
+
+// dirMode 返回目录元数据中的权限
+func (d *OstreeDelta) dirMode(sum []byte) (os.FileMode, error) {
+	meta, err := d.metaObject(ostreeObjDirMeta, sum, ostreeDirMetaType)
+	if err != nil {
+		return 0, err
+	}
+	// 与文件一样以大端序保存
+	return os.FileMode(bits.ReverseBytes32(meta[2].(uint32))&0777) | os.ModeDir, nil
+}
+
+func (d *OstreeDelta) entry(name string, sum []byte) (*OstreeEntry, error) {
+	obj := ostreeObject{typ: ostreeObjFile}
+	copy(obj.sum[:], sum)
+	c, ok := d.content[obj]
+	if !ok || len(sum) != ostreeChecksumLen {
+		return nil, fmt.Errorf("ostree object %x is missing", sum)
+	}
+	e := &OstreeEntry{Path: name, Mode: os.FileMode(c.mode & 0777), Checksum: hex.EncodeToString(sum)}
+	switch c.mode & 0170000 {
+	case 0100000:
+		e.Size = c.size
+		e.data = io.NewSectionReader(d.spool, c.offset, c.size)
+	case 0120000:
+		e.Mode |= os.ModeSymlink
+		e.Link = c.link
+	default:
+		return nil, fmt.Errorf("unsupported file mode %o of %s", c.mode, name)
+	}
+	return e, nil
+}
+
+// Walk 按目录顺序遍历提交中的所有条目, 每个目录先于其中的条目, 根目录除外
+func (d *OstreeDelta) Walk(fn func(e *OstreeEntry) error) error {
+	count := 0
+	return d.walkDir("/", d.commit[6].([]byte), make(map[string]bool), &count, fn)
+}
+
+// walkDir parents 为上级目录的 dirtree, 内容相同的目录可以共用 dirtree, 但目录不能包含自身
+func (d *OstreeDelta) walkDir(dir string, sum []byte, parents map[string]bool, count *int, fn func(e *OstreeEntry) error) error {
+	if parents[string(sum)] {
+		return fmt.Errorf("ostree directory loop at %s", dir)
+	}
+	parents[string(sum)] = true
+	defer delete(parents, string(sum))
+	tree, err := d.metaObject(ostreeObjDirTree, sum, ostreeDirTreeType)
+	if err != nil {
+		return err
+	}
+	*count += len(tree[0].([]interface{})) + len(tree[1].([]interface{}))
+	if *count > ostreeMaxEntries {
+		return errors.New("too many entries in ostree commit")
+	}
+	for _, f := range tree[0].([]interface{}) {
+		file := f.([]interface{})
+		e, err := d.entry(path.Join(dir, file[0].(string)), file[1].([]byte))
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	for _, s := range tree[1].([]interface{}) {
+		sub := s.([]interface{})
+		name := path.Join(dir, sub[0].(string))
+		mode, err := d.dirMode(sub[2].([]byte))
+		if err != nil {
+			return err
+		}
+		if err := fn(&OstreeEntry{Path: name, Mode: mode}); err != nil {
+			return err
+		}
+		if err := d.walkDir(name, sub[1].([]byte), parents, count, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile 读取提交中的一个普通文件, 不跟随符号链接, 文件不存在时返回 os.ErrNotExist
+func (d *OstreeDelta) ReadFile(name string) ([]byte, error) {
+	sum := d.commit[6].([]byte)
+	parts := strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/")
+	for i, part := range parts {
+		tree, err := d.metaObject(ostreeObjDirTree, sum, ostreeDirTreeType)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(parts)-1 {
+			for _, f := range tree[0].([]interface{}) {
+				file := f.([]interface{})
+				if file[0].(string) != part {
+					continue
+				}
+				e, err := d.entry(name, file[1].([]byte))
+				if err != nil {
+					return nil, err
+				}
+				if !e.Mode.IsRegular() {
+					return nil, os.ErrNotExist
+				}
+				return ioutil.ReadAll(e.Open())
+			}
+			return nil, os.ErrNotExist
+		}
+		found := false
+		for _, s := range tree[1].([]interface{}) {
+			sub := s.([]interface{})
+			if sub[0].(string) == part {
+				sum = sub[1].([]byte)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, os.ErrNotExist
+		}
+	}
+	return nil, os.ErrNotExist
+}
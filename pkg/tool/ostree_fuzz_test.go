@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func FuzzOstreeDelta(f *testing.F) {
+	data, err := ioutil.ReadFile("testdata/flatpak/hello.flatpak")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := OpenOstreeDelta(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		defer d.Close()
+		d.Walk(func(e *OstreeEntry) error {
+			if !e.Mode.IsRegular() {
+				return nil
+			}
+			n, err := io.Copy(ioutil.Discard, e.Open())
+			if err == nil && n != e.Size {
+				t.Fatalf("%s: read %d bytes, size %d", e.Path, n, e.Size)
+			}
+			return nil
+		})
+		d.ReadFile("files/bin/hello")
+	})
+}
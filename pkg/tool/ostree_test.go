@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// hello.flatpak 为 flatpak build-bundle 格式的单文件包, 分块经 xz 压缩
+func openTestOstreeDelta(t *testing.T, data []byte) (*OstreeDelta, error) {
+	t.Helper()
+	d, err := OpenOstreeDelta(bytes.NewReader(data), int64(len(data)))
+	if err == nil {
+		t.Cleanup(d.Close)
+	}
+	return d, err
+}
+
+func TestOstreeDelta(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/flatpak/hello.flatpak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := openTestOstreeDelta(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Commit != "92a69751a7a72f55a7ab4c604d6aede266f402787bda42f869cc5860de97be9d" {
+		t.Errorf("Commit = %q", d.Commit)
+	}
+	if ref := d.Metadata["ref"]; ref != "app/org.example.Hello/x86_64/stable" {
+		t.Errorf("ref = %#v", ref)
+	}
+
+	var got []string
+	err = d.Walk(func(e *OstreeEntry) error {
+		s := fmt.Sprintf("%s %v", e.Path, e.Mode)
+		switch {
+		case e.Mode.IsRegular():
+			content, err := ioutil.ReadAll(e.Open())
+			if err != nil {
+				return err
+			}
+			if int64(len(content)) != e.Size {
+				return fmt.Errorf("%s: read %d bytes, want %d", e.Path, len(content), e.Size)
+			}
+			s += fmt.Sprintf(" %d %s", e.Size, e.Checksum[:8])
+		case e.Mode&os.ModeSymlink != 0:
+			s += " -> " + e.Link
+		}
+		got = append(got, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/metadata -rw-r--r-- 360 0294efb9",
+		"/export drwxr-xr-x",
+		"/export/share drwxr-xr-x",
+		"/export/share/applications drwxr-xr-x",
+		"/export/share/applications/org.example.Hello.desktop -rw-r--r-- 27 457d525a",
+		"/files drwxr-xr-x",
+		"/files/manifest.json -rw-r--r-- 684 367841ce",
+		"/files/bin drwxr-xr-x",
+		"/files/bin/hello -rwxr-xr-x 27 3ddc07d8",
+		"/files/lib drwxr-xr-x",
+		"/files/lib/dup.txt -rw-r--r-- 5 b01e6a95",
+		"/files/lib/libfoo.so Lrwxrwxrwx -> libfoo.so.1",
+		"/files/lib/libfoo.so.1 -rw-r--r-- 57 93b365e8",
+		"/files/lib/small.txt -rw-r--r-- 5 b01e6a95",
+		"/files/share drwxr-xr-x",
+		"/files/share/metainfo drwxr-xr-x",
+		"/files/share/metainfo/org.example.Hello.metainfo.xml -rw-r--r-- 559 735050bd",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	hello, err := d.ReadFile("files/bin/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(hello) != "#!/bin/sh\necho hello world\n" {
+		t.Errorf("files/bin/hello = %q", hello)
+	}
+	for _, name := range []string{"files/missing", "files/lib/libfoo.so", "files/bin"} {
+		if _, err := d.ReadFile(name); !os.IsNotExist(err) {
+			t.Errorf("ReadFile(%q) error = %v, want not exist", name, err)
+		}
+	}
+}
+
+func TestOstreeDeltaCorrupt(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/flatpak/hello.flatpak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 16, 100, 3000, len(data) - 1} {
+		t.Run(fmt.Sprintf("truncated at %d", n), func(t *testing.T) {
+			if d, err := openTestOstreeDelta(t, data[:n]); err == nil {
+				if err := d.Walk(func(*OstreeEntry) error { return nil }); err == nil {
+					t.Error("expected an error")
+				}
+			}
+		})
+	}
+	if _, err := ReadOstreeDeltaMetadata(bytes.NewReader(data[:100]), 100); err == nil {
+		t.Error("ReadOstreeDeltaMetadata: expected an error")
+	}
+}
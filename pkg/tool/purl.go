@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultPurlNamespace purl 中默认使用的发行版命名空间
+const DefaultPurlNamespace = "deepin"
+
+// PackageURL 生成 pkg:<type>/<namespace>/<name>@<version>?arch=<arch> 形式的 purl,
+// namespace 为发行版命名空间, 如 deepin、uos, namespace、version 与 arch 为空时省略; rpm 版本中的 epoch 按 purl 规范放在 epoch 限定符中
+func PackageURL(pkgType, namespace, name, version, arch string) string {
+	qualifiers := map[string]string{"arch": arch}
+	if pkgType == "rpm" {
+		if idx := strings.Index(version, ":"); idx > 0 {
+			qualifiers["epoch"] = version[:idx]
+			version = version[idx+1:]
+		}
+	}
+	return formatPackageURL(pkgType, namespace, name, version, qualifiers)
+}
+
+func formatPackageURL(pkgType, namespace, name, version string, qualifiers map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("pkg:")
+	sb.WriteString(strings.ToLower(pkgType))
+	sb.WriteByte('/')
+	if namespace != "" {
+		sb.WriteString(url.PathEscape(namespace))
+		sb.WriteByte('/')
+	}
+	sb.WriteString(url.PathEscape(name))
+	if version != "" {
+		sb.WriteByte('@')
+		sb.WriteString(url.PathEscape(version))
+	}
+	var keys []string
+	for k, v := range qualifiers {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(k + "=" + url.PathEscape(qualifiers[k]))
+	}
+	return sb.String()
+}
+
+// UpstreamVersion 去掉版本中的 epoch 与发行版修订号, 如 1:2.10-3 返回 2.10
+func UpstreamVersion(version string) string {
+	if idx := strings.Index(version, ":"); idx >= 0 {
+		version = version[idx+1:]
+	}
+	if idx := strings.LastIndex(version, "-"); idx > 0 {
+		version = version[:idx]
+	}
+	return version
+}
+
+// cpeEscape 按 CPE 2.3 格式化字符串规则转义字母、数字与 - . _ 以外的字符
+func cpeEscape(s string) string {
+	if s == "" {
+		return "*"
+	}
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_') {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// CPE 生成尽力而为的 CPE 2.3 字符串, 厂商与产品均取软件包名称, 版本取上游版本, 为空时为 *
+func CPE(name, version string) string {
+	name = cpeEscape(name)
+	return "cpe:2.3:a:" + name + ":" + name + ":" + cpeEscape(UpstreamVersion(version)) + ":*:*:*:*:*:*:*"
+}
@@ -0,0 +1,645 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spdx/tools-golang/spdx/v2/common"
+)
+
+var (
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+const (
+	rpmLeadSize      = 96
+	rpmHeaderTagsMax = 0xffff
+	rpmHeaderDataMax = 0x0fffffff
+	cpioNameMax      = 4096 //PATH_MAX
+)
+
+// 头部数据类型
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeInt64       = 5
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18nString  = 9
+)
+
+// 头部标签
+const (
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagEpoch             = 1003
+	rpmTagSummary           = 1004
+	rpmTagDescription       = 1005
+	rpmTagSize              = 1009
+	rpmTagVendor            = 1011
+	rpmTagLicense           = 1014
+	rpmTagPackager          = 1015
+	rpmTagGroup             = 1016
+	rpmTagURL               = 1020
+	rpmTagArch              = 1022
+	rpmTagOldFileNames      = 1027
+	rpmTagFileSizes         = 1028
+	rpmTagFileModes         = 1030
+	rpmTagFileDigests       = 1035
+	rpmTagFileLinkTos       = 1036
+	rpmTagFileFlags         = 1037
+	rpmTagSourceRpm         = 1044
+	rpmTagRequireFlags      = 1048
+	rpmTagRequireName       = 1049
+	rpmTagRequireVersion    = 1050
+	rpmTagDirIndexes        = 1116
+	rpmTagBaseNames         = 1117
+	rpmTagDirNames          = 1118
+	rpmTagPayloadFormat     = 1124
+	rpmTagPayloadCompressor = 1125
+	rpmTagLongFileSizes     = 5008
+	rpmTagLongSize          = 5009
+	rpmTagFileDigestAlgo    = 5011
+)
+
+// 依赖标志位
+const (
+	rpmSenseLess    = 1 << 1
+	rpmSenseGreater = 1 << 2
+	rpmSenseEqual   = 1 << 3
+	rpmSenseRpmlib  = 1 << 24
+)
+
+const (
+	rpmFileFlagGhost = 1 << 6
+	rpmFileTypeMask  = 0170000
+	rpmFileTypeReg   = 0100000
+)
+
+// rpm 文件摘要算法编号(PGPHASHALGO_*)
+var rpmDigestAlgos = map[int32]common.ChecksumAlgorithm{
+	1:  common.MD5,
+	2:  common.SHA1,
+	8:  common.SHA256,
+	9:  common.SHA384,
+	10: common.SHA512,
+	11: common.SHA224,
+}
+
+// IsRpmFile 判断文件是否为 RPM 包文件
+func IsRpmFile(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magicBytes := make([]byte, len(rpmLeadMagic))
+	_, err = io.ReadFull(file, magicBytes)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(magicBytes, rpmLeadMagic), nil
+}
+
+// HasRpmSignatureHeader 判断 96 字节的 lead 之后是否为签名头, 用于确认 RPM 包
+func HasRpmSignatureHeader(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magicBytes := make([]byte, rpmLeadSize+len(rpmHeaderMagic))
+	if _, err := io.ReadFull(file, magicBytes); err != nil {
+		return false, err
+	}
+	return bytes.Equal(magicBytes[:len(rpmLeadMagic)], rpmLeadMagic) && bytes.Equal(magicBytes[rpmLeadSize:], rpmHeaderMagic), nil
+}
+
+type RpmDepend struct {
+	Name    string
+	Flags   int32
+	Version string
+}
+
+// Operator 版本比较符, 如 >=, 没有版本约束时为空
+func (d RpmDepend) Operator() string {
+	op := ""
+	if d.Flags&rpmSenseLess != 0 {
+		op += "<"
+	}
+	if d.Flags&rpmSenseGreater != 0 {
+		op += ">"
+	}
+	if d.Flags&rpmSenseEqual != 0 {
+		op += "="
+	}
+	return op
+}
+
+// String 以 Debian 依赖的书写方式输出, 如 "glibc (>= 2.34)"
+func (d RpmDepend) String() string {
+	op := d.Operator()
+	if op == "" || d.Version == "" {
+		return d.Name
+	}
+	return d.Name + " (" + op + " " + d.Version + ")"
+}
+
+// IsRpmlib 是否为 rpmlib(...) 之类的 rpm 自身特性依赖, 不对应任何软件包
+func (d RpmDepend) IsRpmlib() bool {
+	return d.Flags&rpmSenseRpmlib != 0 || strings.HasPrefix(d.Name, "rpmlib(")
+}
+
+type RpmFile struct {
+	Name   string
+	Mode   uint16
+	Size   int64
+	Flags  int32
+	Digest string
+	LinkTo string
+}
+
+// IsRegular 是否为需要计算摘要的普通文件, %ghost 文件不在负载中
+func (f RpmFile) IsRegular() bool {
+	return int(f.Mode)&rpmFileTypeMask == rpmFileTypeReg && f.Flags&rpmFileFlagGhost == 0
+}
+
+type RpmHeader struct {
+	Name              string
+	Version           string
+	Release           string
+	Epoch             string
+	Arch              string
+	License           string
+	Vendor            string
+	Packager          string
+	URL               string
+	Group             string
+	Summary           string
+	Description       string
+	SourceRpm         string
+	Size              int64
+	Requires          []RpmDepend
+	Files             []RpmFile
+	DigestAlgo        common.ChecksumAlgorithm
+	PayloadFormat     string
+	PayloadCompressor string
+}
+
+// FullVersion 返回 [epoch:]version-release
+func (h *RpmHeader) FullVersion() string {
+	ver := h.Version
+	if h.Release != "" {
+		ver += "-" + h.Release
+	}
+	if h.Epoch != "" {
+		ver = h.Epoch + ":" + ver
+	}
+	return ver
+}
+
+type rpmIndexEntry struct {
+	Tag    int32
+	Type   uint32
+	Offset int32
+	Count  uint32
+}
+
+// rpm 头部结构(签名头与主头部格式相同)
+type rpmHeaderStore struct {
+	entries map[int32]rpmIndexEntry
+	data    []byte
+}
+
+// readRpmHeaderStore 读取一个头部结构, 返回头部及其占用的字节数
+func readRpmHeaderStore(r io.Reader) (*rpmHeaderStore, int64, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(intro[:4], rpmHeaderMagic) {
+		return nil, 0, errors.New("bad rpm header magic")
+	}
+	nindex := binary.BigEndian.Uint32(intro[8:12])
+	hsize := binary.BigEndian.Uint32(intro[12:16])
+	if nindex > rpmHeaderTagsMax || hsize > rpmHeaderDataMax {
+		return nil, 0, errors.New("rpm header too large")
+	}
+
+	// 长度字段不可信, 按实际读到的内容分配, 截断的文件不会按声明的大小占用内存
+	raw, err := readLimited(r, 16*int64(nindex)+int64(hsize))
+	if err != nil {
+		return nil, 0, err
+	}
+	index := make([]rpmIndexEntry, nindex)
+	if err := binary.Read(bytes.NewReader(raw), binary.BigEndian, index); err != nil {
+		return nil, 0, err
+	}
+	h := &rpmHeaderStore{
+		entries: make(map[int32]rpmIndexEntry, nindex),
+		data:    raw[16*int64(nindex):],
+	}
+	for _, e := range index {
+		if e.Offset < 0 || uint32(e.Offset) > hsize {
+			return nil, 0, fmt.Errorf("rpm header tag %d out of range", e.Tag)
+		}
+		h.entries[e.Tag] = e
+	}
+	return h, int64(16 + 16*int64(nindex) + int64(hsize)), nil
+}
+
+// readLimited 读取 n 个字节, 缓冲区随读取的内容增长
+func readLimited(r io.Reader, n int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+func (h *rpmHeaderStore) getStrings(tag int32) []string {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+	switch e.Type {
+	case rpmTypeString:
+		e.Count = 1
+	case rpmTypeStringArray, rpmTypeI18nString:
+	default:
+		return nil
+	}
+	var res []string
+	data := h.data[e.Offset:]
+	for i := uint32(0); i < e.Count; i++ {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			break
+		}
+		res = append(res, string(data[:end]))
+		data = data[end+1:]
+	}
+	return res
+}
+
+func (h *rpmHeaderStore) getString(tag int32) string {
+	if s := h.getStrings(tag); len(s) > 0 {
+		return s[0]
+	}
+	return ""
+}
+
+// getInts 读取整数数组标签, 统一转换为 int64
+func (h *rpmHeaderStore) getInts(tag int32) []int64 {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+	var size uint32
+	switch e.Type {
+	case rpmTypeInt16:
+		size = 2
+	case rpmTypeInt32:
+		size = 4
+	case rpmTypeInt64:
+		size = 8
+	default:
+		return nil
+	}
+	data := h.data[e.Offset:]
+	if uint64(len(data)) < uint64(size)*uint64(e.Count) {
+		return nil
+	}
+	res := make([]int64, e.Count)
+	for i := range res {
+		switch size {
+		case 2:
+			res[i] = int64(binary.BigEndian.Uint16(data[i*2:]))
+		case 4:
+			res[i] = int64(int32(binary.BigEndian.Uint32(data[i*4:])))
+		case 8:
+			res[i] = int64(binary.BigEndian.Uint64(data[i*8:]))
+		}
+	}
+	return res
+}
+
+func (h *rpmHeaderStore) getInt(tag int32) (int64, bool) {
+	if v := h.getInts(tag); len(v) > 0 {
+		return v[0], true
+	}
+	return 0, false
+}
+
+// openRpm 打开 RPM 包并跳过 lead 与签名头, 返回主头部与定位在负载起始处的文件
+func openRpm(rpmPath string) (*rpmHeaderStore, *os.File, error) {
+	f, err := os.Open(rpmPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	lead := make([]byte, rpmLeadSize)
+	if _, err := io.ReadFull(f, lead); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !bytes.Equal(lead[:4], rpmLeadMagic) {
+		f.Close()
+		return nil, nil, errors.New(rpmPath + " is not a rpm package")
+	}
+	// 签名头按 8 字节对齐
+	_, sigSize, err := readRpmHeaderStore(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if pad := (8 - sigSize%8) % 8; pad > 0 {
+		if _, err := f.Seek(pad, io.SeekCurrent); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	h, _, err := readRpmHeaderStore(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return h, f, nil
+}
+
+// ReadRpmHeader 读取 RPM 包元信息及文件列表
+func ReadRpmHeader(rpmPath string) (*RpmHeader, error) {
+	h, f, err := openRpm(rpmPath)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	rpmHdr := &RpmHeader{
+		Name:              h.getString(rpmTagName),
+		Version:           h.getString(rpmTagVersion),
+		Release:           h.getString(rpmTagRelease),
+		Arch:              h.getString(rpmTagArch),
+		License:           h.getString(rpmTagLicense),
+		Vendor:            h.getString(rpmTagVendor),
+		Packager:          h.getString(rpmTagPackager),
+		URL:               h.getString(rpmTagURL),
+		Group:             h.getString(rpmTagGroup),
+		Summary:           h.getString(rpmTagSummary),
+		Description:       h.getString(rpmTagDescription),
+		SourceRpm:         h.getString(rpmTagSourceRpm),
+		PayloadFormat:     h.getString(rpmTagPayloadFormat),
+		PayloadCompressor: h.getString(rpmTagPayloadCompressor),
+		DigestAlgo:        common.MD5,
+	}
+	if epoch, ok := h.getInt(rpmTagEpoch); ok {
+		rpmHdr.Epoch = strconv.FormatInt(epoch, 10)
+	}
+	if size, ok := h.getInt(rpmTagLongSize); ok {
+		rpmHdr.Size = size
+	} else if size, ok := h.getInt(rpmTagSize); ok {
+		rpmHdr.Size = int64(uint32(size))
+	}
+	if algo, ok := h.getInt(rpmTagFileDigestAlgo); ok {
+		if a, ok := rpmDigestAlgos[int32(algo)]; ok {
+			rpmHdr.DigestAlgo = a
+		}
+	}
+
+	names := h.getStrings(rpmTagRequireName)
+	flags := h.getInts(rpmTagRequireFlags)
+	versions := h.getStrings(rpmTagRequireVersion)
+	for i, name := range names {
+		dep := RpmDepend{Name: name}
+		if i < len(flags) {
+			dep.Flags = int32(flags[i])
+		}
+		if i < len(versions) {
+			dep.Version = versions[i]
+		}
+		rpmHdr.Requires = append(rpmHdr.Requires, dep)
+	}
+
+	rpmHdr.Files = readRpmFileList(h)
+	return rpmHdr, nil
+}
+
+func readRpmFileList(h *rpmHeaderStore) []RpmFile {
+	// 新格式使用 dirnames + basenames, 旧格式使用 oldfilenames
+	var paths []string
+	if baseNames := h.getStrings(rpmTagBaseNames); len(baseNames) > 0 {
+		dirNames := h.getStrings(rpmTagDirNames)
+		dirIndexes := h.getInts(rpmTagDirIndexes)
+		for i, base := range baseNames {
+			dir := ""
+			if i < len(dirIndexes) && dirIndexes[i] >= 0 && dirIndexes[i] < int64(len(dirNames)) {
+				dir = dirNames[dirIndexes[i]]
+			}
+			paths = append(paths, dir+base)
+		}
+	} else {
+		paths = h.getStrings(rpmTagOldFileNames)
+	}
+
+	sizes := h.getInts(rpmTagLongFileSizes)
+	if sizes == nil {
+		sizes = h.getInts(rpmTagFileSizes)
+		for i := range sizes {
+			sizes[i] = int64(uint32(sizes[i]))
+		}
+	}
+	modes := h.getInts(rpmTagFileModes)
+	fileFlags := h.getInts(rpmTagFileFlags)
+	digests := h.getStrings(rpmTagFileDigests)
+	linkTos := h.getStrings(rpmTagFileLinkTos)
+
+	files := make([]RpmFile, 0, len(paths))
+	for i, p := range paths {
+		file := RpmFile{Name: p}
+		if i < len(modes) {
+			file.Mode = uint16(modes[i])
+		}
+		if i < len(sizes) {
+			file.Size = sizes[i]
+		}
+		if i < len(fileFlags) {
+			file.Flags = int32(fileFlags[i])
+		}
+		if i < len(digests) {
+			file.Digest = digests[i]
+		}
+		if i < len(linkTos) {
+			file.LinkTo = linkTos[i]
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// WalkRpmPayload 遍历 RPM 负载(cpio)中的普通文件。
+// 硬链接在 cpio 中只有最后一项携带数据, 因此回调以共享同一内容的全部路径调用一次
+func WalkRpmPayload(rpmPath string, fn func(names []string, size int64, r io.Reader) error) error {
+	h, f, err := openRpm(rpmPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	format := h.getString(rpmTagPayloadFormat)
+	if format != "" && format != "cpio" {
+		return fmt.Errorf("unsupported rpm payload format: %s", format)
+	}
+	compressor := h.getString(rpmTagPayloadCompressor)
+	if compressor == "" {
+		compressor = "gzip"
+	}
+	payload, err := NewDecompressReader(compressor, bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer payload.Close()
+
+	links := make(map[uint64][]string)
+	cr := newCpioReader(payload)
+	for {
+		hdr, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Mode&rpmFileTypeMask != rpmFileTypeReg {
+			continue
+		}
+		name := "/" + strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "."), "/")
+		if hdr.Nlink > 1 {
+			links[hdr.Ino] = append(links[hdr.Ino], name)
+			if hdr.Size == 0 {
+				continue
+			}
+			names := links[hdr.Ino]
+			delete(links, hdr.Ino)
+			if err := fn(names, hdr.Size, cr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn([]string{name}, hdr.Size, cr); err != nil {
+			return err
+		}
+	}
+	// 内容为空的硬链接组
+	for _, names := range links {
+		if err := fn(names, 0, bytes.NewReader(nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cpioHeader struct {
+	Name  string
+	Ino   uint64
+	Mode  int64
+	Nlink int64
+	Size  int64
+}
+
+// cpio newc 格式读取器
+type cpioReader struct {
+	r       io.Reader
+	remain  int64
+	padding int64
+}
+
+func newCpioReader(r io.Reader) *cpioReader {
+	return &cpioReader{r: r}
+}
+
+// Next 读取下一项的头部, 读到 TRAILER!!! 时返回 io.EOF, 缺少 TRAILER!!! 的归档视为被截断
+func (c *cpioReader) Next() (*cpioHeader, error) {
+	if _, err := io.CopyN(ioutil.Discard, c.r, c.remain+c.padding); err != nil {
+		return nil, noEOF(err)
+	}
+	c.remain, c.padding = 0, 0
+
+	raw := make([]byte, 110)
+	if _, err := io.ReadFull(c.r, raw); err != nil {
+		return nil, noEOF(err)
+	}
+	magic := string(raw[:6])
+	if magic != "070701" && magic != "070702" {
+		return nil, fmt.Errorf("unsupported cpio magic: %q", magic)
+	}
+	var fields [13]int64
+	for i := range fields {
+		v, err := strconv.ParseUint(string(raw[6+i*8:14+i*8]), 16, 32)
+		if err != nil {
+			return nil, errors.New("invalid cpio header")
+		}
+		fields[i] = int64(v)
+	}
+	hdr := &cpioHeader{
+		Ino:   uint64(fields[0]),
+		Mode:  fields[1],
+		Nlink: fields[4],
+		Size:  fields[6],
+	}
+	nameSize := fields[11]
+	if nameSize > cpioNameMax {
+		return nil, fmt.Errorf("cpio name too long: %d", nameSize)
+	}
+	name := make([]byte, nameSize+(4-(110+nameSize)%4)%4)
+	if _, err := io.ReadFull(c.r, name); err != nil {
+		return nil, err
+	}
+	hdr.Name = string(bytes.TrimRight(name[:nameSize], "\x00"))
+	if hdr.Name == "TRAILER!!!" {
+		return nil, io.EOF
+	}
+	c.remain = hdr.Size
+	c.padding = (4 - hdr.Size%4) % 4
+	return hdr, nil
+}
+
+// noEOF 将未读到结束标记时的 io.EOF 转换为 io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (c *cpioReader) Read(p []byte) (int, error) {
+	if c.remain <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remain {
+		p = p[:c.remain]
+	}
+	n, err := c.r.Read(p)
+	c.remain -= int64(n)
+	if err == io.EOF && c.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
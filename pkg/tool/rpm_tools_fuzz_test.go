@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func FuzzReadRpmHeaderStore(f *testing.F) {
+	f.Add(buildRpmHeader([]rpmTestTag{
+		{rpmTagName, "hello"},
+		{rpmTagDirNames, []string{"/usr/bin/"}},
+		{rpmTagBaseNames, []string{"hello"}},
+		{rpmTagDirIndexes, []int32{0}},
+		{rpmTagFileSizes, []int32{5}},
+	}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, _, err := readRpmHeaderStore(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for tag := range h.entries {
+			h.getStrings(tag)
+			h.getInts(tag)
+		}
+		readRpmFileList(h)
+	})
+}
+
+func FuzzCpioReader(f *testing.F) {
+	f.Add(buildCpio([]cpioTestEntry{
+		{name: "./usr/bin/hello", ino: 1, mode: 0100755, nlink: 1, data: "hello"},
+		{name: "./usr/bin/a", ino: 2, mode: 0100755, nlink: 2},
+		{name: "./usr/bin/b", ino: 2, mode: 0100755, nlink: 2, data: "linked"},
+	}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cr := newCpioReader(bytes.NewReader(data))
+		for {
+			hdr, err := cr.Next()
+			if err != nil {
+				return
+			}
+			n, err := io.Copy(ioutil.Discard, cr)
+			if err != nil {
+				return
+			}
+			if n != hdr.Size {
+				t.Fatalf("%s: read %d bytes, header says %d", hdr.Name, n, hdr.Size)
+			}
+		}
+	})
+}
@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// rpmTestTag 构造测试头部用的标签, value 为 string、[]string 或 []int32
+type rpmTestTag struct {
+	tag   int32
+	value interface{}
+}
+
+// buildRpmHeader 按 rpm 头部结构编码标签
+func buildRpmHeader(tags []rpmTestTag) []byte {
+	var index, data bytes.Buffer
+	for _, t := range tags {
+		e := rpmIndexEntry{Tag: t.tag, Offset: int32(data.Len())}
+		switch v := t.value.(type) {
+		case string:
+			e.Type, e.Count = rpmTypeString, 1
+			data.WriteString(v + "\x00")
+		case []string:
+			e.Type, e.Count = rpmTypeStringArray, uint32(len(v))
+			for _, s := range v {
+				data.WriteString(s + "\x00")
+			}
+		case []int32:
+			e.Type, e.Count = rpmTypeInt32, uint32(len(v))
+			binary.Write(&data, binary.BigEndian, v)
+		}
+		binary.Write(&index, binary.BigEndian, e)
+	}
+	var buf bytes.Buffer
+	buf.Write(rpmHeaderMagic)
+	buf.Write(make([]byte, 4))
+	binary.Write(&buf, binary.BigEndian, uint32(len(tags)))
+	binary.Write(&buf, binary.BigEndian, uint32(data.Len()))
+	buf.Write(index.Bytes())
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// cpioTestEntry 测试负载中的一项
+type cpioTestEntry struct {
+	name  string
+	ino   int
+	mode  int
+	nlink int
+	data  string
+}
+
+// buildCpio 按 newc 格式编码条目并追加 TRAILER!!!
+func buildCpio(entries []cpioTestEntry) []byte {
+	var buf bytes.Buffer
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	write := func(e cpioTestEntry) {
+		fmt.Fprintf(&buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			e.ino, e.mode, 0, 0, e.nlink, 0, len(e.data), 0, 0, 0, 0, len(e.name)+1, 0)
+		buf.WriteString(e.name + "\x00")
+		pad()
+		buf.WriteString(e.data)
+		pad()
+	}
+	for _, e := range entries {
+		write(e)
+	}
+	write(cpioTestEntry{name: "TRAILER!!!", nlink: 1})
+	return buf.Bytes()
+}
+
+// writeTestRpm 生成包含 lead、签名头、主头部与 gzip 负载的 rpm 包
+func writeTestRpm(t *testing.T, tags []rpmTestTag, payload []byte) string {
+	var buf bytes.Buffer
+	lead := make([]byte, rpmLeadSize)
+	copy(lead, rpmLeadMagic)
+	buf.Write(lead)
+	sig := buildRpmHeader([]rpmTestTag{{tag: 1000, value: []int32{int32(len(payload))}}})
+	buf.Write(sig)
+	buf.Write(make([]byte, (8-len(sig)%8)%8))
+	buf.Write(buildRpmHeader(tags))
+	gz := gzip.NewWriter(&buf)
+	gz.Write(payload)
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "test.rpm")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadRpmHeader(t *testing.T) {
+	path := writeTestRpm(t, []rpmTestTag{
+		{rpmTagName, "hello"},
+		{rpmTagVersion, "2.10"},
+		{rpmTagRelease, "3.fc39"},
+		{rpmTagEpoch, []int32{1}},
+		{rpmTagArch, "x86_64"},
+		{rpmTagLicense, "GPLv3+"},
+		{rpmTagSize, []int32{1234}},
+		{rpmTagRequireName, []string{"glibc", "rpmlib(CompressedFileNames)", "/bin/sh"}},
+		{rpmTagRequireFlags, []int32{rpmSenseGreater | rpmSenseEqual, rpmSenseLess | rpmSenseEqual | rpmSenseRpmlib, 0}},
+		{rpmTagRequireVersion, []string{"2.34", "3.0.4-1", ""}},
+		{rpmTagDirNames, []string{"/usr/bin/", "/usr/share/doc/hello/"}},
+		{rpmTagBaseNames, []string{"hello", "README", "ghost"}},
+		{rpmTagDirIndexes, []int32{0, 1, 1}},
+		{rpmTagFileSizes, []int32{5, 6, 0}},
+		{rpmTagFileModes, []int32{0100755, 0100644, 0100644}},
+		{rpmTagFileFlags, []int32{0, 0, rpmFileFlagGhost}},
+		{rpmTagFileDigestAlgo, []int32{8}},
+	}, nil)
+
+	h, err := ReadRpmHeader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "hello" || h.Arch != "x86_64" || h.License != "GPLv3+" || h.Size != 1234 {
+		t.Errorf("unexpected header: %+v", h)
+	}
+	if v := h.FullVersion(); v != "1:2.10-3.fc39" {
+		t.Errorf("FullVersion() = %q", v)
+	}
+	if h.DigestAlgo != "SHA256" {
+		t.Errorf("DigestAlgo = %q", h.DigestAlgo)
+	}
+
+	var requires []string
+	for _, d := range h.Requires {
+		if !d.IsRpmlib() {
+			requires = append(requires, d.String())
+		}
+	}
+	if want := []string{"glibc (>= 2.34)", "/bin/sh"}; !reflect.DeepEqual(requires, want) {
+		t.Errorf("requires = %q, want %q", requires, want)
+	}
+
+	var files []string
+	for _, f := range h.Files {
+		if f.IsRegular() {
+			files = append(files, fmt.Sprintf("%s %d", f.Name, f.Size))
+		}
+	}
+	if want := []string{"/usr/bin/hello 5", "/usr/share/doc/hello/README 6"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %q, want %q", files, want)
+	}
+}
+
+// TestReadRpmFileListBadDirIndex 目录索引越界时只保留文件名
+func TestReadRpmFileListBadDirIndex(t *testing.T) {
+	data := buildRpmHeader([]rpmTestTag{
+		{rpmTagDirNames, []string{"/usr/bin/"}},
+		{rpmTagBaseNames, []string{"a", "b", "c"}},
+		{rpmTagDirIndexes, []int32{0, -1, 1}},
+	})
+	h, _, err := readRpmHeaderStore(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range readRpmFileList(h) {
+		got = append(got, f.Name)
+	}
+	if want := []string{"/usr/bin/a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+func TestWalkRpmPayload(t *testing.T) {
+	payload := buildCpio([]cpioTestEntry{
+		{name: "./usr", ino: 1, mode: 040755, nlink: 2},
+		{name: "./usr/bin/hello", ino: 2, mode: 0100755, nlink: 1, data: "hello"},
+		// 硬链接只有最后一项携带数据
+		{name: "./usr/bin/a", ino: 3, mode: 0100755, nlink: 2},
+		{name: "./usr/bin/b", ino: 3, mode: 0100755, nlink: 2, data: "linked"},
+		{name: "./usr/bin/sh", ino: 4, mode: 0120777, nlink: 1, data: "bash"},
+		{name: "./empty1", ino: 5, mode: 0100644, nlink: 2},
+		{name: "./empty2", ino: 5, mode: 0100644, nlink: 2},
+	})
+	path := writeTestRpm(t, []rpmTestTag{{rpmTagName, "hello"}, {rpmTagPayloadFormat, "cpio"}}, payload)
+
+	var got []string
+	err := WalkRpmPayload(path, func(names []string, size int64, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != size {
+			return fmt.Errorf("%v: read %d bytes, want %d", names, len(data), size)
+		}
+		got = append(got, strings.Join(names, ",")+"="+string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"/empty1,/empty2=", "/usr/bin/a,/usr/bin/b=linked", "/usr/bin/hello=hello"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCpioReaderErrors(t *testing.T) {
+	valid := buildCpio([]cpioTestEntry{{name: "./a", ino: 1, mode: 0100644, nlink: 1, data: "abc"}})
+	hugeName := append([]byte(nil), valid...)
+	copy(hugeName[6+11*8:], "ffffffff")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("070707"), valid[6:]...)},
+		{"bad hex", append([]byte("070701zzzzzzzz"), valid[14:]...)},
+		{"truncated header", valid[:60]},
+		{"truncated data", valid[:118]},
+		{"huge name", hugeName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := newCpioReader(bytes.NewReader(tt.data))
+			for {
+				_, err := cr.Next()
+				if err == io.EOF {
+					t.Fatal("expected an error")
+				}
+				if err != nil {
+					return
+				}
+				if _, err := io.Copy(ioutil.Discard, cr); err != nil {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestReadRpmHeaderStoreErrors(t *testing.T) {
+	valid := buildRpmHeader([]rpmTestTag{{rpmTagName, "hello"}})
+	badOffset := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(badOffset[16+8:], 0x7fffffff)
+	tooLarge := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(tooLarge[8:], rpmHeaderTagsMax+1)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte{0, 0, 0, 0}, valid[4:]...)},
+		{"truncated", valid[:len(valid)-1]},
+		{"offset out of range", badOffset},
+		{"too many tags", tooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := readRpmHeaderStore(bytes.NewReader(tt.data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestReadRpmHeaderStoreHugeSize 头部声明的数据区很大但文件很短时, 不按声明的大小分配内存
+func TestReadRpmHeaderStoreHugeSize(t *testing.T) {
+	data := buildRpmHeader([]rpmTestTag{{rpmTagName, "hello"}})
+	binary.BigEndian.PutUint32(data[12:], rpmHeaderDataMax)
+	data = append(data, make([]byte, 112-len(data))...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, _, err := readRpmHeaderStore(bytes.NewReader(data)); err == nil {
+		t.Error("expected an error")
+	}
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("allocated %d bytes for a %d byte header", alloc, len(data))
+	}
+}
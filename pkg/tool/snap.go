@@ -0,0 +1,258 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// snap 包中的元数据文件
+const (
+	SnapYAML         = "meta/snap.yaml"
+	SnapManifestYAML = "snap/manifest.yaml"         //snapcraft 构建信息, 含各 part 的 stage-packages
+	SnapDpkgYAML     = "usr/share/snappy/dpkg.yaml" //snapcraft 记录的 stage-packages
+	SnapDpkgList     = "usr/share/snappy/dpkg.list" //基础快照中 dpkg -l 的输出
+)
+
+// SnapApp snap.yaml 中的一个应用
+type SnapApp struct {
+	Name    string
+	Command string
+	Daemon  string
+	Plugs   []string
+}
+
+// SnapPlug snap.yaml 中的一个插口, 内容快照的 DefaultProvider 为提供内容的 snap 名称
+type SnapPlug struct {
+	Name            string
+	Interface       string
+	Content         string
+	DefaultProvider string
+}
+
+// SnapMeta meta/snap.yaml 中的信息
+type SnapMeta struct {
+	Name          string
+	Version       string
+	Summary       string
+	Description   string
+	License       string
+	Base          string
+	Type          string
+	Confinement   string
+	Grade         string
+	Website       string
+	Architectures []string
+	Apps          []SnapApp  //按名称排序
+	Plugs         []SnapPlug //按名称排序, 包括应用中使用但未在顶层声明的插口
+}
+
+// ParseSnapYAML 解析 meta/snap.yaml
+func ParseSnapYAML(data []byte) (*SnapMeta, error) {
+	var raw struct {
+		Name          string   `json:"name"`
+		Version       string   `json:"version"`
+		Summary       string   `json:"summary"`
+		Description   string   `json:"description"`
+		License       string   `json:"license"`
+		Base          string   `json:"base"`
+		Type          string   `json:"type"`
+		Confinement   string   `json:"confinement"`
+		Grade         string   `json:"grade"`
+		Website       string   `json:"website"`
+		Architectures []string `json:"architectures"`
+		Links         struct {
+			Website []string `json:"website"`
+		} `json:"links"`
+		Apps map[string]struct {
+			Command string   `json:"command"`
+			Daemon  string   `json:"daemon"`
+			Plugs   []string `json:"plugs"`
+		} `json:"apps"`
+		Plugs map[string]json.RawMessage `json:"plugs"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Name == "" {
+		return nil, errors.New("snap.yaml has no name")
+	}
+	meta := &SnapMeta{
+		Name:          raw.Name,
+		Version:       raw.Version,
+		Summary:       raw.Summary,
+		Description:   strings.TrimSpace(raw.Description),
+		License:       raw.License,
+		Base:          raw.Base,
+		Type:          raw.Type,
+		Confinement:   raw.Confinement,
+		Grade:         raw.Grade,
+		Website:       raw.Website,
+		Architectures: raw.Architectures,
+	}
+	if meta.Type == "" {
+		meta.Type = "app"
+	}
+	if meta.Website == "" && len(raw.Links.Website) > 0 {
+		meta.Website = raw.Links.Website[0]
+	}
+
+	// 插口可以写成 "名称: 接口" 的简写, 或不写属性, 此时接口名与插口名相同
+	plugs := make(map[string]SnapPlug)
+	for name, v := range raw.Plugs {
+		plug := SnapPlug{Name: name, Interface: name}
+		var iface string
+		var attrs struct {
+			Interface       string `json:"interface"`
+			Content         string `json:"content"`
+			DefaultProvider string `json:"default-provider"`
+		}
+		if json.Unmarshal(v, &iface) == nil && iface != "" {
+			plug.Interface = iface
+		} else if json.Unmarshal(v, &attrs) == nil {
+			if attrs.Interface != "" {
+				plug.Interface = attrs.Interface
+			}
+			plug.Content = attrs.Content
+			plug.DefaultProvider = attrs.DefaultProvider
+		}
+		plugs[name] = plug
+	}
+	for name, app := range raw.Apps {
+		meta.Apps = append(meta.Apps, SnapApp{Name: name, Command: app.Command, Daemon: app.Daemon, Plugs: app.Plugs})
+		for _, p := range app.Plugs {
+			if _, ok := plugs[p]; !ok {
+				plugs[p] = SnapPlug{Name: p, Interface: p}
+			}
+		}
+	}
+	sort.Slice(meta.Apps, func(i, j int) bool { return meta.Apps[i].Name < meta.Apps[j].Name })
+	for _, p := range plugs {
+		meta.Plugs = append(meta.Plugs, p)
+	}
+	sort.Slice(meta.Plugs, func(i, j int) bool { return meta.Plugs[i].Name < meta.Plugs[j].Name })
+	return meta, nil
+}
+
+// BaseSnap 返回 snap 运行依赖的基础快照, 未声明 base 的应用使用 core, bare 表示不需要基础快照
+func (m *SnapMeta) BaseSnap() string {
+	if m.Base == "bare" {
+		return ""
+	}
+	if m.Base == "" && m.Type == "app" {
+		return "core"
+	}
+	return m.Base
+}
+
+// ContentSnaps 返回内容接口插口的默认提供者, 即内容快照的名称, 已去重
+func (m *SnapMeta) ContentSnaps() []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, p := range m.Plugs {
+		if p.Interface != "content" || p.DefaultProvider == "" {
+			continue
+		}
+		// default-provider 可以写成 <snap>:<slot>
+		name := p.DefaultProvider
+		if idx := strings.Index(name, ":"); idx >= 0 {
+			name = name[:idx]
+		}
+		if !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// SnapStagePackage snapcraft 构建时放入 snap 的 deb 包
+type SnapStagePackage struct {
+	Name    string
+	Arch    string
+	Version string
+}
+
+// parseSnapStagePackage 解析 stage-packages 的记录, 格式为 name[:arch]=version
+func parseSnapStagePackage(s string) (SnapStagePackage, bool) {
+	s = strings.TrimSpace(s)
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		return SnapStagePackage{}, false
+	}
+	p := SnapStagePackage{Name: s[:idx], Version: s[idx+1:]}
+	if i := strings.Index(p.Name, ":"); i >= 0 {
+		p.Name, p.Arch = p.Name[:i], p.Name[i+1:]
+	}
+	return p, true
+}
+
+// ParseSnapManifest 解析 snap/manifest.yaml 中各 part 的 stage-packages 及 primed-stage-packages
+func ParseSnapManifest(data []byte) ([]SnapStagePackage, error) {
+	var raw struct {
+		Parts map[string]struct {
+			StagePackages []string `json:"stage-packages"`
+		} `json:"parts"`
+		PrimedStagePackages []string `json:"primed-stage-packages"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	entries := raw.PrimedStagePackages
+	var parts []string
+	for name := range raw.Parts {
+		parts = append(parts, name)
+	}
+	sort.Strings(parts)
+	for _, name := range parts {
+		entries = append(entries, raw.Parts[name].StagePackages...)
+	}
+	return parseSnapStagePackages(entries), nil
+}
+
+// ParseSnapDpkgYAML 解析 usr/share/snappy/dpkg.yaml 中的 packages
+func ParseSnapDpkgYAML(data []byte) ([]SnapStagePackage, error) {
+	var raw struct {
+		Packages []string `json:"packages"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return parseSnapStagePackages(raw.Packages), nil
+}
+
+func parseSnapStagePackages(entries []string) []SnapStagePackage {
+	var res []SnapStagePackage
+	seen := make(map[SnapStagePackage]bool)
+	for _, e := range entries {
+		if p, ok := parseSnapStagePackage(e); ok && !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// ParseDpkgList 解析 dpkg -l 的输出, 只保留已安装(ii)的软件包
+func ParseDpkgList(data []byte) []SnapStagePackage {
+	var res []SnapStagePackage
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "ii" {
+			continue
+		}
+		p := SnapStagePackage{Name: fields[1], Version: fields[2], Arch: fields[3]}
+		if i := strings.Index(p.Name, ":"); i >= 0 {
+			p.Name = p.Name[:i]
+		}
+		res = append(res, p)
+	}
+	return res
+}
@@ -0,0 +1,674 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// squashfs 4.0 格式, 参考 https://dr-emann.github.io/squashfs/
+const (
+	squashfsMagic        = "hsqs"
+	squashfsMetaSize     = 8192
+	squashfsNoFragment   = 0xffffffff
+	squashfsUncompressed = 1 << 24 //数据块大小中表示未压缩的位
+	squashfsMaxSymlink   = 4096
+)
+
+// squashfs 压缩算法编号
+const (
+	squashfsGzip = 1
+	squashfsLzma = 2
+	squashfsLzo  = 3
+	squashfsXz   = 4
+	squashfsLz4  = 5
+	squashfsZstd = 6
+)
+
+// squashfs inode 类型
+const (
+	squashfsDir         = 1
+	squashfsFile        = 2
+	squashfsSymlink     = 3
+	squashfsBlockDev    = 4
+	squashfsCharDev     = 5
+	squashfsFifo        = 6
+	squashfsSocket      = 7
+	squashfsExtDir      = 8
+	squashfsExtFile     = 9
+	squashfsExtSymlink  = 10
+	squashfsExtBlockDev = 11
+	squashfsExtCharDev  = 12
+	squashfsExtFifo     = 13
+	squashfsExtSocket   = 14
+)
+
+type squashfsSuperblock struct {
+	Magic        [4]byte
+	InodeCount   uint32
+	ModTime      uint32
+	BlockSize    uint32
+	FragCount    uint32
+	Compressor   uint16
+	BlockLog     uint16
+	Flags        uint16
+	IDCount      uint16
+	VersionMajor uint16
+	VersionMinor uint16
+	RootInode    uint64
+	BytesUsed    uint64
+	IDTable      uint64
+	XattrTable   uint64
+	InodeTable   uint64
+	DirTable     uint64
+	FragTable    uint64
+	ExportTable  uint64
+}
+
+type squashfsMetaBlock struct {
+	data []byte
+	next int64
+}
+
+type squashfsFragment struct {
+	Start  uint64
+	Size   uint32
+	Unused uint32
+}
+
+// squashfsInode 解析 inode 时需要的字段
+type squashfsInode struct {
+	typ  uint16
+	perm uint16
+	// 目录
+	dirBlock  uint32
+	dirOffset uint16
+	dirSize   uint32
+	// 普通文件
+	blocksStart uint64
+	fileSize    uint64
+	fragment    uint32
+	fragOffset  uint32
+	blockSizes  []uint32
+	// 符号链接
+	target string
+}
+
+// SquashFS 纯 Go 实现的 squashfs 只读解析, 无需挂载或 root 权限;
+// 支持 gzip、xz、lzma、zstd、lz4 压缩, lzo 没有可用的实现
+type SquashFS struct {
+	r      io.ReaderAt
+	offset int64 //镜像在文件中的偏移, 如 AppImage 中 squashfs 位于 ELF 运行时之后
+	sb     squashfsSuperblock
+	frags  []squashfsFragment
+	zstd   *zstd.Decoder
+	meta   map[int64]squashfsMetaBlock //已解压的元数据块, 元数据通常只占镜像的很小一部分
+
+	fragIndex uint32 //最近读取的分片块
+	fragData  []byte
+}
+
+// SquashFSEntry squashfs 中的一个条目
+type SquashFSEntry struct {
+	Path string //以 / 开头的路径
+	Mode os.FileMode
+	Size int64  //普通文件的大小
+	Link string //符号链接的目标
+
+	fs    *SquashFS
+	inode *squashfsInode
+}
+
+// Open 返回普通文件内容的读取流, 其他类型的条目返回空内容
+func (e *SquashFSEntry) Open() io.Reader {
+	if e.inode.typ != squashfsFile && e.inode.typ != squashfsExtFile {
+		return bytes.NewReader(nil)
+	}
+	return &squashfsFileReader{fs: e.fs, inode: e.inode, pos: e.inode.blocksStart, remain: e.inode.fileSize}
+}
+
+// IsSquashFS 判断文件在 offset 处是否为 squashfs 镜像
+func IsSquashFS(r io.ReaderAt, offset int64) bool {
+	magic := make([]byte, len(squashfsMagic))
+	if _, err := r.ReadAt(magic, offset); err != nil {
+		return false
+	}
+	return string(magic) == squashfsMagic
+}
+
+// OpenSquashFS 读取 offset 处的 squashfs 超级块
+func OpenSquashFS(r io.ReaderAt, offset int64) (*SquashFS, error) {
+	s := &SquashFS{r: r, offset: offset, fragIndex: squashfsNoFragment, meta: make(map[int64]squashfsMetaBlock)}
+	raw := make([]byte, binary.Size(s.sb))
+	if _, err := r.ReadAt(raw, offset); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &s.sb); err != nil {
+		return nil, err
+	}
+	if string(s.sb.Magic[:]) != squashfsMagic {
+		return nil, errors.New("not a squashfs image")
+	}
+	if s.sb.VersionMajor != 4 {
+		return nil, fmt.Errorf("unsupported squashfs version %d.%d", s.sb.VersionMajor, s.sb.VersionMinor)
+	}
+	if s.sb.BlockSize == 0 || s.sb.BlockSize > 1<<20 {
+		return nil, fmt.Errorf("invalid squashfs block size %d", s.sb.BlockSize)
+	}
+	switch s.sb.Compressor {
+	case squashfsGzip, squashfsLzma, squashfsXz, squashfsLz4:
+	case squashfsZstd:
+		d, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(s.sb.BlockSize)))
+		if err != nil {
+			return nil, err
+		}
+		s.zstd = d
+	case squashfsLzo:
+		return nil, errors.New("unsupported squashfs compressor: lzo")
+	default:
+		return nil, fmt.Errorf("unsupported squashfs compressor: %d", s.sb.Compressor)
+	}
+	return s, nil
+}
+
+// Close 释放解压器
+func (s *SquashFS) Close() {
+	if s.zstd != nil {
+		s.zstd.Close()
+	}
+}
+
+// Size 返回镜像占用的字节数
+func (s *SquashFS) Size() int64 {
+	return int64(s.sb.BytesUsed)
+}
+
+// decompress 解压一个元数据块或数据块, 解压后超过 max 字节(元数据块为 8KiB, 数据块为块大小)时报错
+func (s *SquashFS) decompress(data []byte, max int) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch s.sb.Compressor {
+	case squashfsGzip:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case squashfsXz:
+		r, err = xz.NewReader(bytes.NewReader(data))
+	case squashfsLzma:
+		r, err = lzma.NewReader(bytes.NewReader(data))
+	case squashfsZstd:
+		out, err := s.zstd.DecodeAll(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > max {
+			return nil, errors.New("squashfs block too large")
+		}
+		return out, nil
+	case squashfsLz4:
+		out, err := lz4DecodeAll(data, max)
+		if err != nil {
+			return nil, fmt.Errorf("squashfs block: %v", err)
+		}
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > max {
+		return nil, errors.New("squashfs block too large")
+	}
+	return out, nil
+}
+
+// maxMetaBytes 元数据解压后的总大小上限, 每个元数据块至少占用 3 个字节, 解压后不超过 8KiB;
+// 用于在分配内存之前检查镜像中记录的数量
+func (s *SquashFS) maxMetaBytes() uint64 {
+	return s.sb.BytesUsed / 3 * squashfsMetaSize
+}
+
+// readAt 读取镜像中 pos 处的 n 个字节, pos 相对于镜像起始
+func (s *SquashFS) readAt(pos int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := s.r.ReadAt(buf, s.offset+pos); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readMetaBlock 读取 pos 处的元数据块, 返回解压后的内容及下一个块的位置
+func (s *SquashFS) readMetaBlock(pos int64) ([]byte, int64, error) {
+	if b, ok := s.meta[pos]; ok {
+		return b.data, b.next, nil
+	}
+	hdr, err := s.readAt(pos, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	n := binary.LittleEndian.Uint16(hdr)
+	size := int(n & 0x7fff)
+	data, err := s.readAt(pos+2, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n&0x8000 == 0 {
+		if data, err = s.decompress(data, squashfsMetaSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	s.meta[pos] = squashfsMetaBlock{data: data, next: pos + 2 + int64(size)}
+	return data, pos + 2 + int64(size), nil
+}
+
+// squashfsMetaReader 从元数据块中连续读取, 跨越块边界时自动读取下一个块
+type squashfsMetaReader struct {
+	fs   *SquashFS
+	next int64
+	data []byte
+}
+
+// metaReader 从 pos 处的元数据块中偏移 offset 开始读取
+func (s *SquashFS) metaReader(pos int64, offset int) (*squashfsMetaReader, error) {
+	data, next, err := s.readMetaBlock(pos)
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(data) {
+		return nil, errors.New("invalid squashfs metadata offset")
+	}
+	return &squashfsMetaReader{fs: s, next: next, data: data[offset:]}, nil
+}
+
+func (m *squashfsMetaReader) Read(p []byte) (int, error) {
+	for len(m.data) == 0 {
+		data, next, err := m.fs.readMetaBlock(m.next)
+		if err != nil {
+			return 0, err
+		}
+		m.data, m.next = data, next
+	}
+	n := copy(p, m.data)
+	m.data = m.data[n:]
+	return n, nil
+}
+
+// readInode 读取 inode 引用(高位为元数据块相对 inode 表的位置, 低 16 位为块内偏移)指向的 inode
+func (s *SquashFS) readInode(ref uint64) (*squashfsInode, error) {
+	r, err := s.metaReader(int64(s.sb.InodeTable)+int64(ref>>16), int(ref&0xffff))
+	if err != nil {
+		return nil, err
+	}
+	var hdr struct {
+		Type, Perm, UID, GID uint16
+		MTime, Number        uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	inode := &squashfsInode{typ: hdr.Type, perm: hdr.Perm}
+	switch hdr.Type {
+	case squashfsDir:
+		var d struct {
+			Block, LinkCount uint32
+			Size, Offset     uint16
+			Parent           uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		inode.dirBlock, inode.dirOffset, inode.dirSize = d.Block, d.Offset, uint32(d.Size)
+	case squashfsExtDir:
+		var d struct {
+			LinkCount, Size, Block, Parent uint32
+			IndexCount, Offset             uint16
+			Xattr                          uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		inode.dirBlock, inode.dirOffset, inode.dirSize = d.Block, d.Offset, d.Size
+	case squashfsFile:
+		var f struct {
+			Start, Fragment, Offset, Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		inode.blocksStart, inode.fragment, inode.fragOffset, inode.fileSize = uint64(f.Start), f.Fragment, f.Offset, uint64(f.Size)
+	case squashfsExtFile:
+		var f struct {
+			Start, Size, Sparse                uint64
+			LinkCount, Fragment, Offset, Xattr uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		inode.blocksStart, inode.fragment, inode.fragOffset, inode.fileSize = f.Start, f.Fragment, f.Offset, f.Size
+	case squashfsSymlink, squashfsExtSymlink:
+		var l struct {
+			LinkCount, Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		if l.Size > squashfsMaxSymlink || uint64(l.Size) > s.sb.BytesUsed {
+			return nil, fmt.Errorf("invalid squashfs symlink size %d", l.Size)
+		}
+		target := make([]byte, l.Size)
+		if _, err := io.ReadFull(r, target); err != nil {
+			return nil, err
+		}
+		inode.target = string(target)
+	}
+
+	if inode.typ == squashfsFile || inode.typ == squashfsExtFile {
+		// 最后不足一个块的部分存放在分片中
+		n := inode.fileSize / uint64(s.sb.BlockSize)
+		if inode.fragment == squashfsNoFragment && inode.fileSize%uint64(s.sb.BlockSize) != 0 {
+			n++
+		}
+		// 块大小列表位于元数据中, 数量不可能超过元数据的大小; 分批读取, 镜像被截断时不会预先分配大量内存
+		if n > s.maxMetaBytes()/4 {
+			return nil, fmt.Errorf("invalid squashfs file size %d", inode.fileSize)
+		}
+		for remain := n; remain > 0; {
+			batch := make([]uint32, 1024)
+			if remain < uint64(len(batch)) {
+				batch = batch[:remain]
+			}
+			if err := binary.Read(r, binary.LittleEndian, batch); err != nil {
+				return nil, err
+			}
+			inode.blockSizes = append(inode.blockSizes, batch...)
+			remain -= uint64(len(batch))
+		}
+	}
+	return inode, nil
+}
+
+// squashfsDirEntry 目录中的一项
+type squashfsDirEntry struct {
+	name string
+	ref  uint64
+}
+
+// readDir 读取目录的各项, 目录大小比实际内容多 3 个字节
+func (s *SquashFS) readDir(inode *squashfsInode) ([]squashfsDirEntry, error) {
+	if inode.dirSize <= 3 {
+		return nil, nil
+	}
+	r, err := s.metaReader(int64(s.sb.DirTable)+int64(inode.dirBlock), int(inode.dirOffset))
+	if err != nil {
+		return nil, err
+	}
+	var entries []squashfsDirEntry
+	remain := int64(inode.dirSize) - 3
+	for remain > 0 {
+		var hdr struct {
+			Count, Start, Number uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+			return nil, err
+		}
+		remain -= 12
+		for i := uint32(0); i <= hdr.Count; i++ {
+			var e struct {
+				Offset      uint16
+				InodeOffset int16
+				Type        uint16
+				NameSize    uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+				return nil, err
+			}
+			name := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, err
+			}
+			remain -= 8 + int64(len(name))
+			entries = append(entries, squashfsDirEntry{name: string(name), ref: uint64(hdr.Start)<<16 | uint64(e.Offset)})
+		}
+	}
+	return entries, nil
+}
+
+// readFragments 读取分片表, 分片表由指向各元数据块的位置数组索引
+func (s *SquashFS) readFragments() error {
+	if s.frags != nil || s.sb.FragCount == 0 {
+		return nil
+	}
+	if uint64(s.sb.FragCount)*16 > s.maxMetaBytes() {
+		return fmt.Errorf("invalid squashfs fragment count %d", s.sb.FragCount)
+	}
+	blocks := (int(s.sb.FragCount)*16 + squashfsMetaSize - 1) / squashfsMetaSize
+	raw, err := s.readAt(int64(s.sb.FragTable), blocks*8)
+	if err != nil {
+		return err
+	}
+	var frags []squashfsFragment
+	for i := 0; i < blocks; i++ {
+		data, _, err := s.readMetaBlock(int64(binary.LittleEndian.Uint64(raw[i*8:])))
+		if err != nil {
+			return err
+		}
+		for len(data) >= 16 && len(frags) < int(s.sb.FragCount) {
+			frags = append(frags, squashfsFragment{
+				Start: binary.LittleEndian.Uint64(data),
+				Size:  binary.LittleEndian.Uint32(data[8:]),
+			})
+			data = data[16:]
+		}
+	}
+	s.frags = frags
+	return nil
+}
+
+// readDataBlock 读取一个数据块或分片块, size 中带有未压缩标志
+func (s *SquashFS) readDataBlock(pos int64, size uint32) ([]byte, error) {
+	data, err := s.readAt(pos, int(size&(squashfsUncompressed-1)))
+	if err != nil {
+		return nil, err
+	}
+	if size&squashfsUncompressed != 0 {
+		return data, nil
+	}
+	return s.decompress(data, int(s.sb.BlockSize))
+}
+
+// fragment 返回分片块的内容, 连续读取同一分片中的小文件时复用
+func (s *SquashFS) fragment(index uint32) ([]byte, error) {
+	if index == s.fragIndex {
+		return s.fragData, nil
+	}
+	if err := s.readFragments(); err != nil {
+		return nil, err
+	}
+	if int(index) >= len(s.frags) {
+		return nil, fmt.Errorf("invalid squashfs fragment %d", index)
+	}
+	f := s.frags[index]
+	data, err := s.readDataBlock(int64(f.Start), f.Size)
+	if err != nil {
+		return nil, err
+	}
+	s.fragIndex, s.fragData = index, data
+	return data, nil
+}
+
+// squashfsFileReader 按块解压普通文件的内容
+type squashfsFileReader struct {
+	fs     *SquashFS
+	inode  *squashfsInode
+	block  int
+	pos    uint64 //下一个数据块的位置
+	remain uint64
+	buf    []byte
+}
+
+func (f *squashfsFileReader) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.remain == 0 {
+			return 0, io.EOF
+		}
+		if err := f.load(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+// load 读取下一个数据块, 所有数据块之后是分片中的剩余部分
+func (f *squashfsFileReader) load() error {
+	blockSize := uint64(f.fs.sb.BlockSize)
+	var data []byte
+	switch {
+	case f.block < len(f.inode.blockSizes):
+		size := f.inode.blockSizes[f.block]
+		f.block++
+		if size&(squashfsUncompressed-1) == 0 {
+			// 稀疏文件中全为 0 的块不占用空间
+			data = make([]byte, blockSize)
+			break
+		}
+		var err error
+		if data, err = f.fs.readDataBlock(int64(f.pos), size); err != nil {
+			return err
+		}
+		f.pos += uint64(size & (squashfsUncompressed - 1))
+	case f.inode.fragment != squashfsNoFragment:
+		frag, err := f.fs.fragment(f.inode.fragment)
+		if err != nil {
+			return err
+		}
+		end := uint64(f.inode.fragOffset) + f.remain
+		if end > uint64(len(frag)) {
+			return errors.New("squashfs fragment out of range")
+		}
+		data = frag[f.inode.fragOffset:end]
+	default:
+		return io.ErrUnexpectedEOF
+	}
+	if uint64(len(data)) > f.remain {
+		data = data[:f.remain]
+	}
+	f.remain -= uint64(len(data))
+	f.buf = data
+	return nil
+}
+
+// Walk 按目录顺序遍历所有条目, 包括目录本身, 根目录除外
+func (s *SquashFS) Walk(fn func(e *SquashFSEntry) error) error {
+	root, err := s.readInode(s.sb.RootInode)
+	if err != nil {
+		return err
+	}
+	if root.typ != squashfsDir && root.typ != squashfsExtDir {
+		return errors.New("squashfs root is not a directory")
+	}
+	return s.walkDir("/", root, fn, map[uint64]bool{s.sb.RootInode: true})
+}
+
+// walkDir 遍历目录, visited 记录已遍历的目录 inode, 目录没有硬链接, 同一目录出现两次说明镜像被构造出了环
+func (s *SquashFS) walkDir(dir string, inode *squashfsInode, fn func(e *SquashFSEntry) error, visited map[uint64]bool) error {
+	entries, err := s.readDir(inode)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		child, err := s.readInode(de.ref)
+		if err != nil {
+			return err
+		}
+		e := &SquashFSEntry{Path: path.Join(dir, de.name), Mode: os.FileMode(child.perm & 0777), fs: s, inode: child}
+		switch child.typ {
+		case squashfsDir, squashfsExtDir:
+			e.Mode |= os.ModeDir
+		case squashfsFile, squashfsExtFile:
+			e.Size = int64(child.fileSize)
+		case squashfsSymlink, squashfsExtSymlink:
+			e.Mode |= os.ModeSymlink
+			e.Link = child.target
+		case squashfsBlockDev, squashfsExtBlockDev:
+			e.Mode |= os.ModeDevice
+		case squashfsCharDev, squashfsExtCharDev:
+			e.Mode |= os.ModeDevice | os.ModeCharDevice
+		case squashfsFifo, squashfsExtFifo:
+			e.Mode |= os.ModeNamedPipe
+		case squashfsSocket, squashfsExtSocket:
+			e.Mode |= os.ModeSocket
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		if e.Mode.IsDir() {
+			if visited[de.ref] {
+				return fmt.Errorf("squashfs directory loop at %s", e.Path)
+			}
+			visited[de.ref] = true
+			if err := s.walkDir(e.Path, child, fn, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFile 读取镜像中的一个普通文件, 不跟随符号链接, 文件不存在时返回 os.ErrNotExist
+func (s *SquashFS) ReadFile(name string) ([]byte, error) {
+	inode, err := s.readInode(s.sb.RootInode)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if inode.typ != squashfsDir && inode.typ != squashfsExtDir {
+			return nil, os.ErrNotExist
+		}
+		entries, err := s.readDir(inode)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, de := range entries {
+			if de.name == part {
+				if inode, err = s.readInode(de.ref); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, os.ErrNotExist
+		}
+	}
+	if inode.typ != squashfsFile && inode.typ != squashfsExtFile {
+		return nil, os.ErrNotExist
+	}
+	e := &SquashFSEntry{fs: s, inode: inode}
+	return ioutil.ReadAll(e.Open())
+}
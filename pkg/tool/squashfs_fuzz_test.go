@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build go1.18
+// +build go1.18
+
+package tool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzSquashFS(f *testing.F) {
+	for _, name := range []string{"basic_gzip.sqfs", "basic_xz.sqfs", "basic_lz4.sqfs", "basic_ext.sqfs", "loop.sqfs"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "squashfs", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fs, err := OpenSquashFS(bytes.NewReader(data), 0)
+		if err != nil {
+			return
+		}
+		defer fs.Close()
+		fs.Walk(func(e *SquashFSEntry) error {
+			// 读取的内容不能超过条目声明的大小
+			n, err := io.Copy(ioutil.Discard, e.Open())
+			if err == nil && n != e.Size && e.Mode.IsRegular() {
+				t.Fatalf("%s: read %d bytes, size %d", e.Path, n, e.Size)
+			}
+			return nil
+		})
+		fs.ReadFile("dir/data.bin")
+	})
+}
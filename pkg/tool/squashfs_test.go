@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2024 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/squashfs 中的镜像由同一目录树生成:
+//
+//	/dir/data.bin  10000 字节, 两个完整数据块加分片
+//	/dir/sub/empty 空文件
+//	/hello.txt     "hello squashfs\n"
+//	/link          指向 hello.txt 的符号链接
+var squashfsTestTree = []string{
+	"/dir d",
+	"/dir/data.bin f 10000",
+	"/dir/sub d",
+	"/dir/sub/empty f 0",
+	"/hello.txt f 15",
+	"/link l hello.txt",
+}
+
+var squashfsTestData = strings.Repeat("0123456789abcdef", 625)
+
+func openTestSquashFS(t *testing.T, name string) *SquashFS {
+	f, err := os.Open(filepath.Join("testdata", "squashfs", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	fs, err := OpenSquashFS(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func TestSquashFSWalk(t *testing.T) {
+	for _, name := range []string{"basic_gzip.sqfs", "basic_xz.sqfs", "basic_lz4.sqfs", "basic_ext.sqfs"} {
+		t.Run(name, func(t *testing.T) {
+			fs := openTestSquashFS(t, name)
+			var got []string
+			contents := make(map[string]string)
+			err := fs.Walk(func(e *SquashFSEntry) error {
+				switch {
+				case e.Mode.IsDir():
+					got = append(got, e.Path+" d")
+				case e.Mode&os.ModeSymlink != 0:
+					got = append(got, e.Path+" l "+e.Link)
+				default:
+					got = append(got, fmt.Sprintf("%s f %d", e.Path, e.Size))
+					data, err := ioutil.ReadAll(e.Open())
+					if err != nil {
+						return err
+					}
+					contents[e.Path] = string(data)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, squashfsTestTree) {
+				t.Errorf("Walk() = %q, want %q", got, squashfsTestTree)
+			}
+			if contents["/dir/data.bin"] != squashfsTestData {
+				t.Errorf("/dir/data.bin content mismatch")
+			}
+			if contents["/hello.txt"] != "hello squashfs\n" {
+				t.Errorf("/hello.txt = %q", contents["/hello.txt"])
+			}
+
+			data, err := fs.ReadFile("dir/data.bin")
+			if err != nil || string(data) != squashfsTestData {
+				t.Errorf("ReadFile(dir/data.bin) = %d bytes, %v", len(data), err)
+			}
+			if _, err := fs.ReadFile("dir/missing"); !os.IsNotExist(err) {
+				t.Errorf("ReadFile(dir/missing) error = %v, want not exist", err)
+			}
+			if _, err := fs.ReadFile("hello.txt/x"); !os.IsNotExist(err) {
+				t.Errorf("ReadFile(hello.txt/x) error = %v, want not exist", err)
+			}
+		})
+	}
+}
+
+// 损坏的镜像应返回错误, 不能死循环或按声明的大小分配内存
+func TestSquashFSCorrupt(t *testing.T) {
+	t.Run("directory loop", func(t *testing.T) {
+		fs := openTestSquashFS(t, "loop.sqfs")
+		err := fs.Walk(func(e *SquashFSEntry) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "loop") {
+			t.Errorf("Walk() error = %v, want directory loop", err)
+		}
+	})
+	t.Run("huge file", func(t *testing.T) {
+		fs := openTestSquashFS(t, "huge.sqfs")
+		err := fs.Walk(func(e *SquashFSEntry) error {
+			_, err := ioutil.ReadAll(e.Open())
+			return err
+		})
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+	t.Run("truncated", func(t *testing.T) {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "squashfs", "basic_gzip.sqfs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := int(openTestSquashFS(t, "basic_gzip.sqfs").Size())
+		for _, n := range []int{0, 50, 96, used / 4, used / 2} {
+			fs, err := OpenSquashFS(strings.NewReader(string(data[:n])), 0)
+			if err != nil {
+				continue
+			}
+			err = fs.Walk(func(e *SquashFSEntry) error {
+				_, err := ioutil.ReadAll(e.Open())
+				return err
+			})
+			if err == nil {
+				t.Errorf("Walk() on %d of %d bytes succeeded", n, used)
+			}
+		}
+	})
+}